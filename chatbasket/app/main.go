@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,17 +26,23 @@ func main() {
 	e.Use(middleware.Recover())
 	e.Use(middleware.RequestID())
 	e.Use(middleware.Secure())
-	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{Level: 5}))
-	e.Use(middleware.BodyLimit("10M"))
+	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
+		Level: 5,
+		// Images are already compressed; gzip only costs CPU and breaks Content-Length
+		Skipper: func(c echo.Context) bool {
+			return strings.HasPrefix(c.Request().URL.Path, "/media/")
+		},
+	}))
 
 	e.Use(middleware.Logger())
 
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		// AllowOrigins: []string{"http://localhost:8081"},
 		AllowOrigins: []string{"https://chatbasket.me"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
+		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
 		// AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "x-api-key"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "x-api-key", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"},
+		ExposeHeaders:    []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires"},
 		AllowCredentials: true,
 	}))
 
@@ -72,7 +79,11 @@ func main() {
 		return c.JSON(http.StatusOK, &model.StatusOkay{Status: true, Message: "ok"})
 	})
 
-	routes.RegisterRoutes(e, pool)
+	// Background workers run until shutdown begins
+	workerCtx, workerCancel := context.WithCancel(context.Background())
+	defer workerCancel()
+
+	routes.RegisterRoutes(workerCtx, e, pool)
 
 	e.GET("/", hello)
 	port := os.Getenv("PORT")
@@ -98,6 +109,7 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	e.Logger.Info("Received shutdown signal - starting graceful shutdown...")
+	workerCancel()
 
 	// Heroku allows 30 seconds total for graceful shutdown
 	// Allocate 15s for server shutdown, 5s for DB cleanup, 10s buffer
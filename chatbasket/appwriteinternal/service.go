@@ -1,6 +1,9 @@
 package appwriteinternal
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/appwrite/sdk-for-go/account"
 	"github.com/appwrite/sdk-for-go/appwrite"
 	"github.com/appwrite/sdk-for-go/databases"
@@ -35,6 +38,13 @@ type AppwriteService struct {
 	PersonalDatabaseID        		string
 	PersonalProfilePicBucketID    	string
 	PersonalUsernameKey       	  []byte
+	PersonalFieldKey          	  []byte
+	AvatarURLSigningKey       	  []byte
+	PublicApiBaseURL          		string
+	Endpoint                  		string
+	ProjectID                 		string
+	apiKey                    		string
+	httpClient                		*http.Client
 }
 
 func NewAppwriteService(
@@ -56,7 +66,10 @@ func NewAppwriteService(
 	aloneUsernameCollectionID,
 	personalDatabaseID,
 	personalProfilePicBucketID string,
-	personalUsernameKey []byte) *AppwriteService {
+	personalUsernameKey,
+	personalFieldKey,
+	avatarURLSigningKey []byte,
+	publicApiBaseURL string) *AppwriteService {
 
 	c := appwrite.NewClient(
 		appwrite.WithEndpoint(endpoint),
@@ -87,6 +100,13 @@ func NewAppwriteService(
 		AloneUsernameCollectionID: aloneUsernameCollectionID,
 		PersonalDatabaseID:        personalDatabaseID,
 		PersonalUsernameKey:       personalUsernameKey,
+		PersonalFieldKey:          personalFieldKey,
+		AvatarURLSigningKey:       avatarURLSigningKey,
+		PublicApiBaseURL:          publicApiBaseURL,
+		Endpoint:                  endpoint,
+		ProjectID:                 projectID,
+		apiKey:                    apiKey,
+		httpClient:                &http.Client{Timeout: 30 * time.Second},
 		PersonalProfilePicBucketID: personalProfilePicBucketID,
 	}
 }
@@ -0,0 +1,52 @@
+package appwriteinternal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpenFileView opens a streaming GET to the Appwrite file view endpoint.
+// The SDK's GetFileView buffers the whole file in memory; this returns the raw response
+// so callers can copy the body straight to the client. When tokenSecret is set the file
+// token is used, otherwise the request authenticates with the server API key.
+// The caller must close the response body. Non-2xx responses are returned as errors.
+func (as *AppwriteService) OpenFileView(ctx context.Context, bucketID, fileID, tokenSecret string) (*http.Response, error) {
+	q := url.Values{}
+	q.Set("project", as.ProjectID)
+	if tokenSecret != "" {
+		q.Set("token", tokenSecret)
+	}
+	u := fmt.Sprintf("%s/storage/buckets/%s/files/%s/view?%s",
+		strings.TrimRight(as.Endpoint, "/"), url.PathEscape(bucketID), url.PathEscape(fileID), q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Appwrite-Project", as.ProjectID)
+	if tokenSecret == "" {
+		req.Header.Set("X-Appwrite-Key", as.apiKey)
+	}
+
+	resp, err := as.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &FileViewError{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// FileViewError is returned by OpenFileView when Appwrite answers with a non-2xx status.
+type FileViewError struct {
+	StatusCode int
+}
+
+func (e *FileViewError) Error() string {
+	return fmt.Sprintf("appwrite file view failed with status %d", e.StatusCode)
+}
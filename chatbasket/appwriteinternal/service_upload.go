@@ -0,0 +1,129 @@
+package appwriteinternal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/appwrite/sdk-for-go/models"
+)
+
+// UploadChunkSize is the largest body Appwrite accepts in one upload request. Larger files
+// are sent as consecutive chunks with a Content-Range header.
+const UploadChunkSize = 5 << 20
+
+// ErrUploadSizeRequired is returned by CreateFileStream for bodies of unknown size that do not
+// fit in one chunk: every chunk request must state the total size.
+var ErrUploadSizeRequired = errors.New("appwrite upload: size is required for files larger than one chunk")
+
+// CreateFileStream uploads body to a bucket without staging it on disk. The SDK's CreateFile
+// only reads from a file path; this streams small files in a single request and holds at most
+// one UploadChunkSize chunk in memory for larger ones. size may be -1 when unknown.
+func (as *AppwriteService) CreateFileStream(ctx context.Context, bucketID, fileID, name string, body io.Reader, size int64) (*models.File, error) {
+	if size < 0 || size > UploadChunkSize {
+		// Read one chunk (plus a byte) to learn whether the body fits in a single request
+		buf := make([]byte, UploadChunkSize+1)
+		n, err := io.ReadFull(body, buf)
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return as.uploadChunk(ctx, bucketID, fileID, name, bytes.NewReader(buf[:n]), "")
+		case err != nil:
+			return nil, err
+		case size < 0:
+			return nil, ErrUploadSizeRequired
+		}
+		return as.uploadChunks(ctx, bucketID, fileID, name, io.MultiReader(bytes.NewReader(buf[:n]), body), size)
+	}
+	return as.uploadChunk(ctx, bucketID, fileID, name, body, "")
+}
+
+// uploadChunks sends a body of known size as UploadChunkSize chunks.
+func (as *AppwriteService) uploadChunks(ctx context.Context, bucketID, fileID, name string, body io.Reader, size int64) (*models.File, error) {
+	buf := make([]byte, UploadChunkSize)
+	var res *models.File
+	for offset := int64(0); offset < size; {
+		n, err := io.ReadFull(body, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 || (n < len(buf) && offset+int64(n) != size) {
+			return nil, fmt.Errorf("appwrite upload: body ended at %d of %d bytes", offset+int64(n), size)
+		}
+		contentRange := fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(n)-1, size)
+		if res, err = as.uploadChunk(ctx, bucketID, fileID, name, bytes.NewReader(buf[:n]), contentRange); err != nil {
+			return nil, err
+		}
+		offset += int64(n)
+	}
+	return res, nil
+}
+
+// uploadChunk posts one multipart request. The form is streamed through a pipe so the
+// chunk is never copied into a second buffer.
+func (as *AppwriteService) uploadChunk(ctx context.Context, bucketID, fileID, name string, chunk io.Reader, contentRange string) (*models.File, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := mw.WriteField("fileId", fileID)
+		if err == nil {
+			var part io.Writer
+			if part, err = mw.CreateFormFile("file", name); err == nil {
+				if _, err = io.Copy(part, chunk); err == nil {
+					err = mw.Close()
+				}
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	u := fmt.Sprintf("%s/storage/buckets/%s/files", strings.TrimRight(as.Endpoint, "/"), url.PathEscape(bucketID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Appwrite-Project", as.ProjectID)
+	req.Header.Set("X-Appwrite-Key", as.apiKey)
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+		req.Header.Set("X-Appwrite-ID", fileID)
+	}
+
+	resp, err := as.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+		return nil, &FileUploadError{StatusCode: resp.StatusCode, Message: body.Message}
+	}
+
+	var file models.File
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FileUploadError is returned by CreateFileStream when Appwrite answers with a non-2xx status.
+type FileUploadError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *FileUploadError) Error() string {
+	return fmt.Sprintf("appwrite file upload failed with status %d: %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,24 @@
+package audit
+
+import "context"
+
+// Request identifies the HTTP request an action came from.
+type Request struct {
+	Id        string // X-Request-Id set by echo's RequestID middleware
+	IP        string
+	UserAgent string
+}
+
+type requestKey struct{}
+
+// WithRequest stores the request details in ctx for events recorded while handling it.
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFrom returns the details stored by WithRequest; they are empty outside a request
+// (e.g. in workers).
+func RequestFrom(ctx context.Context) Request {
+	req, _ := ctx.Value(requestKey{}).(Request)
+	return req
+}
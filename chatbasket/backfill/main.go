@@ -0,0 +1,218 @@
+package main
+
+import (
+	"chatbasket/db"
+	"chatbasket/db/postgresCode"
+	"chatbasket/utils"
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// backfill runs one-off data migrations that cannot be expressed in SQL
+// because they need application keys (e.g. re-encrypting stored ciphertexts).
+//
+// Usage (from the chatbasket/ directory):
+//
+//	go run ./backfill usernames
+//	go run ./backfill fields
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("usage: backfill <usernames|fields>")
+	}
+
+	cfg, err := db.LoadPostgresConfig()
+	if err != nil {
+		log.Fatal("failed to load postgres config: " + err.Error())
+	}
+	startupCtx, startupCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	pool, err := db.NewPool(startupCtx, cfg)
+	startupCancel()
+	if err != nil {
+		log.Fatal("failed to connect to postgres: " + err.Error())
+	}
+	defer pool.Close()
+
+	queries := postgresCode.New(pool)
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "usernames":
+		key, err := utils.LoadKeyFromEnvInByte("PERSONAL_USERNAME_KEY")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := backfillUsernames(ctx, queries, key); err != nil {
+			log.Fatal(err)
+		}
+	case "fields":
+		key, err := utils.LoadKeyFromEnvInByte("PERSONAL_FIELD_KEY")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := backfillFields(ctx, queries, key); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown backfill %q", os.Args[1])
+	}
+}
+
+// backfillUsernames re-encrypts every v1 username ciphertext with the XChaCha20-Poly1305 v2 format.
+// It is safe to re-run: rows already in v2 are skipped and each update is a compare-and-swap.
+func backfillUsernames(ctx context.Context, queries *postgresCode.Queries, key []byte) error {
+	const batchSize = 500
+	var (
+		after    uuid.UUID
+		migrated int
+		skipped  int
+	)
+
+	for {
+		rows, err := queries.ListUsersWithLegacyUsernameCipher(ctx, postgresCode.ListUsersWithLegacyUsernameCipherParams{
+			ID:    after,
+			Limit: batchSize,
+		})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			after = row.ID
+
+			username, err := utils.DecryptUsername(row.B64CipherChacha20poly1305Username, key, row.ID.String())
+			if err != nil {
+				log.Printf("user %s: decrypt failed: %v", row.ID, err)
+				skipped++
+				continue
+			}
+			cipher, err := utils.EncryptUsername(username, key, row.ID.String())
+			if err != nil {
+				return err
+			}
+
+			updated, err := queries.UpdateUserUsernameCipher(ctx, postgresCode.UpdateUserUsernameCipherParams{
+				ID:        row.ID,
+				NewCipher: cipher,
+				OldCipher: row.B64CipherChacha20poly1305Username,
+			})
+			if err != nil {
+				return err
+			}
+			if updated == 0 {
+				// Row changed concurrently; it was written by the app in the new format.
+				skipped++
+				continue
+			}
+			migrated++
+		}
+	}
+
+	log.Printf("usernames: migrated=%d skipped=%d", migrated, skipped)
+	return nil
+}
+
+// backfillFields encrypts legacy plaintext contact nicknames, contact request nicknames and bios.
+// Each list query only returns rows without the field cipher prefix, so batches shrink as rows are
+// migrated; a batch that makes no progress (all rows changed concurrently) ends the run.
+func backfillFields(ctx context.Context, queries *postgresCode.Queries, key []byte) error {
+	const batchSize = 500
+
+	migrated := 0
+	for {
+		rows, err := queries.ListPlaintextContactNicknames(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		progress := 0
+		for _, row := range rows {
+			// Contact nicknames belong to the contact owner
+			sealed, err := utils.EncryptOptionalField(row.Nickname, key, utils.FieldPurposeNickname, row.OwnerUserID.String())
+			if err != nil {
+				return err
+			}
+			updated, err := queries.UpdateContactNicknameCipher(ctx, postgresCode.UpdateContactNicknameCipherParams{
+				OwnerUserID:   row.OwnerUserID,
+				ContactUserID: row.ContactUserID,
+				NewNickname:   sealed,
+				OldNickname:   row.Nickname,
+			})
+			if err != nil {
+				return err
+			}
+			progress += int(updated)
+		}
+		migrated += progress
+		if len(rows) < batchSize || progress == 0 {
+			break
+		}
+	}
+	log.Printf("user_contacts.nickname: migrated=%d", migrated)
+
+	migrated = 0
+	for {
+		rows, err := queries.ListPlaintextContactRequestNicknames(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		progress := 0
+		for _, row := range rows {
+			// Request nicknames belong to the requester, who becomes the contact owner on accept
+			sealed, err := utils.EncryptOptionalField(row.Nickname, key, utils.FieldPurposeNickname, row.RequesterUserID.String())
+			if err != nil {
+				return err
+			}
+			updated, err := queries.UpdateContactRequestNicknameCipher(ctx, postgresCode.UpdateContactRequestNicknameCipherParams{
+				ID:          row.ID,
+				NewNickname: sealed,
+				OldNickname: row.Nickname,
+			})
+			if err != nil {
+				return err
+			}
+			progress += int(updated)
+		}
+		migrated += progress
+		if len(rows) < batchSize || progress == 0 {
+			break
+		}
+	}
+	log.Printf("contact_requests.nickname: migrated=%d", migrated)
+
+	migrated = 0
+	for {
+		rows, err := queries.ListUsersWithPlaintextBio(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		progress := 0
+		for _, row := range rows {
+			sealed, err := utils.EncryptOptionalField(row.Bio, key, utils.FieldPurposeBio, row.ID.String())
+			if err != nil {
+				return err
+			}
+			updated, err := queries.UpdateUserBioCipher(ctx, postgresCode.UpdateUserBioCipherParams{
+				ID:     row.ID,
+				NewBio: sealed,
+				OldBio: row.Bio,
+			})
+			if err != nil {
+				return err
+			}
+			progress += int(updated)
+		}
+		migrated += progress
+		if len(rows) < batchSize || progress == 0 {
+			break
+		}
+	}
+	log.Printf("users.bio: migrated=%d", migrated)
+
+	return nil
+}
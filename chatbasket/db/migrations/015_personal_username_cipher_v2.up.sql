@@ -0,0 +1,24 @@
+-- +migrate Up
+
+-- ======================================
+-- Widen users.b64_cipher_chacha20poly1305_username for XChaCha20-Poly1305 ciphertexts
+--        v1: base64(12-byte nonce + 10-byte username + 16-byte tag)          = 52 chars
+--        v2: "v2." + base64(24-byte nonce + 10-byte username + 16-byte tag)  = 71 chars
+-- Both formats are accepted until every row has been re-encrypted
+-- (go run ./backfill usernames).
+-- ======================================
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_b64_cipher_chacha20poly1305_username_check;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_b64_cipher_chacha20poly1305_username_check
+    CHECK (length(b64_cipher_chacha20poly1305_username) <= 71);
+
+-- Partial index to find rows still using the legacy v1 cipher during the backfill
+CREATE INDEX IF NOT EXISTS idx_users_legacy_username_cipher
+    ON users(id)
+    WHERE b64_cipher_chacha20poly1305_username NOT LIKE 'v2.%';
+
+-- ======================================
+-- End of username cipher v2 section
+-- ======================================
@@ -0,0 +1,12 @@
+-- +migrate Down
+
+-- Drop legacy cipher lookup index
+DROP INDEX IF EXISTS idx_users_legacy_username_cipher;             -- Legacy v1 cipher backfill index
+
+-- Restore the original 52-char limit. NOT VALID keeps already re-encrypted v2 rows readable;
+-- new writes must use the v1 format again once this runs.
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_b64_cipher_chacha20poly1305_username_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_b64_cipher_chacha20poly1305_username_check
+    CHECK (length(b64_cipher_chacha20poly1305_username) <= 52) NOT VALID;
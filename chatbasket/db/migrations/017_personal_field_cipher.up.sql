@@ -0,0 +1,31 @@
+-- +migrate Up
+
+-- ======================================
+-- Widen free-text columns for field-level XChaCha20-Poly1305 ciphertexts
+--        stored as "enc1." + base64(24-byte nonce + plaintext + 16-byte tag)
+--        nickname: 40 chars  (<= 160 bytes UTF-8) -> at most 273 chars
+--        bio:      150 chars (<= 600 bytes UTF-8) -> at most 861 chars
+-- Plaintext length limits are enforced in the API layer.
+-- Existing plaintext rows are encrypted by: go run ./backfill fields
+-- ======================================
+ALTER TABLE user_contacts
+    DROP CONSTRAINT IF EXISTS user_contacts_nickname_check;
+ALTER TABLE user_contacts
+    ADD CONSTRAINT user_contacts_nickname_check
+    CHECK (length(nickname) <= 280);
+
+ALTER TABLE contact_requests
+    DROP CONSTRAINT IF EXISTS contact_requests_nickname_check;
+ALTER TABLE contact_requests
+    ADD CONSTRAINT contact_requests_nickname_check
+    CHECK (length(nickname) <= 280);
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_bio_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_bio_check
+    CHECK (length(bio) <= 900);
+
+-- ======================================
+-- End of field cipher section
+-- ======================================
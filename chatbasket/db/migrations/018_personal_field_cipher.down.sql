@@ -0,0 +1,21 @@
+-- +migrate Down
+
+-- Restore the original plaintext limits. NOT VALID keeps already encrypted rows in place;
+-- they must be decrypted before the constraints can be validated again.
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_bio_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_bio_check
+    CHECK (length(bio) <= 150) NOT VALID;
+
+ALTER TABLE contact_requests
+    DROP CONSTRAINT IF EXISTS contact_requests_nickname_check;
+ALTER TABLE contact_requests
+    ADD CONSTRAINT contact_requests_nickname_check
+    CHECK (length(nickname) <= 40) NOT VALID;
+
+ALTER TABLE user_contacts
+    DROP CONSTRAINT IF EXISTS user_contacts_nickname_check;
+ALTER TABLE user_contacts
+    ADD CONSTRAINT user_contacts_nickname_check
+    CHECK (length(nickname) <= 40) NOT VALID;
@@ -0,0 +1,59 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: storage_objects
+--        One row per stored file, used for per-user quota accounting.
+--        owner_id has no FK: public-mode users live in Appwrite, not in users.
+--        Objects stored before this table existed are not counted until replaced.
+-- ======================================
+CREATE TABLE IF NOT EXISTS storage_objects (
+    bucket_id   TEXT        NOT NULL,
+    file_id     TEXT        NOT NULL,
+    owner_id    UUID        NOT NULL,
+    size_bytes  BIGINT      NOT NULL CHECK (size_bytes >= 0),
+    kind        TEXT        NOT NULL CHECK (kind IN ('avatar', 'attachment')),
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    PRIMARY KEY (bucket_id, file_id)  -- Direct index via PK
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS storage_objects_timestamps_trigger ON storage_objects;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER storage_objects_timestamps_trigger
+BEFORE INSERT OR UPDATE ON storage_objects
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: per-user usage breakdown
+CREATE INDEX IF NOT EXISTS idx_storage_objects_owner_kind
+    ON storage_objects(owner_id, kind);
+
+-- ======================================
+-- Table: storage_usage
+--        Running per-user totals kept in step with storage_objects,
+--        and the plan that decides the user's limit. used_bytes also
+--        holds the bytes reserved by uploads still in progress.
+-- ======================================
+CREATE TABLE IF NOT EXISTS storage_usage (
+    owner_id      UUID        PRIMARY KEY,  -- Direct index via PK
+    plan          TEXT        NOT NULL DEFAULT 'free' CHECK (plan IN ('free', 'plus')),
+    used_bytes    BIGINT      NOT NULL DEFAULT 0 CHECK (used_bytes >= 0),
+    object_count  INTEGER     NOT NULL DEFAULT 0 CHECK (object_count >= 0),
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS storage_usage_timestamps_trigger ON storage_usage;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER storage_usage_timestamps_trigger
+BEFORE INSERT OR UPDATE ON storage_usage
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- ======================================
+-- End of storage quota section
+-- ======================================
@@ -0,0 +1,10 @@
+-- +migrate Down
+
+-- Drop storage usage totals
+DROP TRIGGER IF EXISTS storage_usage_timestamps_trigger ON storage_usage;       -- Timestamp trigger
+DROP TABLE IF EXISTS storage_usage CASCADE;                                      -- Also drops PK
+
+-- Drop storage objects
+DROP INDEX IF EXISTS idx_storage_objects_owner_kind;                             -- Usage breakdown index
+DROP TRIGGER IF EXISTS storage_objects_timestamps_trigger ON storage_objects;   -- Timestamp trigger
+DROP TABLE IF EXISTS storage_objects CASCADE;                                    -- Also drops PK
@@ -0,0 +1,39 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: account_deletion_jobs
+--        One row per account being deleted. The job walks a fixed list of
+--        idempotent steps; step is the next one to run, so a failed step is
+--        retried from where it stopped.
+--        user_id has no FK: the job deletes the users row itself, and
+--        public-mode users never had one.
+-- ======================================
+CREATE TABLE IF NOT EXISTS account_deletion_jobs (
+    user_id          UUID        PRIMARY KEY,  -- Direct index via PK
+    step             TEXT        NOT NULL,
+    status           TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'done')),
+    attempts         INTEGER     NOT NULL DEFAULT 0,
+    last_error       TEXT,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at     TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS account_deletion_jobs_timestamps_trigger ON account_deletion_jobs;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER account_deletion_jobs_timestamps_trigger
+BEFORE INSERT OR UPDATE ON account_deletion_jobs
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: due jobs for the deletion worker
+CREATE INDEX IF NOT EXISTS idx_account_deletion_jobs_due
+    ON account_deletion_jobs(next_attempt_at)
+    WHERE status = 'pending';
+
+-- ======================================
+-- End of account deletion section
+-- ======================================
@@ -0,0 +1,6 @@
+-- +migrate Down
+
+-- Drop account deletion jobs
+DROP INDEX IF EXISTS idx_account_deletion_jobs_due;                                      -- Due jobs index
+DROP TRIGGER IF EXISTS account_deletion_jobs_timestamps_trigger ON account_deletion_jobs; -- Timestamp trigger
+DROP TABLE IF EXISTS account_deletion_jobs CASCADE;                                      -- Also drops PK
@@ -0,0 +1,51 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: data_exports
+--        Personal data exports (takeout). A worker builds the ZIP for pending
+--        rows and stores it under file_id until expires_at; the download link
+--        works once, after which the file is deleted.
+-- ======================================
+CREATE TABLE IF NOT EXISTS data_exports (
+    id               UUID        PRIMARY KEY,  -- Direct index via PK
+    user_id          UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status           TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'downloaded', 'expired', 'failed')),
+    file_id          TEXT,
+    size_bytes       BIGINT,
+    attempts         INTEGER     NOT NULL DEFAULT 0,
+    last_error       TEXT,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at       TIMESTAMPTZ,
+    downloaded_at    TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS data_exports_timestamps_trigger ON data_exports;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER data_exports_timestamps_trigger
+BEFORE INSERT OR UPDATE ON data_exports
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: at most one export in flight per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_active
+    ON data_exports(user_id)
+    WHERE status IN ('pending', 'ready');
+-- Index: user's exports by recency
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_created
+    ON data_exports(user_id, created_at DESC);
+-- Index: due jobs for the export worker
+CREATE INDEX IF NOT EXISTS idx_data_exports_due
+    ON data_exports(next_attempt_at)
+    WHERE status = 'pending';
+-- Index: stored files waiting to be purged
+CREATE INDEX IF NOT EXISTS idx_data_exports_files
+    ON data_exports(expires_at)
+    WHERE file_id IS NOT NULL;
+
+-- ======================================
+-- End of data exports section
+-- ======================================
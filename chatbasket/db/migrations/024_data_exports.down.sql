@@ -0,0 +1,9 @@
+-- +migrate Down
+
+-- Drop data exports
+DROP INDEX IF EXISTS idx_data_exports_files;                         -- Stored files index
+DROP INDEX IF EXISTS idx_data_exports_due;                           -- Due jobs index
+DROP INDEX IF EXISTS idx_data_exports_user_created;                  -- User recency index
+DROP INDEX IF EXISTS idx_data_exports_user_active;                   -- One active export per user
+DROP TRIGGER IF EXISTS data_exports_timestamps_trigger ON data_exports; -- Timestamp trigger
+DROP TABLE IF EXISTS data_exports CASCADE;                           -- Also drops PK
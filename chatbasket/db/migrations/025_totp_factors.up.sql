@@ -0,0 +1,61 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: totp_factors
+--        RFC 6238 second factor, one per user. The row is created on
+--        enrolment and only enforced at login once enabled_at is set.
+--        secret is sealed with the field key (purpose "totp_secret").
+--        last_used_step is the last accepted 30s time step, so a code
+--        cannot be replayed inside its validity window.
+--        user_id has no FK: login is shared by public-mode users, who
+--        have no users row.
+-- ======================================
+CREATE TABLE IF NOT EXISTS totp_factors (
+    user_id         UUID        PRIMARY KEY,  -- Direct index via PK
+    secret          TEXT        NOT NULL,
+    enabled_at      TIMESTAMPTZ,
+    last_used_step  BIGINT      NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS totp_factors_timestamps_trigger ON totp_factors;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER totp_factors_timestamps_trigger
+BEFORE INSERT OR UPDATE ON totp_factors
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- ======================================
+-- Table: totp_recovery_codes
+--        Single-use codes that stand in for a TOTP code when the
+--        authenticator is lost. Only argon2id hashes are stored.
+-- ======================================
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+    id          UUID        PRIMARY KEY,
+    user_id     UUID        NOT NULL REFERENCES totp_factors (user_id) ON DELETE CASCADE,
+    code_hash   TEXT        NOT NULL,
+    used_at     TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS totp_recovery_codes_timestamps_trigger ON totp_recovery_codes;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER totp_recovery_codes_timestamps_trigger
+BEFORE INSERT OR UPDATE ON totp_recovery_codes
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: a user's unused codes, checked at login
+CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_user_unused
+    ON totp_recovery_codes(user_id)
+    WHERE used_at IS NULL;
+
+-- ======================================
+-- End of TOTP section
+-- ======================================
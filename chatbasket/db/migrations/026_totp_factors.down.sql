@@ -0,0 +1,10 @@
+-- +migrate Down
+
+-- Drop recovery codes first (FK to totp_factors)
+DROP INDEX IF EXISTS idx_totp_recovery_codes_user_unused;                                  -- Unused codes index
+DROP TRIGGER IF EXISTS totp_recovery_codes_timestamps_trigger ON totp_recovery_codes;     -- Timestamp trigger
+DROP TABLE IF EXISTS totp_recovery_codes CASCADE;                                          -- Also drops PK
+
+-- Drop TOTP factors
+DROP TRIGGER IF EXISTS totp_factors_timestamps_trigger ON totp_factors;                   -- Timestamp trigger
+DROP TABLE IF EXISTS totp_factors CASCADE;                                                 -- Also drops PK
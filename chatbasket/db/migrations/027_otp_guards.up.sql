@@ -0,0 +1,36 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: otp_guards
+--        Per-user brute-force and resend limits for emailed OTPs.
+--        failed_attempts counts guesses against the OTP sent last and
+--        is reset by every new send; reaching the limit invalidates the
+--        OTP and locks the user out for a period that doubles with each
+--        lockout (lockouts) until a code is verified.
+--        sends_in_window / window_started_at throttle resends.
+--        user_id has no FK: OTPs are sent before a users row exists.
+-- ======================================
+CREATE TABLE IF NOT EXISTS otp_guards (
+    user_id            UUID        PRIMARY KEY,  -- Direct index via PK
+    failed_attempts    INTEGER     NOT NULL DEFAULT 0,
+    lockouts           INTEGER     NOT NULL DEFAULT 0,
+    locked_until       TIMESTAMPTZ,
+    last_sent_at       TIMESTAMPTZ,
+    sends_in_window    INTEGER     NOT NULL DEFAULT 0,
+    window_started_at  TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ,
+    updated_at         TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS otp_guards_timestamps_trigger ON otp_guards;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER otp_guards_timestamps_trigger
+BEFORE INSERT OR UPDATE ON otp_guards
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- ======================================
+-- End of OTP guards section
+-- ======================================
@@ -0,0 +1,5 @@
+-- +migrate Down
+
+-- Drop OTP guards
+DROP TRIGGER IF EXISTS otp_guards_timestamps_trigger ON otp_guards; -- Timestamp trigger
+DROP TABLE IF EXISTS otp_guards CASCADE;                            -- Also drops PK
@@ -0,0 +1,48 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: otp_challenges
+--        Emailed one-time codes, replacing the Appwrite temp OTP
+--        collection. One live challenge per user and purpose; a new
+--        send replaces it. target is the address the code went to.
+--        attempts counts guesses against this code (see otp_guards for
+--        the lockout that follows). Expired and consumed rows are
+--        removed by workers.OtpChallengeSweeper.
+--        user_id has no FK: codes are sent before a users row exists.
+-- ======================================
+CREATE TABLE IF NOT EXISTS otp_challenges (
+    id           UUID        PRIMARY KEY,
+    user_id      UUID        NOT NULL,
+    purpose      TEXT        NOT NULL,
+    target       TEXT        NOT NULL,
+    code_hash    TEXT        NOT NULL,
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    consumed_at  TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS otp_challenges_timestamps_trigger ON otp_challenges;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER otp_challenges_timestamps_trigger
+BEFORE INSERT OR UPDATE ON otp_challenges
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: one live challenge per user and purpose
+CREATE UNIQUE INDEX IF NOT EXISTS idx_otp_challenges_user_purpose
+    ON otp_challenges(user_id, purpose);
+
+-- Index: expired challenges for the sweeper
+CREATE INDEX IF NOT EXISTS idx_otp_challenges_expires
+    ON otp_challenges(expires_at);
+
+-- Attempts are now counted per challenge
+ALTER TABLE otp_guards DROP COLUMN IF EXISTS failed_attempts;
+
+-- ======================================
+-- End of OTP challenges section
+-- ======================================
@@ -0,0 +1,10 @@
+-- +migrate Down
+
+-- Restore per-user attempt counting
+ALTER TABLE otp_guards ADD COLUMN IF NOT EXISTS failed_attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Drop OTP challenges
+DROP INDEX IF EXISTS idx_otp_challenges_expires;                                  -- Sweeper index
+DROP INDEX IF EXISTS idx_otp_challenges_user_purpose;                             -- User/purpose index
+DROP TRIGGER IF EXISTS otp_challenges_timestamps_trigger ON otp_challenges;       -- Timestamp trigger
+DROP TABLE IF EXISTS otp_challenges CASCADE;                                      -- Also drops PK
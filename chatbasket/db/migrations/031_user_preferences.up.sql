@@ -0,0 +1,28 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: user_preferences
+--        Per-account settings that are not part of a profile, such
+--        as the language of emails. Keyed by the Appwrite user id
+--        with no FK: the preference applies to public-mode accounts
+--        that have no users row too.
+-- ======================================
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id     UUID        PRIMARY KEY,
+    locale      TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS user_preferences_timestamps_trigger ON user_preferences;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER user_preferences_timestamps_trigger
+BEFORE INSERT OR UPDATE ON user_preferences
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- ======================================
+-- End of user preferences section
+-- ======================================
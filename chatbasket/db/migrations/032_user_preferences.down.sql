@@ -0,0 +1,5 @@
+-- +migrate Down
+
+-- Drop user preferences
+DROP TRIGGER IF EXISTS user_preferences_timestamps_trigger ON user_preferences;   -- Timestamp trigger
+DROP TABLE IF EXISTS user_preferences CASCADE;                                    -- Also drops PK
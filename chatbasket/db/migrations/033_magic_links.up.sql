@@ -0,0 +1,44 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: magic_links
+--        Single-use passwordless login links. The emailed token
+--        carries the id and an HMAC; nonce_hash is the SHA-256 hex
+--        of the nonce held by the device that asked for the link,
+--        which has to be presented with the token. attempts counts
+--        wrong second factors tried with the link. A new link
+--        replaces the user's pending ones; stale rows are removed by
+--        workers.OtpChallengeSweeper.
+-- ======================================
+CREATE TABLE IF NOT EXISTS magic_links (
+    id           UUID        PRIMARY KEY,
+    user_id      UUID        NOT NULL,
+    email        TEXT        NOT NULL,
+    nonce_hash   TEXT        NOT NULL,
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    consumed_at  TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS magic_links_timestamps_trigger ON magic_links;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER magic_links_timestamps_trigger
+BEFORE INSERT OR UPDATE ON magic_links
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: a user's pending links
+CREATE INDEX IF NOT EXISTS idx_magic_links_user
+    ON magic_links(user_id);
+
+-- Index: expired links for the sweeper
+CREATE INDEX IF NOT EXISTS idx_magic_links_expires
+    ON magic_links(expires_at);
+
+-- ======================================
+-- End of magic links section
+-- ======================================
@@ -0,0 +1,7 @@
+-- +migrate Down
+
+-- Drop magic links
+DROP INDEX IF EXISTS idx_magic_links_expires;                                     -- Sweeper index
+DROP INDEX IF EXISTS idx_magic_links_user;                                        -- User index
+DROP TRIGGER IF EXISTS magic_links_timestamps_trigger ON magic_links;             -- Timestamp trigger
+DROP TABLE IF EXISTS magic_links CASCADE;                                         -- Also drops PK
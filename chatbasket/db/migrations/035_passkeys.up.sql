@@ -0,0 +1,78 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: passkey_credentials
+--        WebAuthn credentials registered by a user. credential_id
+--        is the authenticator's id and identifies the credential in
+--        assertions. sign_count is the last signature counter seen;
+--        an assertion has to move it forward (or leave both at 0 for
+--        authenticators without a counter). flags holds the raw
+--        authenticator flags from registration.
+-- ======================================
+CREATE TABLE IF NOT EXISTS passkey_credentials (
+    id                UUID        PRIMARY KEY,
+    user_id           UUID        NOT NULL,
+    credential_id     BYTEA       NOT NULL,
+    public_key        BYTEA       NOT NULL,
+    attestation_type  TEXT        NOT NULL,
+    transports        TEXT[]      NOT NULL DEFAULT '{}',
+    aaguid            BYTEA,
+    sign_count        BIGINT      NOT NULL DEFAULT 0,
+    flags             SMALLINT    NOT NULL DEFAULT 0,
+    name              TEXT        NOT NULL,
+    last_used_at      TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS passkey_credentials_timestamps_trigger ON passkey_credentials;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER passkey_credentials_timestamps_trigger
+BEFORE INSERT OR UPDATE ON passkey_credentials
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: credential lookup during login
+CREATE UNIQUE INDEX IF NOT EXISTS idx_passkey_credentials_credential_id
+    ON passkey_credentials(credential_id);
+
+-- Index: a user's passkeys
+CREATE INDEX IF NOT EXISTS idx_passkey_credentials_user
+    ON passkey_credentials(user_id);
+
+-- ======================================
+-- Table: webauthn_challenges
+--        Pending registration and login ceremonies. session_data is
+--        the webauthn.SessionData handed back when the ceremony is
+--        finished. user_id is NULL for passkey (discoverable) login,
+--        where the user is only known from the assertion. A
+--        challenge is deleted when it is used.
+-- ======================================
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    id            UUID        PRIMARY KEY,
+    user_id       UUID,
+    ceremony      TEXT        NOT NULL,
+    session_data  JSONB       NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS webauthn_challenges_timestamps_trigger ON webauthn_challenges;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER webauthn_challenges_timestamps_trigger
+BEFORE INSERT OR UPDATE ON webauthn_challenges
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: expired challenges for the sweeper
+CREATE INDEX IF NOT EXISTS idx_webauthn_challenges_expires
+    ON webauthn_challenges(expires_at);
+
+-- ======================================
+-- End of passkeys section
+-- ======================================
@@ -0,0 +1,12 @@
+-- +migrate Down
+
+-- Drop WebAuthn challenges
+DROP INDEX IF EXISTS idx_webauthn_challenges_expires;                             -- Sweeper index
+DROP TRIGGER IF EXISTS webauthn_challenges_timestamps_trigger ON webauthn_challenges; -- Timestamp trigger
+DROP TABLE IF EXISTS webauthn_challenges CASCADE;                                 -- Also drops PK
+
+-- Drop passkey credentials
+DROP INDEX IF EXISTS idx_passkey_credentials_user;                                -- User index
+DROP INDEX IF EXISTS idx_passkey_credentials_credential_id;                       -- Credential id index
+DROP TRIGGER IF EXISTS passkey_credentials_timestamps_trigger ON passkey_credentials; -- Timestamp trigger
+DROP TABLE IF EXISTS passkey_credentials CASCADE;                                 -- Also drops PK
@@ -0,0 +1,60 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: refresh_tokens
+--        Rotating refresh tokens of native clients. Only the
+--        SHA-256 hex of a token is stored. Every refresh marks the
+--        presented token rotated, links the next one in the same
+--        family (replaced_by) and issues it. Presenting a rotated
+--        token again revokes the whole family, unless it is a retry
+--        shortly after the rotation whose successor was never used.
+--        The Appwrite session a family belongs to is
+--        kept as a hash (for logout lookups) and sealed with
+--        utils.EncryptField (to mint access tokens). Stale rows are
+--        removed by workers.RefreshTokenSweeper.
+-- ======================================
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id                     UUID        PRIMARY KEY,
+    family_id              UUID        NOT NULL,
+    user_id                UUID        NOT NULL,
+    sha256_hex_session_id  TEXT        NOT NULL CHECK (
+        length(sha256_hex_session_id) = 64
+    ),
+    b64_cipher_session_id  TEXT        NOT NULL,
+    token_hash             TEXT        NOT NULL CHECK (
+        length(token_hash) = 64
+    ),
+    expires_at             TIMESTAMPTZ NOT NULL,
+    rotated_at             TIMESTAMPTZ,
+    replaced_by            UUID,
+    revoked_at             TIMESTAMPTZ,
+    created_at             TIMESTAMPTZ,
+    updated_at             TIMESTAMPTZ,
+
+    CONSTRAINT refresh_tokens_unique_hash UNIQUE (token_hash)
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS refresh_tokens_timestamps_trigger ON refresh_tokens;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER refresh_tokens_timestamps_trigger
+BEFORE INSERT OR UPDATE ON refresh_tokens
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: revoking a family
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family
+    ON refresh_tokens(family_id);
+
+-- Index: revoking a user's or a session's tokens on logout
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_session
+    ON refresh_tokens(user_id, sha256_hex_session_id);
+
+-- Index: expired tokens for the sweeper
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires
+    ON refresh_tokens(expires_at);
+
+-- ======================================
+-- End of refresh tokens section
+-- ======================================
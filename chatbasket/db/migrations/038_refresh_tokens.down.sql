@@ -0,0 +1,8 @@
+-- +migrate Down
+
+-- Drop refresh tokens
+DROP INDEX IF EXISTS idx_refresh_tokens_expires;                            -- Sweeper index
+DROP INDEX IF EXISTS idx_refresh_tokens_user_session;                       -- Logout index
+DROP INDEX IF EXISTS idx_refresh_tokens_family;                             -- Family index
+DROP TRIGGER IF EXISTS refresh_tokens_timestamps_trigger ON refresh_tokens; -- Timestamp trigger
+DROP TABLE IF EXISTS refresh_tokens CASCADE;                                -- Also drops PK and unique hash
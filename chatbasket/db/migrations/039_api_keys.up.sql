@@ -0,0 +1,43 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: api_keys
+--        Scoped keys for bots and server-to-server integrations,
+--        sent in the X-Api-Key header. prefix is the public part of
+--        the key ("cbk_" and 8 characters) used to find it and to
+--        show it in listings; only the SHA-256 hex of the whole key
+--        is stored. last_used_at is refreshed at most once a minute.
+-- ======================================
+CREATE TABLE IF NOT EXISTS api_keys (
+    id            UUID        PRIMARY KEY,
+    user_id       UUID        NOT NULL,
+    name          TEXT        NOT NULL,
+    prefix        TEXT        NOT NULL,
+    key_hash      TEXT        NOT NULL CHECK (
+        length(key_hash) = 64
+    ),
+    scopes        TEXT[]      NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    last_used_at  TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+
+    CONSTRAINT api_keys_unique_prefix UNIQUE (prefix)
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS api_keys_timestamps_trigger ON api_keys;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER api_keys_timestamps_trigger
+BEFORE INSERT OR UPDATE ON api_keys
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: a user's keys
+CREATE INDEX IF NOT EXISTS idx_api_keys_user
+    ON api_keys(user_id);
+
+-- ======================================
+-- End of API keys section
+-- ======================================
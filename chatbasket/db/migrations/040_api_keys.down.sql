@@ -0,0 +1,6 @@
+-- +migrate Down
+
+-- Drop API keys
+DROP INDEX IF EXISTS idx_api_keys_user;                         -- User index
+DROP TRIGGER IF EXISTS api_keys_timestamps_trigger ON api_keys; -- Timestamp trigger
+DROP TABLE IF EXISTS api_keys CASCADE;                          -- Also drops PK and unique prefix
@@ -0,0 +1,71 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: audit_events
+--        Append-only log of security- and moderation-relevant
+--        actions, written by the audit package. actor_user_id is
+--        who acted (NULL for the system), target_type/target_id
+--        what was acted on. request_id is the X-Request-Id of the
+--        request. Rows cannot be updated or deleted; ids are UUIDv7
+--        so they also order events in time.
+--        Personal data: ip and user_agent of the request are the
+--        only personal data kept, and since rows are never deleted
+--        they are kept indefinitely, also after the account is
+--        deleted (actor_user_id then points at no user). metadata
+--        must not carry any (e.g. email addresses).
+-- ======================================
+CREATE TABLE IF NOT EXISTS audit_events (
+    id             UUID        PRIMARY KEY,
+    actor_user_id  UUID,
+    actor_type     TEXT        NOT NULL CHECK (actor_type IN ('user', 'admin', 'system')),
+    action         TEXT        NOT NULL,
+    target_type    TEXT        NOT NULL,
+    target_id      TEXT        NOT NULL,
+    request_id     TEXT,
+    ip             TEXT,
+    user_agent     TEXT,
+    metadata       JSONB       NOT NULL DEFAULT '{}'::jsonb,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- ======================================
+-- Function: audit_events_append_only()
+-- Rejects any change to recorded events
+-- ======================================
+CREATE OR REPLACE FUNCTION audit_events_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Drop existing triggers if already present
+DROP TRIGGER IF EXISTS audit_events_append_only_trigger ON audit_events;
+DROP TRIGGER IF EXISTS audit_events_no_truncate_trigger ON audit_events;
+
+-- Attach append-only triggers
+CREATE TRIGGER audit_events_append_only_trigger
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate_trigger
+BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT
+EXECUTE FUNCTION audit_events_append_only();
+
+-- Index: events by actor
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor
+    ON audit_events(actor_user_id, id DESC);
+
+-- Index: events by target
+CREATE INDEX IF NOT EXISTS idx_audit_events_target
+    ON audit_events(target_type, target_id, id DESC);
+
+-- Index: events by action
+CREATE INDEX IF NOT EXISTS idx_audit_events_action
+    ON audit_events(action, id DESC);
+
+-- ======================================
+-- End of audit events section
+-- ======================================
@@ -0,0 +1,10 @@
+-- +migrate Down
+
+-- Drop audit events
+DROP INDEX IF EXISTS idx_audit_events_action;                               -- Action index
+DROP INDEX IF EXISTS idx_audit_events_target;                               -- Target index
+DROP INDEX IF EXISTS idx_audit_events_actor;                                -- Actor index
+DROP TRIGGER IF EXISTS audit_events_no_truncate_trigger ON audit_events;    -- Truncate guard
+DROP TRIGGER IF EXISTS audit_events_append_only_trigger ON audit_events;    -- Append-only guard
+DROP TABLE IF EXISTS audit_events CASCADE;                                  -- Also drops PK
+DROP FUNCTION IF EXISTS audit_events_append_only();                         -- Guard function
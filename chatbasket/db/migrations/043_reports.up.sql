@@ -0,0 +1,65 @@
+-- +migrate Up
+
+-- ======================================
+-- Table: reports
+--        Reports filed from personal mode against a user, their
+--        profile or one of their messages. b64_cipher_evidence is
+--        the snapshot taken when the report was filed (the profile
+--        as it was, and for messages the content the reporter
+--        submitted, flagged reporter_supplied) as JSON, sealed with
+--        utils.EncryptField since it holds the target's personal
+--        data. target_user_id is cleared when the reported user
+--        deletes their account; the report and its evidence are
+--        kept. A reporter can hold one
+--        open report per target. Admins triage the queue: in_review, then actioned
+--        (the target was dealt with, e.g. admin-blocked) or
+--        dismissed.
+-- ======================================
+CREATE TABLE IF NOT EXISTS reports (
+    id                   UUID        PRIMARY KEY,
+    reporter_user_id     UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type          TEXT        NOT NULL CHECK (target_type IN ('user', 'profile', 'message')),
+    target_user_id       UUID        REFERENCES users(id) ON DELETE SET NULL,
+    target_id            TEXT        NOT NULL,
+    category             TEXT        NOT NULL CHECK (category IN ('spam', 'harassment', 'hate', 'impersonation', 'inappropriate', 'other')),
+    details              TEXT        CHECK (length(details) <= 1000),
+    b64_cipher_evidence  TEXT        NOT NULL,
+    status               TEXT        NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'in_review', 'actioned', 'dismissed')),
+    resolution_note      TEXT        CHECK (length(resolution_note) <= 1000),
+    handled_by           UUID,
+    resolved_at          TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ,
+
+    CONSTRAINT reports_no_self_report CHECK (reporter_user_id != target_user_id)
+);
+
+-- Drop existing trigger if already present
+DROP TRIGGER IF EXISTS reports_timestamps_trigger ON reports;
+
+-- Attach auto timestamp trigger
+CREATE TRIGGER reports_timestamps_trigger
+BEFORE INSERT OR UPDATE ON reports
+FOR EACH ROW
+EXECUTE FUNCTION set_timestamps();
+
+-- Index: one open report per reporter and target (deduplication)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_unique_open
+    ON reports(reporter_user_id, target_type, target_id)
+    WHERE status IN ('open', 'in_review');
+
+-- Index: the moderation queue, oldest first within a status
+CREATE INDEX IF NOT EXISTS idx_reports_status
+    ON reports(status, id);
+
+-- Index: reports against a user
+CREATE INDEX IF NOT EXISTS idx_reports_target_user
+    ON reports(target_user_id, status);
+
+-- Index: a reporter's own reports
+CREATE INDEX IF NOT EXISTS idx_reports_reporter
+    ON reports(reporter_user_id, id DESC);
+
+-- ======================================
+-- End of reports section
+-- ======================================
@@ -0,0 +1,9 @@
+-- +migrate Down
+
+-- Drop reports
+DROP INDEX IF EXISTS idx_reports_reporter;                    -- Reporter index
+DROP INDEX IF EXISTS idx_reports_target_user;                 -- Target user index
+DROP INDEX IF EXISTS idx_reports_status;                      -- Queue index
+DROP INDEX IF EXISTS idx_reports_unique_open;                 -- Deduplication index
+DROP TRIGGER IF EXISTS reports_timestamps_trigger ON reports; -- Timestamp trigger
+DROP TABLE IF EXISTS reports CASCADE;                         -- Also drops PK
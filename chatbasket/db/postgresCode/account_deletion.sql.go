@@ -0,0 +1,176 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: account_deletion.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const advanceAccountDeletionJob = `-- name: AdvanceAccountDeletionJob :exec
+UPDATE account_deletion_jobs
+SET step = $1,
+    attempts = 0,
+    last_error = NULL
+WHERE user_id = $2
+`
+
+type AdvanceAccountDeletionJobParams struct {
+	NextStep string    `json:"next_step"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+// Records that a step finished and moves on to the next one
+func (q *Queries) AdvanceAccountDeletionJob(ctx context.Context, arg AdvanceAccountDeletionJobParams) error {
+	_, err := q.db.Exec(ctx, advanceAccountDeletionJob, arg.NextStep, arg.UserID)
+	return err
+}
+
+const claimAccountDeletionJobs = `-- name: ClaimAccountDeletionJobs :many
+UPDATE account_deletion_jobs
+SET next_attempt_at = now() + $1::INTERVAL
+WHERE user_id IN (
+    SELECT j.user_id
+    FROM account_deletion_jobs AS j
+    WHERE j.status = 'pending'
+      AND j.next_attempt_at <= now()
+    ORDER BY j.next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING user_id, step, status, attempts, last_error, next_attempt_at, completed_at, created_at, updated_at
+`
+
+type ClaimAccountDeletionJobsParams struct {
+	Lease     pgtype.Interval `json:"lease"`
+	BatchSize int32           `json:"batch_size"`
+}
+
+// Leases due jobs by pushing next_attempt_at past the lease; SKIP LOCKED lets several
+// instances claim concurrently without picking the same job
+func (q *Queries) ClaimAccountDeletionJobs(ctx context.Context, arg ClaimAccountDeletionJobsParams) ([]AccountDeletionJob, error) {
+	rows, err := q.db.Query(ctx, claimAccountDeletionJobs, arg.Lease, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AccountDeletionJob
+	for rows.Next() {
+		var i AccountDeletionJob
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Step,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeAccountDeletionJob = `-- name: CompleteAccountDeletionJob :exec
+UPDATE account_deletion_jobs
+SET status = 'done',
+    step = 'done',
+    last_error = NULL,
+    completed_at = now()
+WHERE user_id = $1
+`
+
+// Marks every step as done
+func (q *Queries) CompleteAccountDeletionJob(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, completeAccountDeletionJob, userID)
+	return err
+}
+
+const enqueueAccountDeletion = `-- name: EnqueueAccountDeletion :one
+
+INSERT INTO account_deletion_jobs (user_id, step)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET next_attempt_at = LEAST(account_deletion_jobs.next_attempt_at, now())
+RETURNING user_id, step, status, attempts, last_error, next_attempt_at, completed_at, created_at, updated_at
+`
+
+type EnqueueAccountDeletionParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	FirstStep string    `json:"first_step"`
+}
+
+// ===========================================
+// Account deletion job queries for sqlc
+// ===========================================
+// Creates the job, or makes an existing pending job due now
+func (q *Queries) EnqueueAccountDeletion(ctx context.Context, arg EnqueueAccountDeletionParams) (AccountDeletionJob, error) {
+	row := q.db.QueryRow(ctx, enqueueAccountDeletion, arg.UserID, arg.FirstStep)
+	var i AccountDeletionJob
+	err := row.Scan(
+		&i.UserID,
+		&i.Step,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const failAccountDeletionJob = `-- name: FailAccountDeletionJob :exec
+UPDATE account_deletion_jobs
+SET attempts = attempts + 1,
+    last_error = $1,
+    next_attempt_at = $2
+WHERE user_id = $3
+`
+
+type FailAccountDeletionJobParams struct {
+	LastError *string            `json:"last_error"`
+	RetryAt   pgtype.Timestamptz `json:"retry_at"`
+	UserID    uuid.UUID          `json:"user_id"`
+}
+
+// Records a failed step and schedules the retry
+func (q *Queries) FailAccountDeletionJob(ctx context.Context, arg FailAccountDeletionJobParams) error {
+	_, err := q.db.Exec(ctx, failAccountDeletionJob, arg.LastError, arg.RetryAt, arg.UserID)
+	return err
+}
+
+const getAccountDeletionJob = `-- name: GetAccountDeletionJob :one
+SELECT user_id, step, status, attempts, last_error, next_attempt_at, completed_at, created_at, updated_at FROM account_deletion_jobs
+WHERE user_id = $1
+`
+
+func (q *Queries) GetAccountDeletionJob(ctx context.Context, userID uuid.UUID) (AccountDeletionJob, error) {
+	row := q.db.QueryRow(ctx, getAccountDeletionJob, userID)
+	var i AccountDeletionJob
+	err := row.Scan(
+		&i.UserID,
+		&i.Step,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
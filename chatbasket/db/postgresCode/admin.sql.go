@@ -0,0 +1,162 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: admin.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const adminGetUserByHashedUsername = `-- name: AdminGetUserByHashedUsername :one
+
+SELECT id, name, bio, profile_type, is_admin_blocked, admin_block_reason, hmac_sha256_hex_username, b64_cipher_chacha20poly1305_username, created_at, updated_at
+FROM users
+WHERE hmac_sha256_hex_username = $1
+`
+
+// ===========================================
+// Admin queries for sqlc
+// ===========================================
+// Like GetUserByHashedUsername, but also finds admin-blocked users
+func (q *Queries) AdminGetUserByHashedUsername(ctx context.Context, hmacSha256HexUsername string) (User, error) {
+	row := q.db.QueryRow(ctx, adminGetUserByHashedUsername, hmacSha256HexUsername)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Bio,
+		&i.ProfileType,
+		&i.IsAdminBlocked,
+		&i.AdminBlockReason,
+		&i.HmacSha256HexUsername,
+		&i.B64CipherChacha20poly1305Username,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const adminSearchUsersByName = `-- name: AdminSearchUsersByName :many
+SELECT id, name, bio, profile_type, is_admin_blocked, admin_block_reason, hmac_sha256_hex_username, b64_cipher_chacha20poly1305_username, created_at, updated_at
+FROM users
+WHERE name ILIKE '%' || $1::text || '%'
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type AdminSearchUsersByNameParams struct {
+	Name       string `json:"name"`
+	MaxResults int32  `json:"max_results"`
+}
+
+// Users whose display name contains the search text, newest first
+func (q *Queries) AdminSearchUsersByName(ctx context.Context, arg AdminSearchUsersByNameParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, adminSearchUsersByName, arg.Name, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Bio,
+			&i.ProfileType,
+			&i.IsAdminBlocked,
+			&i.AdminBlockReason,
+			&i.HmacSha256HexUsername,
+			&i.B64CipherChacha20poly1305Username,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getContactRequestVolume = `-- name: GetContactRequestVolume :one
+SELECT
+    count(*) FILTER (WHERE requester_user_id = $1 AND created_at > now() - interval '1 day')   AS sent_last_day,
+    count(*) FILTER (WHERE requester_user_id = $1 AND created_at > now() - interval '7 days')  AS sent_last_week,
+    count(*) FILTER (WHERE requester_user_id = $1 AND created_at > now() - interval '30 days') AS sent_last_month,
+    count(*) FILTER (WHERE requester_user_id = $1)                                            AS sent_total,
+    count(*) FILTER (WHERE requester_user_id = $1 AND status = 'pending')                     AS sent_pending,
+    count(*) FILTER (WHERE requester_user_id = $1 AND status = 'accepted')                    AS sent_accepted,
+    count(*) FILTER (WHERE requester_user_id = $1 AND status = 'declined')                    AS sent_declined,
+    count(*) FILTER (WHERE receiver_user_id = $1)                                             AS received_total
+FROM contact_requests
+WHERE requester_user_id = $1
+   OR receiver_user_id = $1
+`
+
+type GetContactRequestVolumeRow struct {
+	SentLastDay   int64 `json:"sent_last_day"`
+	SentLastWeek  int64 `json:"sent_last_week"`
+	SentLastMonth int64 `json:"sent_last_month"`
+	SentTotal     int64 `json:"sent_total"`
+	SentPending   int64 `json:"sent_pending"`
+	SentAccepted  int64 `json:"sent_accepted"`
+	SentDeclined  int64 `json:"sent_declined"`
+	ReceivedTotal int64 `json:"received_total"`
+}
+
+// Contact requests a user sent (with their outcome) and received, for spotting abuse
+func (q *Queries) GetContactRequestVolume(ctx context.Context, requesterUserID uuid.UUID) (GetContactRequestVolumeRow, error) {
+	row := q.db.QueryRow(ctx, getContactRequestVolume, requesterUserID)
+	var i GetContactRequestVolumeRow
+	err := row.Scan(
+		&i.SentLastDay,
+		&i.SentLastWeek,
+		&i.SentLastMonth,
+		&i.SentTotal,
+		&i.SentPending,
+		&i.SentAccepted,
+		&i.SentDeclined,
+		&i.ReceivedTotal,
+	)
+	return i, err
+}
+
+const setUserAdminBlock = `-- name: SetUserAdminBlock :one
+UPDATE users
+SET is_admin_blocked = $1,
+    admin_block_reason = $2
+WHERE id = $3
+RETURNING id, name, bio, profile_type, is_admin_blocked, admin_block_reason, hmac_sha256_hex_username, b64_cipher_chacha20poly1305_username, created_at, updated_at
+`
+
+type SetUserAdminBlockParams struct {
+	Blocked bool      `json:"blocked"`
+	Reason  *string   `json:"reason"`
+	ID      uuid.UUID `json:"id"`
+}
+
+// Blocks or unblocks a user; the reason is cleared on unblock
+func (q *Queries) SetUserAdminBlock(ctx context.Context, arg SetUserAdminBlockParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserAdminBlock, arg.Blocked, arg.Reason, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Bio,
+		&i.ProfileType,
+		&i.IsAdminBlocked,
+		&i.AdminBlockReason,
+		&i.HmacSha256HexUsername,
+		&i.B64CipherChacha20poly1305Username,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,132 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_events.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const insertAuditEvent = `-- name: InsertAuditEvent :exec
+
+INSERT INTO audit_events (id, actor_user_id, actor_type, action, target_type, target_id, request_id, ip, user_agent, metadata)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+`
+
+type InsertAuditEventParams struct {
+	ID          uuid.UUID   `json:"id"`
+	ActorUserID pgtype.UUID `json:"actor_user_id"`
+	ActorType   string      `json:"actor_type"`
+	Action      string      `json:"action"`
+	TargetType  string      `json:"target_type"`
+	TargetID    string      `json:"target_id"`
+	RequestID   *string     `json:"request_id"`
+	Ip          *string     `json:"ip"`
+	UserAgent   *string     `json:"user_agent"`
+	Metadata    []byte      `json:"metadata"`
+}
+
+// ===========================================
+// Audit event queries for sqlc
+// ===========================================
+func (q *Queries) InsertAuditEvent(ctx context.Context, arg InsertAuditEventParams) error {
+	_, err := q.db.Exec(ctx, insertAuditEvent,
+		arg.ID,
+		arg.ActorUserID,
+		arg.ActorType,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.RequestID,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Metadata,
+	)
+	return err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, actor_user_id, actor_type, action, target_type, target_id, request_id, ip, user_agent, metadata, created_at
+FROM audit_events
+WHERE ($1::uuid IS NULL OR actor_user_id = $1)
+  AND ($2::text IS NULL OR action = $2)
+  AND ($3::text IS NULL OR target_type = $3)
+  AND ($4::text IS NULL OR target_id = $4)
+  AND ($5::text IS NULL OR request_id = $5)
+  AND ($6::timestamptz IS NULL OR created_at >= $6)
+  AND ($7::timestamptz IS NULL OR created_at < $7)
+  AND ($8::uuid IS NULL OR id < $8)
+ORDER BY id DESC
+LIMIT $9
+`
+
+type ListAuditEventsParams struct {
+	ActorUserID pgtype.UUID        `json:"actor_user_id"`
+	Action      *string            `json:"action"`
+	TargetType  *string            `json:"target_type"`
+	TargetID    *string            `json:"target_id"`
+	RequestID   *string            `json:"request_id"`
+	Since       pgtype.Timestamptz `json:"since"`
+	Until       pgtype.Timestamptz `json:"until"`
+	BeforeID    pgtype.UUID        `json:"before_id"`
+	MaxResults  int32              `json:"max_results"`
+}
+
+// Newest first; every filter is optional. before_id pages with the id of the last row seen.
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents,
+		arg.ActorUserID,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.RequestID,
+		arg.Since,
+		arg.Until,
+		arg.BeforeID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorUserID,
+			&i.ActorType,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.RequestID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.Metadata,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
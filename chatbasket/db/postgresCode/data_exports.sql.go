@@ -0,0 +1,597 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: data_exports.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimDataExports = `-- name: ClaimDataExports :many
+UPDATE data_exports
+SET next_attempt_at = now() + $1::INTERVAL
+WHERE id IN (
+    SELECT e.id
+    FROM data_exports AS e
+    WHERE e.status = 'pending'
+      AND e.next_attempt_at <= now()
+    ORDER BY e.next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id, status, file_id, size_bytes, attempts, last_error, next_attempt_at, expires_at, downloaded_at, created_at, updated_at
+`
+
+type ClaimDataExportsParams struct {
+	Lease     pgtype.Interval `json:"lease"`
+	BatchSize int32           `json:"batch_size"`
+}
+
+// Leases due exports by pushing next_attempt_at past the lease; SKIP LOCKED lets several
+// instances claim concurrently without picking the same export
+func (q *Queries) ClaimDataExports(ctx context.Context, arg ClaimDataExportsParams) ([]DataExport, error) {
+	rows, err := q.db.Query(ctx, claimDataExports, arg.Lease, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DataExport
+	for rows.Next() {
+		var i DataExport
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.FileID,
+			&i.SizeBytes,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.ExpiresAt,
+			&i.DownloadedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeDataExport = `-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready',
+    file_id = $1,
+    size_bytes = $2,
+    expires_at = $3,
+    last_error = NULL
+WHERE id = $4
+`
+
+type CompleteDataExportParams struct {
+	FileID    *string            `json:"file_id"`
+	SizeBytes *int64             `json:"size_bytes"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	ID        uuid.UUID          `json:"id"`
+}
+
+// Records the stored archive and opens the download window
+func (q *Queries) CompleteDataExport(ctx context.Context, arg CompleteDataExportParams) error {
+	_, err := q.db.Exec(ctx, completeDataExport,
+		arg.FileID,
+		arg.SizeBytes,
+		arg.ExpiresAt,
+		arg.ID,
+	)
+	return err
+}
+
+const consumeDataExport = `-- name: ConsumeDataExport :one
+UPDATE data_exports
+SET status = 'downloaded',
+    downloaded_at = now()
+WHERE id = $1
+  AND status = 'ready'
+  AND expires_at > now()
+RETURNING id, user_id, status, file_id, size_bytes, attempts, last_error, next_attempt_at, expires_at, downloaded_at, created_at, updated_at
+`
+
+// Claims the one download of a ready export
+func (q *Queries) ConsumeDataExport(ctx context.Context, id uuid.UUID) (DataExport, error) {
+	row := q.db.QueryRow(ctx, consumeDataExport, id)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.FileID,
+		&i.SizeBytes,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.ExpiresAt,
+		&i.DownloadedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createDataExport = `-- name: CreateDataExport :one
+
+INSERT INTO data_exports (id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id) WHERE status IN ('pending', 'ready') DO NOTHING
+RETURNING id, user_id, status, file_id, size_bytes, attempts, last_error, next_attempt_at, expires_at, downloaded_at, created_at, updated_at
+`
+
+type CreateDataExportParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// ===========================================
+// Data export queries for sqlc
+// ===========================================
+// Queues an export; returns no row when the user already has one pending or ready
+func (q *Queries) CreateDataExport(ctx context.Context, arg CreateDataExportParams) (DataExport, error) {
+	row := q.db.QueryRow(ctx, createDataExport, arg.ID, arg.UserID)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.FileID,
+		&i.SizeBytes,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.ExpiresAt,
+		&i.DownloadedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const exportUserBlocks = `-- name: ExportUserBlocks :many
+SELECT blocked_user_id, created_at
+FROM user_blocks
+WHERE blocker_user_id = $1
+ORDER BY created_at
+`
+
+type ExportUserBlocksRow struct {
+	BlockedUserID uuid.UUID          `json:"blocked_user_id"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+}
+
+// Users the user blocked
+func (q *Queries) ExportUserBlocks(ctx context.Context, blockerUserID uuid.UUID) ([]ExportUserBlocksRow, error) {
+	rows, err := q.db.Query(ctx, exportUserBlocks, blockerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserBlocksRow
+	for rows.Next() {
+		var i ExportUserBlocksRow
+		if err := rows.Scan(&i.BlockedUserID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserContactRequests = `-- name: ExportUserContactRequests :many
+SELECT
+    cr.id,
+    cr.requester_user_id,
+    cr.receiver_user_id,
+    cr.status::TEXT AS status,
+    cr.nickname,
+    cr.created_at,
+    cr.updated_at
+FROM contact_requests cr
+WHERE cr.requester_user_id = $1
+   OR cr.receiver_user_id = $1
+ORDER BY cr.created_at
+`
+
+type ExportUserContactRequestsRow struct {
+	ID              uuid.UUID          `json:"id"`
+	RequesterUserID uuid.UUID          `json:"requester_user_id"`
+	ReceiverUserID  uuid.UUID          `json:"receiver_user_id"`
+	Status          string             `json:"status"`
+	Nickname        *string            `json:"nickname"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
+// Every contact request the user sent or received, whatever its status
+func (q *Queries) ExportUserContactRequests(ctx context.Context, requesterUserID uuid.UUID) ([]ExportUserContactRequestsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserContactRequests, requesterUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserContactRequestsRow
+	for rows.Next() {
+		var i ExportUserContactRequestsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RequesterUserID,
+			&i.ReceiverUserID,
+			&i.Status,
+			&i.Nickname,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserContacts = `-- name: ExportUserContacts :many
+SELECT
+    uc.contact_user_id,
+    u.name AS contact_name,
+    uc.nickname,
+    uc.created_at
+FROM user_contacts uc
+JOIN users u ON u.id = uc.contact_user_id
+WHERE uc.owner_user_id = $1
+ORDER BY uc.created_at
+`
+
+type ExportUserContactsRow struct {
+	ContactUserID uuid.UUID          `json:"contact_user_id"`
+	ContactName   string             `json:"contact_name"`
+	Nickname      *string            `json:"nickname"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+}
+
+// The user's contacts with the nickname they set (still encrypted)
+func (q *Queries) ExportUserContacts(ctx context.Context, ownerUserID uuid.UUID) ([]ExportUserContactsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserContacts, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserContactsRow
+	for rows.Next() {
+		var i ExportUserContactsRow
+		if err := rows.Scan(
+			&i.ContactUserID,
+			&i.ContactName,
+			&i.Nickname,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserGlobalRestrictionExemptions = `-- name: ExportUserGlobalRestrictionExemptions :many
+SELECT exempted_user_id, exception_profile, exception_avatar, exception_status, created_at, updated_at
+FROM user_global_restriction_exemptions
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+type ExportUserGlobalRestrictionExemptionsRow struct {
+	ExemptedUserID   uuid.UUID          `json:"exempted_user_id"`
+	ExceptionProfile bool               `json:"exception_profile"`
+	ExceptionAvatar  bool               `json:"exception_avatar"`
+	ExceptionStatus  bool               `json:"exception_status"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
+}
+
+// Users exempted from the user's global restrictions
+func (q *Queries) ExportUserGlobalRestrictionExemptions(ctx context.Context, userID uuid.UUID) ([]ExportUserGlobalRestrictionExemptionsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserGlobalRestrictionExemptions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserGlobalRestrictionExemptionsRow
+	for rows.Next() {
+		var i ExportUserGlobalRestrictionExemptionsRow
+		if err := rows.Scan(
+			&i.ExemptedUserID,
+			&i.ExceptionProfile,
+			&i.ExceptionAvatar,
+			&i.ExceptionStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserGlobalRestrictions = `-- name: ExportUserGlobalRestrictions :many
+SELECT restrict_profile, restrict_avatar, restrict_status, created_at, updated_at
+FROM user_global_restrictions
+WHERE user_id = $1
+`
+
+type ExportUserGlobalRestrictionsRow struct {
+	RestrictProfile bool               `json:"restrict_profile"`
+	RestrictAvatar  bool               `json:"restrict_avatar"`
+	RestrictStatus  bool               `json:"restrict_status"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
+// The user's global restriction flags (at most one row)
+func (q *Queries) ExportUserGlobalRestrictions(ctx context.Context, userID uuid.UUID) ([]ExportUserGlobalRestrictionsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserGlobalRestrictions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserGlobalRestrictionsRow
+	for rows.Next() {
+		var i ExportUserGlobalRestrictionsRow
+		if err := rows.Scan(
+			&i.RestrictProfile,
+			&i.RestrictAvatar,
+			&i.RestrictStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserPushTokens = `-- name: ExportUserPushTokens :many
+SELECT type, token, is_active, created_at, updated_at
+FROM tokens
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+type ExportUserPushTokensRow struct {
+	Type      string             `json:"type"`
+	Token     string             `json:"token"`
+	IsActive  bool               `json:"is_active"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+// The user's registered push tokens
+func (q *Queries) ExportUserPushTokens(ctx context.Context, userID uuid.UUID) ([]ExportUserPushTokensRow, error) {
+	rows, err := q.db.Query(ctx, exportUserPushTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserPushTokensRow
+	for rows.Next() {
+		var i ExportUserPushTokensRow
+		if err := rows.Scan(
+			&i.Type,
+			&i.Token,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportUserRestrictions = `-- name: ExportUserRestrictions :many
+SELECT restricted_user_id, restrict_profile, restrict_avatar, restrict_status, created_at, updated_at
+FROM user_restrictions
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+type ExportUserRestrictionsRow struct {
+	RestrictedUserID uuid.UUID          `json:"restricted_user_id"`
+	RestrictProfile  bool               `json:"restrict_profile"`
+	RestrictAvatar   bool               `json:"restrict_avatar"`
+	RestrictStatus   bool               `json:"restrict_status"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
+}
+
+// Restrictions the user placed on other users
+func (q *Queries) ExportUserRestrictions(ctx context.Context, userID uuid.UUID) ([]ExportUserRestrictionsRow, error) {
+	rows, err := q.db.Query(ctx, exportUserRestrictions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportUserRestrictionsRow
+	for rows.Next() {
+		var i ExportUserRestrictionsRow
+		if err := rows.Scan(
+			&i.RestrictedUserID,
+			&i.RestrictProfile,
+			&i.RestrictAvatar,
+			&i.RestrictStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const failDataExport = `-- name: FailDataExport :exec
+UPDATE data_exports
+SET attempts = attempts + 1,
+    last_error = $1,
+    next_attempt_at = $2,
+    status = CASE WHEN attempts + 1 >= $3::INTEGER THEN 'failed' ELSE status END
+WHERE id = $4
+`
+
+type FailDataExportParams struct {
+	LastError   *string            `json:"last_error"`
+	RetryAt     pgtype.Timestamptz `json:"retry_at"`
+	MaxAttempts int32              `json:"max_attempts"`
+	ID          uuid.UUID          `json:"id"`
+}
+
+// Records a failed build; the export gives up after max_attempts
+func (q *Queries) FailDataExport(ctx context.Context, arg FailDataExportParams) error {
+	_, err := q.db.Exec(ctx, failDataExport,
+		arg.LastError,
+		arg.RetryAt,
+		arg.MaxAttempts,
+		arg.ID,
+	)
+	return err
+}
+
+const getLatestDataExport = `-- name: GetLatestDataExport :one
+SELECT id, user_id, status, file_id, size_bytes, attempts, last_error, next_attempt_at, expires_at, downloaded_at, created_at, updated_at FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+// Returns the user's most recent export
+func (q *Queries) GetLatestDataExport(ctx context.Context, userID uuid.UUID) (DataExport, error) {
+	row := q.db.QueryRow(ctx, getLatestDataExport, userID)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.FileID,
+		&i.SizeBytes,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.ExpiresAt,
+		&i.DownloadedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listPurgeableDataExports = `-- name: ListPurgeableDataExports :many
+SELECT id, file_id
+FROM data_exports
+WHERE file_id IS NOT NULL
+  AND (status = 'downloaded' OR expires_at <= now())
+LIMIT $1
+`
+
+type ListPurgeableDataExportsRow struct {
+	ID     uuid.UUID `json:"id"`
+	FileID *string   `json:"file_id"`
+}
+
+// Returns stored archives that were downloaded or whose window has passed
+func (q *Queries) ListPurgeableDataExports(ctx context.Context, limit int32) ([]ListPurgeableDataExportsRow, error) {
+	rows, err := q.db.Query(ctx, listPurgeableDataExports, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPurgeableDataExportsRow
+	for rows.Next() {
+		var i ListPurgeableDataExportsRow
+		if err := rows.Scan(&i.ID, &i.FileID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDataExportFiles = `-- name: ListUserDataExportFiles :many
+SELECT file_id::TEXT
+FROM data_exports
+WHERE user_id = $1
+  AND file_id IS NOT NULL
+`
+
+// Returns the user's stored archives (for account deletion)
+func (q *Queries) ListUserDataExportFiles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := q.db.Query(ctx, listUserDataExportFiles, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var file_id string
+		if err := rows.Scan(&file_id); err != nil {
+			return nil, err
+		}
+		items = append(items, file_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDataExport = `-- name: PurgeDataExport :exec
+UPDATE data_exports
+SET file_id = NULL,
+    status = CASE WHEN status = 'ready' THEN 'expired' ELSE status END
+WHERE id = $1
+`
+
+// Forgets a deleted archive; exports that were never downloaded become expired
+func (q *Queries) PurgeDataExport(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, purgeDataExport, id)
+	return err
+}
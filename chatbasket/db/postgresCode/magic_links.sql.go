@@ -0,0 +1,132 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: magic_links.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeMagicLink = `-- name: ConsumeMagicLink :execrows
+UPDATE magic_links
+SET consumed_at = now()
+WHERE id = $1
+  AND consumed_at IS NULL
+`
+
+// Uses a link up; no row is affected when a concurrent request got there first
+func (q *Queries) ConsumeMagicLink(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeMagicLink, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const createMagicLink = `-- name: CreateMagicLink :exec
+
+INSERT INTO magic_links (id, user_id, email, nonce_hash, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+`
+
+type CreateMagicLinkParams struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	Email     string             `json:"email"`
+	NonceHash string             `json:"nonce_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+// ===========================================
+// Magic link queries for sqlc
+// ===========================================
+func (q *Queries) CreateMagicLink(ctx context.Context, arg CreateMagicLinkParams) error {
+	_, err := q.db.Exec(ctx, createMagicLink,
+		arg.ID,
+		arg.UserID,
+		arg.Email,
+		arg.NonceHash,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const deleteMagicLink = `-- name: DeleteMagicLink :exec
+DELETE FROM magic_links WHERE id = $1
+`
+
+func (q *Queries) DeleteMagicLink(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMagicLink, id)
+	return err
+}
+
+const deleteStaleMagicLinks = `-- name: DeleteStaleMagicLinks :execrows
+DELETE FROM magic_links
+WHERE expires_at < now() - $1::INTERVAL
+   OR consumed_at < now() - $1::INTERVAL
+`
+
+// Removes links that expired or were used more than grace ago
+func (q *Queries) DeleteStaleMagicLinks(ctx context.Context, grace pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleMagicLinks, grace)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUserMagicLinks = `-- name: DeleteUserMagicLinks :exec
+DELETE FROM magic_links WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserMagicLinks(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserMagicLinks, userID)
+	return err
+}
+
+const getMagicLink = `-- name: GetMagicLink :one
+SELECT id, user_id, email, nonce_hash, attempts, expires_at, consumed_at, created_at, updated_at FROM magic_links WHERE id = $1
+`
+
+func (q *Queries) GetMagicLink(ctx context.Context, id uuid.UUID) (MagicLink, error) {
+	row := q.db.QueryRow(ctx, getMagicLink, id)
+	var i MagicLink
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.NonceHash,
+		&i.Attempts,
+		&i.ExpiresAt,
+		&i.ConsumedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const recordMagicLinkFailure = `-- name: RecordMagicLinkFailure :one
+UPDATE magic_links
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts
+`
+
+// Counts a wrong second factor tried with the link
+func (q *Queries) RecordMagicLinkFailure(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRow(ctx, recordMagicLinkFailure, id)
+	var attempts int32
+	err := row.Scan(&attempts)
+	return attempts, err
+}
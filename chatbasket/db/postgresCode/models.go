@@ -9,6 +9,18 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccountDeletionJob struct {
+	UserID        uuid.UUID          `json:"user_id"`
+	Step          string             `json:"step"`
+	Status        string             `json:"status"`
+	Attempts      int32              `json:"attempts"`
+	LastError     *string            `json:"last_error"`
+	NextAttemptAt pgtype.Timestamptz `json:"next_attempt_at"`
+	CompletedAt   pgtype.Timestamptz `json:"completed_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+}
+
 type AloneUsername struct {
 	ID        uuid.UUID          `json:"id"`
 	Username  string             `json:"username"`
@@ -16,6 +28,33 @@ type AloneUsername struct {
 	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
+type ApiKey struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     uuid.UUID          `json:"user_id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	KeyHash    string             `json:"key_hash"`
+	Scopes     []string           `json:"scopes"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
+}
+
+type AuditEvent struct {
+	ID          uuid.UUID          `json:"id"`
+	ActorUserID pgtype.UUID        `json:"actor_user_id"`
+	ActorType   string             `json:"actor_type"`
+	Action      string             `json:"action"`
+	TargetType  string             `json:"target_type"`
+	TargetID    string             `json:"target_id"`
+	RequestID   *string            `json:"request_id"`
+	Ip          *string            `json:"ip"`
+	UserAgent   *string            `json:"user_agent"`
+	Metadata    []byte             `json:"metadata"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
 type Avatar struct {
 	ID          uuid.UUID          `json:"id"`
 	UserID      uuid.UUID          `json:"user_id"`
@@ -38,6 +77,153 @@ type ContactRequest struct {
 	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
 }
 
+type DataExport struct {
+	ID            uuid.UUID          `json:"id"`
+	UserID        uuid.UUID          `json:"user_id"`
+	Status        string             `json:"status"`
+	FileID        *string            `json:"file_id"`
+	SizeBytes     *int64             `json:"size_bytes"`
+	Attempts      int32              `json:"attempts"`
+	LastError     *string            `json:"last_error"`
+	NextAttemptAt pgtype.Timestamptz `json:"next_attempt_at"`
+	ExpiresAt     pgtype.Timestamptz `json:"expires_at"`
+	DownloadedAt  pgtype.Timestamptz `json:"downloaded_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+}
+
+type MagicLink struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     uuid.UUID          `json:"user_id"`
+	Email      string             `json:"email"`
+	NonceHash  string             `json:"nonce_hash"`
+	Attempts   int32              `json:"attempts"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	ConsumedAt pgtype.Timestamptz `json:"consumed_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
+}
+
+type OtpChallenge struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     uuid.UUID          `json:"user_id"`
+	Purpose    string             `json:"purpose"`
+	Target     string             `json:"target"`
+	CodeHash   string             `json:"code_hash"`
+	Attempts   int32              `json:"attempts"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	ConsumedAt pgtype.Timestamptz `json:"consumed_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
+}
+
+type OtpGuard struct {
+	UserID          uuid.UUID          `json:"user_id"`
+	Lockouts        int32              `json:"lockouts"`
+	LockedUntil     pgtype.Timestamptz `json:"locked_until"`
+	LastSentAt      pgtype.Timestamptz `json:"last_sent_at"`
+	SendsInWindow   int32              `json:"sends_in_window"`
+	WindowStartedAt pgtype.Timestamptz `json:"window_started_at"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
+type PasskeyCredential struct {
+	ID              uuid.UUID          `json:"id"`
+	UserID          uuid.UUID          `json:"user_id"`
+	CredentialID    []byte             `json:"credential_id"`
+	PublicKey       []byte             `json:"public_key"`
+	AttestationType string             `json:"attestation_type"`
+	Transports      []string           `json:"transports"`
+	Aaguid          []byte             `json:"aaguid"`
+	SignCount       int64              `json:"sign_count"`
+	Flags           int16              `json:"flags"`
+	Name            string             `json:"name"`
+	LastUsedAt      pgtype.Timestamptz `json:"last_used_at"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
+type RefreshToken struct {
+	ID                 uuid.UUID          `json:"id"`
+	FamilyID           uuid.UUID          `json:"family_id"`
+	UserID             uuid.UUID          `json:"user_id"`
+	Sha256HexSessionID string             `json:"sha256_hex_session_id"`
+	B64CipherSessionID string             `json:"b64_cipher_session_id"`
+	TokenHash          string             `json:"token_hash"`
+	ExpiresAt          pgtype.Timestamptz `json:"expires_at"`
+	RotatedAt          pgtype.Timestamptz `json:"rotated_at"`
+	ReplacedBy         pgtype.UUID        `json:"replaced_by"`
+	RevokedAt          pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt          pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt          pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Report struct {
+	ID                uuid.UUID          `json:"id"`
+	ReporterUserID    uuid.UUID          `json:"reporter_user_id"`
+	TargetType        string             `json:"target_type"`
+	TargetUserID      pgtype.UUID        `json:"target_user_id"`
+	TargetID          string             `json:"target_id"`
+	Category          string             `json:"category"`
+	Details           *string            `json:"details"`
+	B64CipherEvidence string             `json:"b64_cipher_evidence"`
+	Status            string             `json:"status"`
+	ResolutionNote    *string            `json:"resolution_note"`
+	HandledBy         pgtype.UUID        `json:"handled_by"`
+	ResolvedAt        pgtype.Timestamptz `json:"resolved_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+}
+
+type StorageObject struct {
+	BucketID  string             `json:"bucket_id"`
+	FileID    string             `json:"file_id"`
+	OwnerID   uuid.UUID          `json:"owner_id"`
+	SizeBytes int64              `json:"size_bytes"`
+	Kind      string             `json:"kind"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type StorageUsage struct {
+	OwnerID     uuid.UUID          `json:"owner_id"`
+	Plan        string             `json:"plan"`
+	UsedBytes   int64              `json:"used_bytes"`
+	ObjectCount int32              `json:"object_count"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Token struct {
+	ID                 uuid.UUID          `json:"id"`
+	UserID             uuid.UUID          `json:"user_id"`
+	Sha256HexSessionID string             `json:"sha256_hex_session_id"`
+	Token              string             `json:"token"`
+	Type               string             `json:"type"`
+	IsActive           bool               `json:"is_active"`
+	CreatedAt          pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt          pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TotpFactor struct {
+	UserID       uuid.UUID          `json:"user_id"`
+	Secret       string             `json:"secret"`
+	EnabledAt    pgtype.Timestamptz `json:"enabled_at"`
+	LastUsedStep int64              `json:"last_used_step"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TotpRecoveryCode struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
 type User struct {
 	ID                                uuid.UUID          `json:"id"`
 	Name                              string             `json:"name"`
@@ -86,6 +272,13 @@ type UserGlobalRestrictionExemption struct {
 	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
 }
 
+type UserPreference struct {
+	UserID    uuid.UUID          `json:"user_id"`
+	Locale    string             `json:"locale"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
 type UserRestriction struct {
 	ID               uuid.UUID          `json:"id"`
 	UserID           uuid.UUID          `json:"user_id"`
@@ -96,3 +289,13 @@ type UserRestriction struct {
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
 }
+
+type WebauthnChallenge struct {
+	ID          uuid.UUID          `json:"id"`
+	UserID      pgtype.UUID        `json:"user_id"`
+	Ceremony    string             `json:"ceremony"`
+	SessionData []byte             `json:"session_data"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
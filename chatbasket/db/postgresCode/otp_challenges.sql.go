@@ -0,0 +1,145 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: otp_challenges.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeOtpChallenge = `-- name: ConsumeOtpChallenge :execrows
+UPDATE otp_challenges
+SET consumed_at = now()
+WHERE id = $1
+  AND consumed_at IS NULL
+`
+
+// Uses up a verified challenge; returns 0 rows if another request got there first
+func (q *Queries) ConsumeOtpChallenge(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeOtpChallenge, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOtpChallenge = `-- name: DeleteOtpChallenge :exec
+DELETE FROM otp_challenges
+WHERE id = $1
+`
+
+func (q *Queries) DeleteOtpChallenge(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteOtpChallenge, id)
+	return err
+}
+
+const deleteStaleOtpChallenges = `-- name: DeleteStaleOtpChallenges :execrows
+DELETE FROM otp_challenges
+WHERE expires_at < now() - $1::INTERVAL
+   OR consumed_at < now() - $1::INTERVAL
+`
+
+// Removes challenges that expired or were consumed more than grace ago
+func (q *Queries) DeleteStaleOtpChallenges(ctx context.Context, grace pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleOtpChallenges, grace)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUserOtpChallenges = `-- name: DeleteUserOtpChallenges :exec
+DELETE FROM otp_challenges
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserOtpChallenges(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserOtpChallenges, userID)
+	return err
+}
+
+const reserveOtpChallengeAttempt = `-- name: ReserveOtpChallengeAttempt :one
+UPDATE otp_challenges
+SET attempts = attempts + 1
+WHERE user_id = $1
+  AND purpose = $2
+  AND consumed_at IS NULL
+  AND attempts < $3::INTEGER
+RETURNING id, user_id, purpose, target, code_hash, attempts, expires_at, consumed_at, created_at, updated_at
+`
+
+type ReserveOtpChallengeAttemptParams struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Purpose     string    `json:"purpose"`
+	MaxAttempts int32     `json:"max_attempts"`
+}
+
+// Counts a guess before it is checked, so parallel requests cannot exceed the limit. Returns no
+// row when there is no live challenge or its attempts are used up.
+func (q *Queries) ReserveOtpChallengeAttempt(ctx context.Context, arg ReserveOtpChallengeAttemptParams) (OtpChallenge, error) {
+	row := q.db.QueryRow(ctx, reserveOtpChallengeAttempt, arg.UserID, arg.Purpose, arg.MaxAttempts)
+	var i OtpChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Purpose,
+		&i.Target,
+		&i.CodeHash,
+		&i.Attempts,
+		&i.ExpiresAt,
+		&i.ConsumedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertOtpChallenge = `-- name: UpsertOtpChallenge :exec
+
+INSERT INTO otp_challenges (id, user_id, purpose, target, code_hash, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    now() + $6::INTERVAL
+)
+ON CONFLICT (user_id, purpose) DO UPDATE
+SET id = EXCLUDED.id,
+    target = EXCLUDED.target,
+    code_hash = EXCLUDED.code_hash,
+    attempts = 0,
+    expires_at = EXCLUDED.expires_at,
+    consumed_at = NULL
+`
+
+type UpsertOtpChallengeParams struct {
+	ID       uuid.UUID       `json:"id"`
+	UserID   uuid.UUID       `json:"user_id"`
+	Purpose  string          `json:"purpose"`
+	Target   string          `json:"target"`
+	CodeHash string          `json:"code_hash"`
+	Ttl      pgtype.Interval `json:"ttl"`
+}
+
+// ===========================================
+// OTP challenge queries for sqlc
+// ===========================================
+// Stores a freshly sent code, replacing the user's previous challenge for the purpose
+func (q *Queries) UpsertOtpChallenge(ctx context.Context, arg UpsertOtpChallengeParams) error {
+	_, err := q.db.Exec(ctx, upsertOtpChallenge,
+		arg.ID,
+		arg.UserID,
+		arg.Purpose,
+		arg.Target,
+		arg.CodeHash,
+		arg.Ttl,
+	)
+	return err
+}
@@ -0,0 +1,143 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: otp_guards.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const clearOtpGuard = `-- name: ClearOtpGuard :exec
+UPDATE otp_guards
+SET lockouts = 0,
+    locked_until = NULL
+WHERE user_id = $1
+`
+
+// Forgets lockouts once a code was verified
+func (q *Queries) ClearOtpGuard(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, clearOtpGuard, userID)
+	return err
+}
+
+const deleteIdleOtpGuards = `-- name: DeleteIdleOtpGuards :execrows
+DELETE FROM otp_guards
+WHERE updated_at < now() - $1::INTERVAL
+  AND (locked_until IS NULL OR locked_until <= now())
+`
+
+// Removes guards untouched for a while, so lockouts stop escalating after a quiet period
+func (q *Queries) DeleteIdleOtpGuards(ctx context.Context, idle pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteIdleOtpGuards, idle)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOtpGuard = `-- name: DeleteOtpGuard :exec
+DELETE FROM otp_guards
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteOtpGuard(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteOtpGuard, userID)
+	return err
+}
+
+const getOtpGuard = `-- name: GetOtpGuard :one
+SELECT user_id, lockouts, locked_until, last_sent_at, sends_in_window, window_started_at, created_at, updated_at FROM otp_guards
+WHERE user_id = $1
+`
+
+func (q *Queries) GetOtpGuard(ctx context.Context, userID uuid.UUID) (OtpGuard, error) {
+	row := q.db.QueryRow(ctx, getOtpGuard, userID)
+	var i OtpGuard
+	err := row.Scan(
+		&i.UserID,
+		&i.Lockouts,
+		&i.LockedUntil,
+		&i.LastSentAt,
+		&i.SendsInWindow,
+		&i.WindowStartedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const lockOtpGuard = `-- name: LockOtpGuard :one
+INSERT INTO otp_guards AS g (user_id, lockouts, locked_until)
+VALUES ($1, 1, now() + ($2::INTERVAL[])[1])
+ON CONFLICT (user_id) DO UPDATE
+SET lockouts = g.lockouts + 1,
+    locked_until = now() + ($2::INTERVAL[])[
+        LEAST(g.lockouts + 1, cardinality($2::INTERVAL[]))
+    ]
+RETURNING locked_until
+`
+
+type LockOtpGuardParams struct {
+	UserID   uuid.UUID         `json:"user_id"`
+	Schedule []pgtype.Interval `json:"schedule"`
+}
+
+// Starts a lockout after a code's attempts ran out. The n-th lockout in a row lasts schedule[n],
+// or the last entry once the schedule is used up (see otp.lockoutSchedule)
+func (q *Queries) LockOtpGuard(ctx context.Context, arg LockOtpGuardParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, lockOtpGuard, arg.UserID, arg.Schedule)
+	var locked_until pgtype.Timestamptz
+	err := row.Scan(&locked_until)
+	return locked_until, err
+}
+
+const reserveOtpSend = `-- name: ReserveOtpSend :one
+
+INSERT INTO otp_guards AS g (user_id, last_sent_at, sends_in_window, window_started_at)
+VALUES ($1, now(), 1, now())
+ON CONFLICT (user_id) DO UPDATE
+SET last_sent_at = now(),
+    sends_in_window = CASE
+        WHEN g.window_started_at IS NULL OR g.window_started_at <= now() - $2::INTERVAL THEN 1
+        ELSE g.sends_in_window + 1
+    END,
+    window_started_at = CASE
+        WHEN g.window_started_at IS NULL OR g.window_started_at <= now() - $2::INTERVAL THEN now()
+        ELSE g.window_started_at
+    END
+WHERE (g.locked_until IS NULL OR g.locked_until <= now())
+  AND (g.last_sent_at IS NULL OR g.last_sent_at <= now() - $3::INTERVAL)
+  AND (g.window_started_at IS NULL
+       OR g.window_started_at <= now() - $2::INTERVAL
+       OR g.sends_in_window < $4::INTEGER)
+RETURNING user_id
+`
+
+type ReserveOtpSendParams struct {
+	UserID     uuid.UUID       `json:"user_id"`
+	SendWindow pgtype.Interval `json:"send_window"`
+	Cooldown   pgtype.Interval `json:"cooldown"`
+	MaxSends   int32           `json:"max_sends"`
+}
+
+// ===========================================
+// OTP guard queries for sqlc
+// ===========================================
+// Records a send. Returns no row while the user is locked out, inside the resend cooldown, or
+// out of sends for the window.
+func (q *Queries) ReserveOtpSend(ctx context.Context, arg ReserveOtpSendParams) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, reserveOtpSend,
+		arg.UserID,
+		arg.SendWindow,
+		arg.Cooldown,
+		arg.MaxSends,
+	)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
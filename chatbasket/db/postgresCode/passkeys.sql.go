@@ -0,0 +1,279 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: passkeys.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createPasskeyCredential = `-- name: CreatePasskeyCredential :one
+
+INSERT INTO passkey_credentials (
+    id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, flags, name
+)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, flags, name, last_used_at, created_at, updated_at
+`
+
+type CreatePasskeyCredentialParams struct {
+	ID              uuid.UUID `json:"id"`
+	UserID          uuid.UUID `json:"user_id"`
+	CredentialID    []byte    `json:"credential_id"`
+	PublicKey       []byte    `json:"public_key"`
+	AttestationType string    `json:"attestation_type"`
+	Transports      []string  `json:"transports"`
+	Aaguid          []byte    `json:"aaguid"`
+	SignCount       int64     `json:"sign_count"`
+	Flags           int16     `json:"flags"`
+	Name            string    `json:"name"`
+}
+
+// ===========================================
+// Passkey (WebAuthn) queries for sqlc
+// ===========================================
+func (q *Queries) CreatePasskeyCredential(ctx context.Context, arg CreatePasskeyCredentialParams) (PasskeyCredential, error) {
+	row := q.db.QueryRow(ctx, createPasskeyCredential,
+		arg.ID,
+		arg.UserID,
+		arg.CredentialID,
+		arg.PublicKey,
+		arg.AttestationType,
+		arg.Transports,
+		arg.Aaguid,
+		arg.SignCount,
+		arg.Flags,
+		arg.Name,
+	)
+	var i PasskeyCredential
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.AttestationType,
+		&i.Transports,
+		&i.Aaguid,
+		&i.SignCount,
+		&i.Flags,
+		&i.Name,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWebauthnChallenge = `-- name: CreateWebauthnChallenge :exec
+INSERT INTO webauthn_challenges (id, user_id, ceremony, session_data, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    now() + $5::INTERVAL
+)
+`
+
+type CreateWebauthnChallengeParams struct {
+	ID          uuid.UUID       `json:"id"`
+	UserID      pgtype.UUID     `json:"user_id"`
+	Ceremony    string          `json:"ceremony"`
+	SessionData []byte          `json:"session_data"`
+	Ttl         pgtype.Interval `json:"ttl"`
+}
+
+func (q *Queries) CreateWebauthnChallenge(ctx context.Context, arg CreateWebauthnChallengeParams) error {
+	_, err := q.db.Exec(ctx, createWebauthnChallenge,
+		arg.ID,
+		arg.UserID,
+		arg.Ceremony,
+		arg.SessionData,
+		arg.Ttl,
+	)
+	return err
+}
+
+const deletePasskeyCredential = `-- name: DeletePasskeyCredential :execrows
+DELETE FROM passkey_credentials
+WHERE id = $1
+  AND user_id = $2
+`
+
+type DeletePasskeyCredentialParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeletePasskeyCredential(ctx context.Context, arg DeletePasskeyCredentialParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePasskeyCredential, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteStaleWebauthnChallenges = `-- name: DeleteStaleWebauthnChallenges :execrows
+DELETE FROM webauthn_challenges
+WHERE expires_at < now() - $1::INTERVAL
+`
+
+func (q *Queries) DeleteStaleWebauthnChallenges(ctx context.Context, grace pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleWebauthnChallenges, grace)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUserPasskeyCredentials = `-- name: DeleteUserPasskeyCredentials :exec
+DELETE FROM passkey_credentials WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserPasskeyCredentials(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserPasskeyCredentials, userID)
+	return err
+}
+
+const deleteUserWebauthnChallenges = `-- name: DeleteUserWebauthnChallenges :exec
+DELETE FROM webauthn_challenges WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserWebauthnChallenges(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserWebauthnChallenges, userID)
+	return err
+}
+
+const getPasskeyCredentialByCredentialId = `-- name: GetPasskeyCredentialByCredentialId :one
+SELECT id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, flags, name, last_used_at, created_at, updated_at FROM passkey_credentials WHERE credential_id = $1
+`
+
+func (q *Queries) GetPasskeyCredentialByCredentialId(ctx context.Context, credentialID []byte) (PasskeyCredential, error) {
+	row := q.db.QueryRow(ctx, getPasskeyCredentialByCredentialId, credentialID)
+	var i PasskeyCredential
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CredentialID,
+		&i.PublicKey,
+		&i.AttestationType,
+		&i.Transports,
+		&i.Aaguid,
+		&i.SignCount,
+		&i.Flags,
+		&i.Name,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listPasskeyCredentials = `-- name: ListPasskeyCredentials :many
+SELECT id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, flags, name, last_used_at, created_at, updated_at FROM passkey_credentials
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListPasskeyCredentials(ctx context.Context, userID uuid.UUID) ([]PasskeyCredential, error) {
+	rows, err := q.db.Query(ctx, listPasskeyCredentials, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PasskeyCredential
+	for rows.Next() {
+		var i PasskeyCredential
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CredentialID,
+			&i.PublicKey,
+			&i.AttestationType,
+			&i.Transports,
+			&i.Aaguid,
+			&i.SignCount,
+			&i.Flags,
+			&i.Name,
+			&i.LastUsedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const takeWebauthnChallenge = `-- name: TakeWebauthnChallenge :one
+DELETE FROM webauthn_challenges
+WHERE id = $1
+  AND ceremony = $2
+  AND expires_at > now()
+RETURNING id, user_id, ceremony, session_data, expires_at, created_at, updated_at
+`
+
+type TakeWebauthnChallengeParams struct {
+	ID       uuid.UUID `json:"id"`
+	Ceremony string    `json:"ceremony"`
+}
+
+// Uses a challenge up. Returns no row when it does not exist, belongs to another ceremony or
+// has expired.
+func (q *Queries) TakeWebauthnChallenge(ctx context.Context, arg TakeWebauthnChallengeParams) (WebauthnChallenge, error) {
+	row := q.db.QueryRow(ctx, takeWebauthnChallenge, arg.ID, arg.Ceremony)
+	var i WebauthnChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Ceremony,
+		&i.SessionData,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updatePasskeySignCount = `-- name: UpdatePasskeySignCount :execrows
+UPDATE passkey_credentials
+SET sign_count = $1,
+    last_used_at = now()
+WHERE id = $2
+  AND ($1::BIGINT > sign_count OR ($1::BIGINT = 0 AND sign_count = 0))
+`
+
+type UpdatePasskeySignCountParams struct {
+	SignCount int64     `json:"sign_count"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Stores the counter of a successful assertion. No row is affected when the counter did not
+// move forward, e.g. a replayed assertion or a cloned authenticator.
+func (q *Queries) UpdatePasskeySignCount(ctx context.Context, arg UpdatePasskeySignCountParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updatePasskeySignCount, arg.SignCount, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
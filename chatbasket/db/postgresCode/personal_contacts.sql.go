@@ -714,6 +714,76 @@ func (q *Queries) IsEitherBlocked(ctx context.Context, arg IsEitherBlockedParams
 	return column_1, err
 }
 
+const listPlaintextContactNicknames = `-- name: ListPlaintextContactNicknames :many
+SELECT owner_user_id, contact_user_id, nickname
+FROM user_contacts
+WHERE nickname IS NOT NULL
+  AND nickname NOT LIKE 'enc1.%'
+LIMIT $1
+`
+
+type ListPlaintextContactNicknamesRow struct {
+	OwnerUserID   uuid.UUID `json:"owner_user_id"`
+	ContactUserID uuid.UUID `json:"contact_user_id"`
+	Nickname      *string   `json:"nickname"`
+}
+
+// Returns contact nicknames not yet encrypted at field level (for the backfill)
+func (q *Queries) ListPlaintextContactNicknames(ctx context.Context, limit int32) ([]ListPlaintextContactNicknamesRow, error) {
+	rows, err := q.db.Query(ctx, listPlaintextContactNicknames, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPlaintextContactNicknamesRow
+	for rows.Next() {
+		var i ListPlaintextContactNicknamesRow
+		if err := rows.Scan(&i.OwnerUserID, &i.ContactUserID, &i.Nickname); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlaintextContactRequestNicknames = `-- name: ListPlaintextContactRequestNicknames :many
+SELECT id, requester_user_id, nickname
+FROM contact_requests
+WHERE nickname IS NOT NULL
+  AND nickname NOT LIKE 'enc1.%'
+LIMIT $1
+`
+
+type ListPlaintextContactRequestNicknamesRow struct {
+	ID              uuid.UUID `json:"id"`
+	RequesterUserID uuid.UUID `json:"requester_user_id"`
+	Nickname        *string   `json:"nickname"`
+}
+
+// Returns contact request nicknames not yet encrypted at field level (for the backfill)
+func (q *Queries) ListPlaintextContactRequestNicknames(ctx context.Context, limit int32) ([]ListPlaintextContactRequestNicknamesRow, error) {
+	rows, err := q.db.Query(ctx, listPlaintextContactRequestNicknames, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPlaintextContactRequestNicknamesRow
+	for rows.Next() {
+		var i ListPlaintextContactRequestNicknamesRow
+		if err := rows.Scan(&i.ID, &i.RequesterUserID, &i.Nickname); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const rejectContactRequest = `-- name: RejectContactRequest :one
 WITH updated AS (
     UPDATE contact_requests AS cr
@@ -797,3 +867,54 @@ func (q *Queries) UpdateContactNickname(ctx context.Context, arg UpdateContactNi
 	err := row.Scan(&updated)
 	return updated, err
 }
+
+const updateContactNicknameCipher = `-- name: UpdateContactNicknameCipher :execrows
+UPDATE user_contacts
+SET nickname = $1
+WHERE owner_user_id = $2
+  AND contact_user_id = $3
+  AND nickname = $4
+`
+
+type UpdateContactNicknameCipherParams struct {
+	NewNickname   *string   `json:"new_nickname"`
+	OwnerUserID   uuid.UUID `json:"owner_user_id"`
+	ContactUserID uuid.UUID `json:"contact_user_id"`
+	OldNickname   *string   `json:"old_nickname"`
+}
+
+// Swaps the contact nickname only if it has not changed since it was read
+func (q *Queries) UpdateContactNicknameCipher(ctx context.Context, arg UpdateContactNicknameCipherParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateContactNicknameCipher,
+		arg.NewNickname,
+		arg.OwnerUserID,
+		arg.ContactUserID,
+		arg.OldNickname,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateContactRequestNicknameCipher = `-- name: UpdateContactRequestNicknameCipher :execrows
+UPDATE contact_requests
+SET nickname = $1
+WHERE id = $2
+  AND nickname = $3
+`
+
+type UpdateContactRequestNicknameCipherParams struct {
+	NewNickname *string   `json:"new_nickname"`
+	ID          uuid.UUID `json:"id"`
+	OldNickname *string   `json:"old_nickname"`
+}
+
+// Swaps the contact request nickname only if it has not changed since it was read
+func (q *Queries) UpdateContactRequestNicknameCipher(ctx context.Context, arg UpdateContactRequestNicknameCipherParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateContactRequestNicknameCipher, arg.NewNickname, arg.ID, arg.OldNickname)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
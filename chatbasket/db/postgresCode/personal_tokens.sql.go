@@ -0,0 +1,54 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: personal_tokens.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deactivateSessionTokens = `-- name: DeactivateSessionTokens :execrows
+
+UPDATE tokens
+SET is_active = FALSE
+WHERE user_id = $1
+  AND sha256_hex_session_id = $2
+  AND is_active = TRUE
+`
+
+type DeactivateSessionTokensParams struct {
+	UserID             uuid.UUID `json:"user_id"`
+	Sha256HexSessionID string    `json:"sha256_hex_session_id"`
+}
+
+// ===========================================
+// Tokens Queries for sqlc
+// ===========================================
+// Stops push notifications to the device of a signed-out session
+func (q *Queries) DeactivateSessionTokens(ctx context.Context, arg DeactivateSessionTokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deactivateSessionTokens, arg.UserID, arg.Sha256HexSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deactivateUserTokens = `-- name: DeactivateUserTokens :execrows
+UPDATE tokens
+SET is_active = FALSE
+WHERE user_id = $1
+  AND is_active = TRUE
+`
+
+// Stops push notifications to every device of the user (sign-out everywhere)
+func (q *Queries) DeactivateUserTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deactivateUserTokens, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -137,6 +137,20 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 	return i, err
 }
 
+const deleteAloneUsername = `-- name: DeleteAloneUsername :execrows
+DELETE FROM alone_username
+WHERE username = $1
+`
+
+// Frees a plaintext username once its owner is deleted
+func (q *Queries) DeleteAloneUsername(ctx context.Context, username string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteAloneUsername, username)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteAvatar = `-- name: DeleteAvatar :exec
 DELETE FROM avatars
 WHERE user_id = $1 AND file_id = $1::TEXT
@@ -148,6 +162,20 @@ func (q *Queries) DeleteAvatar(ctx context.Context, userID uuid.UUID) error {
 	return err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+// Deletes the user; avatars, contacts, restrictions, blocks, requests and tokens cascade
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getUserCoreProfile = `-- name: GetUserCoreProfile :one
 SELECT id, name, bio, profile_type, is_admin_blocked, admin_block_reason, hmac_sha256_hex_username, b64_cipher_chacha20poly1305_username, created_at, updated_at FROM users
 WHERE id = $1
@@ -226,6 +254,140 @@ func (q *Queries) GetUserProfile(ctx context.Context, id uuid.UUID) (GetUserProf
 	return i, err
 }
 
+const getUserProfileCard = `-- name: GetUserProfileCard :one
+SELECT
+    u.id,
+    u.name,
+    u.bio,
+    u.profile_type,
+    u.is_admin_blocked,
+    u.b64_cipher_chacha20poly1305_username AS username,
+
+    -- Raw avatar data (Go applies visibility logic)
+    a.file_id AS avatar_file_id,
+    a.token_id AS avatar_token_id,
+    a.token_secret AS avatar_token_secret,
+    a.token_expiry AS avatar_token_expiry,
+    a.updated_at AS avatar_updated_at,
+
+    -- Global restriction flags and the viewer's exemptions
+    COALESCE(ugr.restrict_profile, FALSE) AS global_restrict_profile,
+    COALESCE(ugr.restrict_avatar, FALSE) AS global_restrict_avatar,
+    COALESCE(ugre.exception_profile, FALSE) AS exception_global_profile,
+    COALESCE(ugre.exception_avatar, FALSE) AS exception_global_avatar,
+
+    -- User-level restriction flags against the viewer
+    COALESCE(ur.restrict_profile, FALSE) AS user_restrict_profile,
+    COALESCE(ur.restrict_avatar, FALSE) AS user_restrict_avatar,
+
+    -- Relationship state between viewer and user
+    my_uc.nickname AS my_nickname,
+    (my_uc.owner_user_id IS NOT NULL)::BOOLEAN AS is_contact,
+    EXISTS(
+        SELECT 1 FROM user_contacts their_uc
+        WHERE their_uc.owner_user_id = u.id AND their_uc.contact_user_id = $1
+    ) AS added_you,
+    EXISTS(
+        SELECT 1 FROM contact_requests cr
+        WHERE cr.requester_user_id = $1 AND cr.receiver_user_id = u.id AND cr.status = 'pending'
+    ) AS request_sent,
+    EXISTS(
+        SELECT 1 FROM contact_requests cr
+        WHERE cr.requester_user_id = u.id AND cr.receiver_user_id = $1 AND cr.status = 'pending'
+    ) AS request_received,
+    EXISTS(
+        SELECT 1 FROM user_blocks ub
+        WHERE ub.blocker_user_id = $1 AND ub.blocked_user_id = u.id
+    ) AS blocked_by_you,
+    EXISTS(
+        SELECT 1 FROM user_blocks ub
+        WHERE ub.blocker_user_id = u.id AND ub.blocked_user_id = $1
+    ) AS blocked_you
+
+FROM users u
+LEFT JOIN avatars a
+    ON u.id = a.user_id
+    AND a.avatar_type = 'profile'
+LEFT JOIN user_global_restrictions ugr
+    ON u.id = ugr.user_id
+LEFT JOIN user_global_restriction_exemptions ugre
+    ON u.id = ugre.user_id
+    AND ugre.exempted_user_id = $1
+LEFT JOIN user_restrictions ur
+    ON u.id = ur.user_id
+    AND ur.restricted_user_id = $1
+LEFT JOIN user_contacts my_uc
+    ON my_uc.owner_user_id = $1
+    AND my_uc.contact_user_id = u.id
+WHERE u.id = $2
+`
+
+type GetUserProfileCardParams struct {
+	ViewerID uuid.UUID `json:"viewer_id"`
+	ID       uuid.UUID `json:"id"`
+}
+
+type GetUserProfileCardRow struct {
+	ID                     uuid.UUID          `json:"id"`
+	Name                   string             `json:"name"`
+	Bio                    *string            `json:"bio"`
+	ProfileType            string             `json:"profile_type"`
+	IsAdminBlocked         bool               `json:"is_admin_blocked"`
+	Username               string             `json:"username"`
+	AvatarFileID           *string            `json:"avatar_file_id"`
+	AvatarTokenID          *string            `json:"avatar_token_id"`
+	AvatarTokenSecret      *string            `json:"avatar_token_secret"`
+	AvatarTokenExpiry      pgtype.Timestamptz `json:"avatar_token_expiry"`
+	AvatarUpdatedAt        pgtype.Timestamptz `json:"avatar_updated_at"`
+	GlobalRestrictProfile  bool               `json:"global_restrict_profile"`
+	GlobalRestrictAvatar   bool               `json:"global_restrict_avatar"`
+	ExceptionGlobalProfile bool               `json:"exception_global_profile"`
+	ExceptionGlobalAvatar  bool               `json:"exception_global_avatar"`
+	UserRestrictProfile    bool               `json:"user_restrict_profile"`
+	UserRestrictAvatar     bool               `json:"user_restrict_avatar"`
+	MyNickname             *string            `json:"my_nickname"`
+	IsContact              bool               `json:"is_contact"`
+	AddedYou               bool               `json:"added_you"`
+	RequestSent            bool               `json:"request_sent"`
+	RequestReceived        bool               `json:"request_received"`
+	BlockedByYou           bool               `json:"blocked_by_you"`
+	BlockedYou             bool               `json:"blocked_you"`
+}
+
+// Another user's profile card with raw restriction and relationship data for Go processing
+// id = the user being viewed, viewer_id = the viewer
+func (q *Queries) GetUserProfileCard(ctx context.Context, arg GetUserProfileCardParams) (GetUserProfileCardRow, error) {
+	row := q.db.QueryRow(ctx, getUserProfileCard, arg.ViewerID, arg.ID)
+	var i GetUserProfileCardRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Bio,
+		&i.ProfileType,
+		&i.IsAdminBlocked,
+		&i.Username,
+		&i.AvatarFileID,
+		&i.AvatarTokenID,
+		&i.AvatarTokenSecret,
+		&i.AvatarTokenExpiry,
+		&i.AvatarUpdatedAt,
+		&i.GlobalRestrictProfile,
+		&i.GlobalRestrictAvatar,
+		&i.ExceptionGlobalProfile,
+		&i.ExceptionGlobalAvatar,
+		&i.UserRestrictProfile,
+		&i.UserRestrictAvatar,
+		&i.MyNickname,
+		&i.IsContact,
+		&i.AddedYou,
+		&i.RequestSent,
+		&i.RequestReceived,
+		&i.BlockedByYou,
+		&i.BlockedYou,
+	)
+	return i, err
+}
+
 const isUserAdminBlocked = `-- name: IsUserAdminBlocked :one
 SELECT EXISTS(
     SELECT 1 FROM users
@@ -274,6 +436,64 @@ func (q *Queries) IsUserProfilePicExists(ctx context.Context, id uuid.UUID) (boo
 	return exists, err
 }
 
+const listAvatarsWithExpiringTokens = `-- name: ListAvatarsWithExpiringTokens :many
+SELECT id, user_id, file_id, token_id, token_expiry
+FROM avatars
+WHERE token_expiry IS NOT NULL
+  AND token_expiry < $1
+  AND (token_expiry, id) > ($2::TIMESTAMPTZ, $3::UUID)
+ORDER BY token_expiry, id
+LIMIT $4
+`
+
+type ListAvatarsWithExpiringTokensParams struct {
+	ExpiresBefore pgtype.Timestamptz `json:"expires_before"`
+	AfterExpiry   pgtype.Timestamptz `json:"after_expiry"`
+	AfterID       uuid.UUID          `json:"after_id"`
+	BatchSize     int32              `json:"batch_size"`
+}
+
+type ListAvatarsWithExpiringTokensRow struct {
+	ID          uuid.UUID          `json:"id"`
+	UserID      uuid.UUID          `json:"user_id"`
+	FileID      string             `json:"file_id"`
+	TokenID     *string            `json:"token_id"`
+	TokenExpiry pgtype.Timestamptz `json:"token_expiry"`
+}
+
+// Returns avatars whose storage token expires before expires_before, ordered for keyset pagination
+// on (token_expiry, id). Served by idx_avatars_token_expiry.
+func (q *Queries) ListAvatarsWithExpiringTokens(ctx context.Context, arg ListAvatarsWithExpiringTokensParams) ([]ListAvatarsWithExpiringTokensRow, error) {
+	rows, err := q.db.Query(ctx, listAvatarsWithExpiringTokens,
+		arg.ExpiresBefore,
+		arg.AfterExpiry,
+		arg.AfterID,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAvatarsWithExpiringTokensRow
+	for rows.Next() {
+		var i ListAvatarsWithExpiringTokensRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.FileID,
+			&i.TokenID,
+			&i.TokenExpiry,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsersAfter = `-- name: ListUsersAfter :many
 SELECT id, name, bio, profile_type, is_admin_blocked, admin_block_reason, hmac_sha256_hex_username, b64_cipher_chacha20poly1305_username, created_at, updated_at
 FROM users
@@ -319,6 +539,112 @@ func (q *Queries) ListUsersAfter(ctx context.Context, arg ListUsersAfterParams)
 	return items, nil
 }
 
+const listUsersWithLegacyUsernameCipher = `-- name: ListUsersWithLegacyUsernameCipher :many
+SELECT id, b64_cipher_chacha20poly1305_username
+FROM users
+WHERE id > $1
+  AND b64_cipher_chacha20poly1305_username NOT LIKE 'v2.%'
+ORDER BY id
+LIMIT $2
+`
+
+type ListUsersWithLegacyUsernameCipherParams struct {
+	ID    uuid.UUID `json:"id"`
+	Limit int32     `json:"limit"`
+}
+
+type ListUsersWithLegacyUsernameCipherRow struct {
+	ID                                uuid.UUID `json:"id"`
+	B64CipherChacha20poly1305Username string    `json:"b64_cipher_chacha20poly1305_username"`
+}
+
+// Returns users whose encrypted username still uses the v1 UUID-derived nonce (keyset pagination by id)
+func (q *Queries) ListUsersWithLegacyUsernameCipher(ctx context.Context, arg ListUsersWithLegacyUsernameCipherParams) ([]ListUsersWithLegacyUsernameCipherRow, error) {
+	rows, err := q.db.Query(ctx, listUsersWithLegacyUsernameCipher, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersWithLegacyUsernameCipherRow
+	for rows.Next() {
+		var i ListUsersWithLegacyUsernameCipherRow
+		if err := rows.Scan(&i.ID, &i.B64CipherChacha20poly1305Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersWithPlaintextBio = `-- name: ListUsersWithPlaintextBio :many
+SELECT id, bio
+FROM users
+WHERE bio IS NOT NULL
+  AND bio NOT LIKE 'enc1.%'
+LIMIT $1
+`
+
+type ListUsersWithPlaintextBioRow struct {
+	ID  uuid.UUID `json:"id"`
+	Bio *string   `json:"bio"`
+}
+
+// Returns users whose bio is not yet encrypted at field level (for the backfill)
+func (q *Queries) ListUsersWithPlaintextBio(ctx context.Context, limit int32) ([]ListUsersWithPlaintextBioRow, error) {
+	rows, err := q.db.Query(ctx, listUsersWithPlaintextBio, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersWithPlaintextBioRow
+	for rows.Next() {
+		var i ListUsersWithPlaintextBioRow
+		if err := rows.Scan(&i.ID, &i.Bio); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rotateAvatarToken = `-- name: RotateAvatarToken :execrows
+UPDATE avatars
+SET token_id = $1,
+    token_secret = $2,
+    token_expiry = $3
+WHERE id = $4
+  AND token_id IS NOT DISTINCT FROM $5
+`
+
+type RotateAvatarTokenParams struct {
+	NewTokenID     *string            `json:"new_token_id"`
+	NewTokenSecret *string            `json:"new_token_secret"`
+	NewTokenExpiry pgtype.Timestamptz `json:"new_token_expiry"`
+	ID             uuid.UUID          `json:"id"`
+	OldTokenID     *string            `json:"old_token_id"`
+}
+
+// Replaces the avatar's storage token only if it still holds the token that was renewed
+func (q *Queries) RotateAvatarToken(ctx context.Context, arg RotateAvatarTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rotateAvatarToken,
+		arg.NewTokenID,
+		arg.NewTokenSecret,
+		arg.NewTokenExpiry,
+		arg.ID,
+		arg.OldTokenID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateAvatarTokens = `-- name: UpdateAvatarTokens :one
 UPDATE avatars
 SET token_id = $2, token_secret = $3, token_expiry = $4
@@ -356,6 +682,28 @@ func (q *Queries) UpdateAvatarTokens(ctx context.Context, arg UpdateAvatarTokens
 	return i, err
 }
 
+const updateUserBioCipher = `-- name: UpdateUserBioCipher :execrows
+UPDATE users
+SET bio = $1
+WHERE id = $2
+  AND bio = $3
+`
+
+type UpdateUserBioCipherParams struct {
+	NewBio *string   `json:"new_bio"`
+	ID     uuid.UUID `json:"id"`
+	OldBio *string   `json:"old_bio"`
+}
+
+// Swaps the bio only if it has not changed since it was read
+func (q *Queries) UpdateUserBioCipher(ctx context.Context, arg UpdateUserBioCipherParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserBioCipher, arg.NewBio, arg.ID, arg.OldBio)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateUserProfile = `-- name: UpdateUserProfile :one
 UPDATE users SET
     name = COALESCE($2, name),
@@ -395,3 +743,25 @@ func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfilePa
 	)
 	return i, err
 }
+
+const updateUserUsernameCipher = `-- name: UpdateUserUsernameCipher :execrows
+UPDATE users
+SET b64_cipher_chacha20poly1305_username = $1
+WHERE id = $2
+  AND b64_cipher_chacha20poly1305_username = $3
+`
+
+type UpdateUserUsernameCipherParams struct {
+	NewCipher string    `json:"new_cipher"`
+	ID        uuid.UUID `json:"id"`
+	OldCipher string    `json:"old_cipher"`
+}
+
+// Swaps the encrypted username only if it has not changed since it was read
+func (q *Queries) UpdateUserUsernameCipher(ctx context.Context, arg UpdateUserUsernameCipherParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserUsernameCipher, arg.NewCipher, arg.ID, arg.OldCipher)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,221 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: refresh_tokens.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createRefreshToken = `-- name: CreateRefreshToken :exec
+
+INSERT INTO refresh_tokens (id, family_id, user_id, sha256_hex_session_id, b64_cipher_session_id, token_hash, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+`
+
+type CreateRefreshTokenParams struct {
+	ID                 uuid.UUID          `json:"id"`
+	FamilyID           uuid.UUID          `json:"family_id"`
+	UserID             uuid.UUID          `json:"user_id"`
+	Sha256HexSessionID string             `json:"sha256_hex_session_id"`
+	B64CipherSessionID string             `json:"b64_cipher_session_id"`
+	TokenHash          string             `json:"token_hash"`
+	ExpiresAt          pgtype.Timestamptz `json:"expires_at"`
+}
+
+// ===========================================
+// Refresh token queries for sqlc
+// ===========================================
+func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error {
+	_, err := q.db.Exec(ctx, createRefreshToken,
+		arg.ID,
+		arg.FamilyID,
+		arg.UserID,
+		arg.Sha256HexSessionID,
+		arg.B64CipherSessionID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const deleteStaleRefreshTokens = `-- name: DeleteStaleRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < now() - $1::interval
+   OR revoked_at < now() - $1::interval
+`
+
+// Rotated tokens are kept until they expire so their reuse is still detected
+func (q *Queries) DeleteStaleRefreshTokens(ctx context.Context, grace pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleRefreshTokens, grace)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUserRefreshTokens = `-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserRefreshTokens, userID)
+	return err
+}
+
+const getRefreshTokenByHash = `-- name: GetRefreshTokenByHash :one
+SELECT id, family_id, user_id, sha256_hex_session_id, b64_cipher_session_id, token_hash, expires_at, rotated_at, replaced_by, revoked_at, created_at, updated_at FROM refresh_tokens WHERE token_hash = $1
+`
+
+func (q *Queries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRow(ctx, getRefreshTokenByHash, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.FamilyID,
+		&i.UserID,
+		&i.Sha256HexSessionID,
+		&i.B64CipherSessionID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.RotatedAt,
+		&i.ReplacedBy,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const isSessionRefreshTokenLive = `-- name: IsSessionRefreshTokenLive :one
+SELECT EXISTS(
+    SELECT 1 FROM refresh_tokens
+    WHERE user_id = $1
+      AND sha256_hex_session_id = $2
+      AND revoked_at IS NULL
+      AND expires_at > now()
+)
+`
+
+type IsSessionRefreshTokenLiveParams struct {
+	UserID             uuid.UUID `json:"user_id"`
+	Sha256HexSessionID string    `json:"sha256_hex_session_id"`
+}
+
+// Reports whether a session still has a refresh token that is neither revoked nor expired; the
+// session middleware rejects the access tokens of sessions without one
+func (q *Queries) IsSessionRefreshTokenLive(ctx context.Context, arg IsSessionRefreshTokenLiveParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isSessionRefreshTokenLive, arg.UserID, arg.Sha256HexSessionID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE family_id = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeRefreshTokenFamily, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeSessionRefreshTokens = `-- name: RevokeSessionRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE user_id = $1
+  AND sha256_hex_session_id = $2
+  AND revoked_at IS NULL
+`
+
+type RevokeSessionRefreshTokensParams struct {
+	UserID             uuid.UUID `json:"user_id"`
+	Sha256HexSessionID string    `json:"sha256_hex_session_id"`
+}
+
+func (q *Queries) RevokeSessionRefreshTokens(ctx context.Context, arg RevokeSessionRefreshTokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeSessionRefreshTokens, arg.UserID, arg.Sha256HexSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUnusedRefreshToken = `-- name: RevokeUnusedRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE id = $1
+  AND rotated_at IS NULL
+  AND revoked_at IS NULL
+`
+
+// Revokes a successor that was never presented, when its predecessor is retried; no row is
+// affected once it was used
+func (q *Queries) RevokeUnusedRefreshToken(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUnusedRefreshToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE user_id = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserRefreshTokens, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = COALESCE(rotated_at, now()),
+    replaced_by = $1
+WHERE id = $2
+  AND revoked_at IS NULL
+  AND expires_at > now()
+  AND replaced_by IS NOT DISTINCT FROM $3
+`
+
+type RotateRefreshTokenParams struct {
+	ReplacedBy     pgtype.UUID `json:"replaced_by"`
+	ID             uuid.UUID   `json:"id"`
+	PriorSuccessor pgtype.UUID `json:"prior_successor"`
+}
+
+// Marks a token rotated and links the successor issued for it. prior_successor must still be the
+// linked one (NULL before the first rotation), so only one of concurrent refreshes wins.
+// rotated_at keeps the time of the first rotation
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rotateRefreshToken, arg.ReplacedBy, arg.ID, arg.PriorSuccessor)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,375 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: reports.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createReport = `-- name: CreateReport :one
+
+INSERT INTO reports (id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+ON CONFLICT (reporter_user_id, target_type, target_id) WHERE status IN ('open', 'in_review') DO NOTHING
+RETURNING id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence, status, resolution_note, handled_by, resolved_at, created_at, updated_at
+`
+
+type CreateReportParams struct {
+	ID                uuid.UUID   `json:"id"`
+	ReporterUserID    uuid.UUID   `json:"reporter_user_id"`
+	TargetType        string      `json:"target_type"`
+	TargetUserID      pgtype.UUID `json:"target_user_id"`
+	TargetID          string      `json:"target_id"`
+	Category          string      `json:"category"`
+	Details           *string     `json:"details"`
+	B64CipherEvidence string      `json:"b64_cipher_evidence"`
+}
+
+// ===========================================
+// Report queries for sqlc
+// ===========================================
+// Returns no row when the reporter already has an open report on the target
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRow(ctx, createReport,
+		arg.ID,
+		arg.ReporterUserID,
+		arg.TargetType,
+		arg.TargetUserID,
+		arg.TargetID,
+		arg.Category,
+		arg.Details,
+		arg.B64CipherEvidence,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterUserID,
+		&i.TargetType,
+		&i.TargetUserID,
+		&i.TargetID,
+		&i.Category,
+		&i.Details,
+		&i.B64CipherEvidence,
+		&i.Status,
+		&i.ResolutionNote,
+		&i.HandledBy,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getReport = `-- name: GetReport :one
+SELECT id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence, status, resolution_note, handled_by, resolved_at, created_at, updated_at FROM reports WHERE id = $1
+`
+
+func (q *Queries) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRow(ctx, getReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterUserID,
+		&i.TargetType,
+		&i.TargetUserID,
+		&i.TargetID,
+		&i.Category,
+		&i.Details,
+		&i.B64CipherEvidence,
+		&i.Status,
+		&i.ResolutionNote,
+		&i.HandledBy,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listReportQueue = `-- name: ListReportQueue :many
+SELECT r.id, r.reporter_user_id, r.target_type, r.target_user_id, r.target_id, r.category, r.details, r.b64_cipher_evidence, r.status, r.resolution_note, r.handled_by, r.resolved_at, r.created_at, r.updated_at,
+    (SELECT count(*) FROM reports o
+     WHERE o.target_user_id = r.target_user_id
+       AND o.status IN ('open', 'in_review'))::bigint AS open_reports_on_target
+FROM reports r
+WHERE ($1::text IS NULL OR r.status = $1)
+  AND ($2::text IS NULL OR r.category = $2)
+  AND ($3::uuid IS NULL OR r.target_user_id = $3)
+  AND ($4::uuid IS NULL OR r.id > $4)
+ORDER BY r.id
+LIMIT $5
+`
+
+type ListReportQueueParams struct {
+	Status       *string     `json:"status"`
+	Category     *string     `json:"category"`
+	TargetUserID pgtype.UUID `json:"target_user_id"`
+	AfterID      pgtype.UUID `json:"after_id"`
+	MaxResults   int32       `json:"max_results"`
+}
+
+type ListReportQueueRow struct {
+	ID                  uuid.UUID          `json:"id"`
+	ReporterUserID      uuid.UUID          `json:"reporter_user_id"`
+	TargetType          string             `json:"target_type"`
+	TargetUserID        pgtype.UUID        `json:"target_user_id"`
+	TargetID            string             `json:"target_id"`
+	Category            string             `json:"category"`
+	Details             *string            `json:"details"`
+	B64CipherEvidence   string             `json:"b64_cipher_evidence"`
+	Status              string             `json:"status"`
+	ResolutionNote      *string            `json:"resolution_note"`
+	HandledBy           pgtype.UUID        `json:"handled_by"`
+	ResolvedAt          pgtype.Timestamptz `json:"resolved_at"`
+	CreatedAt           pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt           pgtype.Timestamptz `json:"updated_at"`
+	OpenReportsOnTarget int64              `json:"open_reports_on_target"`
+}
+
+// Oldest first, so the queue is worked in filing order; every filter is optional
+func (q *Queries) ListReportQueue(ctx context.Context, arg ListReportQueueParams) ([]ListReportQueueRow, error) {
+	rows, err := q.db.Query(ctx, listReportQueue,
+		arg.Status,
+		arg.Category,
+		arg.TargetUserID,
+		arg.AfterID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReportQueueRow
+	for rows.Next() {
+		var i ListReportQueueRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReporterUserID,
+			&i.TargetType,
+			&i.TargetUserID,
+			&i.TargetID,
+			&i.Category,
+			&i.Details,
+			&i.B64CipherEvidence,
+			&i.Status,
+			&i.ResolutionNote,
+			&i.HandledBy,
+			&i.ResolvedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OpenReportsOnTarget,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReporterReports = `-- name: ListReporterReports :many
+SELECT id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence, status, resolution_note, handled_by, resolved_at, created_at, updated_at FROM reports
+WHERE reporter_user_id = $1
+  AND ($2::uuid IS NULL OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListReporterReportsParams struct {
+	ReporterUserID uuid.UUID   `json:"reporter_user_id"`
+	BeforeID       pgtype.UUID `json:"before_id"`
+	MaxResults     int32       `json:"max_results"`
+}
+
+func (q *Queries) ListReporterReports(ctx context.Context, arg ListReporterReportsParams) ([]Report, error) {
+	rows, err := q.db.Query(ctx, listReporterReports, arg.ReporterUserID, arg.BeforeID, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReporterUserID,
+			&i.TargetType,
+			&i.TargetUserID,
+			&i.TargetID,
+			&i.Category,
+			&i.Details,
+			&i.B64CipherEvidence,
+			&i.Status,
+			&i.ResolutionNote,
+			&i.HandledBy,
+			&i.ResolvedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveOpenReportsOnUser = `-- name: ResolveOpenReportsOnUser :many
+UPDATE reports
+SET status = $1,
+    resolution_note = $2,
+    handled_by = $3,
+    resolved_at = now()
+WHERE target_user_id = $4
+  AND status IN ('open', 'in_review')
+RETURNING id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence, status, resolution_note, handled_by, resolved_at, created_at, updated_at
+`
+
+type ResolveOpenReportsOnUserParams struct {
+	Status         string      `json:"status"`
+	ResolutionNote *string     `json:"resolution_note"`
+	HandledBy      pgtype.UUID `json:"handled_by"`
+	TargetUserID   pgtype.UUID `json:"target_user_id"`
+}
+
+// Closes every open or in-review report against a user, e.g. once they were admin-blocked
+func (q *Queries) ResolveOpenReportsOnUser(ctx context.Context, arg ResolveOpenReportsOnUserParams) ([]Report, error) {
+	rows, err := q.db.Query(ctx, resolveOpenReportsOnUser,
+		arg.Status,
+		arg.ResolutionNote,
+		arg.HandledBy,
+		arg.TargetUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReporterUserID,
+			&i.TargetType,
+			&i.TargetUserID,
+			&i.TargetID,
+			&i.Category,
+			&i.Details,
+			&i.B64CipherEvidence,
+			&i.Status,
+			&i.ResolutionNote,
+			&i.HandledBy,
+			&i.ResolvedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resolveReport = `-- name: ResolveReport :one
+UPDATE reports
+SET status = $1,
+    resolution_note = $2,
+    handled_by = $3,
+    resolved_at = now()
+WHERE id = $4
+  AND status IN ('open', 'in_review')
+RETURNING id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence, status, resolution_note, handled_by, resolved_at, created_at, updated_at
+`
+
+type ResolveReportParams struct {
+	Status         string      `json:"status"`
+	ResolutionNote *string     `json:"resolution_note"`
+	HandledBy      pgtype.UUID `json:"handled_by"`
+	ID             uuid.UUID   `json:"id"`
+}
+
+// Closes an open or in-review report
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRow(ctx, resolveReport,
+		arg.Status,
+		arg.ResolutionNote,
+		arg.HandledBy,
+		arg.ID,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterUserID,
+		&i.TargetType,
+		&i.TargetUserID,
+		&i.TargetID,
+		&i.Category,
+		&i.Details,
+		&i.B64CipherEvidence,
+		&i.Status,
+		&i.ResolutionNote,
+		&i.HandledBy,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const startReportReview = `-- name: StartReportReview :one
+UPDATE reports
+SET status = 'in_review',
+    handled_by = $1
+WHERE id = $2
+  AND status = 'open'
+RETURNING id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence, status, resolution_note, handled_by, resolved_at, created_at, updated_at
+`
+
+type StartReportReviewParams struct {
+	HandledBy pgtype.UUID `json:"handled_by"`
+	ID        uuid.UUID   `json:"id"`
+}
+
+func (q *Queries) StartReportReview(ctx context.Context, arg StartReportReviewParams) (Report, error) {
+	row := q.db.QueryRow(ctx, startReportReview, arg.HandledBy, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ReporterUserID,
+		&i.TargetType,
+		&i.TargetUserID,
+		&i.TargetID,
+		&i.Category,
+		&i.Details,
+		&i.B64CipherEvidence,
+		&i.Status,
+		&i.ResolutionNote,
+		&i.HandledBy,
+		&i.ResolvedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,277 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: storage_quota.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deleteStorageObject = `-- name: DeleteStorageObject :execrows
+WITH deleted AS (
+    DELETE FROM storage_objects AS so
+    WHERE so.bucket_id = $1
+      AND so.file_id = $2
+    RETURNING so.owner_id, so.size_bytes
+)
+UPDATE storage_usage AS su
+SET used_bytes   = GREATEST(su.used_bytes - d.size_bytes, 0),
+    object_count = GREATEST(su.object_count - 1, 0)
+FROM deleted AS d
+WHERE su.owner_id = d.owner_id
+`
+
+type DeleteStorageObjectParams struct {
+	BucketID string `json:"bucket_id"`
+	FileID   string `json:"file_id"`
+}
+
+// Forgets an object and releases its bytes from the owner's totals.
+func (q *Queries) DeleteStorageObject(ctx context.Context, arg DeleteStorageObjectParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStorageObject, arg.BucketID, arg.FileID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteStorageOwner = `-- name: DeleteStorageOwner :exec
+WITH deleted AS (
+    DELETE FROM storage_objects AS so
+    WHERE so.owner_id = $1
+)
+DELETE FROM storage_usage AS su
+WHERE su.owner_id = $1
+`
+
+// Drops every object record and the totals of a deleted owner
+func (q *Queries) DeleteStorageOwner(ctx context.Context, ownerID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteStorageOwner, ownerID)
+	return err
+}
+
+const ensureStorageUsage = `-- name: EnsureStorageUsage :exec
+INSERT INTO storage_usage (owner_id)
+VALUES ($1)
+ON CONFLICT (owner_id) DO NOTHING
+`
+
+// Creates the owner's totals on the free plan, so ReserveStorageBytes has a row to lock
+func (q *Queries) EnsureStorageUsage(ctx context.Context, ownerID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, ensureStorageUsage, ownerID)
+	return err
+}
+
+const getStorageQuotaHeadroom = `-- name: GetStorageQuotaHeadroom :one
+
+SELECT
+    COALESCE((SELECT su.plan FROM storage_usage AS su WHERE su.owner_id = $1), 'free')::text AS plan,
+    COALESCE((SELECT su.used_bytes FROM storage_usage AS su WHERE su.owner_id = $1), 0)::bigint AS used_bytes,
+    COALESCE((
+        SELECT sum(so.size_bytes)
+        FROM storage_objects AS so
+        WHERE so.bucket_id = $2
+          AND so.file_id = ANY($3::text[])
+          AND so.owner_id = $1
+    ), 0)::bigint AS replaced_bytes
+`
+
+type GetStorageQuotaHeadroomParams struct {
+	OwnerID  uuid.UUID `json:"owner_id"`
+	BucketID string    `json:"bucket_id"`
+	FileIds  []string  `json:"file_ids"`
+}
+
+type GetStorageQuotaHeadroomRow struct {
+	Plan          string `json:"plan"`
+	UsedBytes     int64  `json:"used_bytes"`
+	ReplacedBytes int64  `json:"replaced_bytes"`
+}
+
+// ===========================================
+// Storage quota queries for sqlc
+// ===========================================
+// Returns the owner's plan and usage, plus the bytes held by the files an upload will replace
+func (q *Queries) GetStorageQuotaHeadroom(ctx context.Context, arg GetStorageQuotaHeadroomParams) (GetStorageQuotaHeadroomRow, error) {
+	row := q.db.QueryRow(ctx, getStorageQuotaHeadroom, arg.OwnerID, arg.BucketID, arg.FileIds)
+	var i GetStorageQuotaHeadroomRow
+	err := row.Scan(&i.Plan, &i.UsedBytes, &i.ReplacedBytes)
+	return i, err
+}
+
+const getStorageUsage = `-- name: GetStorageUsage :one
+SELECT
+    COALESCE((SELECT su.plan FROM storage_usage AS su WHERE su.owner_id = $1), 'free')::text AS plan,
+    COALESCE((SELECT su.used_bytes FROM storage_usage AS su WHERE su.owner_id = $1), 0)::bigint AS used_bytes,
+    COALESCE((SELECT su.object_count FROM storage_usage AS su WHERE su.owner_id = $1), 0)::integer AS object_count
+`
+
+type GetStorageUsageRow struct {
+	Plan        string `json:"plan"`
+	UsedBytes   int64  `json:"used_bytes"`
+	ObjectCount int32  `json:"object_count"`
+}
+
+// Returns the owner's totals; owners with nothing stored get the free plan and zeros
+func (q *Queries) GetStorageUsage(ctx context.Context, ownerID uuid.UUID) (GetStorageUsageRow, error) {
+	row := q.db.QueryRow(ctx, getStorageUsage, ownerID)
+	var i GetStorageUsageRow
+	err := row.Scan(&i.Plan, &i.UsedBytes, &i.ObjectCount)
+	return i, err
+}
+
+const getStorageUsageByKind = `-- name: GetStorageUsageByKind :many
+SELECT
+    so.kind,
+    count(*)::integer            AS objects,
+    sum(so.size_bytes)::bigint   AS bytes
+FROM storage_objects AS so
+WHERE so.owner_id = $1
+GROUP BY so.kind
+ORDER BY so.kind
+`
+
+type GetStorageUsageByKindRow struct {
+	Kind    string `json:"kind"`
+	Objects int32  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Breaks the owner's usage down by object kind
+func (q *Queries) GetStorageUsageByKind(ctx context.Context, ownerID uuid.UUID) ([]GetStorageUsageByKindRow, error) {
+	rows, err := q.db.Query(ctx, getStorageUsageByKind, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStorageUsageByKindRow
+	for rows.Next() {
+		var i GetStorageUsageByKindRow
+		if err := rows.Scan(&i.Kind, &i.Objects, &i.Bytes); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordStorageObject = `-- name: RecordStorageObject :one
+WITH previous AS (
+    SELECT so.size_bytes
+    FROM storage_objects AS so
+    WHERE so.bucket_id = $2
+      AND so.file_id = $3
+    FOR UPDATE
+), upserted AS (
+    INSERT INTO storage_objects (bucket_id, file_id, owner_id, size_bytes, kind)
+    VALUES ($2, $3, $1, $4, $5)
+    ON CONFLICT (bucket_id, file_id) DO UPDATE
+    SET size_bytes = EXCLUDED.size_bytes,
+        kind       = EXCLUDED.kind
+    RETURNING 1
+), change AS (
+    SELECT
+        $4::bigint
+            - COALESCE((SELECT size_bytes FROM previous), 0)
+            - $6::bigint AS bytes,
+        CASE WHEN EXISTS (SELECT 1 FROM previous) THEN 0 ELSE 1 END AS objects
+)
+INSERT INTO storage_usage (owner_id, used_bytes, object_count)
+SELECT $1, GREATEST(c.bytes, 0), c.objects
+FROM change AS c
+ON CONFLICT (owner_id) DO UPDATE
+SET used_bytes   = GREATEST(storage_usage.used_bytes + (SELECT bytes FROM change), 0),
+    object_count = storage_usage.object_count + EXCLUDED.object_count
+RETURNING used_bytes
+`
+
+type RecordStorageObjectParams struct {
+	OwnerID       uuid.UUID `json:"owner_id"`
+	BucketID      string    `json:"bucket_id"`
+	FileID        string    `json:"file_id"`
+	SizeBytes     int64     `json:"size_bytes"`
+	Kind          string    `json:"kind"`
+	ReservedBytes int64     `json:"reserved_bytes"`
+}
+
+// Upserts an object and settles its reservation: the owner's totals move by the size
+// difference, less the bytes reserved for it. Keys are per-owner (e.g. avatars use the user
+// id), so a replaced object always belongs to the same owner.
+func (q *Queries) RecordStorageObject(ctx context.Context, arg RecordStorageObjectParams) (int64, error) {
+	row := q.db.QueryRow(ctx, recordStorageObject,
+		arg.OwnerID,
+		arg.BucketID,
+		arg.FileID,
+		arg.SizeBytes,
+		arg.Kind,
+		arg.ReservedBytes,
+	)
+	var used_bytes int64
+	err := row.Scan(&used_bytes)
+	return used_bytes, err
+}
+
+const releaseStorageBytes = `-- name: ReleaseStorageBytes :exec
+UPDATE storage_usage
+SET used_bytes = GREATEST(used_bytes - $1::bigint, 0)
+WHERE owner_id = $2
+`
+
+type ReleaseStorageBytesParams struct {
+	Bytes   int64     `json:"bytes"`
+	OwnerID uuid.UUID `json:"owner_id"`
+}
+
+// Gives back a reservation whose upload failed
+func (q *Queries) ReleaseStorageBytes(ctx context.Context, arg ReleaseStorageBytesParams) error {
+	_, err := q.db.Exec(ctx, releaseStorageBytes, arg.Bytes, arg.OwnerID)
+	return err
+}
+
+const reserveStorageBytes = `-- name: ReserveStorageBytes :one
+UPDATE storage_usage AS su
+SET used_bytes = su.used_bytes + $1::bigint
+WHERE su.owner_id = $2
+  AND su.used_bytes + $1::bigint - COALESCE((
+        SELECT sum(so.size_bytes)
+        FROM storage_objects AS so
+        WHERE so.bucket_id = $3
+          AND so.file_id = ANY($4::text[])
+          AND so.owner_id = $2
+      ), 0) <= ($5::bigint[])[array_position($6::text[], su.plan)]
+RETURNING su.used_bytes
+`
+
+type ReserveStorageBytesParams struct {
+	Bytes    int64     `json:"bytes"`
+	OwnerID  uuid.UUID `json:"owner_id"`
+	BucketID string    `json:"bucket_id"`
+	FileIds  []string  `json:"file_ids"`
+	Limits   []int64   `json:"limits"`
+	Plans    []string  `json:"plans"`
+}
+
+// Adds the bytes of an upload to the owner's usage before it is stored, if that keeps them
+// within their plan's limit once the files it replaces are released. The row lock makes
+// concurrent uploads queue, so they cannot pass the check together. Returns no row when the
+// upload does not fit. limits[i] is the limit of plans[i].
+func (q *Queries) ReserveStorageBytes(ctx context.Context, arg ReserveStorageBytesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, reserveStorageBytes,
+		arg.Bytes,
+		arg.OwnerID,
+		arg.BucketID,
+		arg.FileIds,
+		arg.Limits,
+		arg.Plans,
+	)
+	var used_bytes int64
+	err := row.Scan(&used_bytes)
+	return used_bytes, err
+}
@@ -0,0 +1,187 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: totp.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const createTotpRecoveryCode = `-- name: CreateTotpRecoveryCode :exec
+INSERT INTO totp_recovery_codes (id, user_id, code_hash)
+VALUES ($1, $2, $3)
+`
+
+type CreateTotpRecoveryCodeParams struct {
+	ID       uuid.UUID `json:"id"`
+	UserID   uuid.UUID `json:"user_id"`
+	CodeHash string    `json:"code_hash"`
+}
+
+func (q *Queries) CreateTotpRecoveryCode(ctx context.Context, arg CreateTotpRecoveryCodeParams) error {
+	_, err := q.db.Exec(ctx, createTotpRecoveryCode, arg.ID, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const deleteTotpFactor = `-- name: DeleteTotpFactor :exec
+DELETE FROM totp_factors
+WHERE user_id = $1
+`
+
+// Removes the factor; recovery codes go with it (ON DELETE CASCADE)
+func (q *Queries) DeleteTotpFactor(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteTotpFactor, userID)
+	return err
+}
+
+const deleteTotpRecoveryCodes = `-- name: DeleteTotpRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteTotpRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteTotpRecoveryCodes, userID)
+	return err
+}
+
+const enableTotpFactor = `-- name: EnableTotpFactor :execrows
+UPDATE totp_factors
+SET enabled_at = now(),
+    last_used_step = $1
+WHERE user_id = $2
+  AND enabled_at IS NULL
+`
+
+type EnableTotpFactorParams struct {
+	Step   int64     `json:"step"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Activates a pending enrolment with the step of the code that confirmed it
+func (q *Queries) EnableTotpFactor(ctx context.Context, arg EnableTotpFactorParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enableTotpFactor, arg.Step, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getTotpFactor = `-- name: GetTotpFactor :one
+SELECT user_id, secret, enabled_at, last_used_step, created_at, updated_at FROM totp_factors
+WHERE user_id = $1
+`
+
+func (q *Queries) GetTotpFactor(ctx context.Context, userID uuid.UUID) (TotpFactor, error) {
+	row := q.db.QueryRow(ctx, getTotpFactor, userID)
+	var i TotpFactor
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listUnusedTotpRecoveryCodes = `-- name: ListUnusedTotpRecoveryCodes :many
+SELECT id, code_hash
+FROM totp_recovery_codes
+WHERE user_id = $1
+  AND used_at IS NULL
+`
+
+type ListUnusedTotpRecoveryCodesRow struct {
+	ID       uuid.UUID `json:"id"`
+	CodeHash string    `json:"code_hash"`
+}
+
+func (q *Queries) ListUnusedTotpRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]ListUnusedTotpRecoveryCodesRow, error) {
+	rows, err := q.db.Query(ctx, listUnusedTotpRecoveryCodes, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnusedTotpRecoveryCodesRow
+	for rows.Next() {
+		var i ListUnusedTotpRecoveryCodesRow
+		if err := rows.Scan(&i.ID, &i.CodeHash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPendingTotpFactor = `-- name: UpsertPendingTotpFactor :one
+
+INSERT INTO totp_factors (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0
+WHERE totp_factors.enabled_at IS NULL
+RETURNING user_id
+`
+
+type UpsertPendingTotpFactorParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Secret string    `json:"secret"`
+}
+
+// ===========================================
+// TOTP second factor queries for sqlc
+// ===========================================
+// Starts (or restarts) an enrolment; returns no row when TOTP is already enabled
+func (q *Queries) UpsertPendingTotpFactor(ctx context.Context, arg UpsertPendingTotpFactorParams) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, upsertPendingTotpFactor, arg.UserID, arg.Secret)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const useTotpRecoveryCode = `-- name: UseTotpRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = now()
+WHERE id = $1
+  AND used_at IS NULL
+`
+
+// Burns a recovery code; returns 0 rows if it was already used
+func (q *Queries) UseTotpRecoveryCode(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, useTotpRecoveryCode, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useTotpStep = `-- name: UseTotpStep :execrows
+UPDATE totp_factors
+SET last_used_step = $1
+WHERE user_id = $2
+  AND enabled_at IS NOT NULL
+  AND last_used_step < $1
+`
+
+type UseTotpStepParams struct {
+	Step   int64     `json:"step"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Accepts a code's time step once; returns 0 rows for a replayed or older step
+func (q *Queries) UseTotpStep(ctx context.Context, arg UseTotpStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useTotpStep, arg.Step, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,52 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: user_preferences.sql
+
+package postgresCode
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deleteUserPreferences = `-- name: DeleteUserPreferences :exec
+DELETE FROM user_preferences WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserPreferences(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserPreferences, userID)
+	return err
+}
+
+const getUserLocale = `-- name: GetUserLocale :one
+
+SELECT locale FROM user_preferences WHERE user_id = $1
+`
+
+// ===========================================
+// User preference queries for sqlc
+// ===========================================
+func (q *Queries) GetUserLocale(ctx context.Context, userID uuid.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, getUserLocale, userID)
+	var locale string
+	err := row.Scan(&locale)
+	return locale, err
+}
+
+const setUserLocale = `-- name: SetUserLocale :exec
+INSERT INTO user_preferences (user_id, locale)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET locale = EXCLUDED.locale
+`
+
+type SetUserLocaleParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Locale string    `json:"locale"`
+}
+
+func (q *Queries) SetUserLocale(ctx context.Context, arg SetUserLocaleParams) error {
+	_, err := q.db.Exec(ctx, setUserLocale, arg.UserID, arg.Locale)
+	return err
+}
@@ -0,0 +1,56 @@
+-- ===========================================
+-- Account deletion job queries for sqlc
+-- ===========================================
+
+-- name: EnqueueAccountDeletion :one
+-- Creates the job, or makes an existing pending job due now
+INSERT INTO account_deletion_jobs (user_id, step)
+VALUES (sqlc.arg(user_id), sqlc.arg(first_step))
+ON CONFLICT (user_id) DO UPDATE
+SET next_attempt_at = LEAST(account_deletion_jobs.next_attempt_at, now())
+RETURNING *;
+
+-- name: ClaimAccountDeletionJobs :many
+-- Leases due jobs by pushing next_attempt_at past the lease; SKIP LOCKED lets several
+-- instances claim concurrently without picking the same job
+UPDATE account_deletion_jobs
+SET next_attempt_at = now() + sqlc.arg(lease)::INTERVAL
+WHERE user_id IN (
+    SELECT j.user_id
+    FROM account_deletion_jobs AS j
+    WHERE j.status = 'pending'
+      AND j.next_attempt_at <= now()
+    ORDER BY j.next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: AdvanceAccountDeletionJob :exec
+-- Records that a step finished and moves on to the next one
+UPDATE account_deletion_jobs
+SET step = sqlc.arg(next_step),
+    attempts = 0,
+    last_error = NULL
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: FailAccountDeletionJob :exec
+-- Records a failed step and schedules the retry
+UPDATE account_deletion_jobs
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = sqlc.arg(retry_at)
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: CompleteAccountDeletionJob :exec
+-- Marks every step as done
+UPDATE account_deletion_jobs
+SET status = 'done',
+    step = 'done',
+    last_error = NULL,
+    completed_at = now()
+WHERE user_id = $1;
+
+-- name: GetAccountDeletionJob :one
+SELECT * FROM account_deletion_jobs
+WHERE user_id = $1;
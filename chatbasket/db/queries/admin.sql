@@ -0,0 +1,40 @@
+-- ===========================================
+-- Admin queries for sqlc
+-- ===========================================
+
+-- name: AdminGetUserByHashedUsername :one
+-- Like GetUserByHashedUsername, but also finds admin-blocked users
+SELECT *
+FROM users
+WHERE hmac_sha256_hex_username = $1;
+
+-- name: AdminSearchUsersByName :many
+-- Users whose display name contains the search text, newest first
+SELECT *
+FROM users
+WHERE name ILIKE '%' || sqlc.arg(name)::text || '%'
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: SetUserAdminBlock :one
+-- Blocks or unblocks a user; the reason is cleared on unblock
+UPDATE users
+SET is_admin_blocked = sqlc.arg(blocked),
+    admin_block_reason = sqlc.narg(reason)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetContactRequestVolume :one
+-- Contact requests a user sent (with their outcome) and received, for spotting abuse
+SELECT
+    count(*) FILTER (WHERE requester_user_id = $1 AND created_at > now() - interval '1 day')   AS sent_last_day,
+    count(*) FILTER (WHERE requester_user_id = $1 AND created_at > now() - interval '7 days')  AS sent_last_week,
+    count(*) FILTER (WHERE requester_user_id = $1 AND created_at > now() - interval '30 days') AS sent_last_month,
+    count(*) FILTER (WHERE requester_user_id = $1)                                            AS sent_total,
+    count(*) FILTER (WHERE requester_user_id = $1 AND status = 'pending')                     AS sent_pending,
+    count(*) FILTER (WHERE requester_user_id = $1 AND status = 'accepted')                    AS sent_accepted,
+    count(*) FILTER (WHERE requester_user_id = $1 AND status = 'declined')                    AS sent_declined,
+    count(*) FILTER (WHERE receiver_user_id = $1)                                             AS received_total
+FROM contact_requests
+WHERE requester_user_id = $1
+   OR receiver_user_id = $1;
@@ -0,0 +1,42 @@
+-- ===========================================
+-- API key queries for sqlc
+-- ===========================================
+
+-- name: CreateApiKey :one
+INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(name),
+    sqlc.arg(prefix),
+    sqlc.arg(key_hash),
+    sqlc.arg(scopes)::text[],
+    sqlc.arg(expires_at)
+)
+RETURNING *;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: CountApiKeys :one
+SELECT count(*) FROM api_keys WHERE user_id = $1;
+
+-- name: GetApiKeyByPrefix :one
+SELECT * FROM api_keys WHERE prefix = $1;
+
+-- name: TouchApiKey :exec
+-- Records use, at most once a minute per key
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
+
+-- name: DeleteApiKey :execrows
+DELETE FROM api_keys
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteUserApiKeys :exec
+DELETE FROM api_keys WHERE user_id = $1;
@@ -0,0 +1,33 @@
+-- ===========================================
+-- Audit event queries for sqlc
+-- ===========================================
+
+-- name: InsertAuditEvent :exec
+INSERT INTO audit_events (id, actor_user_id, actor_type, action, target_type, target_id, request_id, ip, user_agent, metadata)
+VALUES (
+    sqlc.arg(id),
+    sqlc.narg(actor_user_id),
+    sqlc.arg(actor_type),
+    sqlc.arg(action),
+    sqlc.arg(target_type),
+    sqlc.arg(target_id),
+    sqlc.narg(request_id),
+    sqlc.narg(ip),
+    sqlc.narg(user_agent),
+    sqlc.arg(metadata)
+);
+
+-- name: ListAuditEvents :many
+-- Newest first; every filter is optional. before_id pages with the id of the last row seen.
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg(actor_user_id)::uuid IS NULL OR actor_user_id = sqlc.narg(actor_user_id))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(target_type)::text IS NULL OR target_type = sqlc.narg(target_type))
+  AND (sqlc.narg(target_id)::text IS NULL OR target_id = sqlc.narg(target_id))
+  AND (sqlc.narg(request_id)::text IS NULL OR request_id = sqlc.narg(request_id))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until))
+  AND (sqlc.narg(before_id)::uuid IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,145 @@
+-- ===========================================
+-- Data export queries for sqlc
+-- ===========================================
+
+-- name: CreateDataExport :one
+-- Queues an export; returns no row when the user already has one pending or ready
+INSERT INTO data_exports (id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id) WHERE status IN ('pending', 'ready') DO NOTHING
+RETURNING *;
+
+-- name: GetLatestDataExport :one
+-- Returns the user's most recent export
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ClaimDataExports :many
+-- Leases due exports by pushing next_attempt_at past the lease; SKIP LOCKED lets several
+-- instances claim concurrently without picking the same export
+UPDATE data_exports
+SET next_attempt_at = now() + sqlc.arg(lease)::INTERVAL
+WHERE id IN (
+    SELECT e.id
+    FROM data_exports AS e
+    WHERE e.status = 'pending'
+      AND e.next_attempt_at <= now()
+    ORDER BY e.next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+-- Records the stored archive and opens the download window
+UPDATE data_exports
+SET status = 'ready',
+    file_id = sqlc.arg(file_id),
+    size_bytes = sqlc.arg(size_bytes),
+    expires_at = sqlc.arg(expires_at),
+    last_error = NULL
+WHERE id = sqlc.arg(id);
+
+-- name: FailDataExport :exec
+-- Records a failed build; the export gives up after max_attempts
+UPDATE data_exports
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = sqlc.arg(retry_at),
+    status = CASE WHEN attempts + 1 >= sqlc.arg(max_attempts)::INTEGER THEN 'failed' ELSE status END
+WHERE id = sqlc.arg(id);
+
+-- name: ConsumeDataExport :one
+-- Claims the one download of a ready export
+UPDATE data_exports
+SET status = 'downloaded',
+    downloaded_at = now()
+WHERE id = $1
+  AND status = 'ready'
+  AND expires_at > now()
+RETURNING *;
+
+-- name: ListPurgeableDataExports :many
+-- Returns stored archives that were downloaded or whose window has passed
+SELECT id, file_id
+FROM data_exports
+WHERE file_id IS NOT NULL
+  AND (status = 'downloaded' OR expires_at <= now())
+LIMIT $1;
+
+-- name: PurgeDataExport :exec
+-- Forgets a deleted archive; exports that were never downloaded become expired
+UPDATE data_exports
+SET file_id = NULL,
+    status = CASE WHEN status = 'ready' THEN 'expired' ELSE status END
+WHERE id = $1;
+
+-- name: ListUserDataExportFiles :many
+-- Returns the user's stored archives (for account deletion)
+SELECT file_id::TEXT
+FROM data_exports
+WHERE user_id = $1
+  AND file_id IS NOT NULL;
+
+-- name: ExportUserContacts :many
+-- The user's contacts with the nickname they set (still encrypted)
+SELECT
+    uc.contact_user_id,
+    u.name AS contact_name,
+    uc.nickname,
+    uc.created_at
+FROM user_contacts uc
+JOIN users u ON u.id = uc.contact_user_id
+WHERE uc.owner_user_id = $1
+ORDER BY uc.created_at;
+
+-- name: ExportUserContactRequests :many
+-- Every contact request the user sent or received, whatever its status
+SELECT
+    cr.id,
+    cr.requester_user_id,
+    cr.receiver_user_id,
+    cr.status::TEXT AS status,
+    cr.nickname,
+    cr.created_at,
+    cr.updated_at
+FROM contact_requests cr
+WHERE cr.requester_user_id = $1
+   OR cr.receiver_user_id = $1
+ORDER BY cr.created_at;
+
+-- name: ExportUserRestrictions :many
+-- Restrictions the user placed on other users
+SELECT restricted_user_id, restrict_profile, restrict_avatar, restrict_status, created_at, updated_at
+FROM user_restrictions
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserGlobalRestrictions :many
+-- The user's global restriction flags (at most one row)
+SELECT restrict_profile, restrict_avatar, restrict_status, created_at, updated_at
+FROM user_global_restrictions
+WHERE user_id = $1;
+
+-- name: ExportUserGlobalRestrictionExemptions :many
+-- Users exempted from the user's global restrictions
+SELECT exempted_user_id, exception_profile, exception_avatar, exception_status, created_at, updated_at
+FROM user_global_restriction_exemptions
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserBlocks :many
+-- Users the user blocked
+SELECT blocked_user_id, created_at
+FROM user_blocks
+WHERE blocker_user_id = $1
+ORDER BY created_at;
+
+-- name: ExportUserPushTokens :many
+-- The user's registered push tokens
+SELECT type, token, is_active, created_at, updated_at
+FROM tokens
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,42 @@
+-- ===========================================
+-- Magic link queries for sqlc
+-- ===========================================
+
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links (id, user_id, email, nonce_hash, expires_at)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(email),
+    sqlc.arg(nonce_hash),
+    sqlc.arg(expires_at)
+);
+
+-- name: GetMagicLink :one
+SELECT * FROM magic_links WHERE id = $1;
+
+-- name: ConsumeMagicLink :execrows
+-- Uses a link up; no row is affected when a concurrent request got there first
+UPDATE magic_links
+SET consumed_at = now()
+WHERE id = $1
+  AND consumed_at IS NULL;
+
+-- name: RecordMagicLinkFailure :one
+-- Counts a wrong second factor tried with the link
+UPDATE magic_links
+SET attempts = attempts + 1
+WHERE id = $1
+RETURNING attempts;
+
+-- name: DeleteMagicLink :exec
+DELETE FROM magic_links WHERE id = $1;
+
+-- name: DeleteUserMagicLinks :exec
+DELETE FROM magic_links WHERE user_id = $1;
+
+-- name: DeleteStaleMagicLinks :execrows
+-- Removes links that expired or were used more than grace ago
+DELETE FROM magic_links
+WHERE expires_at < now() - sqlc.arg(grace)::INTERVAL
+   OR consumed_at < now() - sqlc.arg(grace)::INTERVAL;
@@ -0,0 +1,54 @@
+-- ===========================================
+-- OTP challenge queries for sqlc
+-- ===========================================
+
+-- name: UpsertOtpChallenge :exec
+-- Stores a freshly sent code, replacing the user's previous challenge for the purpose
+INSERT INTO otp_challenges (id, user_id, purpose, target, code_hash, expires_at)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(purpose),
+    sqlc.arg(target),
+    sqlc.arg(code_hash),
+    now() + sqlc.arg(ttl)::INTERVAL
+)
+ON CONFLICT (user_id, purpose) DO UPDATE
+SET id = EXCLUDED.id,
+    target = EXCLUDED.target,
+    code_hash = EXCLUDED.code_hash,
+    attempts = 0,
+    expires_at = EXCLUDED.expires_at,
+    consumed_at = NULL;
+
+-- name: ReserveOtpChallengeAttempt :one
+-- Counts a guess before it is checked, so parallel requests cannot exceed the limit. Returns no
+-- row when there is no live challenge or its attempts are used up.
+UPDATE otp_challenges
+SET attempts = attempts + 1
+WHERE user_id = sqlc.arg(user_id)
+  AND purpose = sqlc.arg(purpose)
+  AND consumed_at IS NULL
+  AND attempts < sqlc.arg(max_attempts)::INTEGER
+RETURNING *;
+
+-- name: ConsumeOtpChallenge :execrows
+-- Uses up a verified challenge; returns 0 rows if another request got there first
+UPDATE otp_challenges
+SET consumed_at = now()
+WHERE id = $1
+  AND consumed_at IS NULL;
+
+-- name: DeleteOtpChallenge :exec
+DELETE FROM otp_challenges
+WHERE id = $1;
+
+-- name: DeleteUserOtpChallenges :exec
+DELETE FROM otp_challenges
+WHERE user_id = $1;
+
+-- name: DeleteStaleOtpChallenges :execrows
+-- Removes challenges that expired or were consumed more than grace ago
+DELETE FROM otp_challenges
+WHERE expires_at < now() - sqlc.arg(grace)::INTERVAL
+   OR consumed_at < now() - sqlc.arg(grace)::INTERVAL;
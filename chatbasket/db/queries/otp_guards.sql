@@ -0,0 +1,58 @@
+-- ===========================================
+-- OTP guard queries for sqlc
+-- ===========================================
+
+-- name: ReserveOtpSend :one
+-- Records a send. Returns no row while the user is locked out, inside the resend cooldown, or
+-- out of sends for the window.
+INSERT INTO otp_guards AS g (user_id, last_sent_at, sends_in_window, window_started_at)
+VALUES (sqlc.arg(user_id), now(), 1, now())
+ON CONFLICT (user_id) DO UPDATE
+SET last_sent_at = now(),
+    sends_in_window = CASE
+        WHEN g.window_started_at IS NULL OR g.window_started_at <= now() - sqlc.arg(send_window)::INTERVAL THEN 1
+        ELSE g.sends_in_window + 1
+    END,
+    window_started_at = CASE
+        WHEN g.window_started_at IS NULL OR g.window_started_at <= now() - sqlc.arg(send_window)::INTERVAL THEN now()
+        ELSE g.window_started_at
+    END
+WHERE (g.locked_until IS NULL OR g.locked_until <= now())
+  AND (g.last_sent_at IS NULL OR g.last_sent_at <= now() - sqlc.arg(cooldown)::INTERVAL)
+  AND (g.window_started_at IS NULL
+       OR g.window_started_at <= now() - sqlc.arg(send_window)::INTERVAL
+       OR g.sends_in_window < sqlc.arg(max_sends)::INTEGER)
+RETURNING user_id;
+
+-- name: LockOtpGuard :one
+-- Starts a lockout after a code's attempts ran out. The n-th lockout in a row lasts schedule[n],
+-- or the last entry once the schedule is used up (see otp.lockoutSchedule)
+INSERT INTO otp_guards AS g (user_id, lockouts, locked_until)
+VALUES (sqlc.arg(user_id), 1, now() + (sqlc.arg(schedule)::INTERVAL[])[1])
+ON CONFLICT (user_id) DO UPDATE
+SET lockouts = g.lockouts + 1,
+    locked_until = now() + (sqlc.arg(schedule)::INTERVAL[])[
+        LEAST(g.lockouts + 1, cardinality(sqlc.arg(schedule)::INTERVAL[]))
+    ]
+RETURNING locked_until;
+
+-- name: ClearOtpGuard :exec
+-- Forgets lockouts once a code was verified
+UPDATE otp_guards
+SET lockouts = 0,
+    locked_until = NULL
+WHERE user_id = $1;
+
+-- name: GetOtpGuard :one
+SELECT * FROM otp_guards
+WHERE user_id = $1;
+
+-- name: DeleteOtpGuard :exec
+DELETE FROM otp_guards
+WHERE user_id = $1;
+
+-- name: DeleteIdleOtpGuards :execrows
+-- Removes guards untouched for a while, so lockouts stop escalating after a quiet period
+DELETE FROM otp_guards
+WHERE updated_at < now() - sqlc.arg(idle)::INTERVAL
+  AND (locked_until IS NULL OR locked_until <= now());
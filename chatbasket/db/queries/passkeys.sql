@@ -0,0 +1,72 @@
+-- ===========================================
+-- Passkey (WebAuthn) queries for sqlc
+-- ===========================================
+
+-- name: CreatePasskeyCredential :one
+INSERT INTO passkey_credentials (
+    id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count, flags, name
+)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(credential_id),
+    sqlc.arg(public_key),
+    sqlc.arg(attestation_type),
+    sqlc.arg(transports),
+    sqlc.arg(aaguid),
+    sqlc.arg(sign_count),
+    sqlc.arg(flags),
+    sqlc.arg(name)
+)
+RETURNING *;
+
+-- name: ListPasskeyCredentials :many
+SELECT * FROM passkey_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: GetPasskeyCredentialByCredentialId :one
+SELECT * FROM passkey_credentials WHERE credential_id = $1;
+
+-- name: UpdatePasskeySignCount :execrows
+-- Stores the counter of a successful assertion. No row is affected when the counter did not
+-- move forward, e.g. a replayed assertion or a cloned authenticator.
+UPDATE passkey_credentials
+SET sign_count = sqlc.arg(sign_count),
+    last_used_at = now()
+WHERE id = sqlc.arg(id)
+  AND (sqlc.arg(sign_count)::BIGINT > sign_count OR (sqlc.arg(sign_count)::BIGINT = 0 AND sign_count = 0));
+
+-- name: DeletePasskeyCredential :execrows
+DELETE FROM passkey_credentials
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteUserPasskeyCredentials :exec
+DELETE FROM passkey_credentials WHERE user_id = $1;
+
+-- name: CreateWebauthnChallenge :exec
+INSERT INTO webauthn_challenges (id, user_id, ceremony, session_data, expires_at)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(ceremony),
+    sqlc.arg(session_data),
+    now() + sqlc.arg(ttl)::INTERVAL
+);
+
+-- name: TakeWebauthnChallenge :one
+-- Uses a challenge up. Returns no row when it does not exist, belongs to another ceremony or
+-- has expired.
+DELETE FROM webauthn_challenges
+WHERE id = sqlc.arg(id)
+  AND ceremony = sqlc.arg(ceremony)
+  AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteUserWebauthnChallenges :exec
+DELETE FROM webauthn_challenges WHERE user_id = $1;
+
+-- name: DeleteStaleWebauthnChallenges :execrows
+DELETE FROM webauthn_challenges
+WHERE expires_at < now() - sqlc.arg(grace)::INTERVAL;
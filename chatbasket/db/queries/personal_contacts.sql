@@ -356,3 +356,34 @@ SELECT *
 FROM users
 WHERE hmac_sha256_hex_username = $1
   AND is_admin_blocked IS NOT TRUE;
+
+-- name: ListPlaintextContactNicknames :many
+-- Returns contact nicknames not yet encrypted at field level (for the backfill)
+SELECT owner_user_id, contact_user_id, nickname
+FROM user_contacts
+WHERE nickname IS NOT NULL
+  AND nickname NOT LIKE 'enc1.%'
+LIMIT $1;
+
+-- name: UpdateContactNicknameCipher :execrows
+-- Swaps the contact nickname only if it has not changed since it was read
+UPDATE user_contacts
+SET nickname = sqlc.arg('new_nickname')
+WHERE owner_user_id = sqlc.arg('owner_user_id')
+  AND contact_user_id = sqlc.arg('contact_user_id')
+  AND nickname = sqlc.arg('old_nickname');
+
+-- name: ListPlaintextContactRequestNicknames :many
+-- Returns contact request nicknames not yet encrypted at field level (for the backfill)
+SELECT id, requester_user_id, nickname
+FROM contact_requests
+WHERE nickname IS NOT NULL
+  AND nickname NOT LIKE 'enc1.%'
+LIMIT $1;
+
+-- name: UpdateContactRequestNicknameCipher :execrows
+-- Swaps the contact request nickname only if it has not changed since it was read
+UPDATE contact_requests
+SET nickname = sqlc.arg('new_nickname')
+WHERE id = sqlc.arg('id')
+  AND nickname = sqlc.arg('old_nickname');
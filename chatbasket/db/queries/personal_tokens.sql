@@ -2,3 +2,17 @@
 -- Tokens Queries for sqlc
 -- ===========================================
 
+-- name: DeactivateSessionTokens :execrows
+-- Stops push notifications to the device of a signed-out session
+UPDATE tokens
+SET is_active = FALSE
+WHERE user_id = $1
+  AND sha256_hex_session_id = $2
+  AND is_active = TRUE;
+
+-- name: DeactivateUserTokens :execrows
+-- Stops push notifications to every device of the user (sign-out everywhere)
+UPDATE tokens
+SET is_active = FALSE
+WHERE user_id = $1
+  AND is_active = TRUE;
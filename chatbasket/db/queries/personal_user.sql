@@ -112,3 +112,133 @@ SELECT EXISTS(
 -- Minimal user profile without avatar join
 SELECT * FROM users
 WHERE id = $1;
+
+-- name: ListUsersWithLegacyUsernameCipher :many
+-- Returns users whose encrypted username still uses the v1 UUID-derived nonce (keyset pagination by id)
+SELECT id, b64_cipher_chacha20poly1305_username
+FROM users
+WHERE id > $1
+  AND b64_cipher_chacha20poly1305_username NOT LIKE 'v2.%'
+ORDER BY id
+LIMIT $2;
+
+-- name: UpdateUserUsernameCipher :execrows
+-- Swaps the encrypted username only if it has not changed since it was read
+UPDATE users
+SET b64_cipher_chacha20poly1305_username = sqlc.arg('new_cipher')
+WHERE id = sqlc.arg('id')
+  AND b64_cipher_chacha20poly1305_username = sqlc.arg('old_cipher');
+
+-- name: ListUsersWithPlaintextBio :many
+-- Returns users whose bio is not yet encrypted at field level (for the backfill)
+SELECT id, bio
+FROM users
+WHERE bio IS NOT NULL
+  AND bio NOT LIKE 'enc1.%'
+LIMIT $1;
+
+-- name: UpdateUserBioCipher :execrows
+-- Swaps the bio only if it has not changed since it was read
+UPDATE users
+SET bio = sqlc.arg('new_bio')
+WHERE id = sqlc.arg('id')
+  AND bio = sqlc.arg('old_bio');
+
+-- name: GetUserProfileCard :one
+-- Another user's profile card with raw restriction and relationship data for Go processing
+-- id = the user being viewed, viewer_id = the viewer
+SELECT
+    u.id,
+    u.name,
+    u.bio,
+    u.profile_type,
+    u.is_admin_blocked,
+    u.b64_cipher_chacha20poly1305_username AS username,
+
+    -- Raw avatar data (Go applies visibility logic)
+    a.file_id AS avatar_file_id,
+    a.token_id AS avatar_token_id,
+    a.token_secret AS avatar_token_secret,
+    a.token_expiry AS avatar_token_expiry,
+    a.updated_at AS avatar_updated_at,
+
+    -- Global restriction flags and the viewer's exemptions
+    COALESCE(ugr.restrict_profile, FALSE) AS global_restrict_profile,
+    COALESCE(ugr.restrict_avatar, FALSE) AS global_restrict_avatar,
+    COALESCE(ugre.exception_profile, FALSE) AS exception_global_profile,
+    COALESCE(ugre.exception_avatar, FALSE) AS exception_global_avatar,
+
+    -- User-level restriction flags against the viewer
+    COALESCE(ur.restrict_profile, FALSE) AS user_restrict_profile,
+    COALESCE(ur.restrict_avatar, FALSE) AS user_restrict_avatar,
+
+    -- Relationship state between viewer and user
+    my_uc.nickname AS my_nickname,
+    (my_uc.owner_user_id IS NOT NULL)::BOOLEAN AS is_contact,
+    EXISTS(
+        SELECT 1 FROM user_contacts their_uc
+        WHERE their_uc.owner_user_id = u.id AND their_uc.contact_user_id = sqlc.arg('viewer_id')
+    ) AS added_you,
+    EXISTS(
+        SELECT 1 FROM contact_requests cr
+        WHERE cr.requester_user_id = sqlc.arg('viewer_id') AND cr.receiver_user_id = u.id AND cr.status = 'pending'
+    ) AS request_sent,
+    EXISTS(
+        SELECT 1 FROM contact_requests cr
+        WHERE cr.requester_user_id = u.id AND cr.receiver_user_id = sqlc.arg('viewer_id') AND cr.status = 'pending'
+    ) AS request_received,
+    EXISTS(
+        SELECT 1 FROM user_blocks ub
+        WHERE ub.blocker_user_id = sqlc.arg('viewer_id') AND ub.blocked_user_id = u.id
+    ) AS blocked_by_you,
+    EXISTS(
+        SELECT 1 FROM user_blocks ub
+        WHERE ub.blocker_user_id = u.id AND ub.blocked_user_id = sqlc.arg('viewer_id')
+    ) AS blocked_you
+
+FROM users u
+LEFT JOIN avatars a
+    ON u.id = a.user_id
+    AND a.avatar_type = 'profile'
+LEFT JOIN user_global_restrictions ugr
+    ON u.id = ugr.user_id
+LEFT JOIN user_global_restriction_exemptions ugre
+    ON u.id = ugre.user_id
+    AND ugre.exempted_user_id = sqlc.arg('viewer_id')
+LEFT JOIN user_restrictions ur
+    ON u.id = ur.user_id
+    AND ur.restricted_user_id = sqlc.arg('viewer_id')
+LEFT JOIN user_contacts my_uc
+    ON my_uc.owner_user_id = sqlc.arg('viewer_id')
+    AND my_uc.contact_user_id = u.id
+WHERE u.id = sqlc.arg('id');
+
+-- name: ListAvatarsWithExpiringTokens :many
+-- Returns avatars whose storage token expires before expires_before, ordered for keyset pagination
+-- on (token_expiry, id). Served by idx_avatars_token_expiry.
+SELECT id, user_id, file_id, token_id, token_expiry
+FROM avatars
+WHERE token_expiry IS NOT NULL
+  AND token_expiry < sqlc.arg('expires_before')
+  AND (token_expiry, id) > (sqlc.arg('after_expiry')::TIMESTAMPTZ, sqlc.arg('after_id')::UUID)
+ORDER BY token_expiry, id
+LIMIT sqlc.arg('batch_size');
+
+-- name: RotateAvatarToken :execrows
+-- Replaces the avatar's storage token only if it still holds the token that was renewed
+UPDATE avatars
+SET token_id = sqlc.arg('new_token_id'),
+    token_secret = sqlc.arg('new_token_secret'),
+    token_expiry = sqlc.arg('new_token_expiry')
+WHERE id = sqlc.arg('id')
+  AND token_id IS NOT DISTINCT FROM sqlc.narg('old_token_id');
+
+-- name: DeleteUser :execrows
+-- Deletes the user; avatars, contacts, restrictions, blocks, requests and tokens cascade
+DELETE FROM users
+WHERE id = $1;
+
+-- name: DeleteAloneUsername :execrows
+-- Frees a plaintext username once its owner is deleted
+DELETE FROM alone_username
+WHERE username = $1;
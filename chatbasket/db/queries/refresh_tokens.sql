@@ -0,0 +1,78 @@
+-- ===========================================
+-- Refresh token queries for sqlc
+-- ===========================================
+
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (id, family_id, user_id, sha256_hex_session_id, b64_cipher_session_id, token_hash, expires_at)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(family_id),
+    sqlc.arg(user_id),
+    sqlc.arg(sha256_hex_session_id),
+    sqlc.arg(b64_cipher_session_id),
+    sqlc.arg(token_hash),
+    sqlc.arg(expires_at)
+);
+
+-- name: RotateRefreshToken :execrows
+-- Marks a token rotated and links the successor issued for it. prior_successor must still be the
+-- linked one (NULL before the first rotation), so only one of concurrent refreshes wins.
+-- rotated_at keeps the time of the first rotation
+UPDATE refresh_tokens
+SET rotated_at = COALESCE(rotated_at, now()),
+    replaced_by = sqlc.arg(replaced_by)
+WHERE id = sqlc.arg(id)
+  AND revoked_at IS NULL
+  AND expires_at > now()
+  AND replaced_by IS NOT DISTINCT FROM sqlc.narg(prior_successor);
+
+-- name: RevokeUnusedRefreshToken :execrows
+-- Revokes a successor that was never presented, when its predecessor is retried; no row is
+-- affected once it was used
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE id = $1
+  AND rotated_at IS NULL
+  AND revoked_at IS NULL;
+
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM refresh_tokens WHERE token_hash = $1;
+
+-- name: IsSessionRefreshTokenLive :one
+-- Reports whether a session still has a refresh token that is neither revoked nor expired; the
+-- session middleware rejects the access tokens of sessions without one
+SELECT EXISTS(
+    SELECT 1 FROM refresh_tokens
+    WHERE user_id = sqlc.arg(user_id)
+      AND sha256_hex_session_id = sqlc.arg(sha256_hex_session_id)
+      AND revoked_at IS NULL
+      AND expires_at > now()
+);
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE family_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeSessionRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE user_id = sqlc.arg(user_id)
+  AND sha256_hex_session_id = sqlc.arg(sha256_hex_session_id)
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE user_id = $1;
+
+-- name: DeleteStaleRefreshTokens :execrows
+-- Rotated tokens are kept until they expire so their reuse is still detected
+DELETE FROM refresh_tokens
+WHERE expires_at < now() - sqlc.arg(grace)::interval
+   OR revoked_at < now() - sqlc.arg(grace)::interval;
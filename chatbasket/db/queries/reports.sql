@@ -0,0 +1,73 @@
+-- ===========================================
+-- Report queries for sqlc
+-- ===========================================
+
+-- name: CreateReport :one
+-- Returns no row when the reporter already has an open report on the target
+INSERT INTO reports (id, reporter_user_id, target_type, target_user_id, target_id, category, details, b64_cipher_evidence)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(reporter_user_id),
+    sqlc.arg(target_type),
+    sqlc.arg(target_user_id),
+    sqlc.arg(target_id),
+    sqlc.arg(category),
+    sqlc.narg(details),
+    sqlc.arg(b64_cipher_evidence)
+)
+ON CONFLICT (reporter_user_id, target_type, target_id) WHERE status IN ('open', 'in_review') DO NOTHING
+RETURNING *;
+
+-- name: ListReporterReports :many
+SELECT * FROM reports
+WHERE reporter_user_id = sqlc.arg(reporter_user_id)
+  AND (sqlc.narg(before_id)::uuid IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: ListReportQueue :many
+-- Oldest first, so the queue is worked in filing order; every filter is optional
+SELECT r.*,
+    (SELECT count(*) FROM reports o
+     WHERE o.target_user_id = r.target_user_id
+       AND o.status IN ('open', 'in_review'))::bigint AS open_reports_on_target
+FROM reports r
+WHERE (sqlc.narg(status)::text IS NULL OR r.status = sqlc.narg(status))
+  AND (sqlc.narg(category)::text IS NULL OR r.category = sqlc.narg(category))
+  AND (sqlc.narg(target_user_id)::uuid IS NULL OR r.target_user_id = sqlc.narg(target_user_id))
+  AND (sqlc.narg(after_id)::uuid IS NULL OR r.id > sqlc.narg(after_id))
+ORDER BY r.id
+LIMIT sqlc.arg(max_results);
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: StartReportReview :one
+UPDATE reports
+SET status = 'in_review',
+    handled_by = sqlc.arg(handled_by)
+WHERE id = sqlc.arg(id)
+  AND status = 'open'
+RETURNING *;
+
+-- name: ResolveReport :one
+-- Closes an open or in-review report
+UPDATE reports
+SET status = sqlc.arg(status),
+    resolution_note = sqlc.narg(resolution_note),
+    handled_by = sqlc.arg(handled_by),
+    resolved_at = now()
+WHERE id = sqlc.arg(id)
+  AND status IN ('open', 'in_review')
+RETURNING *;
+
+-- name: ResolveOpenReportsOnUser :many
+-- Closes every open or in-review report against a user, e.g. once they were admin-blocked
+UPDATE reports
+SET status = sqlc.arg(status),
+    resolution_note = sqlc.narg(resolution_note),
+    handled_by = sqlc.arg(handled_by),
+    resolved_at = now()
+WHERE target_user_id = sqlc.arg(target_user_id)
+  AND status IN ('open', 'in_review')
+RETURNING *;
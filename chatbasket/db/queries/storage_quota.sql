@@ -0,0 +1,118 @@
+-- ===========================================
+-- Storage quota queries for sqlc
+-- ===========================================
+
+-- name: GetStorageQuotaHeadroom :one
+-- Returns the owner's plan and usage, plus the bytes held by the files an upload will replace
+SELECT
+    COALESCE((SELECT su.plan FROM storage_usage AS su WHERE su.owner_id = sqlc.arg(owner_id)), 'free')::text AS plan,
+    COALESCE((SELECT su.used_bytes FROM storage_usage AS su WHERE su.owner_id = sqlc.arg(owner_id)), 0)::bigint AS used_bytes,
+    COALESCE((
+        SELECT sum(so.size_bytes)
+        FROM storage_objects AS so
+        WHERE so.bucket_id = sqlc.arg(bucket_id)
+          AND so.file_id = ANY(sqlc.arg(file_ids)::text[])
+          AND so.owner_id = sqlc.arg(owner_id)
+    ), 0)::bigint AS replaced_bytes;
+
+-- name: EnsureStorageUsage :exec
+-- Creates the owner's totals on the free plan, so ReserveStorageBytes has a row to lock
+INSERT INTO storage_usage (owner_id)
+VALUES ($1)
+ON CONFLICT (owner_id) DO NOTHING;
+
+-- name: ReserveStorageBytes :one
+-- Adds the bytes of an upload to the owner's usage before it is stored, if that keeps them
+-- within their plan's limit once the files it replaces are released. The row lock makes
+-- concurrent uploads queue, so they cannot pass the check together. Returns no row when the
+-- upload does not fit. limits[i] is the limit of plans[i].
+UPDATE storage_usage AS su
+SET used_bytes = su.used_bytes + sqlc.arg(bytes)::bigint
+WHERE su.owner_id = sqlc.arg(owner_id)
+  AND su.used_bytes + sqlc.arg(bytes)::bigint - COALESCE((
+        SELECT sum(so.size_bytes)
+        FROM storage_objects AS so
+        WHERE so.bucket_id = sqlc.arg(bucket_id)
+          AND so.file_id = ANY(sqlc.arg(file_ids)::text[])
+          AND so.owner_id = sqlc.arg(owner_id)
+      ), 0) <= (sqlc.arg(limits)::bigint[])[array_position(sqlc.arg(plans)::text[], su.plan)]
+RETURNING su.used_bytes;
+
+-- name: ReleaseStorageBytes :exec
+-- Gives back a reservation whose upload failed
+UPDATE storage_usage
+SET used_bytes = GREATEST(used_bytes - sqlc.arg(bytes)::bigint, 0)
+WHERE owner_id = sqlc.arg(owner_id);
+
+-- name: RecordStorageObject :one
+-- Upserts an object and settles its reservation: the owner's totals move by the size
+-- difference, less the bytes reserved for it. Keys are per-owner (e.g. avatars use the user
+-- id), so a replaced object always belongs to the same owner.
+WITH previous AS (
+    SELECT so.size_bytes
+    FROM storage_objects AS so
+    WHERE so.bucket_id = sqlc.arg(bucket_id)
+      AND so.file_id = sqlc.arg(file_id)
+    FOR UPDATE
+), upserted AS (
+    INSERT INTO storage_objects (bucket_id, file_id, owner_id, size_bytes, kind)
+    VALUES (sqlc.arg(bucket_id), sqlc.arg(file_id), sqlc.arg(owner_id), sqlc.arg(size_bytes), sqlc.arg(kind))
+    ON CONFLICT (bucket_id, file_id) DO UPDATE
+    SET size_bytes = EXCLUDED.size_bytes,
+        kind       = EXCLUDED.kind
+    RETURNING 1
+), change AS (
+    SELECT
+        sqlc.arg(size_bytes)::bigint
+            - COALESCE((SELECT size_bytes FROM previous), 0)
+            - sqlc.arg(reserved_bytes)::bigint AS bytes,
+        CASE WHEN EXISTS (SELECT 1 FROM previous) THEN 0 ELSE 1 END AS objects
+)
+INSERT INTO storage_usage (owner_id, used_bytes, object_count)
+SELECT sqlc.arg(owner_id), GREATEST(c.bytes, 0), c.objects
+FROM change AS c
+ON CONFLICT (owner_id) DO UPDATE
+SET used_bytes   = GREATEST(storage_usage.used_bytes + (SELECT bytes FROM change), 0),
+    object_count = storage_usage.object_count + EXCLUDED.object_count
+RETURNING used_bytes;
+
+-- name: DeleteStorageObject :execrows
+-- Forgets an object and releases its bytes from the owner's totals.
+WITH deleted AS (
+    DELETE FROM storage_objects AS so
+    WHERE so.bucket_id = @bucket_id
+      AND so.file_id = @file_id
+    RETURNING so.owner_id, so.size_bytes
+)
+UPDATE storage_usage AS su
+SET used_bytes   = GREATEST(su.used_bytes - d.size_bytes, 0),
+    object_count = GREATEST(su.object_count - 1, 0)
+FROM deleted AS d
+WHERE su.owner_id = d.owner_id;
+
+-- name: GetStorageUsage :one
+-- Returns the owner's totals; owners with nothing stored get the free plan and zeros
+SELECT
+    COALESCE((SELECT su.plan FROM storage_usage AS su WHERE su.owner_id = $1), 'free')::text AS plan,
+    COALESCE((SELECT su.used_bytes FROM storage_usage AS su WHERE su.owner_id = $1), 0)::bigint AS used_bytes,
+    COALESCE((SELECT su.object_count FROM storage_usage AS su WHERE su.owner_id = $1), 0)::integer AS object_count;
+
+-- name: GetStorageUsageByKind :many
+-- Breaks the owner's usage down by object kind
+SELECT
+    so.kind,
+    count(*)::integer            AS objects,
+    sum(so.size_bytes)::bigint   AS bytes
+FROM storage_objects AS so
+WHERE so.owner_id = $1
+GROUP BY so.kind
+ORDER BY so.kind;
+
+-- name: DeleteStorageOwner :exec
+-- Drops every object record and the totals of a deleted owner
+WITH deleted AS (
+    DELETE FROM storage_objects AS so
+    WHERE so.owner_id = $1
+)
+DELETE FROM storage_usage AS su
+WHERE su.owner_id = $1;
@@ -0,0 +1,59 @@
+-- ===========================================
+-- TOTP second factor queries for sqlc
+-- ===========================================
+
+-- name: UpsertPendingTotpFactor :one
+-- Starts (or restarts) an enrolment; returns no row when TOTP is already enabled
+INSERT INTO totp_factors (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0
+WHERE totp_factors.enabled_at IS NULL
+RETURNING user_id;
+
+-- name: GetTotpFactor :one
+SELECT * FROM totp_factors
+WHERE user_id = $1;
+
+-- name: EnableTotpFactor :execrows
+-- Activates a pending enrolment with the step of the code that confirmed it
+UPDATE totp_factors
+SET enabled_at = now(),
+    last_used_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id)
+  AND enabled_at IS NULL;
+
+-- name: UseTotpStep :execrows
+-- Accepts a code's time step once; returns 0 rows for a replayed or older step
+UPDATE totp_factors
+SET last_used_step = sqlc.arg(step)
+WHERE user_id = sqlc.arg(user_id)
+  AND enabled_at IS NOT NULL
+  AND last_used_step < sqlc.arg(step);
+
+-- name: DeleteTotpFactor :exec
+-- Removes the factor; recovery codes go with it (ON DELETE CASCADE)
+DELETE FROM totp_factors
+WHERE user_id = $1;
+
+-- name: CreateTotpRecoveryCode :exec
+INSERT INTO totp_recovery_codes (id, user_id, code_hash)
+VALUES ($1, $2, $3);
+
+-- name: DeleteTotpRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1;
+
+-- name: ListUnusedTotpRecoveryCodes :many
+SELECT id, code_hash
+FROM totp_recovery_codes
+WHERE user_id = $1
+  AND used_at IS NULL;
+
+-- name: UseTotpRecoveryCode :execrows
+-- Burns a recovery code; returns 0 rows if it was already used
+UPDATE totp_recovery_codes
+SET used_at = now()
+WHERE id = $1
+  AND used_at IS NULL;
@@ -0,0 +1,14 @@
+-- ===========================================
+-- User preference queries for sqlc
+-- ===========================================
+
+-- name: GetUserLocale :one
+SELECT locale FROM user_preferences WHERE user_id = $1;
+
+-- name: SetUserLocale :exec
+INSERT INTO user_preferences (user_id, locale)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET locale = EXCLUDED.locale;
+
+-- name: DeleteUserPreferences :exec
+DELETE FROM user_preferences WHERE user_id = $1;
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+// AccountHandler serves account-wide actions that span personal and public mode.
+type AccountHandler struct {
+	Service *services.GlobalService
+}
+
+func NewAccountHandler(service *services.GlobalService) *AccountHandler {
+	return &AccountHandler{Service: service}
+}
+
+// RequestDeletion emails the OTP that ConfirmDeletion expects.
+func (h *AccountHandler) RequestDeletion(c echo.Context) error {
+	userId, ok := c.Get("userId").(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+	email, ok := c.Get("email").(string)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid email context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.RequestAccountDeletion(c.Request().Context(), userId, email)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ConfirmDeletion verifies the OTP and schedules the deletion of the whole account.
+func (h *AccountHandler) ConfirmDeletion(c echo.Context) error {
+	var payload model.OtpVerificationPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid otp payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+	if payload.Secret == "" {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Missing required fields",
+			Type:    "missing_value",
+		})
+	}
+
+	userId, ok := c.Get("userId").(string)
+	uuidUserId, okUUID := c.Get("uuidUserId").(uuid.UUID)
+	if !ok || !okUUID {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.ConfirmAccountDeletion(c.Request().Context(), &payload, model.UserId{StringUserId: userId, UuidUserId: uuidUserId})
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	return c.JSON(http.StatusAccepted, res)
+}
+
+// UpdateLocale sets the language the user's emails are written in.
+func (h *AccountHandler) UpdateLocale(c echo.Context) error {
+	var payload model.UpdateLocalePayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid locale payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.SetEmailLocale(c.Request().Context(), &payload, userId)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+// AdminHandler serves the moderation API for personal-mode users. Its routes are behind
+// middleware.AdminMiddleware.
+type AdminHandler struct {
+	Service *services.GlobalService
+}
+
+func NewAdminHandler(service *services.GlobalService) *AdminHandler {
+	return &AdminHandler{Service: service}
+}
+
+// SearchUsers looks users up by the `q` query parameter: an id, an email, a username or part of
+// a display name.
+func (h *AdminHandler) SearchUsers(c echo.Context) error {
+	res, err := h.Service.AdminSearchUsers(c.Request().Context(), c.QueryParam("q"))
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// RecentUsers lists accounts newest first; pass `next_before` of a page as `before` for the next.
+func (h *AdminHandler) RecentUsers(c echo.Context) error {
+	limit := 0
+	if s := c.QueryParam("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, &model.ApiError{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid limit",
+				Type:    "bad_request",
+			})
+		}
+		limit = n
+	}
+
+	res, err := h.Service.AdminListRecentUsers(c.Request().Context(), c.QueryParam("before"), limit)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) BlockUser(c echo.Context) error {
+	var payload model.AdminBlockPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid block payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+
+	adminId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.AdminSetUserBlock(c.Request().Context(), adminId.UuidUserId, c.Param("id"), true, payload.Reason)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) UnblockUser(c echo.Context) error {
+	adminId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.AdminSetUserBlock(c.Request().Context(), adminId.UuidUserId, c.Param("id"), false, "")
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) ContactRequestVolume(c echo.Context) error {
+	res, err := h.Service.AdminContactRequestVolume(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// AuditEvents queries the audit log; see model.AuditEventFilter for the query parameters.
+func (h *AdminHandler) AuditEvents(c echo.Context) error {
+	var filter model.AuditEventFilter
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &filter); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid audit query: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+
+	res, err := h.Service.AdminListAuditEvents(c.Request().Context(), &filter)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Reports lists the moderation queue; see model.AdminReportFilter for the query parameters.
+func (h *AdminHandler) Reports(c echo.Context) error {
+	var filter model.AdminReportFilter
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &filter); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid report query: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+
+	res, err := h.Service.AdminListReports(c.Request().Context(), &filter)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) Report(c echo.Context) error {
+	res, err := h.Service.AdminGetReport(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) StartReportReview(c echo.Context) error {
+	adminId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.AdminStartReportReview(c.Request().Context(), adminId.UuidUserId, c.Param("id"))
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *AdminHandler) ResolveReport(c echo.Context) error {
+	var payload model.ResolveReportPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid resolve payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+
+	adminId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.AdminResolveReport(c.Request().Context(), adminId.UuidUserId, c.Param("id"), &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// EscalateReport admin-blocks the reported user and closes every open report against them.
+func (h *AdminHandler) EscalateReport(c echo.Context) error {
+	var payload model.EscalateReportPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid escalate payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+
+	adminId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.AdminEscalateReport(c.Request().Context(), adminId.UuidUserId, c.Param("id"), &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+// ApiKeyHandler manages the user's API keys. Its routes need a session: a key cannot manage keys.
+type ApiKeyHandler struct {
+	Service *services.GlobalService
+}
+
+func NewApiKeyHandler(service *services.GlobalService) *ApiKeyHandler {
+	return &ApiKeyHandler{Service: service}
+}
+
+// Create returns the new key; it is not shown again.
+func (h *ApiKeyHandler) Create(c echo.Context) error {
+	var payload model.CreateApiKeyPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid API key payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.CreateApiKey(c.Request().Context(), userId, &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+func (h *ApiKeyHandler) List(c echo.Context) error {
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.ListApiKeys(c.Request().Context(), userId)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Delete revokes a key immediately.
+func (h *ApiKeyHandler) Delete(c echo.Context) error {
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.DeleteApiKey(c.Request().Context(), userId, c.Param("id"))
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+// ExportHandler serves personal data exports. Downloads are authorized by the signed `t` query
+// parameter of the emailed link instead of a session, so the link also works in a browser.
+type ExportHandler struct {
+	Service *services.GlobalService
+}
+
+func NewExportHandler(service *services.GlobalService) *ExportHandler {
+	return &ExportHandler{Service: service}
+}
+
+// RequestExport queues an export of the user's data; the download link is emailed when it is ready.
+func (h *ExportHandler) RequestExport(c echo.Context) error {
+	uuidUserId, ok := c.Get("uuidUserId").(uuid.UUID)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.RequestDataExport(c.Request().Context(), uuidUserId)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	return c.JSON(http.StatusAccepted, res)
+}
+
+// GetExport returns the state of the user's latest export.
+func (h *ExportHandler) GetExport(c echo.Context) error {
+	uuidUserId, ok := c.Get("uuidUserId").(uuid.UUID)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.GetDataExportStatus(c.Request().Context(), uuidUserId)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// Download streams an export archive once; the archive is deleted afterwards.
+func (h *ExportHandler) Download(c echo.Context) error {
+	ctx := c.Request().Context()
+	export, reader, apiErr := h.Service.OpenDataExportDownload(ctx, c.Param("id"), c.QueryParam("t"))
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+	defer reader.Body.Close()
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set(echo.HeaderContentDisposition, `attachment; filename="chatbasket-export.zip"`)
+	if reader.ContentLength >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(reader.ContentLength, 10))
+	}
+	if err := c.Stream(http.StatusOK, "application/zip", reader.Body); err != nil {
+		// The link is used up either way; the worker purges the archive
+		return err
+	}
+
+	if err := h.Service.PurgeDataExportFile(ctx, export.ID, *export.FileID); err != nil {
+		log.Printf("failed to purge export %s: %v", export.ID, err)
+	}
+	return nil
+}
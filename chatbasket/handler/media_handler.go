@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/personalServices"
+	"chatbasket/publicServices"
+	"chatbasket/services"
+	"chatbasket/utils"
+)
+
+// MediaHandler serves stored media through the backend so storage tokens never reach clients.
+// Requests are authorized by the signed `t` query parameter instead of a session, since
+// image loaders cannot attach session headers.
+type MediaHandler struct {
+	Personal   *personalServices.Service
+	Public     *publicServices.Service
+	SigningKey []byte
+}
+
+func NewMediaHandler(personal *personalServices.Service, public *publicServices.Service, signingKey []byte) *MediaHandler {
+	return &MediaHandler{Personal: personal, Public: public, SigningKey: signingKey}
+}
+
+func (h *MediaHandler) GetAvatar(c echo.Context) error {
+	claims, err := utils.VerifyAvatarToken(c.QueryParam("t"), c.Param("id"), h.SigningKey, time.Now())
+	if err != nil {
+		message := "invalid_avatar_url"
+		if errors.Is(err, utils.ErrAvatarTokenExpired) {
+			message = "avatar_url_expired"
+		}
+		return c.JSON(http.StatusForbidden, &model.ApiError{
+			Code:    http.StatusForbidden,
+			Message: message,
+			Type:    "forbidden",
+		})
+	}
+
+	ifNoneMatch := c.Request().Header.Get("If-None-Match")
+
+	var stream *services.AvatarStream
+	var apiErr *model.ApiError
+	switch claims.Scope {
+	case utils.AvatarScopePersonal:
+		stream, apiErr = h.Personal.OpenAvatar(c.Request().Context(), claims, ifNoneMatch)
+	case utils.AvatarScopePublic:
+		stream, apiErr = h.Public.OpenAvatar(c.Request().Context(), claims, ifNoneMatch)
+	default:
+		apiErr = &model.ApiError{Code: http.StatusForbidden, Message: "invalid_avatar_url", Type: "forbidden"}
+	}
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+
+	// The URL is viewer-specific, so only the viewer's own cache may keep it, and no longer than the URL is valid
+	maxAge := int(time.Until(claims.Expiry).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	header := c.Response().Header()
+	header.Set("ETag", stream.ETag)
+	header.Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+
+	if stream.NotModified {
+		return c.NoContent(http.StatusNotModified)
+	}
+	defer stream.Body.Close()
+
+	contentType := stream.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if stream.ContentLength >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(stream.ContentLength, 10))
+	}
+	return c.Stream(http.StatusOK, contentType, stream.Body)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+// PasskeyHandler serves WebAuthn passkey registration, management and login.
+type PasskeyHandler struct {
+	Service *services.GlobalService
+}
+
+func NewPasskeyHandler(service *services.GlobalService) *PasskeyHandler {
+	return &PasskeyHandler{Service: service}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create; it needs the password
+// and, when TOTP is enabled, a TOTP or recovery code.
+func (h *PasskeyHandler) BeginRegistration(c echo.Context) error {
+	var payload model.BeginPasskeyRegistrationPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid passkey payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+	if payload.Password == "" {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Missing required fields",
+			Type:    "missing_value",
+		})
+	}
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid email context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.BeginPasskeyRegistration(c.Request().Context(), userId, email, &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// FinishRegistration stores the passkey created by the authenticator.
+func (h *PasskeyHandler) FinishRegistration(c echo.Context) error {
+	var payload model.PasskeyRegistrationPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid passkey payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+	if payload.ChallengeId == "" || len(payload.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Missing required fields",
+			Type:    "missing_value",
+		})
+	}
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid email context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.FinishPasskeyRegistration(c.Request().Context(), userId, email, &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+// List returns the user's passkeys.
+func (h *PasskeyHandler) List(c echo.Context) error {
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.ListPasskeys(c.Request().Context(), userId)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Delete removes one of the user's passkeys.
+func (h *PasskeyHandler) Delete(c echo.Context) error {
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.DeletePasskey(c.Request().Context(), userId, c.Param("id"))
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// BeginLogin returns the options for navigator.credentials.get. No session or email is needed.
+func (h *PasskeyHandler) BeginLogin(c echo.Context) error {
+	res, err := h.Service.BeginPasskeyLogin(c.Request().Context())
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// FinishLogin verifies the assertion and answers like LoginVerification.
+func (h *PasskeyHandler) FinishLogin(c echo.Context) error {
+	var payload model.PasskeyLoginPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Invalid passkey payload: " + err.Error(), Type: "bad_request"})
+	}
+	if payload.ChallengeId == "" || payload.Platform == "" || len(payload.Credential) == 0 {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	}
+
+	user, err := h.Service.FinishPasskeyLogin(c.Request().Context(), &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return respondWithSession(c, h.Service, payload.Platform, user)
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+type StorageHandler struct {
+	Service *services.GlobalService
+}
+
+func NewStorageHandler(service *services.GlobalService) *StorageHandler {
+	return &StorageHandler{Service: service}
+}
+
+// GetUsage returns the caller's storage plan, limit and usage across every bucket.
+func (h *StorageHandler) GetUsage(c echo.Context) error {
+	uuidUserId, ok := c.Get("uuidUserId").(uuid.UUID)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	usage, err := h.Service.GetStorageUsage(c.Request().Context(), uuidUserId)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
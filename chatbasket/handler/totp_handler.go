@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/services"
+)
+
+// TotpHandler manages the optional TOTP second factor enforced at login.
+type TotpHandler struct {
+	Service *services.GlobalService
+}
+
+func NewTotpHandler(service *services.GlobalService) *TotpHandler {
+	return &TotpHandler{Service: service}
+}
+
+// userIdFromContext reads the ids set by the session middleware.
+func userIdFromContext(c echo.Context) (model.UserId, bool) {
+	userId, ok := c.Get("userId").(string)
+	uuidUserId, okUUID := c.Get("uuidUserId").(uuid.UUID)
+	return model.UserId{StringUserId: userId, UuidUserId: uuidUserId}, ok && okUUID && userId != ""
+}
+
+// Enroll returns a new secret and otpauth:// URI; TOTP stays off until Activate.
+func (h *TotpHandler) Enroll(c echo.Context) error {
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+	email, ok := c.Get("email").(string)
+	if !ok || email == "" {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid email context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.EnrollTotp(c.Request().Context(), userId, email)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Activate turns TOTP on with a first code from the app and returns the recovery codes.
+func (h *TotpHandler) Activate(c echo.Context) error {
+	var payload model.TotpCodePayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid totp payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+	if payload.Code == "" {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Missing required fields",
+			Type:    "missing_value",
+		})
+	}
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.ActivateTotp(c.Request().Context(), userId, payload.Code)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Disable turns TOTP off; it needs the password and a TOTP or recovery code.
+func (h *TotpHandler) Disable(c echo.Context) error {
+	var payload model.DisableTotpPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid totp payload: " + err.Error(),
+			Type:    "bad_request",
+		})
+	}
+	if payload.Password == "" || (payload.Code == "" && payload.RecoveryCode == "") {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Missing required fields",
+			Type:    "missing_value",
+		})
+	}
+	userId, ok := userIdFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, err := h.Service.DisableTotp(c.Request().Context(), userId, &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"chatbasket/model"
+	"chatbasket/personalServices"
+	"chatbasket/publicServices"
+	"chatbasket/tus"
+	"chatbasket/utils"
+)
+
+// Upload purposes, sent as the "purpose" key of Upload-Metadata. They decide the size cap and
+// which service receives the completed file.
+const (
+	TusPurposePersonalAvatar = "personal_avatar"
+	TusPurposePublicAvatar   = "public_avatar"
+)
+
+var tusMaxSizes = map[string]int64{
+	TusPurposePersonalAvatar: utils.AvatarMaxUploadBytes,
+	TusPurposePublicAvatar:   utils.AvatarMaxUploadBytes,
+}
+
+// TusHandler implements the tus 1.0 resumable upload protocol (core plus the creation,
+// termination and expiration extensions). Partial uploads live in Store; once the last byte
+// arrives the file is handed to the service for its purpose and the upload is removed.
+type TusHandler struct {
+	Store    *tus.Store
+	Personal *personalServices.Service
+	Public   *publicServices.Service
+	// BasePath is the mount point used to build Location headers, e.g. "/uploads".
+	BasePath string
+}
+
+func NewTusHandler(store *tus.Store, personal *personalServices.Service, public *publicServices.Service, basePath string) *TusHandler {
+	return &TusHandler{Store: store, Personal: personal, Public: public, BasePath: basePath}
+}
+
+// tusError writes an ApiError whose type is the snake_cased status text (e.g. "not_found").
+func tusError(c echo.Context, code int, message string) error {
+	errType := strings.ToLower(strings.ReplaceAll(http.StatusText(code), " ", "_"))
+	if code == http.StatusRequestEntityTooLarge {
+		errType = "payload_too_large"
+	}
+	return c.JSON(code, &model.ApiError{Code: code, Message: message, Type: errType})
+}
+
+// Middleware sets Tus-Resumable on every response and rejects other protocol versions.
+// OPTIONS is exempt so clients can discover the supported version.
+func (h *TusHandler) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Tus-Resumable", tus.Version)
+		if c.Request().Method != http.MethodOptions && c.Request().Header.Get("Tus-Resumable") != tus.Version {
+			c.Response().Header().Set("Tus-Version", tus.Version)
+			return tusError(c, http.StatusPreconditionFailed, "unsupported_tus_version")
+		}
+		return next(c)
+	}
+}
+
+func (h *TusHandler) Options(c echo.Context) error {
+	header := c.Response().Header()
+	header.Set("Tus-Version", tus.Version)
+	header.Set("Tus-Extension", tus.Extensions)
+	header.Set("Tus-Max-Size", strconv.FormatInt(utils.AvatarMaxUploadBytes, 10))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Create handles POST: Upload-Length is required (deferred length is not supported).
+func (h *TusHandler) Create(c echo.Context) error {
+	userId, ok := c.Get("userId").(string)
+	if !ok {
+		return tusError(c, http.StatusInternalServerError, "Invalid user context")
+	}
+
+	length, err := strconv.ParseInt(c.Request().Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return tusError(c, http.StatusBadRequest, "invalid_upload_length")
+	}
+	meta, err := tus.ParseMetadata(c.Request().Header.Get("Upload-Metadata"))
+	if err != nil {
+		return tusError(c, http.StatusBadRequest, "invalid_upload_metadata")
+	}
+	maxSize, ok := tusMaxSizes[meta["purpose"]]
+	if !ok {
+		return tusError(c, http.StatusBadRequest, "invalid_upload_purpose")
+	}
+	if length > maxSize {
+		return tusError(c, http.StatusRequestEntityTooLarge, "file_too_large")
+	}
+
+	upload, err := h.Store.Create(userId, length, meta)
+	if err != nil {
+		return tusError(c, http.StatusInternalServerError, "Failed to create upload: "+err.Error())
+	}
+
+	header := c.Response().Header()
+	header.Set("Location", h.BasePath+"/"+upload.ID)
+	header.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	return c.NoContent(http.StatusCreated)
+}
+
+// load returns the caller's upload; uploads of other users are reported as not found.
+func (h *TusHandler) load(c echo.Context) (*tus.Upload, error) {
+	upload, err := h.Store.Get(c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, tus.ErrNotFound):
+			return nil, tusError(c, http.StatusNotFound, "upload_not_found")
+		case errors.Is(err, tus.ErrExpired):
+			return nil, tusError(c, http.StatusGone, "upload_expired")
+		}
+		return nil, tusError(c, http.StatusInternalServerError, "Failed to load upload: "+err.Error())
+	}
+	if userId, _ := c.Get("userId").(string); upload.OwnerID != userId {
+		return nil, tusError(c, http.StatusNotFound, "upload_not_found")
+	}
+	return upload, nil
+}
+
+func (h *TusHandler) Head(c echo.Context) error {
+	upload, err := h.load(c)
+	if upload == nil {
+		return err
+	}
+	header := c.Response().Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	header.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	return c.NoContent(http.StatusOK)
+}
+
+// Patch appends a chunk at Upload-Offset. When the upload becomes complete the file is handed
+// off before responding; a PATCH with an empty body at the final offset retries a hand-off
+// that failed with a server error.
+func (h *TusHandler) Patch(c echo.Context) error {
+	if c.Request().Header.Get(echo.HeaderContentType) != "application/offset+octet-stream" {
+		return tusError(c, http.StatusUnsupportedMediaType, "invalid_content_type")
+	}
+	offset, err := strconv.ParseInt(c.Request().Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return tusError(c, http.StatusBadRequest, "invalid_upload_offset")
+	}
+	if upload, err := h.load(c); upload == nil {
+		return err
+	}
+
+	unlock, err := h.Store.Lock(c.Param("id"))
+	if err != nil {
+		return tusError(c, http.StatusLocked, "upload_locked")
+	}
+	defer unlock()
+
+	upload, err := h.Store.Append(c.Param("id"), offset, c.Request().Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, tus.ErrOffsetMismatch):
+			return tusError(c, http.StatusConflict, "upload_offset_mismatch")
+		case errors.Is(err, tus.ErrExceedsLength):
+			return tusError(c, http.StatusRequestEntityTooLarge, "upload_length_exceeded")
+		case upload != nil:
+			// The client went away or hit the body limit; what arrived is kept for HEAD/resume
+			c.Response().Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+			var he *echo.HTTPError
+			if errors.As(err, &he) && he.Code == http.StatusRequestEntityTooLarge {
+				return tusError(c, http.StatusRequestEntityTooLarge, "chunk_too_large")
+			}
+			return tusError(c, http.StatusBadRequest, "upload_interrupted")
+		}
+		return tusError(c, http.StatusInternalServerError, "Failed to store chunk: "+err.Error())
+	}
+
+	header := c.Response().Header()
+	header.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	header.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+
+	if upload.Complete() {
+		if apiErr := h.finish(c, upload); apiErr != nil {
+			// Client errors (e.g. not an image) cannot be fixed by retrying the same bytes
+			if apiErr.Code < http.StatusInternalServerError {
+				h.remove(upload.ID)
+			}
+			return c.JSON(apiErr.Code, apiErr)
+		}
+		h.remove(upload.ID)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// finish hands a completed upload to the service for its purpose.
+func (h *TusHandler) finish(c echo.Context, upload *tus.Upload) *model.ApiError {
+	f, err := h.Store.Open(upload.ID)
+	if err != nil {
+		return &model.ApiError{Code: http.StatusInternalServerError, Message: "Failed to open upload: " + err.Error(), Type: "internal_server_error"}
+	}
+	defer f.Close()
+
+	ctx := c.Request().Context()
+	switch upload.Metadata["purpose"] {
+	case TusPurposePersonalAvatar:
+		uuidUserId, ok := c.Get("uuidUserId").(uuid.UUID)
+		if !ok {
+			return &model.ApiError{Code: http.StatusInternalServerError, Message: "Invalid user context", Type: "internal_server_error"}
+		}
+		_, apiErr := h.Personal.UploadUserProfilePicture(ctx, f, model.UserId{StringUserId: upload.OwnerID, UuidUserId: uuidUserId})
+		return apiErr
+	case TusPurposePublicAvatar:
+		_, apiErr := h.Public.UploadUserProfilePicture(ctx, f, upload.OwnerID)
+		return apiErr
+	}
+	return &model.ApiError{Code: http.StatusBadRequest, Message: "invalid_upload_purpose", Type: "bad_request"}
+}
+
+func (h *TusHandler) remove(id string) {
+	if err := h.Store.Remove(id); err != nil && !errors.Is(err, tus.ErrNotFound) {
+		log.Printf("tus: remove upload %s failed: %v", id, err)
+	}
+}
+
+// Delete implements the termination extension.
+func (h *TusHandler) Delete(c echo.Context) error {
+	upload, err := h.load(c)
+	if upload == nil {
+		return err
+	}
+	unlock, err := h.Store.Lock(upload.ID)
+	if err != nil {
+		return tusError(c, http.StatusLocked, "upload_locked")
+	}
+	defer unlock()
+
+	if err := h.Store.Remove(upload.ID); err != nil && !errors.Is(err, tus.ErrNotFound) {
+		return tusError(c, http.StatusInternalServerError, "Failed to delete upload: "+err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
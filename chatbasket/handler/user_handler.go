@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"crypto/rand"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"chatbasket/model"
+	"chatbasket/otp"
 	"chatbasket/services"
 )
 
@@ -65,54 +68,7 @@ func (h *UserHandler) AcountVerification(c echo.Context) error {
 		return c.JSON(err.Code, err)
 	}
 
-	// Handle web platform - set httpOnly cookies
-	if payload.Platform == "web" {
-
-		expiry, err := time.Parse(time.RFC3339, user.SessionExpiry)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, model.ApiError{Code: http.StatusInternalServerError, Message: "invalid session expiry format", Type: "internal_server_error"})
-		}
-
-		// Set cookies with actual values (before they get emptied in response)
-		sessionCookie := &http.Cookie{
-			Name:     "sessionId",
-			Value:    user.SessionID,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   true,
-			// Domain:   "localhost:8081",
-			Domain:   "chatbasket.me",
-			SameSite: http.SameSiteNoneMode,
-			Expires:  expiry,
-		}
-
-		userCookie := &http.Cookie{
-			Name:     "userId",
-			Value:    user.UserId,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteNoneMode,
-			// Domain:   "localhost:8081",
-			Domain:   "chatbasket.me",
-			Expires: expiry,
-		}
-
-		c.SetCookie(sessionCookie)
-		c.SetCookie(userCookie)
-
-		// Return SessionResponse with empty sensitive fields for web
-		webResponse := &model.SessionResponse{
-			UserId:        "",
-			Name:          user.Name,
-			Email:         user.Email,
-			SessionID:     "",
-			SessionExpiry: user.SessionExpiry,
-		}
-		return c.JSON(http.StatusOK, webResponse)
-	}
-
-	return c.JSON(http.StatusOK, user)
+	return respondWithSession(c, h.Service, payload.Platform, user)
 }
 
 func (h *UserHandler) Login(c echo.Context) error {
@@ -124,8 +80,27 @@ func (h *UserHandler) Login(c echo.Context) error {
 	}
 
 	// Validate required fields
-	if payload.Email == "" || payload.Password == "" {
-		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	switch payload.Method {
+	case "", model.LoginMethodOtp:
+		if payload.Email == "" || payload.Password == "" {
+			return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+		}
+	case model.LoginMethodMagicLink:
+		if payload.Email == "" {
+			return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+		}
+		// Web clients keep the nonce in an httpOnly cookie, so the link only works in this browser
+		if payload.Platform == "web" && payload.Nonce == "" {
+			payload.Nonce = rand.Text()
+		}
+		if len(payload.Nonce) < minMagicLinkNonceLength {
+			return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "A nonce of at least 16 characters is required", Type: "bad_request"})
+		}
+		if payload.Platform == "web" {
+			c.SetCookie(magicLinkNonceCookie(payload.Nonce, int(otp.LinkTTL.Seconds())))
+		}
+	default:
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Unknown login method", Type: "bad_request"})
 	}
 
 	// Login via service
@@ -154,52 +129,208 @@ func (h *UserHandler) LoginVerification(c echo.Context) error {
 		return c.JSON(err.Code, err)
 	}
 
-	// Handle web platform - set httpOnly cookies
-	if payload.Platform == "web" {
+	return respondWithSession(c, h.Service, payload.Platform, user)
+}
 
-		expiry, err := time.Parse(time.RFC3339, user.SessionExpiry)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, model.ApiError{Code: http.StatusInternalServerError, Message: "invalid session expiry format", Type: "internal_server_error"})
-		}
+// ForgotPassword emails a reset code. It answers the same whether or not the email is registered.
+func (h *UserHandler) ForgotPassword(c echo.Context) error {
+	var payload model.ForgotPasswordPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Invalid forgot password payload: " + err.Error(), Type: "bad_request"})
+	}
+	if payload.Email == "" {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	}
+
+	res, err := h.Service.ForgotPassword(c.Request().Context(), &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ResetPassword sets a new password with the emailed code and signs out every session.
+func (h *UserHandler) ResetPassword(c echo.Context) error {
+	var payload model.ResetPasswordPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Invalid reset password payload: " + err.Error(), Type: "bad_request"})
+	}
+	if payload.Email == "" || payload.Secret == "" || payload.NewPassword == "" {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	}
+	// Appwrite's own minimum
+	if len(payload.NewPassword) < 8 {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Password must be at least 8 characters", Type: "bad_request"})
+	}
+
+	res, err := h.Service.ResetPassword(c.Request().Context(), &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
 
-		// Set cookies with actual values (before they get emptied in response)
-		sessionCookie := &http.Cookie{
-			Name:     "sessionId",
-			Value:    user.SessionID,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteNoneMode,
-			// Domain:   "localhost:8081",
-			Domain:   "chatbasket.me",
-			Expires: expiry,
+// MagicLink opens a login link in the browser that asked for it. The nonce comes from the
+// cookie set by Login. With a redirect URL configured the browser is sent there, with an
+// `error` query parameter on failure; when TOTP is required the token is passed along too, so
+// the web app can post it with the code to MagicLinkVerification.
+func (h *UserHandler) MagicLink(c echo.Context) error {
+	payload := model.MagicLinkVerificationPayload{Token: c.QueryParam("t"), Platform: "web"}
+	if cookie, err := c.Cookie(magicLinkNonceName); err == nil {
+		payload.Nonce = cookie.Value
+	}
+	redirectURL := h.Service.Auth.MagicLinkRedirectURL
+
+	if payload.Token == "" {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	}
+
+	user, err := h.Service.MagicLinkLogin(c.Request().Context(), &payload)
+	if err != nil {
+		if redirectURL == "" {
+			return c.JSON(err.Code, err)
 		}
+		query := url.Values{"error": {err.Type}}
+		if err.Message == "totp_required" {
+			query.Set("error", err.Message)
+			query.Set("token", payload.Token)
+		}
+		return c.Redirect(http.StatusSeeOther, redirectURL+"?"+query.Encode())
+	}
+
+	c.SetCookie(magicLinkNonceCookie("", -1))
+	if redirectURL == "" {
+		return respondWithSession(c, h.Service, payload.Platform, user)
+	}
+	if err := setSessionCookies(c, user); err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.Redirect(http.StatusSeeOther, redirectURL)
+}
 
-		userCookie := &http.Cookie{
-			Name:     "userId",
-			Value:    user.UserId,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteNoneMode,
-			// Domain:   "localhost:8081",
-			Domain:   "chatbasket.me",
-			Expires: expiry,
+// MagicLinkVerification signs in with a login link token posted by the app that asked for it,
+// e.g. a native app that opened the emailed link as a universal link.
+func (h *UserHandler) MagicLinkVerification(c echo.Context) error {
+	var payload model.MagicLinkVerificationPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Invalid magic link payload: " + err.Error(), Type: "bad_request"})
+	}
+	if payload.Nonce == "" {
+		if cookie, err := c.Cookie(magicLinkNonceName); err == nil {
+			payload.Nonce = cookie.Value
 		}
+	}
+
+	if payload.Token == "" || payload.Platform == "" {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	}
 
-		c.SetCookie(sessionCookie)
-		c.SetCookie(userCookie)
+	user, err := h.Service.MagicLinkLogin(c.Request().Context(), &payload)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	if payload.Platform == "web" {
+		c.SetCookie(magicLinkNonceCookie("", -1))
+	}
+	return respondWithSession(c, h.Service, payload.Platform, user)
+}
+
+// Refresh exchanges a native client's refresh token for a new access and refresh token pair.
+// The presented refresh token cannot be used again.
+func (h *UserHandler) Refresh(c echo.Context) error {
+	var payload model.RefreshTokenPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Invalid refresh payload: " + err.Error(), Type: "bad_request"})
+	}
+	if payload.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, model.ApiError{Code: http.StatusBadRequest, Message: "Missing required fields", Type: "missing_value"})
+	}
 
-		// Return SessionResponse with empty sensitive fields for web
-		webResponse := &model.SessionResponse{
-			UserId:        "",
-			Name:          user.Name,
-			Email:         user.Email,
-			SessionID:     "",
-			SessionExpiry: user.SessionExpiry,
+	tokens, err := h.Service.RefreshSessionTokens(c.Request().Context(), payload.RefreshToken)
+	if err != nil {
+		return c.JSON(err.Code, err)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+const (
+	magicLinkNonceName      = "magicLinkNonce"
+	minMagicLinkNonceLength = 16
+)
+
+// magicLinkNonceCookie holds the nonce a web login link is bound to; maxAge -1 deletes it.
+func magicLinkNonceCookie(nonce string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     magicLinkNonceName,
+		Value:    nonce,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		Domain:   "chatbasket.me",
+		MaxAge:   maxAge,
+	}
+}
+
+// respondWithSession answers a successful login. Web clients get the session in httpOnly
+// cookies and a response without the sensitive fields; native clients get it in the body,
+// together with their first access and refresh tokens.
+func respondWithSession(c echo.Context, gs *services.GlobalService, platform string, user *model.SessionResponse) error {
+	if platform != "web" {
+		if err := gs.IssueSessionTokens(c.Request().Context(), user); err != nil {
+			return c.JSON(err.Code, err)
 		}
-		return c.JSON(http.StatusOK, webResponse)
+		return c.JSON(http.StatusOK, user)
 	}
 
-	return c.JSON(http.StatusOK, user)
+	if err := setSessionCookies(c, user); err != nil {
+		return c.JSON(err.Code, err)
+	}
+
+	// Return SessionResponse with empty sensitive fields for web
+	webResponse := &model.SessionResponse{
+		UserId:        "",
+		Name:          user.Name,
+		Email:         user.Email,
+		SessionID:     "",
+		SessionExpiry: user.SessionExpiry,
+	}
+	return c.JSON(http.StatusOK, webResponse)
+}
+
+// setSessionCookies sets the httpOnly sessionId and userId cookies read by the session middleware.
+func setSessionCookies(c echo.Context, user *model.SessionResponse) *model.ApiError {
+	expiry, err := time.Parse(time.RFC3339, user.SessionExpiry)
+	if err != nil {
+		return &model.ApiError{Code: http.StatusInternalServerError, Message: "invalid session expiry format", Type: "internal_server_error"}
+	}
+
+	sessionCookie := &http.Cookie{
+		Name:     "sessionId",
+		Value:    user.SessionID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		// Domain:   "localhost:8081",
+		Domain:  "chatbasket.me",
+		Expires: expiry,
+	}
+
+	userCookie := &http.Cookie{
+		Name:     "userId",
+		Value:    user.UserId,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		// Domain:   "localhost:8081",
+		Domain:  "chatbasket.me",
+		Expires: expiry,
+	}
+
+	c.SetCookie(sessionCookie)
+	c.SetCookie(userCookie)
+	return nil
 }
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"chatbasket/appwriteinternal"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/appwrite/sdk-for-go/id"
+	"github.com/google/uuid"
+)
+
+// Appwrite sends through Appwrite Messaging. The message goes to the user's email target for
+// the address, which is created when the user has none yet.
+type Appwrite struct {
+	Service *appwriteinternal.AppwriteService
+}
+
+func NewAppwrite(as *appwriteinternal.AppwriteService) *Appwrite {
+	return &Appwrite{Service: as}
+}
+
+func (m *Appwrite) Send(ctx context.Context, msg *Message) error {
+	if msg.UserId == "" || msg.To == "" {
+		return ErrNoRecipient
+	}
+	targetId, err := m.emailTarget(msg.UserId, msg.To)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.Service.Message.CreateEmail(
+		id.Custom(uuid.NewString()),
+		msg.Subject,
+		msg.HTML,
+		m.Service.Message.WithCreateEmailTargets([]string{targetId}),
+		m.Service.Message.WithCreateEmailHtml(true),
+	)
+	if err != nil {
+		return fmt.Errorf("create email: %w", err)
+	}
+	return nil
+}
+
+// emailTarget returns the id of the user's email target for address, creating it if needed.
+func (m *Appwrite) emailTarget(userId, address string) (string, error) {
+	targets, err := m.Service.Users.ListTargets(userId)
+	if err != nil {
+		return "", fmt.Errorf("list targets: %w", err)
+	}
+	for _, target := range targets.Targets {
+		if target.ProviderType == "email" && strings.EqualFold(target.Identifier, address) {
+			return target.Id, nil
+		}
+	}
+
+	created, err := m.Service.Users.CreateTarget(userId, uuid.NewString(), "email", address)
+	if err != nil {
+		return "", fmt.Errorf("create target: %w", err)
+	}
+	return created.Id, nil
+}
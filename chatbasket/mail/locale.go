@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+
+	"golang.org/x/text/language"
+)
+
+type acceptLanguageKey struct{}
+
+var matcher = newMatcher()
+
+func newMatcher() language.Matcher {
+	tags := make([]language.Tag, len(Locales))
+	for i, locale := range Locales {
+		tags[i] = language.Make(locale)
+	}
+	return language.NewMatcher(tags)
+}
+
+// WithAcceptLanguage stores the request's Accept-Language header in ctx for emails sent while
+// handling it.
+func WithAcceptLanguage(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, acceptLanguageKey{}, header)
+}
+
+// AcceptLanguage returns the header stored by WithAcceptLanguage, or "".
+func AcceptLanguage(ctx context.Context) string {
+	header, _ := ctx.Value(acceptLanguageKey{}).(string)
+	return header
+}
+
+// MatchLocale returns the supported locale that best fits an Accept-Language header, or ""
+// when the header is empty, malformed or names no supported language.
+func MatchLocale(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return ""
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return ""
+	}
+	return Locales[index]
+}
@@ -0,0 +1,35 @@
+// Package mail renders the emails sent to users and delivers them through a pluggable Mailer.
+// Messages are built from the html/template and text/template files embedded under templates/,
+// one directory per locale.
+package mail
+
+import (
+	"context"
+	"errors"
+)
+
+// Backends selectable with MAIL_BACKEND.
+const (
+	BackendAppwrite = "appwrite"
+	BackendSMTP     = "smtp"
+	BackendFile     = "file"
+	BackendMemory   = "memory"
+)
+
+var ErrNoRecipient = errors.New("mail: message has no recipient")
+
+// Message is a rendered email to one user.
+type Message struct {
+	// UserId is the Appwrite user the address belongs to. The Appwrite backend delivers through
+	// the user's messaging targets; the other backends only use To.
+	UserId  string
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
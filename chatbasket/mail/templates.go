@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Templates. Each locale directory has <name>.html for the HTML body and <name>.txt for the
+// plain-text body, which also defines "<name>.subject".
+const (
+	TemplateOtp             = "otp"
+	TemplateDataExportReady = "data_export_ready"
+	TemplateMagicLink       = "magic_link"
+	TemplateReportOutcome   = "report_outcome"
+)
+
+// DefaultLocale is used when neither the user nor the request asks for a supported language.
+const DefaultLocale = "en"
+
+// OtpData fills TemplateOtp. Purpose is one of the otp.Purpose* values and picks the wording;
+// Detail names what is being verified for the identity purpose.
+type OtpData struct {
+	Purpose      string
+	Code         string
+	ValidMinutes int
+	Detail       string
+}
+
+// MagicLinkData fills TemplateMagicLink.
+type MagicLinkData struct {
+	Link         string
+	ValidMinutes int
+}
+
+// DataExportReadyData fills TemplateDataExportReady.
+type DataExportReadyData struct {
+	Link      string
+	ExpiresAt time.Time
+}
+
+// ReportOutcomeData fills TemplateReportOutcome. Actioned is false when the report was dismissed.
+type ReportOutcomeData struct {
+	Actioned bool
+	FiledAt  time.Time
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+type localeTemplates struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var (
+	templates = mustParseTemplates()
+	// Locales lists the supported locales, DefaultLocale first.
+	Locales = localeList()
+)
+
+func mustParseTemplates() map[string]*localeTemplates {
+	dirs, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		panic(err)
+	}
+	byLocale := make(map[string]*localeTemplates, len(dirs))
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		locale := dir.Name()
+		byLocale[locale] = &localeTemplates{
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/"+locale+"/*.html")),
+			text: texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/"+locale+"/*.txt")),
+		}
+	}
+	if byLocale[DefaultLocale] == nil {
+		panic("mail: no templates for the default locale")
+	}
+	return byLocale
+}
+
+func localeList() []string {
+	locales := []string{DefaultLocale}
+	for locale := range templates {
+		if locale != DefaultLocale {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// SupportedLocale reports whether there are templates for locale.
+func SupportedLocale(locale string) bool {
+	_, ok := templates[locale]
+	return ok
+}
+
+// Render builds the subject and bodies of template name in locale, falling back to
+// DefaultLocale when the locale is not supported or has no translation of the template yet.
+// The caller fills in the recipient.
+func Render(name, locale string, data any) (*Message, error) {
+	t, ok := templates[locale]
+	if !ok || t.text.Lookup(name+".txt") == nil || t.html.Lookup(name+".html") == nil {
+		t = templates[DefaultLocale]
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("mail: render %s subject: %w", name, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, fmt.Errorf("mail: render %s text: %w", name, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, fmt.Errorf("mail: render %s html: %w", name, err)
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,145 @@
+package mail
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+// sampleData fills every template, keyed by name.
+var sampleData = map[string][]any{
+	TemplateOtp: {
+		OtpData{Purpose: "signup", Code: "123456", ValidMinutes: 3},
+		OtpData{Purpose: "login", Code: "123456", ValidMinutes: 3},
+		OtpData{Purpose: "email_change", Code: "123456", ValidMinutes: 3},
+		OtpData{Purpose: "identity", Code: "123456", ValidMinutes: 3, Detail: "phone number"},
+		OtpData{Purpose: "identity", Code: "123456", ValidMinutes: 3},
+		OtpData{Purpose: "account_deletion", Code: "123456", ValidMinutes: 3},
+		OtpData{Purpose: "password_reset", Code: "123456", ValidMinutes: 3},
+	},
+	TemplateDataExportReady: {
+		DataExportReadyData{Link: "https://example.com/export?token=abc", ExpiresAt: time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)},
+	},
+	TemplateMagicLink: {
+		MagicLinkData{Link: "https://example.com/login?token=abc", ValidMinutes: 15},
+	},
+	TemplateReportOutcome: {
+		ReportOutcomeData{Actioned: true, FiledAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		ReportOutcomeData{Actioned: false, FiledAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+	},
+}
+
+func TestEveryTemplateRendersInEveryLocale(t *testing.T) {
+	if len(Locales) < 2 || Locales[0] != DefaultLocale {
+		t.Fatalf("Locales = %v, want %q first", Locales, DefaultLocale)
+	}
+	for _, locale := range Locales {
+		for name, samples := range sampleData {
+			// Each locale must have its own translation, not fall back to the default one
+			tmpl := templates[locale]
+			if tmpl.text.Lookup(name+".txt") == nil || tmpl.text.Lookup(name+".subject") == nil || tmpl.html.Lookup(name+".html") == nil {
+				t.Errorf("%s/%s: template missing", locale, name)
+				continue
+			}
+			for _, data := range samples {
+				msg, err := Render(name, locale, data)
+				if err != nil {
+					t.Errorf("%s/%s: Render(%+v): %v", locale, name, data, err)
+					continue
+				}
+				for part, body := range map[string]string{"subject": msg.Subject, "text": msg.Text, "html": msg.HTML} {
+					if strings.TrimSpace(body) == "" {
+						t.Errorf("%s/%s: empty %s", locale, name, part)
+					}
+					if strings.Contains(body, "<no value>") {
+						t.Errorf("%s/%s: %s has a missing field: %q", locale, name, part, body)
+					}
+				}
+				if strings.Contains(msg.Subject, "\n") {
+					t.Errorf("%s/%s: multi-line subject %q", locale, name, msg.Subject)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderedValuesReachBothBodies(t *testing.T) {
+	for _, locale := range Locales {
+		msg, err := Render(TemplateOtp, locale, OtpData{Purpose: "login", Code: "987654", ValidMinutes: 3})
+		if err != nil {
+			t.Fatalf("%s: Render: %v", locale, err)
+		}
+		if !strings.Contains(msg.Text, "987654") || !strings.Contains(msg.HTML, "987654") {
+			t.Errorf("%s: the code is missing from a body", locale)
+		}
+
+		link := "https://example.com/login?token=a&b=<c>"
+		msg, err = Render(TemplateMagicLink, locale, MagicLinkData{Link: link, ValidMinutes: 15})
+		if err != nil {
+			t.Fatalf("%s: Render: %v", locale, err)
+		}
+		if !strings.Contains(msg.Text, link) {
+			t.Errorf("%s: the link is missing from the text body", locale)
+		}
+		if strings.Contains(msg.HTML, "<c>") {
+			t.Errorf("%s: the link is not escaped in the HTML body", locale)
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	data := MagicLinkData{Link: "https://example.com", ValidMinutes: 15}
+	want, err := Render(TemplateMagicLink, DefaultLocale, data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	got, err := Render(TemplateMagicLink, "xx", data)
+	if err != nil {
+		t.Fatalf("Render of an unsupported locale: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("unsupported locale rendered %+v, want the default %+v", got, want)
+	}
+
+	// A locale that has not translated a template yet gets the default one
+	templates["zz"] = &localeTemplates{
+		html: htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/"+DefaultLocale+"/otp.html")),
+		text: texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/"+DefaultLocale+"/otp.txt")),
+	}
+	defer delete(templates, "zz")
+	got, err = Render(TemplateMagicLink, "zz", data)
+	if err != nil {
+		t.Fatalf("Render of an untranslated template: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("untranslated template rendered %+v, want the default %+v", got, want)
+	}
+
+	if _, err := Render("no_such_template", DefaultLocale, data); err == nil {
+		t.Fatal("Render of an unknown template succeeded")
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                    "",
+		"en":                  "en",
+		"en-GB,en;q=0.9":      "en",
+		"es":                  "es",
+		"es-MX,en;q=0.5":      "es",
+		"fr-FR,es;q=0.8":      "es",
+		"fr":                  "",
+		"de-DE,fr;q=0.8":      "",
+		"*":                   "",
+		"en;q=nope":           "",
+		"!!!":                 "",
+		"es;q=0.1,en;q=0.9":   "en",
+		"zh-Hant-TW, es;q=.5": "es",
+	} {
+		if got := MatchLocale(header); got != want {
+			t.Errorf("MatchLocale(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"chatbasket/mail"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AcceptLanguage keeps the request's Accept-Language header in its context, so emails sent
+// while handling it can be written in that language when the user has no stored preference.
+func AcceptLanguage() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if header := c.Request().Header.Get("Accept-Language"); header != "" {
+				req := c.Request()
+				c.SetRequest(req.WithContext(mail.WithAcceptLanguage(req.Context(), header)))
+			}
+			return next(c)
+		}
+	}
+}
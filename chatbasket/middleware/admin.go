@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"chatbasket/appwriteinternal"
+	"chatbasket/model"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminMiddleware lets through users that carry the label in Appwrite. It runs after
+// AppwriteSessionMiddleware and reads the user fresh on every request, so removing the label
+// takes effect immediately.
+func AdminMiddleware(as *appwriteinternal.AppwriteService, label string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userId, ok := c.Get("userId").(string)
+			if !ok || userId == "" {
+				return c.JSON(http.StatusUnauthorized, model.SessionError{
+					Code:    http.StatusUnauthorized,
+					Type:    "missing_auth",
+					Message: "Missing session",
+				})
+			}
+
+			user, err := as.Users.Get(userId)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, model.SessionError{
+					Code:    http.StatusInternalServerError,
+					Type:    "internal_server_error",
+					Message: err.Error(),
+				})
+			}
+			if !slices.Contains(user.Labels, label) {
+				return c.JSON(http.StatusForbidden, model.SessionError{
+					Code:    http.StatusForbidden,
+					Type:    "forbidden",
+					Message: "Admin access required",
+				})
+			}
+
+			c.Set("adminUserId", userId)
+			return next(c)
+		}
+	}
+}
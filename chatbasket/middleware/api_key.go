@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"chatbasket/model"
+	"context"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ApiKeyHeader carries the key of bots and server-to-server integrations.
+const ApiKeyHeader = "X-Api-Key"
+
+// ApiKeyAuthenticator resolves the user of an API key that was granted scope, and checks access
+// token sessions for requests without a key (services.GlobalService implements it).
+type ApiKeyAuthenticator interface {
+	AuthenticateApiKey(ctx context.Context, key, scope string) (*model.UserId, *model.ApiError)
+	AccessTokenSessions
+}
+
+// ApiKeyOrSessionMiddleware accepts a request carrying an API key with scope, or else an Appwrite
+// session as AppwriteSessionMiddleware does. Both set userId and uuidUserId; API key requests
+// have platform "api_key" and no sessionId or email, so routes that need those stay
+// session-only.
+func ApiKeyOrSessionMiddleware(auth ApiKeyAuthenticator, accessTokenKey []byte, scope string) echo.MiddlewareFunc {
+	session := AppwriteSessionMiddleware(true, accessTokenKey, auth)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withSession := session(next)
+
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(ApiKeyHeader)
+			if key == "" {
+				return withSession(c)
+			}
+
+			userId, apiErr := auth.AuthenticateApiKey(c.Request().Context(), key, scope)
+			if apiErr != nil {
+				return c.JSON(apiErr.Code, model.SessionError{
+					Code:    apiErr.Code,
+					Type:    apiErr.Type,
+					Message: apiErr.Message,
+				})
+			}
+
+			c.Set("uuidUserId", userId.UuidUserId)
+			c.Set("userId", userId.StringUserId)
+			c.Set("platform", "api_key")
+
+			return next(c)
+		}
+	}
+}
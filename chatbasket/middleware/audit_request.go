@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"chatbasket/audit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuditRequest keeps the request id, client IP and user agent in the request's context, so audit
+// events recorded while handling it can name the request. It must run after echo's RequestID.
+func AuditRequest() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+			if requestId == "" {
+				requestId = req.Header.Get(echo.HeaderXRequestID)
+			}
+			c.SetRequest(req.WithContext(audit.WithRequest(req.Context(), audit.Request{
+				Id:        requestId,
+				IP:        c.RealIP(),
+				UserAgent: req.UserAgent(),
+			})))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
+)
+
+// RouteBodyLimit enforces a request body limit chosen by the matched route path (e.g.
+// "/personal/profile/upload-avatar"), falling back to defaultLimit. Limits use echo's
+// BodyLimit format ("64K", "6M"). The check is hard: bodies over the limit are rejected on
+// Content-Length and streamed bodies fail with 413 once they cross it.
+func RouteBodyLimit(defaultLimit string, perRoute map[string]string) echo.MiddlewareFunc {
+	byLimit := map[string]echo.MiddlewareFunc{defaultLimit: echomw.BodyLimit(defaultLimit)}
+	for _, limit := range perRoute {
+		if _, ok := byLimit[limit]; !ok {
+			byLimit[limit] = echomw.BodyLimit(limit)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		wrapped := make(map[string]echo.HandlerFunc, len(byLimit))
+		for limit, mw := range byLimit {
+			wrapped[limit] = mw(next)
+		}
+
+		return func(c echo.Context) error {
+			limit, ok := perRoute[c.Path()]
+			if !ok {
+				limit = defaultLimit
+			}
+			return wrapped[limit](c)
+		}
+	}
+}
@@ -4,15 +4,26 @@ import (
 	"chatbasket/appwriteinternal"
 	"chatbasket/model"
 	"chatbasket/utils"
+	"context"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+// AccessTokenSessions reports whether the session behind an access token is still signed in
+// (services.GlobalService implements it).
+type AccessTokenSessions interface {
+	AccessTokenSessionActive(ctx context.Context, userId uuid.UUID, sessionId string) (bool, error)
+}
 
-func AppwriteSessionMiddleware(requireVerified bool) echo.MiddlewareFunc {
+// AppwriteSessionMiddleware authenticates a request by its Appwrite session cookies or bearer.
+// accessTokenKey verifies the access tokens of native clients (AuthConfig.AccessTokenSigningKey).
+func AppwriteSessionMiddleware(requireVerified bool, accessTokenKey []byte, sessions AccessTokenSessions) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			var sessionId, userId string
@@ -27,7 +38,10 @@ func AppwriteSessionMiddleware(requireVerified bool) echo.MiddlewareFunc {
 				token := strings.TrimPrefix(authHeader, "Bearer ")
 				parts := strings.SplitN(token, ":", 2)
 				if len(parts) == 2 {
+					// Legacy "sessionId:userId" bearer of app versions without access tokens
 					sessionId, userId = parts[0], parts[1]
+				} else {
+					return accessTokenSession(c, next, token, accessTokenKey, sessions)
 				}
 			} else {
 				// Request from web - extract from httpOnly cookies
@@ -123,3 +137,55 @@ func AppwriteSessionMiddleware(requireVerified bool) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// accessTokenSession authenticates a native request by its access token. Appwrite is only asked
+// again when the token is refreshed, but the session must still hold a live refresh token, so a
+// signed-out or revoked session is rejected at once rather than when its access token expires.
+func accessTokenSession(c echo.Context, next echo.HandlerFunc, token string, key []byte, sessions AccessTokenSessions) error {
+	claims, err := utils.VerifyAccessToken(token, key, time.Now())
+	if err != nil {
+		errType := "token_invalid"
+		if errors.Is(err, utils.ErrAccessTokenExpired) {
+			errType = "token_expired" // the client should refresh and retry
+		}
+		return c.JSON(http.StatusUnauthorized, model.SessionError{
+			Code:    http.StatusUnauthorized,
+			Type:    errType,
+			Message: err.Error(),
+		})
+	}
+
+	uuidUserId, err := utils.StringToUUID(claims.Subject)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, model.SessionError{
+			Code:    http.StatusUnauthorized,
+			Type:    "token_invalid",
+			Message: utils.ErrAccessTokenInvalid.Error(),
+		})
+	}
+
+	// Fail closed: a session that cannot be checked is not trusted
+	active, err := sessions.AccessTokenSessionActive(c.Request().Context(), uuidUserId, claims.SessionId)
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, model.SessionError{
+			Code:    http.StatusServiceUnavailable,
+			Type:    "session_check_failed",
+			Message: "Failed to check session",
+		})
+	}
+	if !active {
+		return c.JSON(http.StatusUnauthorized, model.SessionError{
+			Code:    http.StatusUnauthorized,
+			Type:    "session_revoked",
+			Message: "Session has been signed out",
+		})
+	}
+
+	c.Set("uuidUserId", uuidUserId)
+	c.Set("userId", claims.Subject)
+	c.Set("sessionId", claims.SessionId)
+	c.Set("platform", "native")
+	c.Set("email", claims.Email)
+
+	return next(c)
+}
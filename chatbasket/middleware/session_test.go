@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeSessions tracks signed-out sessions as RevokeRefreshTokens would.
+type fakeSessions struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (f *fakeSessions) AccessTokenSessionActive(_ context.Context, _ uuid.UUID, sessionId string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return !f.revoked[sessionId], nil
+}
+
+var testAccessTokenKey = bytes.Repeat([]byte{0x17}, 32)
+
+func serveWithAccessToken(t *testing.T, sessions AccessTokenSessions, sessionId string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveWithAccessTokenSignedBy(t, testAccessTokenKey, sessions, sessionId)
+}
+
+func serveWithAccessTokenSignedBy(t *testing.T, signingKey []byte, sessions AccessTokenSessions, sessionId string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := utils.SignAccessToken(uuid.NewString(), sessionId, "user@example.com", time.Now().Add(15*time.Minute), signingKey)
+	if err != nil {
+		t.Fatalf("SignAccessToken: %v", err)
+	}
+
+	e := echo.New()
+	handler := AppwriteSessionMiddleware(true, testAccessTokenKey, sessions)(func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("sessionId").(string))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	if err := handler(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return rec
+}
+
+func TestAccessTokenOfActiveSessionIsAccepted(t *testing.T) {
+	rec := serveWithAccessToken(t, &fakeSessions{}, "session-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestAccessTokenOfRevokedSessionIsRejected(t *testing.T) {
+	sessions := &fakeSessions{revoked: map[string]bool{"session-1": true}}
+	rec := serveWithAccessToken(t, sessions, "session-1")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte("session_revoked")) {
+		t.Fatalf("body = %s, want a session_revoked error", rec.Body)
+	}
+}
+
+func TestAccessTokenRejectedWhenSessionCheckFails(t *testing.T) {
+	rec := serveWithAccessToken(t, &fakeSessions{err: errors.New("db down")}, "session-1")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+}
+
+func TestAccessTokenSignedWithAnotherKeyIsRejected(t *testing.T) {
+	rec := serveWithAccessTokenSignedBy(t, bytes.Repeat([]byte{0x42}, 32), &fakeSessions{}, "session-1")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+// AdminUser is a personal-mode user as shown to admins.
+type AdminUser struct {
+	Id               string  `json:"id"`
+	Name             string  `json:"name"`
+	Username         string  `json:"username"`
+	Email            string  `json:"email,omitempty"` // Only set when searching by email
+	ProfileType      string  `json:"profile_type"`
+	IsAdminBlocked   bool    `json:"is_admin_blocked"`
+	AdminBlockReason *string `json:"admin_block_reason,omitempty"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+type AdminUserList struct {
+	Users []AdminUser `json:"users"`
+	// NextBefore is the `before` cursor of the next page of recent users, empty on the last page
+	NextBefore string `json:"next_before,omitempty"`
+}
+
+// AdminBlockPayload blocks a user; the reason is shown to them.
+type AdminBlockPayload struct {
+	Reason string `json:"reason"`
+}
+
+// ContactRequestVolume counts the contact requests a user sent and received.
+type ContactRequestVolume struct {
+	UserId        string `json:"user_id"`
+	SentLastDay   int64  `json:"sent_last_day"`
+	SentLastWeek  int64  `json:"sent_last_week"`
+	SentLastMonth int64  `json:"sent_last_month"`
+	SentTotal     int64  `json:"sent_total"`
+	SentPending   int64  `json:"sent_pending"`
+	SentAccepted  int64  `json:"sent_accepted"`
+	SentDeclined  int64  `json:"sent_declined"`
+	ReceivedTotal int64  `json:"received_total"`
+}
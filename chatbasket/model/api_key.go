@@ -0,0 +1,45 @@
+package model
+
+// API key scopes. A key only reaches routes whose scope it was created with.
+const (
+	ApiKeyScopeProfileRead   = "profile:read"
+	ApiKeyScopeContactsRead  = "contacts:read"
+	ApiKeyScopeContactsWrite = "contacts:write"
+	ApiKeyScopeStorageRead   = "storage:read"
+)
+
+// ApiKeyScopes lists the scopes a key can be created with.
+var ApiKeyScopes = []string{
+	ApiKeyScopeProfileRead,
+	ApiKeyScopeContactsRead,
+	ApiKeyScopeContactsWrite,
+	ApiKeyScopeStorageRead,
+}
+
+// CreateApiKeyPayload creates a key. ExpiresInDays defaults to 90 and is capped at 365.
+type CreateApiKeyPayload struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// ApiKey is one of the user's keys, without the secret part.
+type ApiKey struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// CreatedApiKey is returned once, when the key is created; only its hash is kept.
+type CreatedApiKey struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+type ApiKeyList struct {
+	ApiKeys []ApiKey `json:"api_keys"`
+}
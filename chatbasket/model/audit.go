@@ -0,0 +1,38 @@
+package model
+
+import "encoding/json"
+
+// AuditEvent is one recorded action. Session targets are SHA-256 hex session ids.
+type AuditEvent struct {
+	Id          string          `json:"id"`
+	ActorUserId string          `json:"actor_user_id,omitempty"`
+	ActorType   string          `json:"actor_type"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetId    string          `json:"target_id"`
+	RequestId   string          `json:"request_id,omitempty"`
+	Ip          string          `json:"ip,omitempty"`
+	UserAgent   string          `json:"user_agent,omitempty"`
+	Metadata    json.RawMessage `json:"metadata"`
+	CreatedAt   string          `json:"created_at"`
+}
+
+type AuditEventList struct {
+	Events []AuditEvent `json:"events"`
+	// NextBefore is the `before` cursor of the next page, empty on the last page
+	NextBefore string `json:"next_before,omitempty"`
+}
+
+// AuditEventFilter narrows an audit log query; empty fields do not filter. Since and Until are
+// RFC 3339 timestamps, Before the id of the last event of the previous page.
+type AuditEventFilter struct {
+	ActorId    string `query:"actor_id"`
+	Action     string `query:"action"`
+	TargetType string `query:"target_type"`
+	TargetId   string `query:"target_id"`
+	RequestId  string `query:"request_id"`
+	Since      string `query:"since"`
+	Until      string `query:"until"`
+	Before     string `query:"before"`
+	Limit      int    `query:"limit"`
+}
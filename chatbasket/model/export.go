@@ -0,0 +1,9 @@
+package model
+
+type DataExportStatus struct {
+	Id        string  `json:"id"`
+	Status    string  `json:"status"`
+	SizeBytes *int64  `json:"size_bytes,omitempty"`
+	CreatedAt string  `json:"created_at"`
+	ExpiresAt *string `json:"expires_at,omitempty"`
+}
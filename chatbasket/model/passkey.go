@@ -0,0 +1,57 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// BeginPasskeyRegistrationPayload re-authenticates with the password and, when TOTP is enabled,
+// a TOTP or recovery code.
+type BeginPasskeyRegistrationPayload struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// PasskeyCreationOptions starts passkey registration. The client passes Options.PublicKey to
+// navigator.credentials.create and returns the result with ChallengeId.
+type PasskeyCreationOptions struct {
+	ChallengeId string                       `json:"challenge_id"`
+	Options     *protocol.CredentialCreation `json:"options"`
+}
+
+// PasskeyRegistrationPayload finishes registration. Credential is the PublicKeyCredential as
+// JSON; Name labels the passkey in the list.
+type PasskeyRegistrationPayload struct {
+	ChallengeId string          `json:"challenge_id"`
+	Name        string          `json:"name"`
+	Credential  json.RawMessage `json:"credential"`
+}
+
+// PasskeyRequestOptions starts passkey login. The client passes Options.PublicKey to
+// navigator.credentials.get and returns the result with ChallengeId.
+type PasskeyRequestOptions struct {
+	ChallengeId string                        `json:"challenge_id"`
+	Options     *protocol.CredentialAssertion `json:"options"`
+}
+
+// PasskeyLoginPayload finishes passkey login.
+type PasskeyLoginPayload struct {
+	ChallengeId string          `json:"challenge_id"`
+	Platform    string          `json:"platform"`
+	Credential  json.RawMessage `json:"credential"`
+}
+
+// Passkey is one of the user's registered passkeys.
+type Passkey struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	Synced     bool   `json:"synced"` // Backed up by the platform, e.g. iCloud Keychain
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+type PasskeyList struct {
+	Passkeys []Passkey `json:"passkeys"`
+}
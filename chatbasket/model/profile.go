@@ -1,7 +1,5 @@
 package model
 
-import "fmt"
-
 // 🔐 Full DB model (used internally, never exposed directly in APIs)
 type User struct {
 	Id               string   `json:"$id"`                        // Always required
@@ -94,27 +92,15 @@ type UploadUserProfilePictureDbPayload struct {
 type UploadUserProfilePictureResponse struct {
 	AvatarFileId     string   `json:"avatarFileId"`
 	Name             string   `json:"name"`
-	AvatarFileTokens []string `json:"avatarFileTokens"` // Tokens for accessing Avatar ["personal_token","personal_token_secret","token_expire"]
+	AvatarUri        string   `json:"avatarUri"` // Signed media proxy URL; storage tokens stay server-side
 }
 
-// AppwriteFileData represents the data needed to construct an appwrite file URI
+// AppwriteFileData is the avatar file id and its stored Appwrite tokens
 type AppwriteFileData struct {
 	FileId     string   `json:"fileId"`
 	FileTokens []string `json:"fileTokens"`
 }
 
-// BuildAvatarURI constructs the avatar URL from AppwriteFileData
-// Returns empty string if data is invalid or insufficient tokens
-func BuildAvatarURI(ad *AppwriteFileData, minTokens int) string {
-	if ad == nil || ad.FileId == "" || len(ad.FileTokens) < minTokens {
-		return ""
-	}
-
-	// Use the personal_token_secret (index 1) for avatar access
-	return fmt.Sprintf("https://fra.cloud.appwrite.io/v1/storage/buckets/685bc613002edcfee6bb/files/%s/view?project=6858ed4d0005c859ea03&token=%s",
-		ad.FileId, ad.FileTokens[1])
-}
-
 // 🔁 Convert full user model → private view
 func ToPrivateUser(u *User, avataruri string) *PrivateUser {
 	return &PrivateUser{
@@ -0,0 +1,79 @@
+package model
+
+import "encoding/json"
+
+// Report targets. For a message the target id is the client's message id, otherwise it is the
+// reported user's id.
+const (
+	ReportTargetUser    = "user"
+	ReportTargetProfile = "profile"
+	ReportTargetMessage = "message"
+)
+
+// Report statuses. A report is open until an admin starts reviewing it, and ends actioned or
+// dismissed.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusInReview  = "in_review"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+)
+
+// ReportCategories are the reasons a report can be filed for.
+var ReportCategories = []string{"spam", "harassment", "hate", "impersonation", "inappropriate", "other"}
+
+// AdminReport is a report as shown in the moderation queue. Evidence is the snapshot taken when
+// the report was filed. For message reports the message in it was submitted by the reporter and
+// could not be verified, which ReporterSuppliedEvidence flags.
+type AdminReport struct {
+	Id                       string          `json:"id"`
+	ReporterUserId           string          `json:"reporter_user_id"`
+	TargetType               string          `json:"target_type"`
+	TargetUserId             string          `json:"target_user_id,omitempty"` // Empty once the reported user deleted their account
+	TargetId                 string          `json:"target_id"`
+	Category                 string          `json:"category"`
+	Details                  *string         `json:"details"`
+	Evidence                 json.RawMessage `json:"evidence"`
+	ReporterSuppliedEvidence bool            `json:"reporter_supplied_evidence"`
+	Status                   string          `json:"status"`
+	ResolutionNote           *string         `json:"resolution_note"`
+	HandledBy                string          `json:"handled_by,omitempty"`
+	OpenReportsOnTarget      int64           `json:"open_reports_on_target,omitempty"` // Only set in the queue
+	CreatedAt                string          `json:"created_at"`
+	ResolvedAt               string          `json:"resolved_at,omitempty"`
+}
+
+type AdminReportList struct {
+	Reports []AdminReport `json:"reports"`
+	// NextAfter is the `after` cursor of the next page, empty on the last page
+	NextAfter string `json:"next_after,omitempty"`
+}
+
+// AdminReportFilter narrows the moderation queue, which lists reports oldest first. Status
+// defaults to open; "all" lists every status. After is the id of the last report of the previous
+// page.
+type AdminReportFilter struct {
+	Status       string `query:"status"`
+	Category     string `query:"category"`
+	TargetUserId string `query:"target_user_id"`
+	After        string `query:"after"`
+	Limit        int    `query:"limit"`
+}
+
+// ResolveReportPayload closes a report as actioned or dismissed.
+type ResolveReportPayload struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// EscalateReportPayload admin-blocks the reported user and closes every open report against
+// them as actioned. Reason is shown to the blocked user, Note is kept on the reports.
+type EscalateReportPayload struct {
+	Reason string `json:"reason"`
+	Note   string `json:"note"`
+}
+
+type AdminReportEscalation struct {
+	User    AdminUser     `json:"user"`
+	Reports []AdminReport `json:"reports"` // The reports closed by the escalation
+}
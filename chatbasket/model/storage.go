@@ -0,0 +1,15 @@
+package model
+
+type StorageKindUsage struct {
+	Kind    string `json:"kind"`
+	Objects int32  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+type StorageUsage struct {
+	Plan        string             `json:"plan"`
+	UsedBytes   int64              `json:"used_bytes"`
+	LimitBytes  int64              `json:"limit_bytes"`
+	ObjectCount int32              `json:"object_count"`
+	ByKind      []StorageKindUsage `json:"by_kind"`
+}
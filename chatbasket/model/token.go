@@ -1,12 +1,15 @@
 package model
 
-type RefreshToken struct {
-	Id           string `json:"$id"`
-	UserId       string `json:"userId"`
-	Token        string `json:"token"`
-	CreatedAt    string `json:"$createdAt"`
-	UpdatedAt    string `json:"$updatedAt"`
-	ExpiresAt    string `json:"expiresAt"`
+// SessionTokens are issued to native clients. The access token is a short-lived JWT sent as
+// "Authorization: Bearer <accessToken>"; the refresh token is exchanged for a new pair at
+// POST /auth/refresh and is single-use.
+type SessionTokens struct {
+	AccessToken        string `json:"accessToken"`
+	AccessTokenExpiry  string `json:"accessTokenExpiry"`
+	RefreshToken       string `json:"refreshToken"`
+	RefreshTokenExpiry string `json:"refreshTokenExpiry"`
 }
 
-
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -0,0 +1,24 @@
+package model
+
+// TotpEnrollment is returned when TOTP enrolment starts. The client shows OtpauthUri as a QR
+// code, with Secret for manual entry.
+type TotpEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauth_uri"`
+}
+
+type TotpCodePayload struct {
+	Code string `json:"code"`
+}
+
+// TotpRecoveryCodes are shown once; only their hashes are kept.
+type TotpRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// DisableTotpPayload re-authenticates with the password and a second factor.
+type DisableTotpPayload struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
@@ -21,10 +21,20 @@ type SignupPayload struct {
 	Password string `json:"password"`
 }
 
+// Login methods
+const (
+	LoginMethodOtp       = "otp"
+	LoginMethodMagicLink = "magic_link"
+)
+
 // 🔐 Login payload (supports email or username login)
 type LoginPayload struct {
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"password"` // not needed for magic_link
+	Method   string `json:"method"`   // "otp" (default) or "magic_link"
+	Platform string `json:"platform"`
+	// Nonce binds a magic link to this device; web clients get it as a cookie instead
+	Nonce string `json:"nonce"`
 }
 
 // 🌐 Public user view (used when others view your profile)
@@ -65,6 +75,8 @@ type SessionResponse struct {
 	Email         string       `json:"email"`
 	SessionID     string       `json:"sessionId"`
 	SessionExpiry string       `json:"sessionExpiry"`
+	// Native clients also get an access and refresh token pair
+	*SessionTokens
 }
 
 
@@ -73,9 +85,22 @@ type AuthVerificationPayload struct {
 	Email  string `json:"email"`
 	Secret string `json:"secret"` // OTP code from email
 	Platform string `json:"platform"`
+	// Second factor, required once TOTP is enabled: a code from the authenticator app or a recovery code
+	TotpCode     string `json:"totp_code"`
+	RecoveryCode string `json:"recovery_code"`
 }
 
 
+// MagicLinkVerificationPayload signs in with a magic link token. Web clients may leave Nonce
+// empty: it is then read from the cookie set by login.
+type MagicLinkVerificationPayload struct {
+	Token        string `json:"token"`
+	Nonce        string `json:"nonce"`
+	Platform     string `json:"platform"`
+	TotpCode     string `json:"totp_code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
 // 📝 Logout payload
 type LogoutPayload struct {
 	AllSessions bool   `json:"allSessions"`
@@ -190,3 +215,19 @@ func CheckIfUserBlocked(user *User) error {
 	return nil
 }
 
+
+// UpdateLocalePayload sets the language of emails; "" clears it.
+type UpdateLocalePayload struct {
+	Locale string `json:"locale"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordPayload completes a password reset with the code emailed by forgot-password.
+type ResetPasswordPayload struct {
+	Email       string `json:"email"`
+	Secret      string `json:"secret"`
+	NewPassword string `json:"newPassword"`
+}
@@ -0,0 +1,37 @@
+package otp
+
+import (
+	"chatbasket/model"
+	"errors"
+	"time"
+)
+
+// ApiError maps an error from this package to the response the auth endpoints answer with.
+func ApiError(err error) *model.ApiError {
+	var locked *LockedError
+	switch {
+	case errors.Is(err, ErrInvalid):
+		return &model.ApiError{Code: 401, Message: "Invalid OTP", Type: "unauthorized"}
+	case errors.Is(err, ErrExpired):
+		return &model.ApiError{Code: 401, Message: "OTP has expired", Type: "unauthorized"}
+	case errors.Is(err, ErrThrottled):
+		return &model.ApiError{Code: 429, Message: "Please wait before requesting another code", Type: "too_many_requests"}
+	case errors.As(err, &locked):
+		wait := max(time.Until(locked.Until).Round(time.Second), time.Second)
+		return &model.ApiError{Code: 429, Message: "Too many failed attempts, try again in " + wait.String(), Type: "too_many_attempts"}
+	}
+	return &model.ApiError{Code: 500, Message: "OTP failure: " + err.Error(), Type: "internal_server_error"}
+}
+
+// LinkApiError maps an error from the magic link methods like ApiError, worded for links.
+func LinkApiError(err error) *model.ApiError {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		return &model.ApiError{Code: 401, Message: "Invalid or already used login link", Type: "unauthorized"}
+	case errors.Is(err, ErrExpired):
+		return &model.ApiError{Code: 401, Message: "Login link has expired", Type: "unauthorized"}
+	case errors.Is(err, ErrWrongDevice):
+		return &model.ApiError{Code: 401, Message: "Login link was requested on another device", Type: "wrong_device"}
+	}
+	return ApiError(err)
+}
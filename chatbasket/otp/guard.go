@@ -0,0 +1,46 @@
+package otp
+
+import (
+	"chatbasket/db/postgresCode"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// reserveSend records a send, or refuses while the user is locked out or sending too often.
+func (s *Service) reserveSend(ctx context.Context, userId uuid.UUID) error {
+	_, err := s.Queries.ReserveOtpSend(ctx, postgresCode.ReserveOtpSendParams{
+		UserID:     userId,
+		SendWindow: interval(SendWindow),
+		Cooldown:   interval(ResendCooldown),
+		MaxSends:   MaxSendsPerWindow,
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if err := s.checkLock(ctx, userId); err != nil {
+		return err
+	}
+	return ErrThrottled
+}
+
+// checkLock returns a *LockedError while the user is locked out.
+func (s *Service) checkLock(ctx context.Context, userId uuid.UUID) error {
+	guard, err := s.Queries.GetOtpGuard(ctx, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if guard.LockedUntil.Valid && guard.LockedUntil.Time.After(time.Now()) {
+		return &LockedError{Until: guard.LockedUntil.Time}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package otp
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/utils"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// LinkTTL is how long a magic login link can be used.
+const LinkTTL = 10 * time.Minute
+
+const linkTokenKind = "magic_link"
+
+// ErrWrongDevice is returned for a valid link presented without the nonce of the device that
+// asked for it, e.g. a forwarded link.
+var ErrWrongDevice = errors.New("magic link requested from another device")
+
+// IssueLink creates a single-use login link bound to nonce, replacing the user's pending links,
+// and hands its token to send. Links share the send limits of codes.
+func (s *Service) IssueLink(ctx context.Context, userId uuid.UUID, email, nonce string, send func(token string, expiresAt time.Time) error) error {
+	if len(s.LinkKey) == 0 {
+		return errors.New("otp: no magic link signing key")
+	}
+	if err := s.reserveSend(ctx, userId); err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("generate uuid: %w", err)
+	}
+	expiresAt := time.Now().Add(LinkTTL)
+	if err := s.Queries.DeleteUserMagicLinks(ctx, userId); err != nil {
+		return err
+	}
+	err = s.Queries.CreateMagicLink(ctx, postgresCode.CreateMagicLinkParams{
+		ID:        id,
+		UserID:    userId,
+		Email:     email,
+		NonceHash: utils.HashSessionID(nonce),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	token := id.String() + "." + utils.SignDownloadToken(linkTokenKind, id.String(), expiresAt, s.LinkKey)
+	if err := send(token, expiresAt); err != nil {
+		// A link that never arrived must not stay usable
+		if delErr := s.Queries.DeleteMagicLink(ctx, id); delErr != nil {
+			return errors.Join(err, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckLink verifies a link token and the nonce of the device presenting it without using the
+// link up (see ConsumeLink and FailLink).
+func (s *Service) CheckLink(ctx context.Context, token, nonce string) (*postgresCode.MagicLink, error) {
+	idPart, signed, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalid
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	switch err := utils.VerifyDownloadToken(signed, linkTokenKind, id.String(), s.LinkKey, time.Now()); {
+	case errors.Is(err, utils.ErrDownloadTokenExpired):
+		return nil, ErrExpired
+	case err != nil:
+		return nil, ErrInvalid
+	}
+
+	link, err := s.Queries.GetMagicLink(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalid
+		}
+		return nil, err
+	}
+	if link.ConsumedAt.Valid || link.Attempts >= MaxAttempts {
+		return nil, ErrInvalid
+	}
+	if !link.ExpiresAt.Time.After(time.Now()) {
+		return nil, ErrExpired
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(utils.HashSessionID(nonce)), []byte(link.NonceHash)) != 1 {
+		return nil, ErrWrongDevice
+	}
+	return &link, nil
+}
+
+// ConsumeLink uses up a link returned by CheckLink.
+func (s *Service) ConsumeLink(ctx context.Context, link *postgresCode.MagicLink) error {
+	n, err := s.Queries.ConsumeMagicLink(ctx, link.ID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalid // used by a concurrent request
+	}
+	return nil
+}
+
+// FailLink records a wrong second factor tried with a link returned by CheckLink. The link is
+// dropped once MaxAttempts are used up.
+func (s *Service) FailLink(ctx context.Context, link *postgresCode.MagicLink) error {
+	attempts, err := s.Queries.RecordMagicLinkFailure(ctx, link.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if attempts >= MaxAttempts {
+		return s.Queries.DeleteMagicLink(ctx, link.ID)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+// Package otp issues and checks the emailed one-time codes used by signup, login, email change
+// and the other confirmation flows, and the magic links of passwordless login. Codes are stored as argon2id hashes in otp_challenges, one
+// live challenge per user and purpose, and are guarded by per-user attempt, lockout and resend
+// limits (otp_guards).
+package otp
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Purposes keep a code sent for one flow from confirming another.
+const (
+	PurposeSignup          = "signup"
+	PurposeLogin           = "login"
+	PurposeEmailChange     = "email_change"
+	PurposeIdentity        = "identity" // SendOtp / VerifyOtp re-verification
+	PurposeAccountDeletion = "account_deletion"
+	PurposePasswordReset   = "password_reset"
+)
+
+// Limits. The TTL matches the "valid for 3 minutes" promised in the emails.
+const (
+	// TTL is how long a code can be used.
+	TTL = 3 * time.Minute
+	// MaxAttempts is how many codes can be tried against one challenge before it is invalidated.
+	MaxAttempts = 5
+	// BaseLockout is the first lockout after the attempts run out; every further one doubles.
+	BaseLockout = time.Minute
+	// MaxLockout caps the lockout.
+	MaxLockout = time.Hour
+	// ResendCooldown is the minimum time between two codes.
+	ResendCooldown = time.Minute
+	// SendWindow and MaxSendsPerWindow cap how many codes a user gets per window.
+	SendWindow        = time.Hour
+	MaxSendsPerWindow = 5
+)
+
+var (
+	// ErrInvalid is returned for a wrong code, or when there is no live code for the purpose.
+	ErrInvalid = errors.New("invalid otp")
+	// ErrExpired is returned for a correct code past its TTL.
+	ErrExpired = errors.New("otp expired")
+	// ErrThrottled is returned when a code is requested too soon or too often.
+	ErrThrottled = errors.New("otp requested too often")
+)
+
+// LockedError is returned while the user is locked out after too many wrong codes.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many otp attempts, locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// Service issues and verifies codes and magic login links.
+type Service struct {
+	Queries Store
+	// LinkKey signs magic link tokens.
+	LinkKey []byte
+}
+
+func New(queries Store, linkKey []byte) *Service {
+	return &Service{Queries: queries, LinkKey: linkKey}
+}
+
+func interval(d time.Duration) pgtype.Interval {
+	return pgtype.Interval{Microseconds: d.Microseconds(), Valid: true}
+}
+
+// lockoutSchedule lists the lengths of consecutive lockouts: BaseLockout, doubling each time up
+// to MaxLockout, which then applies to every further one.
+func lockoutSchedule() []time.Duration {
+	var schedule []time.Duration
+	for d := BaseLockout; ; d *= 2 {
+		if d >= MaxLockout {
+			return append(schedule, MaxLockout)
+		}
+		schedule = append(schedule, d)
+	}
+}
+
+// lockoutIntervals is lockoutSchedule as passed to LockOtpGuard.
+var lockoutIntervals = func() []pgtype.Interval {
+	schedule := lockoutSchedule()
+	intervals := make([]pgtype.Interval, len(schedule))
+	for i, d := range schedule {
+		intervals[i] = interval(d)
+	}
+	return intervals
+}()
+
+// Issue generates a code for purpose and hands it to send, which emails it to target. The code
+// is stored, replacing the user's previous one for the purpose, only once send succeeded.
+func (s *Service) Issue(ctx context.Context, userId uuid.UUID, purpose, target string, send func(code string) error) error {
+	if err := s.reserveSend(ctx, userId); err != nil {
+		return err
+	}
+
+	code, err := utils.GenerateOTP()
+	if err != nil {
+		return fmt.Errorf("generate otp: %w", err)
+	}
+	hash, err := utils.HashOTP(code)
+	if err != nil {
+		return fmt.Errorf("hash otp: %w", err)
+	}
+	if err := send(code); err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("generate uuid: %w", err)
+	}
+	return s.Queries.UpsertOtpChallenge(ctx, postgresCode.UpsertOtpChallengeParams{
+		ID:       id,
+		UserID:   userId,
+		Purpose:  purpose,
+		Target:   target,
+		CodeHash: hash,
+		Ttl:      interval(TTL),
+	})
+}
+
+// Check verifies code against the user's live challenge for purpose without using it up, for
+// flows that still have to pass another check (see Consume and Fail). Every call counts as an
+// attempt. target, when not empty, must be the address the code was sent to.
+func (s *Service) Check(ctx context.Context, userId uuid.UUID, purpose, target, code string) (*postgresCode.OtpChallenge, error) {
+	if err := s.checkLock(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	ch, err := s.Queries.ReserveOtpChallengeAttempt(ctx, postgresCode.ReserveOtpChallengeAttemptParams{
+		UserID:      userId,
+		Purpose:     purpose,
+		MaxAttempts: MaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalid
+		}
+		return nil, err
+	}
+	if target != "" && ch.Target != target {
+		return nil, s.fail(ctx, &ch)
+	}
+
+	match, err := utils.VerifyOTP(code, ch.CodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("verify otp: %w", err)
+	}
+	if !match {
+		return nil, s.fail(ctx, &ch)
+	}
+	if !ch.ExpiresAt.Time.After(time.Now()) {
+		return nil, ErrExpired
+	}
+	return &ch, nil
+}
+
+// Verify checks code like Check and uses the challenge up.
+func (s *Service) Verify(ctx context.Context, userId uuid.UUID, purpose, target, code string) (*postgresCode.OtpChallenge, error) {
+	ch, err := s.Check(ctx, userId, purpose, target, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Consume(ctx, ch); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Consume uses up a challenge returned by Check and clears the user's lockouts.
+func (s *Service) Consume(ctx context.Context, ch *postgresCode.OtpChallenge) error {
+	n, err := s.Queries.ConsumeOtpChallenge(ctx, ch.ID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalid // used by a concurrent request
+	}
+	return s.Queries.ClearOtpGuard(ctx, ch.UserID)
+}
+
+// Fail records that a flow rejected a challenge returned by Check for another reason (e.g. a
+// wrong second factor). It returns a *LockedError once the attempts are used up, nil otherwise.
+func (s *Service) Fail(ctx context.Context, ch *postgresCode.OtpChallenge) error {
+	if err := s.fail(ctx, ch); !errors.Is(err, ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// fail answers a wrong guess: ErrInvalid, or a *LockedError when it was the last attempt, in
+// which case the challenge is dropped and a lockout starts.
+func (s *Service) fail(ctx context.Context, ch *postgresCode.OtpChallenge) error {
+	if ch.Attempts < MaxAttempts {
+		return ErrInvalid
+	}
+	if err := s.Queries.DeleteOtpChallenge(ctx, ch.ID); err != nil {
+		return err
+	}
+	lockedUntil, err := s.Queries.LockOtpGuard(ctx, postgresCode.LockOtpGuardParams{
+		UserID:   ch.UserID,
+		Schedule: lockoutIntervals,
+	})
+	if err != nil {
+		return err
+	}
+	return &LockedError{Until: lockedUntil.Time}
+}
+
+// DeleteUser removes all OTP state of a user (for account deletion).
+func (s *Service) DeleteUser(ctx context.Context, userId uuid.UUID) error {
+	if err := s.Queries.DeleteUserOtpChallenges(ctx, userId); err != nil {
+		return err
+	}
+	if err := s.Queries.DeleteUserMagicLinks(ctx, userId); err != nil {
+		return err
+	}
+	return s.Queries.DeleteOtpGuard(ctx, userId)
+}
+
+// Sweep deletes challenges and magic links that expired or were used more than grace ago, and
+// guards that have seen no activity for idle. It returns how many of each were removed.
+func (s *Service) Sweep(ctx context.Context, grace, idle time.Duration) (challenges, links, guards int64, err error) {
+	challenges, err = s.Queries.DeleteStaleOtpChallenges(ctx, interval(grace))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("delete stale challenges: %w", err)
+	}
+	links, err = s.Queries.DeleteStaleMagicLinks(ctx, interval(grace))
+	if err != nil {
+		return challenges, 0, 0, fmt.Errorf("delete stale magic links: %w", err)
+	}
+	guards, err = s.Queries.DeleteIdleOtpGuards(ctx, interval(idle))
+	if err != nil {
+		return challenges, links, 0, fmt.Errorf("delete idle guards: %w", err)
+	}
+	return challenges, links, guards, nil
+}
@@ -0,0 +1,356 @@
+package otp
+
+import (
+	"chatbasket/db/postgresCode"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// memStore keeps guards and challenges in memory, following the conditions of the SQL queries.
+// Magic links are not needed by these tests.
+type memStore struct {
+	Store
+	guards     map[uuid.UUID]*postgresCode.OtpGuard
+	challenges map[string]*postgresCode.OtpChallenge // by user_id/purpose
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		guards:     map[uuid.UUID]*postgresCode.OtpGuard{},
+		challenges: map[string]*postgresCode.OtpChallenge{},
+	}
+}
+
+func duration(i pgtype.Interval) time.Duration {
+	return time.Duration(i.Microseconds) * time.Microsecond
+}
+
+func timestamp(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
+
+func (m *memStore) guard(userID uuid.UUID) *postgresCode.OtpGuard {
+	g, ok := m.guards[userID]
+	if !ok {
+		g = &postgresCode.OtpGuard{UserID: userID}
+		m.guards[userID] = g
+	}
+	return g
+}
+
+func (m *memStore) ReserveOtpSend(_ context.Context, arg postgresCode.ReserveOtpSendParams) (uuid.UUID, error) {
+	now := time.Now()
+	g, ok := m.guards[arg.UserID]
+	if !ok {
+		m.guards[arg.UserID] = &postgresCode.OtpGuard{
+			UserID:          arg.UserID,
+			LastSentAt:      timestamp(now),
+			SendsInWindow:   1,
+			WindowStartedAt: timestamp(now),
+		}
+		return arg.UserID, nil
+	}
+	windowOver := !g.WindowStartedAt.Valid || !g.WindowStartedAt.Time.After(now.Add(-duration(arg.SendWindow)))
+	switch {
+	case g.LockedUntil.Valid && g.LockedUntil.Time.After(now):
+		return uuid.Nil, pgx.ErrNoRows
+	case g.LastSentAt.Valid && g.LastSentAt.Time.After(now.Add(-duration(arg.Cooldown))):
+		return uuid.Nil, pgx.ErrNoRows
+	case !windowOver && g.SendsInWindow >= arg.MaxSends:
+		return uuid.Nil, pgx.ErrNoRows
+	}
+	g.LastSentAt = timestamp(now)
+	if windowOver {
+		g.SendsInWindow = 1
+		g.WindowStartedAt = timestamp(now)
+	} else {
+		g.SendsInWindow++
+	}
+	return arg.UserID, nil
+}
+
+func (m *memStore) GetOtpGuard(_ context.Context, userID uuid.UUID) (postgresCode.OtpGuard, error) {
+	g, ok := m.guards[userID]
+	if !ok {
+		return postgresCode.OtpGuard{}, pgx.ErrNoRows
+	}
+	return *g, nil
+}
+
+func (m *memStore) LockOtpGuard(_ context.Context, arg postgresCode.LockOtpGuardParams) (pgtype.Timestamptz, error) {
+	g := m.guard(arg.UserID)
+	g.Lockouts++
+	i := min(int(g.Lockouts), len(arg.Schedule)) - 1
+	g.LockedUntil = timestamp(time.Now().Add(duration(arg.Schedule[i])))
+	return g.LockedUntil, nil
+}
+
+func (m *memStore) ClearOtpGuard(_ context.Context, userID uuid.UUID) error {
+	if g, ok := m.guards[userID]; ok {
+		g.Lockouts = 0
+		g.LockedUntil = pgtype.Timestamptz{}
+	}
+	return nil
+}
+
+func (m *memStore) UpsertOtpChallenge(_ context.Context, arg postgresCode.UpsertOtpChallengeParams) error {
+	m.challenges[arg.UserID.String()+"/"+arg.Purpose] = &postgresCode.OtpChallenge{
+		ID:        arg.ID,
+		UserID:    arg.UserID,
+		Purpose:   arg.Purpose,
+		Target:    arg.Target,
+		CodeHash:  arg.CodeHash,
+		ExpiresAt: timestamp(time.Now().Add(duration(arg.Ttl))),
+	}
+	return nil
+}
+
+func (m *memStore) ReserveOtpChallengeAttempt(_ context.Context, arg postgresCode.ReserveOtpChallengeAttemptParams) (postgresCode.OtpChallenge, error) {
+	ch, ok := m.challenges[arg.UserID.String()+"/"+arg.Purpose]
+	if !ok || ch.ConsumedAt.Valid || ch.Attempts >= arg.MaxAttempts {
+		return postgresCode.OtpChallenge{}, pgx.ErrNoRows
+	}
+	ch.Attempts++
+	return *ch, nil
+}
+
+func (m *memStore) ConsumeOtpChallenge(_ context.Context, id uuid.UUID) (int64, error) {
+	for _, ch := range m.challenges {
+		if ch.ID == id && !ch.ConsumedAt.Valid {
+			ch.ConsumedAt = timestamp(time.Now())
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *memStore) DeleteOtpChallenge(_ context.Context, id uuid.UUID) error {
+	for key, ch := range m.challenges {
+		if ch.ID == id {
+			delete(m.challenges, key)
+		}
+	}
+	return nil
+}
+
+var testTarget = "user@example.com"
+
+// issue sends a login code to userId and returns it.
+func issue(t *testing.T, s *Service, userId uuid.UUID) string {
+	t.Helper()
+	var sent string
+	err := s.Issue(context.Background(), userId, PurposeLogin, testTarget, func(code string) error {
+		sent = code
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	return sent
+}
+
+// wrongCode returns a well-formed code other than code.
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+// exhaust makes MaxAttempts wrong guesses and returns the error of the last one.
+func exhaust(t *testing.T, s *Service, userId uuid.UUID, code string) error {
+	t.Helper()
+	for i := 1; i < MaxAttempts; i++ {
+		if _, err := s.Check(context.Background(), userId, PurposeLogin, testTarget, wrongCode(code)); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("attempt %d: Check = %v, want ErrInvalid", i, err)
+		}
+	}
+	_, err := s.Check(context.Background(), userId, PurposeLogin, testTarget, wrongCode(code))
+	return err
+}
+
+func TestLastWrongAttemptLocksAndDropsChallenge(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	code := issue(t, s, userId)
+
+	var locked *LockedError
+	if err := exhaust(t, s, userId, code); !errors.As(err, &locked) {
+		t.Fatalf("attempt %d: Check = %v, want *LockedError", MaxAttempts, err)
+	}
+	if wait := time.Until(locked.Until); wait <= 0 || wait > BaseLockout {
+		t.Fatalf("locked for %v, want up to %v", wait, BaseLockout)
+	}
+	if len(store.challenges) != 0 {
+		t.Fatal("the challenge was not dropped")
+	}
+
+	// Even the right code is refused during the lockout
+	if _, err := s.Check(context.Background(), userId, PurposeLogin, testTarget, code); !errors.As(err, &locked) {
+		t.Fatalf("Check during lockout = %v, want *LockedError", err)
+	}
+}
+
+func TestLockoutSchedule(t *testing.T) {
+	want := []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, time.Hour,
+	}
+	got := lockoutSchedule()
+	if len(got) != len(want) {
+		t.Fatalf("lockoutSchedule() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("lockoutSchedule() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLockoutsDoubleUpToMaxLockout(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+
+	want := BaseLockout
+	for n := 1; n <= 9; n++ {
+		ch := &postgresCode.OtpChallenge{ID: uuid.New(), UserID: userId, Attempts: MaxAttempts}
+		var locked *LockedError
+		if err := s.Fail(context.Background(), ch); !errors.As(err, &locked) {
+			t.Fatalf("lockout %d: Fail = %v, want *LockedError", n, err)
+		}
+		if wait := time.Until(locked.Until); wait > want || wait < want-time.Second {
+			t.Fatalf("lockout %d lasts %v, want %v", n, wait, want)
+		}
+		want = min(want*2, MaxLockout)
+	}
+
+	// A verified code starts the schedule over
+	if err := store.ClearOtpGuard(context.Background(), userId); err != nil {
+		t.Fatal(err)
+	}
+	var locked *LockedError
+	if err := s.Fail(context.Background(), &postgresCode.OtpChallenge{UserID: userId, Attempts: MaxAttempts}); !errors.As(err, &locked) {
+		t.Fatalf("Fail = %v, want *LockedError", err)
+	}
+	if wait := time.Until(locked.Until); wait > BaseLockout {
+		t.Fatalf("lockout after a clear lasts %v, want %v", wait, BaseLockout)
+	}
+}
+
+func TestResendThrottling(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	send := func(string) error { return nil }
+
+	issue(t, s, userId)
+	if err := s.Issue(context.Background(), userId, PurposeLogin, testTarget, send); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Issue inside the cooldown = %v, want ErrThrottled", err)
+	}
+
+	for i := 2; i <= MaxSendsPerWindow; i++ {
+		store.guards[userId].LastSentAt = timestamp(time.Now().Add(-ResendCooldown))
+		issue(t, s, userId)
+	}
+	store.guards[userId].LastSentAt = timestamp(time.Now().Add(-ResendCooldown))
+	if err := s.Issue(context.Background(), userId, PurposeLogin, testTarget, send); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Issue after %d sends = %v, want ErrThrottled", MaxSendsPerWindow, err)
+	}
+
+	// A new window allows sending again
+	store.guards[userId].WindowStartedAt = timestamp(time.Now().Add(-SendWindow))
+	issue(t, s, userId)
+}
+
+func TestIssueRefusedWhileLocked(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	code := issue(t, s, userId)
+	exhaust(t, s, userId, code)
+	store.guards[userId].LastSentAt = timestamp(time.Now().Add(-ResendCooldown))
+
+	var locked *LockedError
+	err := s.Issue(context.Background(), userId, PurposeLogin, testTarget, func(string) error { return nil })
+	if !errors.As(err, &locked) {
+		t.Fatalf("Issue during lockout = %v, want *LockedError", err)
+	}
+}
+
+func TestCorrectButExpiredCode(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	code := issue(t, s, userId)
+	store.challenges[userId.String()+"/"+PurposeLogin].ExpiresAt = timestamp(time.Now().Add(-time.Second))
+
+	if _, err := s.Check(context.Background(), userId, PurposeLogin, testTarget, code); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Check = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifyUsesUpTheCode(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	code := issue(t, s, userId)
+
+	if _, err := s.Verify(context.Background(), userId, PurposeLogin, testTarget, code); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := s.Verify(context.Background(), userId, PurposeLogin, testTarget, code); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("second Verify = %v, want ErrInvalid", err)
+	}
+}
+
+func TestWrongTargetCountsAsAttempt(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	code := issue(t, s, userId)
+
+	if _, err := s.Check(context.Background(), userId, PurposeLogin, "other@example.com", code); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Check = %v, want ErrInvalid", err)
+	}
+	if got := store.challenges[userId.String()+"/"+PurposeLogin].Attempts; got != 1 {
+		t.Fatalf("attempts = %d, want 1", got)
+	}
+}
+
+// LoginVerification checks the code, then the password or second factor, and calls Fail when
+// that second check is wrong. Those failures use up the same attempts as wrong codes.
+func TestFailCountsAgainstTheAttemptLimit(t *testing.T) {
+	store := newMemStore()
+	s := New(store, nil)
+	userId := uuid.New()
+	code := issue(t, s, userId)
+
+	for i := 1; i <= MaxAttempts; i++ {
+		ch, err := s.Check(context.Background(), userId, PurposeLogin, testTarget, code)
+		if err != nil {
+			t.Fatalf("attempt %d: Check = %v", i, err)
+		}
+		err = s.Fail(context.Background(), ch)
+		if i < MaxAttempts {
+			if err != nil {
+				t.Fatalf("attempt %d: Fail = %v, want nil", i, err)
+			}
+			continue
+		}
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("attempt %d: Fail = %v, want *LockedError", i, err)
+		}
+	}
+	if len(store.challenges) != 0 {
+		t.Fatal("the challenge was not dropped")
+	}
+}
@@ -0,0 +1,35 @@
+package otp
+
+import (
+	"chatbasket/db/postgresCode"
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Store holds the codes, magic links and guards. *postgresCode.Queries implements it; tests use
+// an in-memory one.
+type Store interface {
+	ReserveOtpSend(ctx context.Context, arg postgresCode.ReserveOtpSendParams) (uuid.UUID, error)
+	GetOtpGuard(ctx context.Context, userID uuid.UUID) (postgresCode.OtpGuard, error)
+	LockOtpGuard(ctx context.Context, arg postgresCode.LockOtpGuardParams) (pgtype.Timestamptz, error)
+	ClearOtpGuard(ctx context.Context, userID uuid.UUID) error
+	DeleteOtpGuard(ctx context.Context, userID uuid.UUID) error
+	DeleteIdleOtpGuards(ctx context.Context, idle pgtype.Interval) (int64, error)
+
+	UpsertOtpChallenge(ctx context.Context, arg postgresCode.UpsertOtpChallengeParams) error
+	ReserveOtpChallengeAttempt(ctx context.Context, arg postgresCode.ReserveOtpChallengeAttemptParams) (postgresCode.OtpChallenge, error)
+	ConsumeOtpChallenge(ctx context.Context, id uuid.UUID) (int64, error)
+	DeleteOtpChallenge(ctx context.Context, id uuid.UUID) error
+	DeleteUserOtpChallenges(ctx context.Context, userID uuid.UUID) error
+	DeleteStaleOtpChallenges(ctx context.Context, grace pgtype.Interval) (int64, error)
+
+	CreateMagicLink(ctx context.Context, arg postgresCode.CreateMagicLinkParams) error
+	GetMagicLink(ctx context.Context, id uuid.UUID) (postgresCode.MagicLink, error)
+	ConsumeMagicLink(ctx context.Context, id uuid.UUID) (int64, error)
+	RecordMagicLinkFailure(ctx context.Context, id uuid.UUID) (int32, error)
+	DeleteMagicLink(ctx context.Context, id uuid.UUID) error
+	DeleteUserMagicLinks(ctx context.Context, userID uuid.UUID) error
+	DeleteStaleMagicLinks(ctx context.Context, grace pgtype.Interval) (int64, error)
+}
@@ -0,0 +1,66 @@
+package personalHandler
+
+import (
+	"chatbasket/model"
+	"chatbasket/personalModel"
+	"chatbasket/personalServices"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ReportHandler lets users report other users, their profiles or their messages to moderators.
+type ReportHandler struct {
+	Service *personalServices.Service
+}
+
+func NewReportHandler(service *personalServices.Service) *ReportHandler {
+	return &ReportHandler{Service: service}
+}
+
+func (h *ReportHandler) FileReport(c echo.Context) error {
+	userId, ok := c.Get("userId").(string)
+	uuidUserId, okUUID := c.Get("uuidUserId").(uuid.UUID)
+	if !ok || !okUUID || userId == "" {
+		return c.JSON(http.StatusUnauthorized, &model.ApiError{
+			Code:    http.StatusUnauthorized,
+			Message: "User id is missing or invalid",
+			Type:    "unauthorized",
+		})
+	}
+
+	var payload personalmodel.CreateReportPayload
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "invalid request payload",
+			Type:    "bad_request",
+		})
+	}
+
+	res, apiErr := h.Service.FileReport(c.Request().Context(), &payload, model.UserId{StringUserId: userId, UuidUserId: uuidUserId})
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+// ListReports lists the user's own reports; pass `next_before` of a page as `before` for the next.
+func (h *ReportHandler) ListReports(c echo.Context) error {
+	userId, ok := c.Get("userId").(string)
+	uuidUserId, okUUID := c.Get("uuidUserId").(uuid.UUID)
+	if !ok || !okUUID || userId == "" {
+		return c.JSON(http.StatusUnauthorized, &model.ApiError{
+			Code:    http.StatusUnauthorized,
+			Message: "User id is missing or invalid",
+			Type:    "unauthorized",
+		})
+	}
+
+	res, apiErr := h.Service.ListMyReports(c.Request().Context(), model.UserId{StringUserId: userId, UuidUserId: uuidUserId}, c.QueryParam("before"))
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+	return c.JSON(http.StatusOK, res)
+}
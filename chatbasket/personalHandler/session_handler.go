@@ -0,0 +1,70 @@
+package personalHandler
+
+import (
+	"chatbasket/model"
+	"chatbasket/personalServices"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// SessionHandler lets users see where they are signed in and sign out other devices.
+type SessionHandler struct {
+	Service *personalServices.Service
+}
+
+func NewSessionHandler(service *personalServices.Service) *SessionHandler {
+	return &SessionHandler{Service: service}
+}
+
+func (h *SessionHandler) ListSessions(c echo.Context) error {
+	userId, ok := c.Get("userId").(string)
+	if !ok || userId == "" {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+	sessionId, ok := c.Get("sessionId").(string)
+	if !ok || sessionId == "" {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid session context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, apiErr := h.Service.ListSessions(c.Request().Context(), userId, sessionId)
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *SessionHandler) RevokeSession(c echo.Context) error {
+	targetSessionId := c.Param("id")
+	if targetSessionId == "" {
+		return c.JSON(http.StatusBadRequest, &model.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "Missing session id",
+			Type:    "missing_value",
+		})
+	}
+	userId, ok := c.Get("userId").(string)
+	uuidUserId, okUUID := c.Get("uuidUserId").(uuid.UUID)
+	if !ok || !okUUID || userId == "" {
+		return c.JSON(http.StatusInternalServerError, &model.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: "Invalid user context",
+			Type:    "internal_server_error",
+		})
+	}
+
+	res, apiErr := h.Service.RevokeSession(c.Request().Context(), &model.UserId{StringUserId: userId, UuidUserId: uuidUserId}, targetSessionId)
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,44 @@
+package personalHandler
+
+import (
+	"chatbasket/model"
+	"chatbasket/personalServices"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// UserHandler handles personal-mode endpoints about other users.
+type UserHandler struct {
+	Service *personalServices.Service
+}
+
+func NewUserHandler(service *personalServices.Service) *UserHandler {
+	return &UserHandler{Service: service}
+}
+
+func (h *UserHandler) GetUserProfileCard(c echo.Context) error {
+	userId, ok := c.Get("userId").(string)
+	if !ok || userId == "" {
+		return c.JSON(http.StatusUnauthorized, &model.ApiError{
+			Code:    http.StatusUnauthorized,
+			Message: "User id is missing or invalid",
+			Type:    "unauthorized",
+		})
+	}
+	uuidUserId, okUUID := c.Get("uuidUserId").(uuid.UUID)
+	if !okUUID {
+		return c.JSON(http.StatusUnauthorized, &model.ApiError{
+			Code:    http.StatusUnauthorized,
+			Message: "User id is missing or invalid",
+			Type:    "unauthorized",
+		})
+	}
+
+	res, apiErr := h.Service.GetUserProfileCard(c.Request().Context(), c.Param("id"), model.UserId{StringUserId: userId, UuidUserId: uuidUserId})
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,39 @@
+package personalmodel
+
+// CreateReportPayload reports a user, their profile or one of their messages. MessageId and
+// Message are required when TargetType is "message".
+type CreateReportPayload struct {
+	TargetType   string           `json:"target_type"`
+	TargetUserId string           `json:"target_user_id"`
+	MessageId    string           `json:"message_id"`
+	Category     string           `json:"category"`
+	Details      *string          `json:"details"`
+	Message      *ReportedMessage `json:"message"`
+}
+
+// ReportedMessage is the reported message as the reporter's client shows it; messages are not
+// stored on the server, so this is the only copy moderators see, and it is shown to them as
+// reporter-supplied.
+type ReportedMessage struct {
+	Content string `json:"content"`
+	SentAt  string `json:"sent_at"`
+}
+
+// Report is a report as shown to the user who filed it.
+type Report struct {
+	Id           string  `json:"id"`
+	TargetType   string  `json:"target_type"`
+	TargetUserId string  `json:"target_user_id,omitempty"` // Empty once the reported user deleted their account
+	TargetId     string  `json:"target_id"`
+	Category     string  `json:"category"`
+	Details      *string `json:"details"`
+	Status       string  `json:"status"`
+	CreatedAt    string  `json:"created_at"`
+	ResolvedAt   string  `json:"resolved_at,omitempty"`
+}
+
+type ReportList struct {
+	Reports []Report `json:"reports"`
+	// NextBefore is the `before` cursor of the next page, empty on the last page
+	NextBefore string `json:"next_before,omitempty"`
+}
@@ -0,0 +1,24 @@
+package personalmodel
+
+// Session is one of the user's signed-in devices.
+type Session struct {
+	Id            string `json:"id"`
+	Current       bool   `json:"current"` // The session making the request
+	ClientName    string `json:"client_name"`
+	ClientVersion string `json:"client_version"`
+	ClientType    string `json:"client_type"`
+	DeviceName    string `json:"device_name"`
+	DeviceBrand   string `json:"device_brand"`
+	DeviceModel   string `json:"device_model"`
+	OsName        string `json:"os_name"`
+	OsVersion     string `json:"os_version"`
+	Ip            string `json:"ip"`
+	CountryName   string `json:"country_name"`
+	CreatedAt     string `json:"created_at"`
+	LastActiveAt  string `json:"last_active_at"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+type SessionList struct {
+	Sessions []Session `json:"sessions"`
+}
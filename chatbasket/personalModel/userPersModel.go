@@ -0,0 +1,24 @@
+package personalmodel
+
+// Relationship describes how the viewer is connected to another user.
+type Relationship struct {
+	IsContact       bool `json:"is_contact"`       // Viewer added the user
+	AddedYou        bool `json:"added_you"`        // User added the viewer
+	IsMutual        bool `json:"is_mutual"`        // Both of the above
+	RequestSent     bool `json:"request_sent"`     // Viewer has a pending request to the user
+	RequestReceived bool `json:"request_received"` // User has a pending request to the viewer
+	Blocked         bool `json:"blocked"`          // Viewer blocked the user
+}
+
+// UserProfileCard is another user's profile as seen by the viewer, with restrictions applied.
+// Bio and AvatarUrl are nil when the user's restrictions hide them from the viewer.
+type UserProfileCard struct {
+	Id           string       `json:"id"`
+	Username     string       `json:"username"`
+	Name         string       `json:"name"`
+	Nickname     *string      `json:"nickname"`
+	Bio          *string      `json:"bio"`
+	AvatarUrl    *string      `json:"avatar_url"`
+	ProfileType  string       `json:"profile_type"`
+	Relationship Relationship `json:"relationship"`
+}
@@ -48,23 +48,11 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 	for _, c := range myContacts {
 		id := c.ID.String()
 		myContactsMap[id] = struct{}{}
-		myNicknameByID[id] = c.Nickname
-	}
-
-	shouldExposeAvatar := func(globalRestrictProfile, exceptionGlobalProfile, globalRestrictAvatar, exceptionGlobalAvatar, userRestrictProfile, userRestrictAvatar bool) bool {
-		if globalRestrictProfile {
-			return exceptionGlobalProfile
-		}
-		if globalRestrictAvatar {
-			return exceptionGlobalAvatar
+		nickname, apiErr := ps.openField(c.Nickname, utils.FieldPurposeNickname, userId.UuidUserId)
+		if apiErr != nil {
+			return nil, apiErr
 		}
-		if userRestrictProfile {
-			return false
-		}
-		if userRestrictAvatar {
-			return false
-		}
-		return true
+		myNicknameByID[id] = nickname
 	}
 
 	contacts := make([]personalmodel.Contact, 0, len(myContacts))
@@ -72,7 +60,7 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 		username := ""
 		if c.Username != "" {
 			var err error
-			username, err = utils.DecryptUsername(c.Username, ps.Appwrite.PersonalUsernameKey)
+			username, err = utils.DecryptUsername(c.Username, ps.Appwrite.PersonalUsernameKey, c.ID.String())
 			if err != nil {
 				return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt contact username", Type: "internal_server_error"}
 			}
@@ -90,11 +78,12 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 
 		var avatarURL *string
 		if shouldExposeAvatar(c.GlobalRestrictProfile, c.ExceptionGlobalProfile, c.GlobalRestrictAvatar, c.ExceptionGlobalAvatar, c.UserRestrictProfile, c.UserRestrictAvatar) {
-			url, apiErr := ps.buildAvatarURL(ctx, c.AvatarFileID, c.AvatarTokenID, c.AvatarTokenSecret, c.AvatarTokenExpiry, c.ID)
-			if apiErr != nil {
-				return nil, apiErr
-			}
-			avatarURL = url
+			avatarURL = ps.buildAvatarURL(c.AvatarFileID, c.ID, userId.UuidUserId, utils.AvatarSizeList)
+		}
+
+		bio, apiErr := ps.openField(c.Bio, utils.FieldPurposeBio, c.ID)
+		if apiErr != nil {
+			return nil, apiErr
 		}
 
 		_, isMutual := addedMeMap[c.ID.String()]
@@ -103,8 +92,8 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 			ID:        c.ID.String(),
 			Name:      c.Name,
 			Username:  username,
-			Bio:       c.Bio,
-			Nickname:  c.Nickname,
+			Bio:       bio,
+			Nickname:  myNicknameByID[c.ID.String()],
 			CreatedAt: createdAt,
 			UpdatedAt: updatedAt,
 			AvatarURL: avatarURL,
@@ -117,7 +106,7 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 		username := ""
 		if p.Username != "" {
 			var err error
-			username, err = utils.DecryptUsername(p.Username, ps.Appwrite.PersonalUsernameKey)
+			username, err = utils.DecryptUsername(p.Username, ps.Appwrite.PersonalUsernameKey, p.ID.String())
 			if err != nil {
 				return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt contact username", Type: "internal_server_error"}
 			}
@@ -135,11 +124,12 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 
 		var avatarURL *string
 		if shouldExposeAvatar(p.GlobalRestrictProfile, p.ExceptionGlobalProfile, p.GlobalRestrictAvatar, p.ExceptionGlobalAvatar, p.UserRestrictProfile, p.UserRestrictAvatar) {
-			url, apiErr := ps.buildAvatarURL(ctx, p.AvatarFileID, p.AvatarTokenID, p.AvatarTokenSecret, p.AvatarTokenExpiry, p.ID)
-			if apiErr != nil {
-				return nil, apiErr
-			}
-			avatarURL = url
+			avatarURL = ps.buildAvatarURL(p.AvatarFileID, p.ID, userId.UuidUserId, utils.AvatarSizeList)
+		}
+
+		bio, apiErr := ps.openField(p.Bio, utils.FieldPurposeBio, p.ID)
+		if apiErr != nil {
+			return nil, apiErr
 		}
 
 		_, isMutual := myContactsMap[p.ID.String()]
@@ -152,7 +142,7 @@ func (ps *Service) GetContacts(ctx context.Context, userId model.UserId) (*perso
 			ID:        p.ID.String(),
 			Name:      p.Name,
 			Username:  username,
-			Bio:       p.Bio,
+			Bio:       bio,
 			Nickname:  myNickname,
 			CreatedAt: createdAt,
 			UpdatedAt: updatedAt,
@@ -297,6 +287,12 @@ func (ps *Service) CreateContact(ctx context.Context, payload *personalmodel.Cre
 			nickname = &v
 		}
 	}
+	// Nicknames are stored encrypted and bound to the owner (requester), so the
+	// accept trigger can copy the request nickname into user_contacts unchanged.
+	nickname, apiErr := ps.sealField(nickname, utils.FieldPurposeNickname, userId.UuidUserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
 	// Handle based on target profile type
 	switch targetProfile.ProfileType {
@@ -552,22 +548,6 @@ func (ps *Service) UndoContactRequest(ctx context.Context, payload *personalmode
 }
 
 func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId) (*personalmodel.GetContactRequestsResponse, *model.ApiError) {
-	shouldExposeAvatar := func(globalRestrictProfile, exceptionGlobalProfile, globalRestrictAvatar, exceptionGlobalAvatar, userRestrictProfile, userRestrictAvatar bool) bool {
-		if globalRestrictProfile {
-			return exceptionGlobalProfile
-		}
-		if globalRestrictAvatar {
-			return exceptionGlobalAvatar
-		}
-		if userRestrictProfile {
-			return false
-		}
-		if userRestrictAvatar {
-			return false
-		}
-		return true
-	}
-
 	// Fetch viewer's contacts so we can reuse their own nicknames for pending requests
 	myContacts, err := ps.Queries.GetUserContacts(ctx, userId.UuidUserId)
 	if err != nil {
@@ -575,7 +555,11 @@ func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId)
 	}
 	myNicknameByID := make(map[string]*string, len(myContacts))
 	for _, c := range myContacts {
-		myNicknameByID[c.ID.String()] = c.Nickname
+		nickname, apiErr := ps.openField(c.Nickname, utils.FieldPurposeNickname, userId.UuidUserId)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		myNicknameByID[c.ID.String()] = nickname
 	}
 
 	transformPending := func(rows []postgresCode.GetPendingContactRequestsRow) ([]personalmodel.PendingContactRequest, *model.ApiError) {
@@ -583,7 +567,7 @@ func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId)
 		for _, r := range rows {
 			username := ""
 			if r.Username != "" {
-				decoded, err := utils.DecryptUsername(r.Username, ps.Appwrite.PersonalUsernameKey)
+				decoded, err := utils.DecryptUsername(r.Username, ps.Appwrite.PersonalUsernameKey, r.ID.String())
 				if err != nil {
 					return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt username", Type: "internal_server_error"}
 				}
@@ -602,11 +586,12 @@ func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId)
 
 			var avatarURL *string
 			if shouldExposeAvatar(r.GlobalRestrictProfile, r.ExceptionGlobalProfile, r.GlobalRestrictAvatar, r.ExceptionGlobalAvatar, r.UserRestrictProfile, r.UserRestrictAvatar) {
-				url, apiErr := ps.buildAvatarURL(ctx, r.AvatarFileID, r.AvatarTokenID, r.AvatarTokenSecret, r.AvatarTokenExpiry, r.ID)
-				if apiErr != nil {
-					return nil, apiErr
-				}
-				avatarURL = url
+				avatarURL = ps.buildAvatarURL(r.AvatarFileID, r.ID, userId.UuidUserId, utils.AvatarSizeList)
+			}
+
+			bio, apiErr := ps.openField(r.Bio, utils.FieldPurposeBio, r.ID)
+			if apiErr != nil {
+				return nil, apiErr
 			}
 
 			// Use viewer's own contact nickname for this user, if it exists
@@ -619,7 +604,7 @@ func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId)
 				ID:          r.ID.String(),
 				Name:        r.Name,
 				Username:    username,
-				Bio:         r.Bio,
+				Bio:         bio,
 				Nickname:    myNickname,
 				RequestedAt: requestedAt,
 				UpdatedAt:   updatedAt,
@@ -635,7 +620,7 @@ func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId)
 		for _, r := range rows {
 			username := ""
 			if r.Username != "" {
-				decoded, err := utils.DecryptUsername(r.Username, ps.Appwrite.PersonalUsernameKey)
+				decoded, err := utils.DecryptUsername(r.Username, ps.Appwrite.PersonalUsernameKey, r.ID.String())
 				if err != nil {
 					return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt username", Type: "internal_server_error"}
 				}
@@ -654,19 +639,25 @@ func (ps *Service) GetContactRequests(ctx context.Context, userId model.UserId)
 
 			var avatarURL *string
 			if shouldExposeAvatar(r.GlobalRestrictProfile, r.ExceptionGlobalProfile, r.GlobalRestrictAvatar, r.ExceptionGlobalAvatar, r.UserRestrictProfile, r.UserRestrictAvatar) {
-				url, apiErr := ps.buildAvatarURL(ctx, r.AvatarFileID, r.AvatarTokenID, r.AvatarTokenSecret, r.AvatarTokenExpiry, r.ID)
-				if apiErr != nil {
-					return nil, apiErr
-				}
-				avatarURL = url
+				avatarURL = ps.buildAvatarURL(r.AvatarFileID, r.ID, userId.UuidUserId, utils.AvatarSizeList)
+			}
+
+			bio, apiErr := ps.openField(r.Bio, utils.FieldPurposeBio, r.ID)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			// Sent requests carry the viewer's own nickname for the receiver
+			nickname, apiErr := ps.openField(r.Nickname, utils.FieldPurposeNickname, userId.UuidUserId)
+			if apiErr != nil {
+				return nil, apiErr
 			}
 
 			records = append(records, personalmodel.SentContactRequest{
 				ID:          r.ID.String(),
 				Name:        r.Name,
 				Username:    username,
-				Bio:         r.Bio,
-				Nickname:    r.Nickname,
+				Bio:         bio,
+				Nickname:    nickname,
 				RequestedAt: requestedAt,
 				UpdatedAt:   updatedAt,
 				Status:      r.Status,
@@ -727,6 +718,10 @@ func (ps *Service) UpdateContactNickname(ctx context.Context, payload *personalm
 			nickname = &v
 		}
 	}
+	nickname, apiErr := ps.sealField(nickname, utils.FieldPurposeNickname, userId.UuidUserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
 	_, err = ps.Queries.UpdateContactNickname(ctx, postgresCode.UpdateContactNicknameParams{
 		OwnerUserID:   userId.UuidUserId,
@@ -0,0 +1,109 @@
+package personalServices
+
+import (
+	"bytes"
+	"chatbasket/appwriteinternal"
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/personalModel"
+	"chatbasket/services"
+	"chatbasket/utils"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// recordingDB captures the arguments of every query and fails it, so a test can inspect what a
+// service would have written without a database.
+type recordingDB struct {
+	args [][]any
+}
+
+func (db *recordingDB) Exec(_ context.Context, _ string, args ...interface{}) (pgconn.CommandTag, error) {
+	db.args = append(db.args, args)
+	return pgconn.CommandTag{}, pgx.ErrNoRows
+}
+
+func (db *recordingDB) Query(_ context.Context, _ string, args ...interface{}) (pgx.Rows, error) {
+	db.args = append(db.args, args)
+	return nil, pgx.ErrNoRows
+}
+
+func (db *recordingDB) QueryRow(_ context.Context, _ string, args ...interface{}) pgx.Row {
+	db.args = append(db.args, args)
+	return errRow{}
+}
+
+type errRow struct{}
+
+func (errRow) Scan(...any) error { return pgx.ErrNoRows }
+
+func newRecordingService() (*Service, *recordingDB) {
+	db := &recordingDB{}
+	gs := &services.GlobalService{
+		Appwrite: &appwriteinternal.AppwriteService{PersonalFieldKey: bytes.Repeat([]byte{0x42}, 32)},
+		Queries:  postgresCode.New(db),
+	}
+	return New(gs), db
+}
+
+// assertSealed checks that the single recorded query received a sealed value and no plaintext.
+func assertSealed(t *testing.T, db *recordingDB, plaintext string) {
+	t.Helper()
+	if len(db.args) != 1 {
+		t.Fatalf("expected 1 query, got %d", len(db.args))
+	}
+	found := false
+	for _, arg := range db.args[0] {
+		s, ok := arg.(*string)
+		if !ok || s == nil {
+			continue
+		}
+		if strings.Contains(*s, plaintext) {
+			t.Fatalf("query argument %q contains the plaintext", *s)
+		}
+		if strings.HasPrefix(*s, "enc1.") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("no sealed enc1. argument reached the query")
+	}
+}
+
+func TestUpdateUserProfileSealsBio(t *testing.T) {
+	ps, db := newRecordingService()
+	bio := "my private bio"
+	userId := model.UserId{UuidUserId: uuid.New()}
+	userId.StringUserId = userId.UuidUserId.String()
+
+	ps.UpdateUserProfile(context.Background(), &personalmodel.UpdateUserProfilePayload{Bio: &bio}, userId)
+	assertSealed(t, db, bio)
+}
+
+func TestUpdateContactNicknameSealsNickname(t *testing.T) {
+	ps, db := newRecordingService()
+	nickname := "bestie"
+	userId := model.UserId{UuidUserId: uuid.New()}
+	userId.StringUserId = userId.UuidUserId.String()
+
+	ps.UpdateContactNickname(context.Background(), &personalmodel.UpdateContactNicknamePayload{
+		ContactUserId: uuid.NewString(),
+		Nickname:      &nickname,
+	}, userId)
+	assertSealed(t, db, nickname)
+
+	// The stored value opens again for its owner
+	for _, arg := range db.args[0] {
+		if s, ok := arg.(*string); ok && s != nil {
+			opened, err := utils.DecryptField(*s, ps.Appwrite.PersonalFieldKey, utils.FieldPurposeNickname, userId.StringUserId)
+			if err != nil || opened != nickname {
+				t.Fatalf("DecryptField = %q, %v; want %q", opened, err, nickname)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package personalServices
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/services"
+	"chatbasket/utils"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// OpenAvatar re-checks that the viewer named in a signed avatar URL may still see the owner's
+// avatar (blocks, admin-block, profile type and restrictions can change after the URL was issued)
+// and opens it from storage. Hidden avatars are reported as not found.
+func (ps *Service) OpenAvatar(ctx context.Context, claims *utils.AvatarURLClaims, ifNoneMatch string) (*services.AvatarStream, *model.ApiError) {
+	notFound := &model.ApiError{Code: http.StatusNotFound, Message: "avatar_not_found", Type: "not_found"}
+
+	ownerUUID, err := uuid.Parse(claims.OwnerID)
+	if err != nil {
+		return nil, notFound
+	}
+	viewerUUID, err := uuid.Parse(claims.ViewerID)
+	if err != nil {
+		return nil, notFound
+	}
+
+	/*
+		DB call to get the owner's avatar with the viewer's restriction and relationship data
+	*/
+	card, err := ps.Queries.GetUserProfileCard(ctx, postgresCode.GetUserProfileCardParams{
+		ID:       ownerUUID,
+		ViewerID: viewerUUID,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, notFound
+		}
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if card.AvatarFileID == nil || *card.AvatarFileID == "" {
+		return nil, notFound
+	}
+
+	if ownerUUID != viewerUUID {
+		if card.IsAdminBlocked || card.BlockedYou || card.BlockedByYou {
+			return nil, notFound
+		}
+		if card.ProfileType == "private" && !card.AddedYou {
+			return nil, notFound
+		}
+		if !shouldExposeAvatar(card.GlobalRestrictProfile, card.ExceptionGlobalProfile, card.GlobalRestrictAvatar, card.ExceptionGlobalAvatar, card.UserRestrictProfile, card.UserRestrictAvatar) {
+			return nil, notFound
+		}
+	}
+
+	version := ""
+	if card.AvatarUpdatedAt.Valid {
+		version = strconv.FormatInt(card.AvatarUpdatedAt.Time.UnixNano(), 10)
+	}
+	tokenSecret := usableAvatarToken(card.AvatarTokenSecret, card.AvatarTokenExpiry)
+	return ps.OpenAvatarVariant(ctx, ps.Appwrite.PersonalProfilePicBucketID, *card.AvatarFileID, claims.Size, tokenSecret, version, ifNoneMatch)
+}
@@ -1,6 +1,7 @@
 package personalServices
 
 import (
+	"chatbasket/audit"
 	"chatbasket/db/postgresCode"
 	"chatbasket/model"
 	"chatbasket/personalModel"
@@ -8,11 +9,11 @@ import (
 	"chatbasket/services"
 	"chatbasket/utils"
 	"context"
-	"mime/multipart"
+	"io"
 	"net/http"
+	"slices"
 	"time"
 
-	"github.com/appwrite/sdk-for-go/query"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -20,9 +21,18 @@ import (
 
 // Template: mirror public profile methods for personal mode. Implement later.
 
-func (ps *Service) Logout(ctx context.Context, payload *personalmodel.LogoutPayload, userId, sessionId string) (*model.StatusOkay, *model.ApiError) {
+func (ps *Service) Logout(ctx context.Context, payload *personalmodel.LogoutPayload, userId *model.UserId, sessionId string) (*model.StatusOkay, *model.ApiError) {
 	if payload.AllSessions {
-		_, err := ps.Appwrite.Users.DeleteSessions(userId)
+		if _, err := ps.Queries.DeactivateUserTokens(ctx, userId.UuidUserId); err != nil {
+			return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "Failed to revoke push tokens: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		if apiErr := ps.RevokeRefreshTokens(ctx, userId.UuidUserId, ""); apiErr != nil {
+			return nil, apiErr
+		}
+		if apiErr := ps.RevokeApiKeys(ctx, userId.UuidUserId); apiErr != nil {
+			return nil, apiErr
+		}
+		_, err := ps.Appwrite.Users.DeleteSessions(userId.StringUserId)
 		if err != nil {
 			return nil, &model.ApiError{
 				Code:    401,
@@ -31,7 +41,10 @@ func (ps *Service) Logout(ctx context.Context, payload *personalmodel.LogoutPayl
 			}
 		}
 	} else {
-		_, err := ps.Appwrite.Users.DeleteSession(userId, sessionId)
+		if apiErr := ps.revokeSessionTokens(ctx, userId.UuidUserId, sessionId); apiErr != nil {
+			return nil, apiErr
+		}
+		_, err := ps.Appwrite.Users.DeleteSession(userId.StringUserId, sessionId)
 		if err != nil {
 			return nil, &model.ApiError{
 				Code:    401,
@@ -40,6 +53,7 @@ func (ps *Service) Logout(ctx context.Context, payload *personalmodel.LogoutPayl
 			}
 		}
 	}
+	ps.RecordLogoutAudit(ctx, userId.UuidUserId, sessionId, payload.AllSessions)
 
 	return &model.StatusOkay{Status: true, Message: "Logged out successfully"}, nil
 }
@@ -117,21 +131,25 @@ func (ps *Service) GetProfile(ctx context.Context, userId model.UserId, email st
 	}
 
 	// decrypt username
-	decodeUsername, err := utils.DecryptUsername(profile.B64CipherChacha20poly1305Username, ps.Appwrite.PersonalUsernameKey)
+	decodeUsername, err := utils.DecryptUsername(profile.B64CipherChacha20poly1305Username, ps.Appwrite.PersonalUsernameKey, userId.StringUserId)
 	if err != nil {
 		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "personal GetProfile failed", Type: "internal_server_error"}
 	}
 
-	finalAvatarUrl, apiErr := ps.buildAvatarURL(ctx, profile.FileID, profile.TokenID, profile.TokenSecret, profile.TokenExpiry, userId.UuidUserId)
+	// decrypt bio
+	bio, apiErr := ps.openField(profile.Bio, utils.FieldPurposeBio, userId.UuidUserId)
 	if apiErr != nil {
 		return nil, apiErr
 	}
+	profile.Bio = bio
+
+	finalAvatarUrl := ps.buildAvatarURL(profile.FileID, userId.UuidUserId, userId.UuidUserId, utils.AvatarSizeProfile)
 
 	return personalmodel.ToPrivateUserWithAvatar(&profile, decodeUsername, email, finalAvatarUrl), nil
 }
 
-func (ps *Service) UploadUserProfilePicture(ctx context.Context, fh *multipart.FileHeader, userId model.UserId) (*model.StatusOkay, *model.ApiError) {
-    if fh == nil {
+func (ps *Service) UploadUserProfilePicture(ctx context.Context, avatar io.Reader, userId model.UserId) (*model.StatusOkay, *model.ApiError) {
+    if avatar == nil {
         return nil, &model.ApiError{Code: 400, Message: "no file provided", Type: "bad_request"}
     }
     // check if user profile pic exists and if it exists, delete it
@@ -140,14 +158,7 @@ func (ps *Service) UploadUserProfilePicture(ctx context.Context, fh *multipart.F
         return nil, &model.ApiError{Code: 500, Message: "Failed to check user profile pic: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
     }
 
-	checkExistInStorage, err := ps.Appwrite.Storage.ListFiles(
-		ps.Appwrite.PersonalProfilePicBucketID,
-		ps.Appwrite.Storage.WithListFilesQueries(
-			[]string{
-				query.Equal("$id", userId.StringUserId),
-			},
-		),
-	)
+	existsInStorage, err := ps.Storage.Exists(ctx, ps.Appwrite.PersonalProfilePicBucketID, userId.StringUserId)
 	if err != nil {
 		return nil, &model.ApiError{
 			Code:    500,
@@ -156,16 +167,17 @@ func (ps *Service) UploadUserProfilePicture(ctx context.Context, fh *multipart.F
 		}
 	}
 
-	var deleteExisting bool
-	if checkExistInStorage.Total == 1 {
-		deleteExisting = true
-	}
-
-	result, apiErr := ps.UploadFileFromMultipart(
+	result, apiErr := ps.UploadAvatarImage(
+		ctx,
 		ps.Appwrite.PersonalProfilePicBucketID,
 		userId.StringUserId,
-		fh,
-		services.UploadOptions{DeleteExisting: deleteExisting, GenerateTokens: true},
+		avatar,
+		services.UploadOptions{
+			DeleteExisting: existsInStorage,
+			GenerateTokens: true,
+			OwnerID:        userId.UuidUserId,
+			Kind:           services.StorageKindAvatar,
+		},
 	)
 	if apiErr != nil {
 		return nil, apiErr
@@ -220,14 +232,7 @@ func (ps *Service) RemoveUserProfilePicture(ctx context.Context, userId model.Us
 		return nil, &model.ApiError{Code: 500, Message: "Failed to check user profile pic: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
 	}
 
-	checkExistInStorage, err := ps.Appwrite.Storage.ListFiles(
-		ps.Appwrite.PersonalProfilePicBucketID,
-		ps.Appwrite.Storage.WithListFilesQueries(
-			[]string{
-				query.Equal("$id", userId.StringUserId),
-			},
-		),
-	)
+	existsInStorage, err := ps.Storage.Exists(ctx, ps.Appwrite.PersonalProfilePicBucketID, userId.StringUserId)
 	if err != nil {
 		return nil, &model.ApiError{
 			Code:    500,
@@ -236,7 +241,7 @@ func (ps *Service) RemoveUserProfilePicture(ctx context.Context, userId model.Us
 		}
 	}
 
-	if checkExistInStorage.Total == 0 {
+	if !existsInStorage {
 		if resUser {
 			err = ps.Queries.DeleteAvatar(ctx, userId.UuidUserId)
 			if err != nil {
@@ -250,39 +255,9 @@ func (ps *Service) RemoveUserProfilePicture(ctx context.Context, userId model.Us
 		}
 	}
 
-	// Delete the file access token
-	tok, err := ps.Appwrite.Tokens.List(ps.Appwrite.PersonalProfilePicBucketID, userId.StringUserId)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query token data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	if tok.Total > 0 {
-		for _, token := range tok.Tokens {
-			_, err := ps.Appwrite.Tokens.Delete(token.Id)
-			if err != nil {
-				return nil, &model.ApiError{
-					Code:    500,
-					Message: "Failed to delete token data: " + err.Error(),
-					Type:    "internal_server_error",
-				}
-			}
-		}
-	}
-
-	// Delete the file from storage
-	_, err = ps.Appwrite.Storage.DeleteFile(
-		ps.Appwrite.PersonalProfilePicBucketID,
-		userId.StringUserId,
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to delete profile picture from storage: " + err.Error(),
-			Type:    "not_found",
-		}
+	// Delete the file access tokens, the file and its resized variants
+	if apiErr := ps.DeleteAvatarFiles(ctx, ps.Appwrite.PersonalProfilePicBucketID, userId.StringUserId); apiErr != nil {
+		return nil, apiErr
 	}
 
 	if resUser {
@@ -297,15 +272,30 @@ func (ps *Service) RemoveUserProfilePicture(ctx context.Context, userId model.Us
 }
 
 func (ps *Service) UpdateUserProfile(ctx context.Context, payload *personalmodel.UpdateUserProfilePayload, userId model.UserId) (*model.StatusOkay, *model.ApiError) {
+	bio, apiErr := ps.sealField(payload.Bio, utils.FieldPurposeBio, userId.UuidUserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
 	_, err := ps.Queries.UpdateUserProfile(ctx, postgresCode.UpdateUserProfileParams{
 		ID:          userId.UuidUserId,
 		Name:        payload.Name,
-		Bio:         payload.Bio,
+		Bio:         bio,
 		ProfileType: payload.ProfileType,
 	})
 	if err != nil {
 		return nil, &model.ApiError{Code: 500, Message: "Failed to update user profile: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
 	}
 
+	// Only which fields changed; the bio is private
+	var fields []string
+	for field, value := range map[string]*string{"name": payload.Name, "bio": payload.Bio, "profile_type": payload.ProfileType} {
+		if value != nil {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	ps.RecordUserAudit(ctx, userId.UuidUserId, audit.ActionProfileUpdated, audit.TargetPersonalProfile, userId.StringUserId, map[string]any{"fields": fields})
+
 	return &model.StatusOkay{Status: true, Message: "Profile updated successfully"}, nil
 }
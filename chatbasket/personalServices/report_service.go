@@ -0,0 +1,222 @@
+package personalServices
+
+import (
+	"chatbasket/audit"
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/personalModel"
+	"chatbasket/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	maxReportDetailsLength   = 1000
+	maxReportedMessageLength = 4000
+	maxReportMessageIdLength = 128
+	defaultReportPageLimit   = 50
+)
+
+// reportEvidence is the snapshot stored with a report, so moderators see the target as it was
+// when it was reported even if it has changed since. It is stored sealed with
+// utils.FieldPurposeReportEvidence and only opened for admins.
+type reportEvidence struct {
+	Profile    reportedProfile  `json:"profile"`
+	Message    *reportedMessage `json:"message,omitempty"`
+	CapturedAt time.Time        `json:"captured_at"`
+}
+
+// reportedMessage is the reported message as the reporter's client showed it. Messages are not
+// stored on the server, so it cannot be checked and is always flagged reporter_supplied.
+type reportedMessage struct {
+	Content          string `json:"content"`
+	SentAt           string `json:"sent_at,omitempty"`
+	ReporterSupplied bool   `json:"reporter_supplied"`
+}
+
+type reportedProfile struct {
+	Name           string  `json:"name"`
+	Username       string  `json:"username"`
+	Bio            *string `json:"bio"`
+	ProfileType    string  `json:"profile_type"`
+	IsAdminBlocked bool    `json:"is_admin_blocked"`
+}
+
+// FileReport files a report against another user, their profile or one of their messages. A
+// reporter can hold one open report per target; filing another returns a conflict.
+func (ps *Service) FileReport(ctx context.Context, payload *personalmodel.CreateReportPayload, userId model.UserId) (*personalmodel.Report, *model.ApiError) {
+	if payload == nil || payload.TargetUserId == "" {
+		return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid request payload", Type: "bad_request"}
+	}
+	targetUUID, err := uuid.Parse(payload.TargetUserId)
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid target_user_id", Type: "bad_request"}
+	}
+	if targetUUID == userId.UuidUserId {
+		return nil, &model.ApiError{Code: http.StatusConflict, Message: "self_action_not_allowed", Type: "conflict"}
+	}
+	if !slices.Contains(model.ReportCategories, payload.Category) {
+		return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid category", Type: "bad_request"}
+	}
+
+	var details *string
+	if payload.Details != nil {
+		d := strings.TrimSpace(*payload.Details)
+		if len(d) > maxReportDetailsLength {
+			return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "details_too_long", Type: "bad_request"}
+		}
+		if d != "" {
+			details = &d
+		}
+	}
+
+	evidence := reportEvidence{CapturedAt: time.Now().UTC()}
+	targetId := targetUUID.String()
+	switch payload.TargetType {
+	case model.ReportTargetUser, model.ReportTargetProfile:
+	case model.ReportTargetMessage:
+		messageId := strings.TrimSpace(payload.MessageId)
+		if messageId == "" || len(messageId) > maxReportMessageIdLength {
+			return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid message_id", Type: "bad_request"}
+		}
+		if payload.Message == nil || strings.TrimSpace(payload.Message.Content) == "" || len(payload.Message.Content) > maxReportedMessageLength {
+			return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid message", Type: "bad_request"}
+		}
+		if payload.Message.SentAt != "" {
+			if _, err := time.Parse(time.RFC3339Nano, payload.Message.SentAt); err != nil {
+				return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "sent_at must be an RFC 3339 timestamp", Type: "bad_request"}
+			}
+		}
+		targetId = messageId
+		evidence.Message = &reportedMessage{
+			Content:          payload.Message.Content,
+			SentAt:           payload.Message.SentAt,
+			ReporterSupplied: true,
+		}
+	default:
+		return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid target_type", Type: "bad_request"}
+	}
+
+	/*
+		DB call to snapshot the reported user's profile
+	*/
+	target, err := ps.Queries.GetUserCoreProfile(ctx, targetUUID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: http.StatusNotFound, Message: "user_not_found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	username, err := utils.DecryptUsername(target.B64CipherChacha20poly1305Username, ps.Appwrite.PersonalUsernameKey, target.ID.String())
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt username", Type: "internal_server_error"}
+	}
+	bio, apiErr := ps.openField(target.Bio, utils.FieldPurposeBio, target.ID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	evidence.Profile = reportedProfile{
+		Name:           target.Name,
+		Username:       username,
+		Bio:            bio,
+		ProfileType:    target.ProfileType,
+		IsAdminBlocked: target.IsAdminBlocked,
+	}
+	evidenceJSON, err := json.Marshal(evidence)
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to encode evidence", Type: "internal_server_error"}
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to generate report id", Type: "internal_server_error"}
+	}
+	// The snapshot holds the target's decrypted username and bio, so it is sealed like them
+	sealedEvidence, err := utils.EncryptField(string(evidenceJSON), ps.Appwrite.PersonalFieldKey, utils.FieldPurposeReportEvidence, id.String())
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to encrypt evidence", Type: "internal_server_error"}
+	}
+	report, err := ps.Queries.CreateReport(ctx, postgresCode.CreateReportParams{
+		ID:                id,
+		ReporterUserID:    userId.UuidUserId,
+		TargetType:        payload.TargetType,
+		TargetUserID:      pgtype.UUID{Bytes: targetUUID, Valid: true},
+		TargetID:          targetId,
+		Category:          payload.Category,
+		Details:           details,
+		B64CipherEvidence: sealedEvidence,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: http.StatusConflict, Message: "report_already_filed", Type: "conflict"}
+		}
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	ps.RecordUserAudit(ctx, userId.UuidUserId, audit.ActionReportFiled, audit.TargetReport, report.ID.String(), map[string]any{
+		"target_type":    report.TargetType,
+		"target_user_id": targetUUID.String(),
+		"category":       report.Category,
+	})
+
+	res := toPersonalReport(report)
+	return &res, nil
+}
+
+// ListMyReports returns the reports the user filed, newest first. before is the id of the last
+// report of the previous page.
+func (ps *Service) ListMyReports(ctx context.Context, userId model.UserId, before string) (*personalmodel.ReportList, *model.ApiError) {
+	params := postgresCode.ListReporterReportsParams{
+		ReporterUserID: userId.UuidUserId,
+		MaxResults:     defaultReportPageLimit,
+	}
+	if before != "" {
+		id, err := uuid.Parse(before)
+		if err != nil {
+			return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid before", Type: "bad_request"}
+		}
+		params.BeforeID = pgtype.UUID{Bytes: id, Valid: true}
+	}
+
+	rows, err := ps.Queries.ListReporterReports(ctx, params)
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	res := &personalmodel.ReportList{Reports: make([]personalmodel.Report, 0, len(rows))}
+	for _, r := range rows {
+		res.Reports = append(res.Reports, toPersonalReport(r))
+	}
+	if len(rows) == defaultReportPageLimit {
+		res.NextBefore = rows[len(rows)-1].ID.String()
+	}
+	return res, nil
+}
+
+func toPersonalReport(r postgresCode.Report) personalmodel.Report {
+	report := personalmodel.Report{
+		Id:         r.ID.String(),
+		TargetType: r.TargetType,
+		TargetId:   r.TargetID,
+		Category:   r.Category,
+		Details:    r.Details,
+		Status:     r.Status,
+		CreatedAt:  r.CreatedAt.Time.UTC().Format(time.RFC3339),
+	}
+	if r.TargetUserID.Valid {
+		report.TargetUserId = uuid.UUID(r.TargetUserID.Bytes).String()
+	}
+	if r.ResolvedAt.Valid {
+		report.ResolvedAt = r.ResolvedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return report
+}
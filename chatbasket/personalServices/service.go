@@ -1,11 +1,9 @@
 package personalServices
 
 import (
-	"chatbasket/db/postgresCode"
 	"chatbasket/model"
 	"chatbasket/services"
 	"chatbasket/utils"
-	"context"
 	"net/http"
 	"time"
 
@@ -24,70 +22,70 @@ func New(gs *services.GlobalService) *Service {
 	return &Service{GlobalService: gs}
 }
 
-func (ps *Service) buildAvatarURL(
-	ctx context.Context,
-	fileID, tokenID, tokenSecret *string,
-	tokenExpiry pgtype.Timestamptz,
-	ownerID uuid.UUID,
-) (*string, *model.ApiError) {
+// buildAvatarURL returns a signed media proxy URL for the size variant of ownerID's avatar as seen
+// by viewerID. Storage tokens never leave the backend; visibility is re-checked when the URL is fetched.
+func (ps *Service) buildAvatarURL(fileID *string, ownerID, viewerID uuid.UUID, size int) *string {
 	if fileID == nil || *fileID == "" {
-		return nil, nil
+		return nil
 	}
+	return utils.BuildSignedAvatarURL(ps.Appwrite.PublicApiBaseURL, ps.Appwrite.AvatarURLSigningKey, utils.AvatarScopePersonal, ownerID.String(), viewerID.String(), size, time.Now())
+}
 
-	finalAvatar := utils.BuildAvatarURI(&utils.AppwriteFileData{
-		FileId:     fileID,
-		FileToken:  tokenID,
-		FileSecret: tokenSecret,
-	})
-
-	now := time.Now().UTC()
-	needsRefresh := false
-	if tokenExpiry.Valid {
-		needsRefresh = !tokenExpiry.Time.UTC().After(now)
-	} else if tokenID != nil && *tokenID != "" && tokenSecret != nil && *tokenSecret != "" {
-		needsRefresh = true
+// usableAvatarToken returns the stored Appwrite file token secret if it is still valid, or ""
+// so the caller falls back to the server API key. Tokens are renewed by workers.AvatarTokenRefresher,
+// never on the request path.
+func usableAvatarToken(tokenSecret *string, tokenExpiry pgtype.Timestamptz) string {
+	if tokenSecret == nil || *tokenSecret == "" || !tokenExpiry.Valid {
+		return ""
 	}
+	if !tokenExpiry.Time.After(time.Now()) {
+		return ""
+	}
+	return *tokenSecret
+}
 
-	if needsRefresh {
-		exp := now.AddDate(1, 0, 0).Format("2006-01-02 15:04:05")
-		tok, err := ps.Appwrite.Tokens.CreateFileToken(ps.Appwrite.PersonalProfilePicBucketID, *fileID, ps.Appwrite.Tokens.WithCreateFileTokenExpire(exp))
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to create personal token: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-
-		tokTime, err := time.Parse(time.RFC3339, tok.Expire)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to parse expire time: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
+// sealField encrypts an optional nickname/bio value before it is written to Postgres.
+// ownerID is the user the value belongs to (the contact owner or requester for nicknames, the user for bios).
+func (ps *Service) sealField(value *string, purpose string, ownerID uuid.UUID) (*string, *model.ApiError) {
+	sealed, err := utils.EncryptOptionalField(value, ps.Appwrite.PersonalFieldKey, purpose, ownerID.String())
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to encrypt " + purpose, Type: "internal_server_error"}
+	}
+	return sealed, nil
+}
 
-		_, err = ps.Queries.UpdateAvatarTokens(ctx, postgresCode.UpdateAvatarTokensParams{
-			UserID:      ownerID,
-			TokenID:     &tok.Id,
-			TokenSecret: &tok.Secret,
-			TokenExpiry: pgtype.Timestamptz{Valid: true, Time: tokTime},
-		})
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to update avatar tokens: " + utils.GetPostgresError(err).Message,
-				Type:    "internal_server_error",
-			}
-		}
+// openField decrypts an optional nickname/bio value read from Postgres. See sealField for ownerID.
+func (ps *Service) openField(value *string, purpose string, ownerID uuid.UUID) (*string, *model.ApiError) {
+	opened, err := utils.DecryptOptionalField(value, ps.Appwrite.PersonalFieldKey, purpose, ownerID.String())
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt " + purpose, Type: "internal_server_error"}
+	}
+	return opened, nil
+}
 
-		finalAvatar = utils.BuildAvatarURI(&utils.AppwriteFileData{
-			FileId:     fileID,
-			FileToken:  &tok.Id,
-			FileSecret: &tok.Secret,
-		})
+// shouldExposeAvatar applies the avatar privacy circuit breaker documented in personal_contacts.sql:
+// global profile → global avatar → user profile → user avatar, each level short-circuiting.
+func shouldExposeAvatar(globalRestrictProfile, exceptionGlobalProfile, globalRestrictAvatar, exceptionGlobalAvatar, userRestrictProfile, userRestrictAvatar bool) bool {
+	if globalRestrictProfile {
+		return exceptionGlobalProfile
+	}
+	if globalRestrictAvatar {
+		return exceptionGlobalAvatar
+	}
+	if userRestrictProfile {
+		return false
 	}
+	if userRestrictAvatar {
+		return false
+	}
+	return true
+}
 
-	return finalAvatar, nil
+// shouldExposeProfile applies the profile-level part of the same circuit breaker. When it returns
+// false only identity fields (name, username) may be shown.
+func shouldExposeProfile(globalRestrictProfile, exceptionGlobalProfile, userRestrictProfile bool) bool {
+	if globalRestrictProfile {
+		return exceptionGlobalProfile
+	}
+	return !userRestrictProfile
 }
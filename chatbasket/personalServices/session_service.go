@@ -0,0 +1,77 @@
+package personalServices
+
+import (
+	"chatbasket/audit"
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/personalModel"
+	"chatbasket/utils"
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// ListSessions returns the user's signed-in devices, newest first as Appwrite lists them, with
+// the requesting session flagged.
+func (ps *Service) ListSessions(ctx context.Context, userId, currentSessionId string) (*personalmodel.SessionList, *model.ApiError) {
+	res, err := ps.Appwrite.Users.ListSessions(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "Failed to list sessions: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	sessions := make([]personalmodel.Session, 0, len(res.Sessions))
+	for _, s := range res.Sessions {
+		sessions = append(sessions, personalmodel.Session{
+			Id:            s.Id,
+			Current:       s.Id == currentSessionId,
+			ClientName:    s.ClientName,
+			ClientVersion: s.ClientVersion,
+			ClientType:    s.ClientType,
+			DeviceName:    s.DeviceName,
+			DeviceBrand:   s.DeviceBrand,
+			DeviceModel:   s.DeviceModel,
+			OsName:        s.OsName,
+			OsVersion:     s.OsVersion,
+			Ip:            s.Ip,
+			CountryName:   s.CountryName,
+			CreatedAt:     s.CreatedAt,
+			LastActiveAt:  s.UpdatedAt,
+			ExpiresAt:     s.Expire,
+		})
+	}
+	return &personalmodel.SessionList{Sessions: sessions}, nil
+}
+
+// RevokeSession signs the user out of one of their sessions and stops push notifications to
+// that device.
+func (ps *Service) RevokeSession(ctx context.Context, userId *model.UserId, sessionId string) (*model.StatusOkay, *model.ApiError) {
+	if apiErr := ps.revokeSessionTokens(ctx, userId.UuidUserId, sessionId); apiErr != nil {
+		return nil, apiErr
+	}
+
+	_, err := ps.Appwrite.Users.DeleteSession(userId.StringUserId, sessionId)
+	if err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return nil, &model.ApiError{Code: http.StatusNotFound, Message: "Session not found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "Failed to revoke session: " + err.Error(), Type: "internal_server_error"}
+	}
+	ps.RecordUserAudit(ctx, userId.UuidUserId, audit.ActionSessionRevoked, audit.TargetSession, utils.HashSessionID(sessionId), nil)
+
+	return &model.StatusOkay{Status: true, Message: "Session revoked"}, nil
+}
+
+// revokeSessionTokens deactivates the push tokens and revokes the refresh tokens of a
+// session. It runs before the session is deleted so a failure never leaves a signed-out device
+// receiving notifications or able to refresh.
+func (ps *Service) revokeSessionTokens(ctx context.Context, userId uuid.UUID, sessionId string) *model.ApiError {
+	_, err := ps.Queries.DeactivateSessionTokens(ctx, postgresCode.DeactivateSessionTokensParams{
+		UserID:             userId,
+		Sha256HexSessionID: utils.HashSessionID(sessionId),
+	})
+	if err != nil {
+		return &model.ApiError{Code: http.StatusInternalServerError, Message: "Failed to revoke push tokens: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return ps.RevokeRefreshTokens(ctx, userId, sessionId)
+}
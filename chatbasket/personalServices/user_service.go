@@ -0,0 +1,94 @@
+package personalServices
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	personalmodel "chatbasket/personalModel"
+	"chatbasket/utils"
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetUserProfileCard returns another user's profile card with profile type, blocks,
+// admin-block status and global/per-user restrictions applied for the viewer.
+func (ps *Service) GetUserProfileCard(ctx context.Context, targetUserId string, userId model.UserId) (*personalmodel.UserProfileCard, *model.ApiError) {
+	targetUUID, err := uuid.Parse(targetUserId)
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "invalid user id", Type: "bad_request"}
+	}
+	if targetUUID == userId.UuidUserId {
+		return nil, &model.ApiError{Code: http.StatusConflict, Message: "self_action_not_allowed", Type: "conflict"}
+	}
+
+	/*
+		DB call to get the profile card with raw restriction and relationship data
+	*/
+	card, err := ps.Queries.GetUserProfileCard(ctx, postgresCode.GetUserProfileCardParams{
+		ID:       targetUUID,
+		ViewerID: userId.UuidUserId,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, &model.ApiError{Code: http.StatusNotFound, Message: "user_not_found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	// A user who blocked the viewer, or who was blocked by an admin, is indistinguishable from a
+	// missing user
+	if card.BlockedYou || card.IsAdminBlocked {
+		return nil, &model.ApiError{Code: http.StatusNotFound, Message: "user_not_found", Type: "not_found"}
+	}
+	// Private profiles are only visible to people the user has added
+	if card.ProfileType == "private" && !card.AddedYou {
+		return nil, &model.ApiError{Code: http.StatusForbidden, Message: "user_private_profile", Type: "forbidden"}
+	}
+
+	username, err := utils.DecryptUsername(card.Username, ps.Appwrite.PersonalUsernameKey, card.ID.String())
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusInternalServerError, Message: "failed to decrypt username", Type: "internal_server_error"}
+	}
+
+	nickname, apiErr := ps.openField(card.MyNickname, utils.FieldPurposeNickname, userId.UuidUserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	res := &personalmodel.UserProfileCard{
+		Id:          card.ID.String(),
+		Username:    username,
+		Name:        card.Name,
+		Nickname:    nickname,
+		ProfileType: card.ProfileType,
+		Relationship: personalmodel.Relationship{
+			IsContact:       card.IsContact,
+			AddedYou:        card.AddedYou,
+			IsMutual:        card.IsContact && card.AddedYou,
+			RequestSent:     card.RequestSent,
+			RequestReceived: card.RequestReceived,
+			Blocked:         card.BlockedByYou,
+		},
+	}
+
+	// Users the viewer blocked only show identity fields
+	if card.BlockedByYou {
+		return res, nil
+	}
+
+	if shouldExposeProfile(card.GlobalRestrictProfile, card.ExceptionGlobalProfile, card.UserRestrictProfile) {
+		bio, apiErr := ps.openField(card.Bio, utils.FieldPurposeBio, card.ID)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		res.Bio = bio
+	}
+
+	if shouldExposeAvatar(card.GlobalRestrictProfile, card.ExceptionGlobalProfile, card.GlobalRestrictAvatar, card.ExceptionGlobalAvatar, card.UserRestrictProfile, card.UserRestrictAvatar) {
+		res.AvatarUrl = ps.buildAvatarURL(card.AvatarFileID, card.ID, userId.UuidUserId, utils.AvatarSizeCard)
+	}
+
+	return res, nil
+}
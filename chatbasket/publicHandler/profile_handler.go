@@ -3,8 +3,8 @@ package publicHandler
 import (
 	"chatbasket/model"
 	"chatbasket/publicServices"
+	"chatbasket/utils"
 	"net/http"
-	"strings"
 
 	"github.com/labstack/echo/v4"
 	// "github.com/go-playground/validator/v10"
@@ -160,51 +160,13 @@ func (h *ProfileHandler) GetProfile(c echo.Context) error {
 }
 
 func (h *ProfileHandler) UploadProfilePicture(c echo.Context) error {
-	err := c.Request().ParseMultipartForm(5 << 20) // 5MB
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, &model.ApiError{
-			Code:    http.StatusBadRequest,
-			Message: "Failed to parse multipart form: " + err.Error(),
-			Type:    "bad_request",
-		})
-	}
-
-	if c.Request().MultipartForm == nil {
-		return c.JSON(http.StatusBadRequest, &model.ApiError{
-			Code:    http.StatusBadRequest,
-			Message: "Multipart form is missing",
-			Type:    "bad_request",
-		})
-	}
-
-	fh, err := c.FormFile("avatar")
-	if err != nil {
-		availableFields := []string{}
-		if c.Request().MultipartForm != nil && c.Request().MultipartForm.File != nil {
-			for field := range c.Request().MultipartForm.File {
-				availableFields = append(availableFields, field)
-			}
-		}
-
-		message := "Avatar file not found in request: " + err.Error()
-		if len(availableFields) > 0 {
-			message += ". Available file fields: " + strings.Join(availableFields, ", ")
-		}
-
-		return c.JSON(http.StatusBadRequest, &model.ApiError{
-			Code:    http.StatusBadRequest,
-			Message: message,
-			Type:    "bad_request",
-		})
-	}
-
-	if fh.Size > 5<<20 {
-		return c.JSON(http.StatusBadRequest, &model.ApiError{
-			Code:    http.StatusBadRequest,
-			Message: "File size exceeds the 5MB limit",
-			Type:    "bad_request",
-		})
+	// The avatar part is streamed straight from the request body; the size cap is
+	// enforced by the route body limit and the service.
+	part, apiErr := utils.OpenMultipartFile(c.Request(), "avatar")
+	if apiErr != nil {
+		return c.JSON(apiErr.Code, apiErr)
 	}
+	defer part.Close()
 
 	userId, ok := c.Get("userId").(string)
 	if !ok {
@@ -214,7 +176,7 @@ func (h *ProfileHandler) UploadProfilePicture(c echo.Context) error {
 			Type:    "internal_server_error",
 		})
 	}
-	user, serviceErr := h.Service.UploadUserProfilePicture(c.Request().Context(), fh, userId)
+	user, serviceErr := h.Service.UploadUserProfilePicture(c.Request().Context(), part, userId)
 
 	if serviceErr != nil {
 		return c.JSON(serviceErr.Code, serviceErr)
@@ -0,0 +1,47 @@
+package publicServices
+
+import (
+	"chatbasket/model"
+	"chatbasket/services"
+	"chatbasket/utils"
+	"context"
+	"net/http"
+	"time"
+)
+
+// OpenAvatar re-checks that the viewer named in a signed avatar URL may still see the owner's
+// public avatar and opens it from storage. Hidden avatars are reported as not found.
+func (ps *Service) OpenAvatar(ctx context.Context, claims *utils.AvatarURLClaims, ifNoneMatch string) (*services.AvatarStream, *model.ApiError) {
+	notFound := &model.ApiError{Code: http.StatusNotFound, Message: "avatar_not_found", Type: "not_found"}
+
+	doc, err := ps.Appwrite.Database.GetDocument(
+		ps.Appwrite.DatabaseID,
+		ps.Appwrite.UsersCollectionID,
+		claims.OwnerID,
+	)
+	if err != nil {
+		return nil, notFound
+	}
+	var user model.User
+	if err := doc.Decode(&user); err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to parse user data: " + err.Error(), Type: "internal_server_error"}
+	}
+	if user.AvatarFileId == "" {
+		return nil, notFound
+	}
+
+	if claims.ViewerID != user.Id {
+		if model.IsUserBlockedByAdmin(user) || !model.CanViewUserProfile(user, claims.ViewerID, false) {
+			return nil, notFound
+		}
+	}
+
+	// avatarFileTokens = ["personal_token","personal_token_secret","token_expire"]; fall back to
+	// the API key when the stored token is missing or expired.
+	tokenSecret := ""
+	if len(user.AvatarFileTokens) >= 3 && user.AvatarFileTokens[2] > time.Now().UTC().Format("2006-01-02 15:04:05") {
+		tokenSecret = user.AvatarFileTokens[1]
+	}
+
+	return ps.OpenAvatarVariant(ctx, ps.Appwrite.ProfilePicBucketID, user.AvatarFileId, claims.Size, tokenSecret, user.UpdatedAt, ifNoneMatch)
+}
@@ -1,18 +1,34 @@
 package publicServices
 
 import (
+	"chatbasket/audit"
 	"chatbasket/model"
 	"chatbasket/services"
+	"chatbasket/utils"
 	"context"
-	"mime/multipart"
-	"time"
+	"io"
+	"slices"
 
 	"github.com/appwrite/sdk-for-go/query"
 )
 
 func (ps *Service) Logout(ctx context.Context, payload *model.LogoutPayload, userId, sessionId string) (*model.StatusOkay, *model.ApiError) {
+	uuidUserId, err := utils.StringToUUID(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
+	}
+	revokeSessionId := sessionId
+	if payload.AllSessions {
+		revokeSessionId = ""
+	}
+	if apiErr := ps.RevokeRefreshTokens(ctx, uuidUserId, revokeSessionId); apiErr != nil {
+		return nil, apiErr
+	}
 
 	if payload.AllSessions {
+		if apiErr := ps.RevokeApiKeys(ctx, uuidUserId); apiErr != nil {
+			return nil, apiErr
+		}
 		_, err := ps.Appwrite.Users.DeleteSessions(userId)
 		if err != nil {
 			return nil, &model.ApiError{
@@ -31,6 +47,7 @@ func (ps *Service) Logout(ctx context.Context, payload *model.LogoutPayload, use
 			}
 		}
 	}
+	ps.RecordLogoutAudit(ctx, uuidUserId, sessionId, payload.AllSessions)
 
 	return &model.StatusOkay{Status: true, Message: "Logged out successfully"}, nil
 }
@@ -205,47 +222,15 @@ func (ps *Service) GetProfile(ctx context.Context, userId string) (*model.Privat
 		FileTokens: finalResponse.AvatarFileTokens,
 	}
 
-
-	if len(finalResponse.AvatarFileTokens) >=3 {
-
-		if finalResponse.AvatarFileTokens[2]<time.Now().Format("2006-01-02 15:04:05") {
-			exp:=time.Now().AddDate(1, 0, 0).Format("2006-01-02 15:04:05")
-			tok,err := ps.Appwrite.Tokens.CreateFileToken(ps.Appwrite.ProfilePicBucketID, finalResponse.AvatarFileId, ps.Appwrite.Tokens.WithCreateFileTokenExpire(exp))
-			if err != nil {
-				return nil, &model.ApiError{
-					Code:    500,
-					Message: "Failed to create personal token: " + err.Error(),
-					Type:    "internal_server_error",
-				}
-			}
-			_,err = ps.Appwrite.Database.UpdateDocument(
-				ps.Appwrite.DatabaseID,
-				ps.Appwrite.UsersCollectionID,
-				userId,
-				ps.Appwrite.Database.WithUpdateDocumentData(model.UploadUserProfilePictureDbPayload{
-					AvatarFileTokens: []string{tok.Id,tok.Secret,exp},
-				}),
-			)
-			if err != nil {
-				return nil, &model.ApiError{
-					Code:    500,
-					Message: "Failed to update user data: " + err.Error(),
-					Type:    "internal_server_error",
-				}
-			}
-			avatarData.FileTokens = []string{tok.Id,tok.Secret,exp}
-
-		}
-	}
-
-
-	avatarUri := model.BuildAvatarURI(&avatarData, 3)
+	// Expired file tokens are not renewed here: the media proxy falls back to the
+	// server API key, so reading a profile never blocks on token creation.
+	avatarUri := ps.buildAvatarURL(avatarData.FileId, userId, userId, utils.AvatarSizeProfile)
 
 	return model.ToPrivateUser(&finalResponse, avatarUri), nil
 
 }
 
-func (ps *Service) UploadUserProfilePicture(ctx context.Context, fh *multipart.FileHeader, userId string) (*model.UploadUserProfilePictureResponse, *model.ApiError) {
+func (ps *Service) UploadUserProfilePicture(ctx context.Context, avatar io.Reader, userId string) (*model.UploadUserProfilePictureResponse, *model.ApiError) {
 	// Fetch user to determine if an existing avatar (same fileId) should be deleted
 	resUser, err := ps.Appwrite.Database.GetDocument(
 		ps.Appwrite.DatabaseID,
@@ -261,11 +246,22 @@ func (ps *Service) UploadUserProfilePicture(ctx context.Context, fh *multipart.F
 	}
 	deleteExisting := user.AvatarFileId == userId
 
-	result, apiErr := ps.UploadFileFromMultipart(
+	ownerId, err := utils.StringToUUID(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	result, apiErr := ps.UploadAvatarImage(
+		ctx,
 		ps.Appwrite.ProfilePicBucketID,
 		userId,
-		fh,
-		services.UploadOptions{DeleteExisting: deleteExisting, GenerateTokens: true},
+		avatar,
+		services.UploadOptions{
+			DeleteExisting: deleteExisting,
+			GenerateTokens: true,
+			OwnerID:        ownerId,
+			Kind:           services.StorageKindAvatar,
+		},
 	)
 	if apiErr != nil {
 		return nil, apiErr
@@ -289,7 +285,7 @@ func (ps *Service) UploadUserProfilePicture(ctx context.Context, fh *multipart.F
 	return &model.UploadUserProfilePictureResponse{
 		AvatarFileId:     result.FileId,
 		Name:             result.Name,
-		AvatarFileTokens: avatarTokens,
+		AvatarUri:        ps.buildAvatarURL(result.FileId, userId, userId, utils.AvatarSizeProfile),
 	}, nil
 }
 
@@ -324,39 +320,9 @@ func (ps *Service) RemoveUserProfilePicture(ctx context.Context, userId string)
 		}
 	}
 
-	// Delete the file access token
-	tok, err := ps.Appwrite.Tokens.List(ps.Appwrite.ProfilePicBucketID, user.AvatarFileId)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query token data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	if tok.Total > 0 {
-		for _, token := range tok.Tokens {
-			_, err := ps.Appwrite.Tokens.Delete(token.Id)
-			if err != nil {
-				return nil, &model.ApiError{
-					Code:    500,
-					Message: "Failed to delete token data: " + err.Error(),
-					Type:    "internal_server_error",
-				}
-			}
-		}
-	}
-
-	// Delete the file from storage
-	_, err = ps.Appwrite.Storage.DeleteFile(
-		ps.Appwrite.ProfilePicBucketID,
-		userId,
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to delete profile picture from storage: " + err.Error(),
-			Type:    "not_found",
-		}
+	// Delete the file access tokens, the file and its resized variants
+	if apiErr := ps.DeleteAvatarFiles(ctx, ps.Appwrite.ProfilePicBucketID, user.AvatarFileId); apiErr != nil {
+		return nil, apiErr
 	}
 
 	dataToUpdateInUserProfile := model.RemoveProfilePictureDbPayload{
@@ -428,7 +394,18 @@ func (ps *Service) UpdateUserProfile(ctx context.Context, payload *model.UpdateU
 		FileId:     updatedUser.AvatarFileId,
 		FileTokens: updatedUser.AvatarFileTokens,
 	}
-	avatarUri := model.BuildAvatarURI(&avatarData, 3)
+	avatarUri := ps.buildAvatarURL(avatarData.FileId, userId, userId, utils.AvatarSizeProfile)
+
+	if uuidUserId, err := utils.StringToUUID(userId); err == nil {
+		var fields []string
+		for field, value := range map[string]string{"username": payload.Username, "name": payload.Name, "bio": payload.Bio, "profile_visible_to": payload.ProfileVisibleTo, "avatar": payload.AvatarFileId} {
+			if value != "" {
+				fields = append(fields, field)
+			}
+		}
+		slices.Sort(fields)
+		ps.RecordUserAudit(ctx, uuidUserId, audit.ActionProfileUpdated, audit.TargetPublicProfile, userId, map[string]any{"fields": fields})
+	}
 
 	return model.ToPrivateUser(&updatedUser, avatarUri), nil
 }
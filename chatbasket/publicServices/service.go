@@ -2,6 +2,8 @@ package publicServices
 
 import (
 	"chatbasket/services"
+	"chatbasket/utils"
+	"time"
 )
 
 // Service wraps the shared GlobalService for public endpoints
@@ -14,3 +16,12 @@ type Service struct {
 func New(gs *services.GlobalService) *Service {
 	return &Service{GlobalService: gs}
 }
+
+// buildAvatarURL returns a signed media proxy URL for the size variant of ownerID's public avatar
+// as seen by viewerID, or "" when the user has no avatar.
+func (ps *Service) buildAvatarURL(fileID, ownerID, viewerID string, size int) string {
+	if fileID == "" {
+		return ""
+	}
+	return *utils.BuildSignedAvatarURL(ps.Appwrite.PublicApiBaseURL, ps.Appwrite.AvatarURLSigningKey, utils.AvatarScopePublic, ownerID, viewerID, size, time.Now())
+}
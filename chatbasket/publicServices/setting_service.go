@@ -1,15 +1,14 @@
 package publicServices
 
 import (
+	"chatbasket/audit"
 	"chatbasket/model"
+	"chatbasket/otp"
+	"chatbasket/services"
 	"chatbasket/utils"
 	"context"
-	"log"
-	"time"
 
-	"github.com/appwrite/sdk-for-go/id"
 	"github.com/appwrite/sdk-for-go/query"
-	"github.com/google/uuid"
 )
 
 func (ps *Service) UpdatePassword(ctx context.Context, payload *model.UpdatePassword, userId string) (*model.StatusOkay, *model.ApiError) {
@@ -25,14 +24,16 @@ func (ps *Service) UpdatePassword(ctx context.Context, payload *model.UpdatePass
 	// if !match {
 	// 	return nil, echo.NewHTTPError(401, "Old password does not match")
 	// }
-	newPass := "00" + payload.NewPassword
-	_, err := ps.Appwrite.Users.UpdatePassword(userId, newPass)
+	_, err := ps.Appwrite.Users.UpdatePassword(userId, services.AccountPassword(payload.NewPassword))
 	if err != nil {
 		return nil, &model.ApiError{
 			Code:    500,
 			Message: "Failed to update password: " + err.Error(),
 		}
 	}
+	if uuidUserId, err := utils.StringToUUID(userId); err == nil {
+		ps.RecordUserAudit(ctx, uuidUserId, audit.ActionPasswordChanged, audit.TargetUser, userId, nil)
+	}
 
 	return &model.StatusOkay{Status: true, Message: "Password updated successfully"}, nil
 }
@@ -91,7 +92,8 @@ func (ps *Service) UpdateEmail(ctx context.Context, payload *model.UpdateEmailPa
 			}
 		}
 	}
-	targetRes, err := ps.Appwrite.Users.CreateTarget(
+	// The target is removed again once the new address is verified
+	_, err = ps.Appwrite.Users.CreateTarget(
 		userId,
 		userId,
 		"email",
@@ -105,100 +107,12 @@ func (ps *Service) UpdateEmail(ctx context.Context, payload *model.UpdateEmailPa
 		}
 	}
 
-	messageId := id.Custom(uuid.NewString())
-	subject := "Otp for email verification"
-	otp, err := utils.GenerateOTP()
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to generate OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-
-	}
-	content := "<p>Hello,<br>Please enter this code in the app to verify your email address. This code is valid for 3 minutes.Your One-Time Password (OTP) for verifying your email address is:<br><h1>" + otp + "</h1></p><p>Thank you,<br>ChatBasket</p>"
-	
-	targetId := targetRes.Id
-	_, err = ps.Appwrite.Message.CreateEmail(
-		messageId,
-		subject,
-		content,
-		ps.Appwrite.Message.WithCreateEmailUsers([]string{userId}),
-		ps.Appwrite.Message.WithCreateEmailCc([]string{targetId}),
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to send email: " + err.Error(),
-			Type:    "internal_server_error",
-		}
+	// The code is bound to the new address, so verification can only switch to that email
+	if apiErr := ps.SendOtpEmail(ctx, userId, payload.Email, otp.PurposeEmailChange, ""); apiErr != nil {
+		return nil, apiErr
 	}
-
-	docOtp, err := ps.Appwrite.Database.ListDocuments(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		ps.Appwrite.Database.WithListDocumentsQueries(
-			[]string{
-				query.Equal("userId", userId),
-				query.Limit(1),
-			},
-		),
-	)
-
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-
-	}
-	if docOtp.Total == 1 {
-		_, err = ps.Appwrite.Database.DeleteDocument(
-			ps.Appwrite.DatabaseID,
-			ps.Appwrite.TempOtpCollectionID,
-			userId,
-		)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to delete existing otp: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-	}
-
-	hashedOtp, err := utils.HashOTP(otp)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to hash OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-
-	}
-
-	tempOtpPayload := model.TempOtpPayload{
-		Email:     payload.Email,
-		Otp:       hashedOtp,
-		UserId:    userId,
-		MessageId: messageId,
-	}
-
-	_, err = ps.Appwrite.Database.CreateDocument(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		userId,
-		tempOtpPayload,
-	)
-
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to save otp in database: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-
+	if uuidUserId, err := utils.StringToUUID(userId); err == nil {
+		ps.RecordUserAudit(ctx, uuidUserId, audit.ActionEmailChangeRequested, audit.TargetUser, userId, nil)
 	}
 
 	return &model.StatusOkay{Status: true, Message: "Otp sent to new email for verification"}, nil
@@ -206,70 +120,15 @@ func (ps *Service) UpdateEmail(ctx context.Context, payload *model.UpdateEmailPa
 
 func (ps *Service) UpdateEmailVerification(ctx context.Context, payload *model.UpdateEmailVerification, userId string) (*model.StatusOkay, *model.ApiError) {
 
-	// Retrieve the temporary OTP document from the database
-	doc, err := ps.Appwrite.Database.GetDocument(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		userId,
-	)
+	uuidUserId, err := utils.StringToUUID(userId)
 	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	var tempOtp model.TempOtp
-	if err := doc.Decode(&tempOtp); err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to parse otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
+		return nil, &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
 	}
 
-	if tempOtp.Email != payload.Email {
-		return nil, &model.ApiError{
-			Code:    401,
-			Message: "Email does not match with the sent OTP email",
-			Type:    "unauthorized",
-		}
-	}
-
-	match, err := utils.VerifyOTP(payload.Otp, tempOtp.Otp)
+	// The code only verifies the address it was sent to
+	challenge, err := ps.Otp.Verify(ctx, uuidUserId, otp.PurposeEmailChange, payload.Email, payload.Otp)
 	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to verify OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	if !match {
-		return nil, &model.ApiError{
-			Code:    401,
-			Message: "Invalid OTP",
-			Type:    "unauthorized",
-		}
-	}
-
-	// check if tempOtp has expired or not time limit is till 3 minutes after created at
-
-	createdAtTime, err := time.Parse(time.RFC3339, tempOtp.CreatedAt)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to parse OTP creation time: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	expired := utils.IsExpiredOTP(createdAtTime, 3)
-	if expired {
-		return nil, &model.ApiError{
-			Code:    401,
-			Message: "OTP has expired",
-			Type:    "unauthorized",
-		}
+		return nil, otp.ApiError(err)
 	}
 
 	_, err = ps.Appwrite.Users.DeleteTarget(userId, userId)
@@ -282,7 +141,7 @@ func (ps *Service) UpdateEmailVerification(ctx context.Context, payload *model.U
 	}
 
 	// Update user's email in Appwrite Auth
-	_, err = ps.Appwrite.Users.UpdateEmail(userId, tempOtp.Email)
+	_, err = ps.Appwrite.Users.UpdateEmail(userId, challenge.Target)
 	if err != nil {
 		return nil, &model.ApiError{
 			Code:    500,
@@ -301,157 +160,17 @@ func (ps *Service) UpdateEmailVerification(ctx context.Context, payload *model.U
 			Type:    "internal_server_error",
 		}
 	}
+	// The address is left out: audit events outlive the account
+	ps.RecordUserAudit(ctx, uuidUserId, audit.ActionEmailChanged, audit.TargetUser, userId, nil)
 
-	// // Update user's email in the user collection document
-	// _, err = ps.Appwrite.Database.UpdateDocument(
-	// 	ps.Appwrite.DatabaseID,
-	// 	ps.Appwrite.UsersCollectionID,
-	// 	userId,
-	// 	ps.Appwrite.Database.WithUpdateDocumentData(
-	// 		map[string]any{
-	// 			"email": tempOtp.Email,
-	// 		}),
-	// )
-	// if err != nil {
-	// 	return nil, &model.ApiError{
-	// 		Code:    500,
-	// 		Message: "Failed to update user email in database: " + err.Error(),
-	// 		Type:    "internal_server_error",
-	// 	}
-	// }
-
-	// Delete the temporary OTP document
-	_, err = ps.Appwrite.Database.DeleteDocument(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		userId,
-	)
-	if err != nil {
-		log.Printf("Failed to delete otp: %v", err.Error())
-	}
-
-	_, err = ps.Appwrite.Message.Delete(tempOtp.MessageId)
-	if err != nil {
-		log.Printf("Failed to delete message: %v", err.Error())
-	}
-
-	return &model.StatusOkay{Status: true, Message: tempOtp.Email}, nil
+	return &model.StatusOkay{Status: true, Message: challenge.Target}, nil
 }
 
 func (ps *Service) SendOtp(ctx context.Context, payload *model.SendOtpPayload, userId string,email string) (*model.StatusOkay, *model.ApiError) {
 
-	// Step1: Generate otp
-	messageId := id.Custom(uuid.NewString())
-	subject := "Otp for " + payload.Subject + " verification"
-	otp, err := utils.GenerateOTP()
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to generate OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	content := "<p>Hello,<br>Please enter this code in the app to verify your identity. This code is valid for 3 minutes.Your One-Time Password (OTP) for verifying your identity is:<br><h1>" + otp + "</h1></p><p>Thank you,<br>ChatBasket</p>"
-
-	emailTarget, err := ps.Appwrite.Users.ListTargets(userId)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to list targets: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	var emailT string
-	if emailTarget.Total == 0 {
-		targetId := uuid.New().String()
-		createTarget, err := ps.Appwrite.Users.CreateTarget(userId, targetId, "email",email)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to create target: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-		emailT = createTarget.Id
-	}
-	if emailTarget.Total > 0 {
-		emailT = emailTarget.Targets[0].Id
-	}
-
-
-	_, err = ps.Appwrite.Message.CreateEmail(
-		messageId,
-		subject,
-		content,
-		ps.Appwrite.Message.WithCreateEmailUsers([]string{userId}),
-		ps.Appwrite.Message.WithCreateEmailCc([]string{emailT}),
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to send email: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	doc, err := ps.Appwrite.Database.ListDocuments(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		ps.Appwrite.Database.WithListDocumentsQueries(
-			[]string{
-				query.Equal("userId", userId),
-				query.Limit(1),
-			},
-		),
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	if doc.Total == 1 {
-		_, err = ps.Appwrite.Database.DeleteDocument(
-			ps.Appwrite.DatabaseID,
-			ps.Appwrite.TempOtpCollectionID,
-			userId,
-		)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to delete existing otp: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-	}
-	hashedOtp, err := utils.HashOTP(otp)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to hash OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	tempOtpPayload := model.TempOtpPayload{
-		Email:     payload.Subject,
-		Otp:       hashedOtp,
-		UserId:    userId,
-		MessageId: messageId,
-	}
-
-	_, err = ps.Appwrite.Database.CreateDocument(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		userId,
-		tempOtpPayload,
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to save otp in database: " + err.Error(),
-			Type:    "internal_server_error",
-		}
+	// Step1: Generate otp and send it
+	if apiErr := ps.SendOtpEmail(ctx, userId, email, otp.PurposeIdentity, payload.Subject); apiErr != nil {
+		return nil, apiErr
 	}
 
 	return &model.StatusOkay{Status: true, Message: "OTP sent to email"}, nil
@@ -460,78 +179,12 @@ func (ps *Service) SendOtp(ctx context.Context, payload *model.SendOtpPayload, u
 
 func (ps *Service) VerifyOtp(ctx context.Context, payload *model.OtpVerificationPayload, userId string) (*model.StatusOkay, *model.ApiError) {
 	// Step 1: Verify OTP
-
-	// Retrieve the temporary OTP document from the database
-	doc, err := ps.Appwrite.Database.GetDocument(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		userId,
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	var tempOtp model.TempOtp
-	if err := doc.Decode(&tempOtp); err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to parse otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	match, err := utils.VerifyOTP(payload.Secret, tempOtp.Otp)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to verify OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	if !match {
-		return nil, &model.ApiError{
-			Code:    401,
-			Message: "Invalid OTP",
-			Type:    "unauthorized",
-		}
-	}
-
-	// check if tempOtp has expired or not time limit is till 3 minutes after created at
-
-	createdAtTime, err := time.Parse(time.RFC3339, tempOtp.CreatedAt)
+	uuidUserId, err := utils.StringToUUID(userId)
 	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to parse OTP creation time: " + err.Error(),
-			Type:    "internal_server_error",
-		}
+		return nil, &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
 	}
-
-	expired := utils.IsExpiredOTP(createdAtTime, 3)
-	if expired {
-		return nil, &model.ApiError{
-			Code:    401,
-			Message: "OTP has expired",
-			Type:    "unauthorized",
-		}
-	}
-
-	// delete message but even it fails continue dont return nil
-	_, err = ps.Appwrite.Message.Delete(tempOtp.MessageId)
-	if err != nil {
-		log.Printf("could not delete message: %v", err.Error())
-	}
-
-	_, err = ps.Appwrite.Database.DeleteDocument(
-		ps.Appwrite.DatabaseID,
-		ps.Appwrite.TempOtpCollectionID,
-		userId,
-	)
-	if err != nil {
-		log.Printf("Failed to delete otp: %v", err.Error())
+	if _, err := ps.Otp.Verify(ctx, uuidUserId, otp.PurposeIdentity, "", payload.Secret); err != nil {
+		return nil, otp.ApiError(err)
 	}
 	return &model.StatusOkay{Status: true, Message: "OTP verified successfully"}, nil
 }
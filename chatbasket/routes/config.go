@@ -1,7 +1,18 @@
 package routes
 
 import (
+	"chatbasket/appwriteinternal"
+	"chatbasket/mail"
+	"chatbasket/services"
+	"chatbasket/storage"
 	"chatbasket/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 type appwriteConfig struct {
@@ -24,6 +35,9 @@ type appwriteConfig struct {
 	PersonalDatabaseID              string
 	PersonalProfilePicBucketID      string
 	PersonalUsernameKey             []byte
+	PersonalFieldKey                []byte
+	AvatarURLSigningKey             []byte
+	PublicApiBaseURL                string
 }
 
 func loadAppwriteConfig() (*appwriteConfig, error) {
@@ -81,6 +95,15 @@ func loadAppwriteConfig() (*appwriteConfig, error) {
 	if c.PersonalUsernameKey, err = utils.LoadKeyFromEnvInByte("PERSONAL_USERNAME_KEY"); err != nil {
 		return nil, err
 	}
+	if c.PersonalFieldKey, err = utils.LoadKeyFromEnvInByte("PERSONAL_FIELD_KEY"); err != nil {
+		return nil, err
+	}
+	if c.AvatarURLSigningKey, err = utils.LoadKeyFromEnvInByte("AVATAR_URL_SIGNING_KEY"); err != nil {
+		return nil, err
+	}
+	if c.PublicApiBaseURL, err = utils.LoadKeyFromEnv("PUBLIC_API_BASE_URL"); err != nil {
+		return nil, err
+	}
 	if c.PersonalDatabaseID, err = utils.LoadKeyFromEnv("APPWRITE_PERSONAL_DATABASE_ID"); err != nil {
 		return nil, err
 	}
@@ -89,3 +112,164 @@ func loadAppwriteConfig() (*appwriteConfig, error) {
 	}
 	return &c, nil
 }
+
+type storageConfig struct {
+	Backend         string
+	LocalDir        string
+	LocalSigningKey []byte
+	TusUploadDir    string
+}
+
+// loadStorageConfig reads STORAGE_BACKEND ("appwrite" by default, or "local"). The local
+// backend also needs STORAGE_LOCAL_DIR and STORAGE_LOCAL_SIGNING_KEY. Partial resumable
+// uploads are kept in TUS_UPLOAD_DIR (default: a directory under the system temp dir).
+func loadStorageConfig() (*storageConfig, error) {
+	c := storageConfig{Backend: os.Getenv("STORAGE_BACKEND"), TusUploadDir: os.Getenv("TUS_UPLOAD_DIR")}
+	if c.Backend == "" {
+		c.Backend = storage.BackendAppwrite
+	}
+	if c.TusUploadDir == "" {
+		c.TusUploadDir = filepath.Join(os.TempDir(), "chatbasket_tus")
+	}
+
+	switch c.Backend {
+	case storage.BackendAppwrite:
+	case storage.BackendLocal:
+		var err error
+		if c.LocalDir, err = utils.LoadKeyFromEnv("STORAGE_LOCAL_DIR"); err != nil {
+			return nil, err
+		}
+		if c.LocalSigningKey, err = utils.LoadKeyFromEnvInByte("STORAGE_LOCAL_SIGNING_KEY"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND: %s", c.Backend)
+	}
+	return &c, nil
+}
+
+// newStorage builds the storage backend selected by cfg.
+func newStorage(cfg *storageConfig, as *appwriteinternal.AppwriteService) (storage.Storage, error) {
+	if cfg.Backend == storage.BackendLocal {
+		return storage.NewLocal(cfg.LocalDir, cfg.LocalSigningKey)
+	}
+	return storage.NewAppwrite(as), nil
+}
+
+// loadExportConfig reads EXPORTS_BUCKET_ID, the bucket that holds finished data exports until
+// they are downloaded, and EXPORT_LINK_SIGNING_KEY, which signs their download links.
+func loadExportConfig() (services.DataExportConfig, error) {
+	var c services.DataExportConfig
+	var err error
+	if c.BucketID, err = utils.LoadKeyFromEnv("EXPORTS_BUCKET_ID"); err != nil {
+		return c, err
+	}
+	if c.SigningKey, err = utils.LoadKeyFromEnvInByte("EXPORT_LINK_SIGNING_KEY"); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+type mailConfig struct {
+	Backend      string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+// loadMailConfig reads MAIL_BACKEND ("appwrite" by default, "smtp", "file" or "memory") and
+// MAIL_FROM, the sender used by every backend except Appwrite, which sends from the provider
+// configured in Appwrite Messaging. The SMTP backend needs SMTP_HOST and takes SMTP_PORT
+// (default 587), SMTP_USERNAME and SMTP_PASSWORD; the file backend writes to MAIL_FILE_DIR.
+func loadMailConfig() (*mailConfig, error) {
+	c := mailConfig{Backend: os.Getenv("MAIL_BACKEND"), From: os.Getenv("MAIL_FROM")}
+	if c.Backend == "" {
+		c.Backend = mail.BackendAppwrite
+	}
+	if c.From == "" {
+		c.From = "ChatBasket <no-reply@localhost>"
+	}
+
+	var err error
+	switch c.Backend {
+	case mail.BackendAppwrite, mail.BackendMemory:
+	case mail.BackendSMTP:
+		if c.SMTPHost, err = utils.LoadKeyFromEnv("SMTP_HOST"); err != nil {
+			return nil, err
+		}
+		c.SMTPPort = 587
+		if port := os.Getenv("SMTP_PORT"); port != "" {
+			if c.SMTPPort, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+			}
+		}
+		c.SMTPUsername = os.Getenv("SMTP_USERNAME")
+		c.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	case mail.BackendFile:
+		if c.FileDir, err = utils.LoadKeyFromEnv("MAIL_FILE_DIR"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown MAIL_BACKEND: %s", c.Backend)
+	}
+	return &c, nil
+}
+
+// newMailer builds the mail backend selected by cfg.
+func newMailer(cfg *mailConfig, as *appwriteinternal.AppwriteService) (mail.Mailer, error) {
+	switch cfg.Backend {
+	case mail.BackendSMTP:
+		return mail.NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case mail.BackendFile:
+		return mail.NewFile(cfg.FileDir, cfg.From)
+	case mail.BackendMemory:
+		return mail.NewMemory(), nil
+	}
+	return mail.NewAppwrite(as), nil
+}
+
+// loadAuthConfig reads MAGIC_LINK_SIGNING_KEY, which signs magic login links, and the optional
+// MAGIC_LINK_REDIRECT_URL, the web page a browser lands on after opening one.
+// ACCESS_TOKEN_SIGNING_KEY signs the access tokens of native clients; the session middleware
+// verifies them with the same key. Passkeys use WEBAUTHN_RP_ID (the site's domain, e.g.
+// "chatbasket.me"), WEBAUTHN_RP_ORIGINS (comma separated; native apps add their
+// android:apk-key-hash: or app origin) and the optional WEBAUTHN_RP_NAME.
+func loadAuthConfig() (services.AuthConfig, error) {
+	c := services.AuthConfig{MagicLinkRedirectURL: os.Getenv("MAGIC_LINK_REDIRECT_URL")}
+	var err error
+	if c.MagicLinkSigningKey, err = utils.LoadKeyFromEnvInByte("MAGIC_LINK_SIGNING_KEY"); err != nil {
+		return c, err
+	}
+	if c.AccessTokenSigningKey, err = utils.LoadKeyFromEnvInByte("ACCESS_TOKEN_SIGNING_KEY"); err != nil {
+		return c, err
+	}
+	if err = utils.CheckAccessTokenKey(c.AccessTokenSigningKey); err != nil {
+		return c, err
+	}
+
+	rpId, err := utils.LoadKeyFromEnv("WEBAUTHN_RP_ID")
+	if err != nil {
+		return c, err
+	}
+	origins, err := utils.LoadKeyFromEnv("WEBAUTHN_RP_ORIGINS")
+	if err != nil {
+		return c, err
+	}
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "ChatBasket"
+	}
+	var rpOrigins []string
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			rpOrigins = append(rpOrigins, origin)
+		}
+	}
+	if c.WebAuthn, err = webauthn.New(&webauthn.Config{RPID: rpId, RPDisplayName: rpName, RPOrigins: rpOrigins}); err != nil {
+		return c, fmt.Errorf("webauthn: %w", err)
+	}
+	return c, nil
+}
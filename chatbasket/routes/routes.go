@@ -4,17 +4,39 @@ import (
 	"chatbasket/appwriteinternal"
 	"chatbasket/handler"
 	"chatbasket/middleware"
+	"chatbasket/model"
 	"chatbasket/personalHandler"
 	"chatbasket/personalServices"
 	"chatbasket/publicHandler"
 	"chatbasket/publicServices"
 	"chatbasket/services"
+	"chatbasket/tus"
+	"chatbasket/workers"
+	"context"
+	"expvar"
+	"log"
+	"os"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 )
 
+// Request body limits. Uploads get a little more than the file cap for multipart overhead;
+// everything else is JSON.
+const (
+	defaultBodyLimit = "1M"
+	avatarBodyLimit  = "6M"
+	tusChunkLimit    = "6M"
+)
+
+// tusUploadTTL is how long an idle resumable upload is kept.
+const tusUploadTTL = 24 * time.Hour
+
+// RegisterRoutes wires services, handlers and routes. Background workers are started
+// with ctx and stop when it is cancelled.
 func RegisterRoutes(
+	ctx context.Context,
 	e *echo.Echo,
 	pool *pgxpool.Pool,
 	// add more services as needed...
@@ -45,9 +67,68 @@ func RegisterRoutes(
 		cfg.PersonalDatabaseID,
 		cfg.PersonalProfilePicBucketID,
 		cfg.PersonalUsernameKey,
+		cfg.PersonalFieldKey,
+		cfg.AvatarURLSigningKey,
+		cfg.PublicApiBaseURL,
 	)
 
-	globalService := services.NewGlobalService(as, pool)
+	storageCfg, err := loadStorageConfig()
+	if err != nil {
+		e.Logger.Fatal("failed to load storage config: " + err.Error())
+	}
+	store, err := newStorage(storageCfg, as)
+	if err != nil {
+		e.Logger.Fatal("failed to init storage: " + err.Error())
+	}
+
+	exportCfg, err := loadExportConfig()
+	if err != nil {
+		e.Logger.Fatal("failed to load export config: " + err.Error())
+	}
+
+	mailCfg, err := loadMailConfig()
+	if err != nil {
+		e.Logger.Fatal("failed to load mail config: " + err.Error())
+	}
+	mailer, err := newMailer(mailCfg, as)
+	if err != nil {
+		e.Logger.Fatal("failed to init mailer: " + err.Error())
+	}
+
+	authCfg, err := loadAuthConfig()
+	if err != nil {
+		e.Logger.Fatal("failed to load auth config: " + err.Error())
+	}
+
+	globalService := services.NewGlobalService(as, pool, store, exportCfg, mailer, authCfg)
+
+	e.Use(middleware.RouteBodyLimit(defaultBodyLimit, map[string]string{
+		"/public/profile/upload-avatar":   avatarBodyLimit,
+		"/personal/profile/upload-avatar": avatarBodyLimit,
+		"/uploads/:id":                    tusChunkLimit,
+	}))
+	e.Use(middleware.AcceptLanguage())
+	e.Use(middleware.AuditRequest())
+
+	// Clear upload temp files left behind by earlier versions that staged uploads on disk
+	if n := workers.CleanStaleUploadTempFiles(os.TempDir(), time.Hour); n > 0 {
+		log.Printf("temp janitor: removed %d stale upload files", n)
+	}
+
+	tusStore, err := tus.NewStore(storageCfg.TusUploadDir, tusUploadTTL)
+	if err != nil {
+		e.Logger.Fatal("failed to init tus upload store: " + err.Error())
+	}
+
+	// Background workers
+	go workers.NewAvatarTokenRefresher(globalService).Run(ctx)
+	go workers.NewTusUploadSweeper(tusStore).Run(ctx)
+	go workers.NewAccountDeletionWorker(globalService).Run(ctx)
+	go workers.NewDataExportWorker(globalService).Run(ctx)
+	go workers.NewOtpChallengeSweeper(globalService.Otp).Run(ctx)
+	go workers.NewWebauthnChallengeSweeper(globalService).Run(ctx)
+	go workers.NewRefreshTokenSweeper(globalService).Run(ctx)
+
 	userHandler := handler.NewUserHandler(globalService)
 	// public services wrapper (shared between profile and settings)
 	pubSvc := publicServices.New(globalService)
@@ -57,9 +138,21 @@ func RegisterRoutes(
 	authGroup.POST("/signup-verification", userHandler.AcountVerification)
 	authGroup.POST("/login", userHandler.Login)
 	authGroup.POST("/login-verification", userHandler.LoginVerification)
+	authGroup.POST("/forgot-password", userHandler.ForgotPassword)
+	authGroup.POST("/reset-password", userHandler.ResetPassword)
+	authGroup.GET("/magic-link", userHandler.MagicLink)
+	authGroup.POST("/magic-link", userHandler.MagicLinkVerification)
+	authGroup.POST("/refresh", userHandler.Refresh)
+	passkeyHandler := handler.NewPasskeyHandler(globalService)
+	authGroup.POST("/passkeys/login/begin", passkeyHandler.BeginLogin)
+	authGroup.POST("/passkeys/login/finish", passkeyHandler.FinishLogin)
+	authGroup.POST("/passkeys/register/begin", passkeyHandler.BeginRegistration, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	authGroup.POST("/passkeys/register/finish", passkeyHandler.FinishRegistration, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	authGroup.GET("/passkeys", passkeyHandler.List, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	authGroup.DELETE("/passkeys/:id", passkeyHandler.Delete, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
 
 	publicProfileGroup := e.Group("/public/profile")
-	publicProfileGroup.Use(middleware.AppwriteSessionMiddleware(true))
+	publicProfileGroup.Use(middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
 	publicProfileHandler := publicHandler.NewProfileHandler(pubSvc)
 	publicProfileGroup.POST("/logout", publicProfileHandler.Logout)
 	publicProfileGroup.POST("/check-username", publicProfileHandler.CheckIfUserNameAvailable)
@@ -70,7 +163,7 @@ func RegisterRoutes(
 	publicProfileGroup.POST("/update-profile", publicProfileHandler.UpdateProfile)
 
 	publicSettingGroup := e.Group("/public/settings")
-	publicSettingGroup.Use(middleware.AppwriteSessionMiddleware(true))
+	publicSettingGroup.Use(middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
 	publicSettingHandler := publicHandler.NewSettingHandler(pubSvc)
 	publicSettingGroup.POST("/update-email", publicSettingHandler.UpdateEmail)
 	publicSettingGroup.POST("/update-password", publicSettingHandler.UpdatePassword)
@@ -80,26 +173,103 @@ func RegisterRoutes(
 
 	personalProfileGroup := e.Group("/personal/profile")
 	perSvc := personalServices.New(globalService)
-	personalProfileGroup.Use(middleware.AppwriteSessionMiddleware(true))
 	personalProfileHandler := personalHandler.NewProfileHandler(perSvc)
-	personalProfileGroup.GET("/get-profile", personalProfileHandler.GetProfile)
-	personalProfileGroup.POST("/create-profile", personalProfileHandler.CreateUserProfile)
-	personalProfileGroup.POST("/logout", personalProfileHandler.Logout)
-	personalProfileGroup.POST("/upload-avatar", personalProfileHandler.UploadProfilePicture)
-	personalProfileGroup.DELETE("/remove-avatar", personalProfileHandler.RemoveProfilePicture)
-	personalProfileGroup.POST("/update-profile", personalProfileHandler.UpdateProfile)
+	personalProfileGroup.GET("/get-profile", personalProfileHandler.GetProfile, middleware.ApiKeyOrSessionMiddleware(globalService, authCfg.AccessTokenSigningKey, model.ApiKeyScopeProfileRead))
+	personalProfileGroup.POST("/create-profile", personalProfileHandler.CreateUserProfile, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	personalProfileGroup.POST("/logout", personalProfileHandler.Logout, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	personalProfileGroup.POST("/upload-avatar", personalProfileHandler.UploadProfilePicture, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	personalProfileGroup.DELETE("/remove-avatar", personalProfileHandler.RemoveProfilePicture, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	personalProfileGroup.POST("/update-profile", personalProfileHandler.UpdateProfile, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
 
+	// Contacts and profile cards also accept API keys with the matching scope
+	contactsRead := middleware.ApiKeyOrSessionMiddleware(globalService, authCfg.AccessTokenSigningKey, model.ApiKeyScopeContactsRead)
+	contactsWrite := middleware.ApiKeyOrSessionMiddleware(globalService, authCfg.AccessTokenSigningKey, model.ApiKeyScopeContactsWrite)
 	personalContactsGroup := e.Group("/personal/contacts")
-	personalContactsGroup.Use(middleware.AppwriteSessionMiddleware(true))
 	persContactsHandler := personalHandler.NewContactHandler(perSvc)
-	personalContactsGroup.GET("/get", persContactsHandler.GetContacts)
-	personalContactsGroup.POST("/check-existence", persContactsHandler.CheckContactExistance)
-	personalContactsGroup.POST("/create", persContactsHandler.CreateContact)
-	personalContactsGroup.POST("/delete", persContactsHandler.DeleteContact)
-	personalContactsGroup.GET("/requests/get", persContactsHandler.GetContactRequests)
-	personalContactsGroup.POST("/requests/accept", persContactsHandler.AcceptContactRequest)
-	personalContactsGroup.POST("/requests/reject", persContactsHandler.RejectContactRequest)
-	personalContactsGroup.POST("/requests/undo", persContactsHandler.UndoContactRequest)
-	personalContactsGroup.POST("/update-nickname", persContactsHandler.UpdateContactNickname)
-	personalContactsGroup.POST("/remove-nickname", persContactsHandler.RemoveContactNickname)
+	personalContactsGroup.GET("/get", persContactsHandler.GetContacts, contactsRead)
+	personalContactsGroup.POST("/check-existence", persContactsHandler.CheckContactExistance, contactsRead)
+	personalContactsGroup.POST("/create", persContactsHandler.CreateContact, contactsWrite)
+	personalContactsGroup.POST("/delete", persContactsHandler.DeleteContact, contactsWrite)
+	personalContactsGroup.GET("/requests/get", persContactsHandler.GetContactRequests, contactsRead)
+	personalContactsGroup.POST("/requests/accept", persContactsHandler.AcceptContactRequest, contactsWrite)
+	personalContactsGroup.POST("/requests/reject", persContactsHandler.RejectContactRequest, contactsWrite)
+	personalContactsGroup.POST("/requests/undo", persContactsHandler.UndoContactRequest, contactsWrite)
+	personalContactsGroup.POST("/update-nickname", persContactsHandler.UpdateContactNickname, contactsWrite)
+	personalContactsGroup.POST("/remove-nickname", persContactsHandler.RemoveContactNickname, contactsWrite)
+
+	personalUsersGroup := e.Group("/personal/users")
+	personalUsersGroup.Use(middleware.ApiKeyOrSessionMiddleware(globalService, authCfg.AccessTokenSigningKey, model.ApiKeyScopeProfileRead))
+	persUsersHandler := personalHandler.NewUserHandler(perSvc)
+	personalUsersGroup.GET("/:id", persUsersHandler.GetUserProfileCard)
+
+	personalSessionsGroup := e.Group("/personal/sessions")
+	personalSessionsGroup.Use(middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	persSessionsHandler := personalHandler.NewSessionHandler(perSvc)
+	personalSessionsGroup.GET("", persSessionsHandler.ListSessions)
+	personalSessionsGroup.DELETE("/:id", persSessionsHandler.RevokeSession)
+
+	personalReportsGroup := e.Group("/personal/reports")
+	personalReportsGroup.Use(middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	persReportsHandler := personalHandler.NewReportHandler(perSvc)
+	personalReportsGroup.POST("", persReportsHandler.FileReport)
+	personalReportsGroup.GET("", persReportsHandler.ListReports)
+
+	// Resumable uploads (tus 1.0)
+	uploadsGroup := e.Group("/uploads")
+	tusHandler := handler.NewTusHandler(tusStore, perSvc, pubSvc, "/uploads")
+	uploadsGroup.Use(tusHandler.Middleware)
+	uploadsGroup.OPTIONS("", tusHandler.Options)
+	uploadsGroup.POST("", tusHandler.Create, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	uploadsGroup.HEAD("/:id", tusHandler.Head, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	uploadsGroup.PATCH("/:id", tusHandler.Patch, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	uploadsGroup.DELETE("/:id", tusHandler.Delete, middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+
+	accountGroup := e.Group("/account")
+	accountGroup.Use(middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService))
+	accountHandler := handler.NewAccountHandler(globalService)
+	accountGroup.POST("/delete/send-otp", accountHandler.RequestDeletion)
+	accountGroup.POST("/delete/confirm", accountHandler.ConfirmDeletion)
+	accountGroup.PUT("/locale", accountHandler.UpdateLocale)
+	exportHandler := handler.NewExportHandler(globalService)
+	accountGroup.POST("/export", exportHandler.RequestExport)
+	accountGroup.GET("/export", exportHandler.GetExport)
+	totpHandler := handler.NewTotpHandler(globalService)
+	accountGroup.POST("/2fa/totp/enroll", totpHandler.Enroll)
+	accountGroup.POST("/2fa/totp/activate", totpHandler.Activate)
+	accountGroup.POST("/2fa/totp/disable", totpHandler.Disable)
+	apiKeyHandler := handler.NewApiKeyHandler(globalService)
+	accountGroup.POST("/api-keys", apiKeyHandler.Create)
+	accountGroup.GET("/api-keys", apiKeyHandler.List)
+	accountGroup.DELETE("/api-keys/:id", apiKeyHandler.Delete)
+
+	storageGroup := e.Group("/storage")
+	storageGroup.Use(middleware.ApiKeyOrSessionMiddleware(globalService, authCfg.AccessTokenSigningKey, model.ApiKeyScopeStorageRead))
+	storageHandler := handler.NewStorageHandler(globalService)
+	storageGroup.GET("/usage", storageHandler.GetUsage)
+
+	// Admin API: a session of a user carrying the admin label in Appwrite
+	adminGroup := e.Group("/admin")
+	adminGroup.Use(middleware.AppwriteSessionMiddleware(true, authCfg.AccessTokenSigningKey, globalService), middleware.AdminMiddleware(as, services.AdminLabel))
+	adminHandler := handler.NewAdminHandler(globalService)
+	adminGroup.GET("/users/search", adminHandler.SearchUsers)
+	adminGroup.GET("/users/recent", adminHandler.RecentUsers)
+	adminGroup.POST("/users/:id/block", adminHandler.BlockUser)
+	adminGroup.POST("/users/:id/unblock", adminHandler.UnblockUser)
+	adminGroup.GET("/users/:id/contact-requests", adminHandler.ContactRequestVolume)
+	adminGroup.GET("/audit-events", adminHandler.AuditEvents)
+	// expvar metrics (cmdline, memstats, worker counters such as avatar_token_refresher)
+	adminGroup.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+	adminGroup.GET("/reports", adminHandler.Reports)
+	adminGroup.GET("/reports/:id", adminHandler.Report)
+	adminGroup.POST("/reports/:id/review", adminHandler.StartReportReview)
+	adminGroup.POST("/reports/:id/resolve", adminHandler.ResolveReport)
+	adminGroup.POST("/reports/:id/escalate", adminHandler.EscalateReport)
+
+	// Media proxy: authorized by the signed `t` query parameter, not a session
+	mediaGroup := e.Group("/media")
+	mediaHandler := handler.NewMediaHandler(perSvc, pubSvc, as.AvatarURLSigningKey)
+	mediaGroup.GET("/avatars/:id", mediaHandler.GetAvatar)
+
+	// Data export downloads: authorized by the signed `t` query parameter, not a session
+	e.GET("/exports/:id/download", exportHandler.Download)
 }
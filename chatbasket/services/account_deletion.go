@@ -0,0 +1,235 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/otp"
+	"chatbasket/storage"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/appwrite/sdk-for-go/query"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Account deletion steps, in the order they run. Every step is idempotent so a job that
+// failed part way can be retried from its current step.
+const (
+	AccountDeletionStepSessions        = "sessions"
+	AccountDeletionStepStorage         = "storage"
+	AccountDeletionStepAloneUsername   = "alone_username"
+	AccountDeletionStepPersonalUser    = "personal_user"
+	AccountDeletionStepSecondFactor    = "second_factor"
+	AccountDeletionStepPublicDocuments = "public_documents"
+	AccountDeletionStepTargets         = "targets"
+	AccountDeletionStepAppwriteUser    = "appwrite_user"
+)
+
+// AccountDeletionSteps lists the steps of an account deletion job. The Appwrite user goes last:
+// until then its id still ties together everything that is left.
+var AccountDeletionSteps = []string{
+	AccountDeletionStepSessions,
+	AccountDeletionStepStorage,
+	AccountDeletionStepAloneUsername,
+	AccountDeletionStepPersonalUser,
+	AccountDeletionStepSecondFactor,
+	AccountDeletionStepPublicDocuments,
+	AccountDeletionStepTargets,
+	AccountDeletionStepAppwriteUser,
+}
+
+// RequestAccountDeletion emails the OTP that confirms an account deletion.
+func (gs *GlobalService) RequestAccountDeletion(ctx context.Context, userId, email string) (*model.StatusOkay, *model.ApiError) {
+	if apiErr := gs.SendOtpEmail(ctx, userId, email, otp.PurposeAccountDeletion, ""); apiErr != nil {
+		return nil, apiErr
+	}
+	return &model.StatusOkay{Status: true, Message: "OTP sent to email"}, nil
+}
+
+// ConfirmAccountDeletion verifies the OTP and schedules the deletion job. The job runs in the
+// background (see workers.AccountDeletionWorker) and is retried until every step succeeds.
+func (gs *GlobalService) ConfirmAccountDeletion(ctx context.Context, payload *model.OtpVerificationPayload, userId model.UserId) (*model.StatusOkay, *model.ApiError) {
+	if apiErr := gs.verifyOtp(ctx, userId.StringUserId, otp.PurposeAccountDeletion, payload.Secret); apiErr != nil {
+		return nil, apiErr
+	}
+
+	_, err := gs.Queries.EnqueueAccountDeletion(ctx, postgresCode.EnqueueAccountDeletionParams{
+		UserID:    userId.UuidUserId,
+		FirstStep: AccountDeletionSteps[0],
+	})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to schedule account deletion: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	return &model.StatusOkay{Status: true, Message: "Account deletion scheduled"}, nil
+}
+
+// RunAccountDeletionStep performs one step of an account deletion job.
+func (gs *GlobalService) RunAccountDeletionStep(ctx context.Context, userId uuid.UUID, step string) error {
+	switch step {
+	case AccountDeletionStepSessions:
+		_, err := gs.Appwrite.Users.DeleteSessions(userId.String())
+		if err != nil && !utils.IsAppwriteNotFound(err) {
+			return err
+		}
+		if err := gs.Queries.DeleteUserRefreshTokens(ctx, userId); err != nil {
+			return err
+		}
+		return gs.Queries.DeleteUserApiKeys(ctx, userId)
+	case AccountDeletionStepStorage:
+		return gs.deleteAccountStorage(ctx, userId)
+	case AccountDeletionStepAloneUsername:
+		return gs.deleteAccountAloneUsername(ctx, userId)
+	case AccountDeletionStepPersonalUser:
+		if _, err := gs.Queries.DeleteUser(ctx, userId); err != nil {
+			return err
+		}
+		return gs.Queries.DeleteUserPreferences(ctx, userId)
+	case AccountDeletionStepSecondFactor:
+		// Login security state: the TOTP factor, passkeys, pending codes and OTP limits
+		if err := gs.Queries.DeleteTotpFactor(ctx, userId); err != nil {
+			return err
+		}
+		if err := gs.Queries.DeleteUserPasskeyCredentials(ctx, userId); err != nil {
+			return err
+		}
+		if err := gs.Queries.DeleteUserWebauthnChallenges(ctx, pgtype.UUID{Bytes: userId, Valid: true}); err != nil {
+			return err
+		}
+		return gs.Otp.DeleteUser(ctx, userId)
+	case AccountDeletionStepPublicDocuments:
+		return gs.deleteAccountDocuments(userId.String())
+	case AccountDeletionStepTargets:
+		return gs.deleteAccountTargets(userId.String())
+	case AccountDeletionStepAppwriteUser:
+		_, err := gs.Appwrite.Users.Delete(userId.String())
+		if err != nil && !utils.IsAppwriteNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown account deletion step %q", step)
+}
+
+// deleteAccountStorage removes both avatars with their file tokens and any data export archives,
+// then the user's quota records.
+func (gs *GlobalService) deleteAccountStorage(ctx context.Context, userId uuid.UUID) error {
+	for _, bucketId := range []string{gs.Appwrite.PersonalProfilePicBucketID, gs.Appwrite.ProfilePicBucketID} {
+		if apiErr := gs.DeleteAvatarFiles(ctx, bucketId, userId.String()); apiErr != nil {
+			return errors.New(apiErr.Message)
+		}
+	}
+	// Data export archives; their rows go with the users row
+	exportFiles, err := gs.Queries.ListUserDataExportFiles(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, fileId := range exportFiles {
+		if err := gs.Storage.Delete(ctx, gs.Exports.BucketID, fileId); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return gs.Queries.DeleteStorageOwner(ctx, userId)
+}
+
+// deleteAccountAloneUsername frees the user's plaintext username. It has to run before the users
+// row is deleted: alone_username is not linked to the user and is found by decrypting the
+// username stored on the row.
+func (gs *GlobalService) deleteAccountAloneUsername(ctx context.Context, userId uuid.UUID) error {
+	user, err := gs.Queries.GetUserCoreProfile(ctx, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil // no personal profile
+		}
+		return err
+	}
+	username, err := utils.DecryptUsername(user.B64CipherChacha20poly1305Username, gs.Appwrite.PersonalUsernameKey, userId.String())
+	if err != nil {
+		return fmt.Errorf("decrypt username: %w", err)
+	}
+	_, err = gs.Queries.DeleteAloneUsername(ctx, username)
+	return err
+}
+
+// deleteAccountDocuments removes the public-mode documents the user owns or is part of.
+func (gs *GlobalService) deleteAccountDocuments(userId string) error {
+	as := gs.Appwrite
+	refs := []struct {
+		collectionId string
+		attribute    string
+	}{
+		{as.PostsCollectionID, "userId"},
+		{as.CommentsCollectionID, "userId"},
+		{as.LikesCollectionID, "userId"},
+		{as.FollowCollectionID, "followerId"},
+		{as.FollowCollectionID, "followingId"},
+		{as.FollowRequestsCollectionID, "requesterId"},
+		{as.FollowRequestsCollectionID, "targetId"},
+		{as.BlockCollectionID, "blockerId"},
+		{as.BlockCollectionID, "blockedId"},
+		{as.RefreshTokensCollectionID, "userId"},
+	}
+	for _, ref := range refs {
+		if err := gs.deleteDocumentsWhere(ref.collectionId, ref.attribute, userId); err != nil {
+			return fmt.Errorf("collection %s: %w", ref.collectionId, err)
+		}
+	}
+
+	// Documents keyed by the user id
+	for _, collectionId := range []string{as.TempOtpCollectionID, as.UsersCollectionID} {
+		_, err := as.Database.DeleteDocument(as.DatabaseID, collectionId, userId)
+		if err != nil && !utils.IsAppwriteNotFound(err) {
+			return fmt.Errorf("collection %s: %w", collectionId, err)
+		}
+	}
+	return nil
+}
+
+// deleteDocumentsWhere deletes every document of a collection whose attribute equals value.
+func (gs *GlobalService) deleteDocumentsWhere(collectionId, attribute, value string) error {
+	as := gs.Appwrite
+	for {
+		res, err := as.Database.ListDocuments(
+			as.DatabaseID,
+			collectionId,
+			as.Database.WithListDocumentsQueries([]string{
+				query.Equal(attribute, value),
+				query.Limit(100),
+			}),
+		)
+		if err != nil {
+			return err
+		}
+		if len(res.Documents) == 0 {
+			return nil
+		}
+		for _, doc := range res.Documents {
+			_, err := as.Database.DeleteDocument(as.DatabaseID, collectionId, doc.Id)
+			if err != nil && !utils.IsAppwriteNotFound(err) {
+				return err
+			}
+		}
+	}
+}
+
+// deleteAccountTargets removes the user's messaging targets (e.g. the email used for OTPs).
+func (gs *GlobalService) deleteAccountTargets(userId string) error {
+	targets, err := gs.Appwrite.Users.ListTargets(userId)
+	if err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, target := range targets.Targets {
+		_, err := gs.Appwrite.Users.DeleteTarget(userId, target.Id)
+		if err != nil && !utils.IsAppwriteNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"chatbasket/audit"
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/appwrite/sdk-for-go/query"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AdminLabel is the Appwrite user label that grants access to the admin API.
+const AdminLabel = "admin"
+
+const (
+	adminSearchLimit      = 50
+	defaultAdminPageLimit = 50
+	maxAdminPageLimit     = 200
+	maxBlockReasonLength  = 500
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// toAdminUser decrypts the username; a row that cannot be decrypted is still listed.
+func (gs *GlobalService) toAdminUser(u postgresCode.User) model.AdminUser {
+	username, err := utils.DecryptUsername(u.B64CipherChacha20poly1305Username, gs.Appwrite.PersonalUsernameKey, u.ID.String())
+	if err != nil {
+		log.Printf("admin: failed to decrypt username of %s: %v", u.ID, err)
+	}
+	return model.AdminUser{
+		Id:               u.ID.String(),
+		Name:             u.Name,
+		Username:         username,
+		ProfileType:      u.ProfileType,
+		IsAdminBlocked:   u.IsAdminBlocked,
+		AdminBlockReason: u.AdminBlockReason,
+		CreatedAt:        u.CreatedAt.Time.UTC().Format(time.RFC3339),
+	}
+}
+
+// AdminSearchUsers finds personal-mode users by id, email or exact username, and otherwise by a
+// part of their display name.
+func (gs *GlobalService) AdminSearchUsers(ctx context.Context, q string) (*model.AdminUserList, *model.ApiError) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, &model.ApiError{Code: 400, Message: "Missing search query", Type: "missing_value"}
+	}
+
+	users := []model.AdminUser{}
+	add := func(u postgresCode.User, email string) {
+		for _, seen := range users {
+			if seen.Id == u.ID.String() {
+				return
+			}
+		}
+		au := gs.toAdminUser(u)
+		au.Email = email
+		users = append(users, au)
+	}
+
+	switch {
+	case uuid.Validate(q) == nil:
+		u, err := gs.Queries.GetUserCoreProfile(ctx, uuid.MustParse(q))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		if err == nil {
+			add(u, "")
+		}
+	case strings.Contains(q, "@"):
+		res, err := gs.Appwrite.Users.List(gs.Appwrite.Users.WithListQueries([]string{
+			query.Equal("email", q),
+			query.Limit(1),
+		}))
+		if err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Failed to query email: " + err.Error(), Type: "internal_server_error"}
+		}
+		for _, au := range res.Users {
+			id, err := utils.StringToUUID(au.Id)
+			if err != nil {
+				continue
+			}
+			u, err := gs.Queries.GetUserCoreProfile(ctx, id)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+			}
+			if err == nil {
+				add(u, au.Email)
+			}
+		}
+	default:
+		hash, err := utils.HashUsername(q, gs.Appwrite.PersonalUsernameKey)
+		if err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Username hashing failed", Type: "internal_server_error"}
+		}
+		u, err := gs.Queries.AdminGetUserByHashedUsername(ctx, hash)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		if err == nil {
+			add(u, "")
+		}
+
+		byName, err := gs.Queries.AdminSearchUsersByName(ctx, postgresCode.AdminSearchUsersByNameParams{
+			Name:       likeEscaper.Replace(q),
+			MaxResults: adminSearchLimit,
+		})
+		if err != nil {
+			return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		for _, u := range byName {
+			add(u, "")
+		}
+	}
+
+	return &model.AdminUserList{Users: users}, nil
+}
+
+// AdminListRecentUsers pages through accounts newest first. before is the created_at cursor of
+// the previous page (RFC 3339), empty for the first page.
+func (gs *GlobalService) AdminListRecentUsers(ctx context.Context, before string, limit int) (*model.AdminUserList, *model.ApiError) {
+	cursor := time.Now()
+	if before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			return nil, &model.ApiError{Code: 400, Message: "before must be an RFC 3339 timestamp", Type: "bad_request"}
+		}
+		cursor = t
+	}
+	if limit <= 0 {
+		limit = defaultAdminPageLimit
+	}
+	limit = min(limit, maxAdminPageLimit)
+
+	rows, err := gs.Queries.ListUsersAfter(ctx, postgresCode.ListUsersAfterParams{
+		CreatedAt: pgtype.Timestamptz{Time: cursor, Valid: true},
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	res := &model.AdminUserList{Users: make([]model.AdminUser, 0, len(rows))}
+	for _, u := range rows {
+		res.Users = append(res.Users, gs.toAdminUser(u))
+	}
+	if len(rows) == limit {
+		res.NextBefore = rows[len(rows)-1].CreatedAt.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return res, nil
+}
+
+// AdminSetUserBlock blocks a user with a reason, or unblocks them, on behalf of adminId. Blocked
+// users are hidden from contact lookups and cannot send contact requests.
+func (gs *GlobalService) AdminSetUserBlock(ctx context.Context, adminId uuid.UUID, userId string, blocked bool, reason string) (*model.AdminUser, *model.ApiError) {
+	id, err := utils.StringToUUID(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid user id", Type: "bad_request"}
+	}
+	var reasonArg *string
+	if blocked {
+		reason = strings.TrimSpace(reason)
+		if reason == "" || len(reason) > maxBlockReasonLength {
+			return nil, &model.ApiError{Code: 400, Message: "A reason of at most 500 characters is required", Type: "bad_request"}
+		}
+		reasonArg = &reason
+	}
+
+	u, err := gs.Queries.SetUserAdminBlock(ctx, postgresCode.SetUserAdminBlockParams{ID: id, Blocked: blocked, Reason: reasonArg})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 404, Message: "User not found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	event := audit.Event{ActorId: adminId, ActorType: audit.ActorAdmin, Action: audit.ActionUserUnblocked, TargetType: audit.TargetUser, TargetId: id.String()}
+	if blocked {
+		event.Action = audit.ActionUserBlocked
+		event.Metadata = map[string]any{"reason": reason}
+	}
+	gs.RecordAudit(ctx, event)
+
+	au := gs.toAdminUser(u)
+	return &au, nil
+}
+
+// AdminContactRequestVolume returns how many contact requests a user sent and received.
+func (gs *GlobalService) AdminContactRequestVolume(ctx context.Context, userId string) (*model.ContactRequestVolume, *model.ApiError) {
+	id, err := utils.StringToUUID(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid user id", Type: "bad_request"}
+	}
+	v, err := gs.Queries.GetContactRequestVolume(ctx, id)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &model.ContactRequestVolume{
+		UserId:        id.String(),
+		SentLastDay:   v.SentLastDay,
+		SentLastWeek:  v.SentLastWeek,
+		SentLastMonth: v.SentLastMonth,
+		SentTotal:     v.SentTotal,
+		SentPending:   v.SentPending,
+		SentAccepted:  v.SentAccepted,
+		SentDeclined:  v.SentDeclined,
+		ReceivedTotal: v.ReceivedTotal,
+	}, nil
+}
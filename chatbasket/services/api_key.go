@@ -0,0 +1,164 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// MaxApiKeysPerUser caps how many keys a user can hold.
+	MaxApiKeysPerUser = 25
+	defaultApiKeyDays = 90
+	maxApiKeyDays     = 365
+)
+
+func toApiKey(k postgresCode.ApiKey) model.ApiKey {
+	a := model.ApiKey{
+		Id:        k.ID.String(),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		ExpiresAt: k.ExpiresAt.Time.UTC().Format(time.RFC3339),
+		CreatedAt: k.CreatedAt.Time.UTC().Format(time.RFC3339),
+	}
+	if k.LastUsedAt.Valid {
+		a.LastUsedAt = k.LastUsedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return a
+}
+
+// CreateApiKey creates a scoped key. The key itself is only in this response.
+func (gs *GlobalService) CreateApiKey(ctx context.Context, userId model.UserId, payload *model.CreateApiKeyPayload) (*model.CreatedApiKey, *model.ApiError) {
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > 64 {
+		return nil, &model.ApiError{Code: 400, Message: "Name must be 1 to 64 characters", Type: "bad_request"}
+	}
+	if len(payload.Scopes) == 0 {
+		return nil, &model.ApiError{Code: 400, Message: "At least one scope is required", Type: "bad_request"}
+	}
+	scopes := make([]string, 0, len(payload.Scopes))
+	for _, scope := range payload.Scopes {
+		if !slices.Contains(model.ApiKeyScopes, scope) {
+			return nil, &model.ApiError{Code: 400, Message: "Unknown scope: " + scope, Type: "bad_request"}
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	days := payload.ExpiresInDays
+	if days == 0 {
+		days = defaultApiKeyDays
+	}
+	if days < 1 || days > maxApiKeyDays {
+		return nil, &model.ApiError{Code: 400, Message: "expires_in_days must be between 1 and 365", Type: "bad_request"}
+	}
+
+	count, err := gs.Queries.CountApiKeys(ctx, userId.UuidUserId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if count >= MaxApiKeysPerUser {
+		return nil, &model.ApiError{Code: 409, Message: "Too many API keys", Type: "conflict"}
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to generate UUID: " + err.Error(), Type: "internal_server_error"}
+	}
+	key, prefix := utils.GenerateApiKey()
+	row, err := gs.Queries.CreateApiKey(ctx, postgresCode.CreateApiKeyParams{
+		ID:        id,
+		UserID:    userId.UuidUserId,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashApiKey(key),
+		Scopes:    scopes,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().AddDate(0, 0, days), Valid: true},
+	})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to create API key: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &model.CreatedApiKey{ApiKey: toApiKey(row), Key: key}, nil
+}
+
+func (gs *GlobalService) ListApiKeys(ctx context.Context, userId model.UserId) (*model.ApiKeyList, *model.ApiError) {
+	rows, err := gs.Queries.ListApiKeys(ctx, userId.UuidUserId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	keys := make([]model.ApiKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, toApiKey(row))
+	}
+	return &model.ApiKeyList{ApiKeys: keys}, nil
+}
+
+// DeleteApiKey revokes one of the user's keys.
+func (gs *GlobalService) DeleteApiKey(ctx context.Context, userId model.UserId, apiKeyId string) (*model.StatusOkay, *model.ApiError) {
+	id, err := uuid.Parse(apiKeyId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid API key id", Type: "bad_request"}
+	}
+	n, err := gs.Queries.DeleteApiKey(ctx, postgresCode.DeleteApiKeyParams{ID: id, UserID: userId.UuidUserId})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if n == 0 {
+		return nil, &model.ApiError{Code: 404, Message: "API key not found", Type: "not_found"}
+	}
+	return &model.StatusOkay{Status: true, Message: "API key deleted"}, nil
+}
+
+// RevokeApiKeys deletes all of the user's keys. Keys outlive sessions, so signing out everywhere
+// and resetting the password revoke them too; otherwise a key minted from a stolen session would
+// keep working.
+func (gs *GlobalService) RevokeApiKeys(ctx context.Context, userId uuid.UUID) *model.ApiError {
+	if err := gs.Queries.DeleteUserApiKeys(ctx, userId); err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to revoke API keys: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return nil
+}
+
+// AuthenticateApiKey resolves the user of a key that was granted scope. It is used by
+// middleware.ApiKeyOrSessionMiddleware.
+func (gs *GlobalService) AuthenticateApiKey(ctx context.Context, key, scope string) (*model.UserId, *model.ApiError) {
+	invalid := &model.ApiError{Code: 401, Message: "Invalid API key", Type: "api_key_invalid"}
+
+	prefix, ok := utils.ApiKeyPrefix(key)
+	if !ok {
+		return nil, invalid
+	}
+	row, err := gs.Queries.GetApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalid
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashApiKey(key)), []byte(row.KeyHash)) != 1 {
+		return nil, invalid
+	}
+	if !row.ExpiresAt.Time.After(time.Now()) {
+		return nil, &model.ApiError{Code: 401, Message: "API key expired", Type: "api_key_expired"}
+	}
+	if !slices.Contains(row.Scopes, scope) {
+		return nil, &model.ApiError{Code: 403, Message: "API key lacks scope " + scope, Type: "insufficient_scope"}
+	}
+
+	if err := gs.Queries.TouchApiKey(ctx, row.ID); err != nil {
+		log.Printf("api key: failed to record use of %s: %v", row.Prefix, err)
+	}
+	return &model.UserId{StringUserId: row.UserID.String(), UuidUserId: row.UserID}, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"chatbasket/audit"
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultAuditPageLimit = 50
+	maxAuditPageLimit     = 500
+)
+
+// RecordAudit records an audit event. The action it describes has already happened, so a
+// failure is logged rather than returned.
+func (gs *GlobalService) RecordAudit(ctx context.Context, e audit.Event) {
+	if err := gs.Audit.Record(ctx, e); err != nil {
+		log.Printf("audit: failed to record %s on %s %s: %v", e.Action, e.TargetType, e.TargetId, err)
+	}
+}
+
+// RecordUserAudit records an action a signed-in user took on their own account.
+func (gs *GlobalService) RecordUserAudit(ctx context.Context, userId uuid.UUID, action, targetType, targetId string, metadata map[string]any) {
+	gs.RecordAudit(ctx, audit.Event{
+		ActorId:    userId,
+		ActorType:  audit.ActorUser,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Metadata:   metadata,
+	})
+}
+
+// RecordLogoutAudit records a sign-out of one session, or of all the user's sessions.
+func (gs *GlobalService) RecordLogoutAudit(ctx context.Context, userId uuid.UUID, sessionId string, allSessions bool) {
+	if allSessions {
+		gs.RecordUserAudit(ctx, userId, audit.ActionLogoutAll, audit.TargetUser, userId.String(), nil)
+		return
+	}
+	gs.RecordUserAudit(ctx, userId, audit.ActionLogout, audit.TargetSession, utils.HashSessionID(sessionId), nil)
+}
+
+// AdminListAuditEvents returns recorded events, newest first.
+func (gs *GlobalService) AdminListAuditEvents(ctx context.Context, f *model.AuditEventFilter) (*model.AuditEventList, *model.ApiError) {
+	params := postgresCode.ListAuditEventsParams{
+		Action:     optionalString(f.Action),
+		TargetType: optionalString(f.TargetType),
+		TargetID:   optionalString(f.TargetId),
+		RequestID:  optionalString(f.RequestId),
+	}
+	var apiErr *model.ApiError
+	if params.ActorUserID, apiErr = optionalUUID(f.ActorId, "actor_id"); apiErr != nil {
+		return nil, apiErr
+	}
+	if params.BeforeID, apiErr = optionalUUID(f.Before, "before"); apiErr != nil {
+		return nil, apiErr
+	}
+	if params.Since, apiErr = optionalTime(f.Since, "since"); apiErr != nil {
+		return nil, apiErr
+	}
+	if params.Until, apiErr = optionalTime(f.Until, "until"); apiErr != nil {
+		return nil, apiErr
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditPageLimit
+	}
+	limit = min(limit, maxAuditPageLimit)
+	params.MaxResults = int32(limit)
+
+	rows, err := gs.Queries.ListAuditEvents(ctx, params)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	res := &model.AuditEventList{Events: make([]model.AuditEvent, 0, len(rows))}
+	for _, row := range rows {
+		e := model.AuditEvent{
+			Id:         row.ID.String(),
+			ActorType:  row.ActorType,
+			Action:     row.Action,
+			TargetType: row.TargetType,
+			TargetId:   row.TargetID,
+			Metadata:   row.Metadata,
+			CreatedAt:  row.CreatedAt.Time.UTC().Format(time.RFC3339Nano),
+		}
+		if row.ActorUserID.Valid {
+			e.ActorUserId = uuid.UUID(row.ActorUserID.Bytes).String()
+		}
+		if row.RequestID != nil {
+			e.RequestId = *row.RequestID
+		}
+		if row.Ip != nil {
+			e.Ip = *row.Ip
+		}
+		if row.UserAgent != nil {
+			e.UserAgent = *row.UserAgent
+		}
+		res.Events = append(res.Events, e)
+	}
+	if len(rows) == limit {
+		res.NextBefore = rows[len(rows)-1].ID.String()
+	}
+	return res, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optionalUUID(s, param string) (pgtype.UUID, *model.ApiError) {
+	if s == "" {
+		return pgtype.UUID{}, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return pgtype.UUID{}, &model.ApiError{Code: 400, Message: "Invalid " + param, Type: "bad_request"}
+	}
+	return pgtype.UUID{Bytes: id, Valid: true}, nil
+}
+
+func optionalTime(s, param string) (pgtype.Timestamptz, *model.ApiError) {
+	if s == "" {
+		return pgtype.Timestamptz{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return pgtype.Timestamptz{}, &model.ApiError{Code: 400, Message: param + " must be an RFC 3339 timestamp", Type: "bad_request"}
+	}
+	return pgtype.Timestamptz{Time: t, Valid: true}, nil
+}
@@ -2,7 +2,11 @@ package services
 
 import (
 	"chatbasket/appwriteinternal"
+	"chatbasket/audit"
 	"chatbasket/db/postgresCode"
+	"chatbasket/mail"
+	"chatbasket/otp"
+	"chatbasket/storage"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -11,12 +15,25 @@ type GlobalService struct {
     Appwrite *appwriteinternal.AppwriteService
     DB       *pgxpool.Pool
     Queries  *postgresCode.Queries
+    Storage  storage.Storage
+    Exports  DataExportConfig
+    Otp      *otp.Service
+    Mailer   mail.Mailer
+    Auth     AuthConfig
+    Audit    *audit.Recorder
 }
 
-func NewGlobalService(app *appwriteinternal.AppwriteService, dbpool *pgxpool.Pool) *GlobalService {
+func NewGlobalService(app *appwriteinternal.AppwriteService, dbpool *pgxpool.Pool, store storage.Storage, exports DataExportConfig, mailer mail.Mailer, auth AuthConfig) *GlobalService {
+    queries := postgresCode.New(dbpool)
     return &GlobalService{
         Appwrite: app,
         DB:       dbpool,
-        Queries:  postgresCode.New(dbpool),
+        Queries:  queries,
+        Otp:      otp.New(queries, auth.MagicLinkSigningKey),
+        Storage:  store,
+        Exports:  exports,
+        Mailer:   mailer,
+        Auth:     auth,
+        Audit:    audit.New(queries),
     }
 }
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"archive/zip"
+	"chatbasket/db/postgresCode"
+	"chatbasket/mail"
+	"chatbasket/model"
+	"chatbasket/storage"
+	"chatbasket/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DataExportLinkTTL is how long a finished export can be downloaded.
+const DataExportLinkTTL = 24 * time.Hour
+
+// DataExportMaxAttempts is how many times a failing export is built before it is marked failed.
+const DataExportMaxAttempts = 5
+
+// dataExportTokenKind scopes download tokens to exports.
+const dataExportTokenKind = "export"
+
+// DataExportConfig says where export archives are kept and how their download links are signed.
+type DataExportConfig struct {
+	BucketID   string
+	SigningKey []byte
+}
+
+func toDataExportStatus(e *postgresCode.DataExport) *model.DataExportStatus {
+	status := &model.DataExportStatus{
+		Id:        e.ID.String(),
+		Status:    e.Status,
+		SizeBytes: e.SizeBytes,
+		CreatedAt: e.CreatedAt.Time.UTC().Format(time.RFC3339),
+	}
+	if e.ExpiresAt.Valid {
+		expiresAt := e.ExpiresAt.Time.UTC().Format(time.RFC3339)
+		status.ExpiresAt = &expiresAt
+	}
+	return status
+}
+
+// RequestDataExport queues an export of the user's personal data. The archive is built in the
+// background (see workers.DataExportWorker) and a download link is emailed when it is ready.
+func (gs *GlobalService) RequestDataExport(ctx context.Context, userId uuid.UUID) (*model.DataExportStatus, *model.ApiError) {
+	exists, err := gs.Queries.IsUserExists(ctx, userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if !exists {
+		return nil, &model.ApiError{Code: 404, Message: "Profile not found", Type: "not_found"}
+	}
+
+	exportId, err := uuid.NewV7()
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to generate uuid", Type: "internal_server_error"}
+	}
+	export, err := gs.Queries.CreateDataExport(ctx, postgresCode.CreateDataExportParams{ID: exportId, UserID: userId})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 409, Message: "export_already_in_progress", Type: "conflict"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: "Failed to queue export: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return toDataExportStatus(&export), nil
+}
+
+// GetDataExportStatus returns the user's most recent export.
+func (gs *GlobalService) GetDataExportStatus(ctx context.Context, userId uuid.UUID) (*model.DataExportStatus, *model.ApiError) {
+	export, err := gs.Queries.GetLatestDataExport(ctx, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 404, Message: "No export found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return toDataExportStatus(&export), nil
+}
+
+// StoreDataExport builds the export archive in a temp file and stores it under the export id.
+func (gs *GlobalService) StoreDataExport(ctx context.Context, export *postgresCode.DataExport, email string) (*storage.Object, error) {
+	tmp, err := os.CreateTemp("", "chatbasket_export_*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := gs.BuildDataExport(ctx, export.UserID, email, tmp); err != nil {
+		return nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// A retried build replaces the archive of the earlier attempt
+	if err := gs.Storage.Delete(ctx, gs.Exports.BucketID, export.ID.String()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	name := "chatbasket-export-" + export.CreatedAt.Time.UTC().Format("2006-01-02") + ".zip"
+	return gs.Storage.Put(ctx, gs.Exports.BucketID, export.ID.String(), name, tmp, size)
+}
+
+// DataExportLink returns the signed one-time download URL of an export.
+func (gs *GlobalService) DataExportLink(exportId uuid.UUID, expiresAt time.Time) string {
+	token := utils.SignDownloadToken(dataExportTokenKind, exportId.String(), expiresAt, gs.Exports.SigningKey)
+	return fmt.Sprintf("%s/exports/%s/download?t=%s",
+		strings.TrimRight(gs.Appwrite.PublicApiBaseURL, "/"), exportId, url.QueryEscape(token))
+}
+
+// SendDataExportReadyEmail emails the download link of a finished export.
+func (gs *GlobalService) SendDataExportReadyEmail(ctx context.Context, userId, email, link string, expiresAt time.Time) *model.ApiError {
+	data := mail.DataExportReadyData{Link: link, ExpiresAt: expiresAt}
+	return gs.SendTemplatedEmail(ctx, userId, email, mail.TemplateDataExportReady, data)
+}
+
+// OpenDataExportDownload checks a download link and uses it up. The caller streams the returned
+// archive and then calls PurgeDataExportFile.
+func (gs *GlobalService) OpenDataExportDownload(ctx context.Context, exportId, token string) (*postgresCode.DataExport, *storage.Reader, *model.ApiError) {
+	if err := utils.VerifyDownloadToken(token, dataExportTokenKind, exportId, gs.Exports.SigningKey, time.Now()); err != nil {
+		message := "invalid_download_link"
+		if errors.Is(err, utils.ErrDownloadTokenExpired) {
+			message = "download_link_expired"
+		}
+		return nil, nil, &model.ApiError{Code: 403, Message: message, Type: "forbidden"}
+	}
+	uuidExportId, err := uuid.Parse(exportId)
+	if err != nil {
+		return nil, nil, &model.ApiError{Code: 403, Message: "invalid_download_link", Type: "forbidden"}
+	}
+
+	export, err := gs.Queries.ConsumeDataExport(ctx, uuidExportId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, &model.ApiError{Code: 410, Message: "download_link_used_or_expired", Type: "gone"}
+		}
+		return nil, nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if export.FileID == nil {
+		return nil, nil, &model.ApiError{Code: 410, Message: "download_link_used_or_expired", Type: "gone"}
+	}
+
+	reader, err := gs.Storage.Get(ctx, gs.Exports.BucketID, *export.FileID, "")
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, &model.ApiError{Code: 410, Message: "download_link_used_or_expired", Type: "gone"}
+		}
+		return nil, nil, &model.ApiError{Code: 500, Message: "Failed to open export: " + err.Error(), Type: "internal_server_error"}
+	}
+	return &export, reader, nil
+}
+
+// PurgeDataExportFile deletes a stored archive and forgets it.
+func (gs *GlobalService) PurgeDataExportFile(ctx context.Context, exportId uuid.UUID, fileId string) error {
+	if err := gs.Storage.Delete(ctx, gs.Exports.BucketID, fileId); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return gs.Queries.PurgeDataExport(ctx, exportId)
+}
+
+//
+// ---------- Archive ----------
+//
+
+type exportManifest struct {
+	FormatVersion int                  `json:"format_version"`
+	UserID        string               `json:"user_id"`
+	GeneratedAt   string               `json:"generated_at"`
+	Files         []exportManifestFile `json:"files"`
+}
+
+type exportManifestFile struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Records     int    `json:"records,omitempty"`
+}
+
+type exportProfile struct {
+	ID               string  `json:"id"`
+	Username         string  `json:"username"`
+	Name             string  `json:"name"`
+	Bio              *string `json:"bio"`
+	Email            string  `json:"email"`
+	ProfileType      string  `json:"profile_type"`
+	IsAdminBlocked   bool    `json:"is_admin_blocked"`
+	AdminBlockReason *string `json:"admin_block_reason"`
+	CreatedAt        string  `json:"created_at"`
+	UpdatedAt        string  `json:"updated_at"`
+}
+
+type exportContact struct {
+	ContactUserID string  `json:"contact_user_id"`
+	ContactName   string  `json:"contact_name"`
+	Nickname      *string `json:"nickname"`
+	AddedAt       string  `json:"added_at"`
+}
+
+type exportContactRequest struct {
+	ID          string  `json:"id"`
+	Direction   string  `json:"direction"` // "sent" or "received"
+	OtherUserID string  `json:"other_user_id"`
+	Status      string  `json:"status"`
+	Nickname    *string `json:"nickname,omitempty"` // only on sent requests; it belongs to the requester
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+type exportRestrictions struct {
+	Global           []postgresCode.ExportUserGlobalRestrictionsRow          `json:"global"`
+	GlobalExemptions []postgresCode.ExportUserGlobalRestrictionExemptionsRow `json:"global_exemptions"`
+	Users            []postgresCode.ExportUserRestrictionsRow                `json:"users"`
+}
+
+func exportTime(t pgtype.Timestamptz) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
+
+// exportZip writes archive entries and records them for the manifest.
+type exportZip struct {
+	zw    *zip.Writer
+	files []exportManifestFile
+}
+
+func (z *exportZip) writeJSON(path, description string, records int, v any) error {
+	w, err := z.zw.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	z.files = append(z.files, exportManifestFile{Path: path, Description: description, Records: records})
+	return nil
+}
+
+func (z *exportZip) writeFile(path, description string, body io.Reader) error {
+	w, err := z.zw.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return err
+	}
+	z.files = append(z.files, exportManifestFile{Path: path, Description: description})
+	return nil
+}
+
+// BuildDataExport writes a ZIP of everything held about the user in personal mode to w: one JSON
+// file per data set, the avatar variants and a manifest.json describing them. Encrypted fields
+// are decrypted.
+func (gs *GlobalService) BuildDataExport(ctx context.Context, userId uuid.UUID, email string, w io.Writer) error {
+	q := gs.Queries
+	ownerId := userId.String()
+	z := &exportZip{zw: zip.NewWriter(w)}
+
+	// Profile
+	user, err := q.GetUserCoreProfile(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	username, err := utils.DecryptUsername(user.B64CipherChacha20poly1305Username, gs.Appwrite.PersonalUsernameKey, ownerId)
+	if err != nil {
+		return fmt.Errorf("profile: decrypt username: %w", err)
+	}
+	bio, err := utils.DecryptOptionalField(user.Bio, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeBio, ownerId)
+	if err != nil {
+		return fmt.Errorf("profile: decrypt bio: %w", err)
+	}
+	profile := exportProfile{
+		ID:               ownerId,
+		Username:         username,
+		Name:             user.Name,
+		Bio:              bio,
+		Email:            email,
+		ProfileType:      user.ProfileType,
+		IsAdminBlocked:   user.IsAdminBlocked,
+		AdminBlockReason: user.AdminBlockReason,
+		CreatedAt:        exportTime(user.CreatedAt),
+		UpdatedAt:        exportTime(user.UpdatedAt),
+	}
+	if err := z.writeJSON("profile.json", "Your personal profile", 1, profile); err != nil {
+		return err
+	}
+
+	// Contacts
+	contactRows, err := q.ExportUserContacts(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("contacts: %w", err)
+	}
+	contacts := make([]exportContact, 0, len(contactRows))
+	for _, c := range contactRows {
+		nickname, err := utils.DecryptOptionalField(c.Nickname, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeNickname, ownerId)
+		if err != nil {
+			return fmt.Errorf("contacts: decrypt nickname: %w", err)
+		}
+		contacts = append(contacts, exportContact{
+			ContactUserID: c.ContactUserID.String(),
+			ContactName:   c.ContactName,
+			Nickname:      nickname,
+			AddedAt:       exportTime(c.CreatedAt),
+		})
+	}
+	if err := z.writeJSON("contacts.json", "People you added as contacts, with the nicknames you gave them", len(contacts), contacts); err != nil {
+		return err
+	}
+
+	// Contact requests
+	requestRows, err := q.ExportUserContactRequests(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("contact requests: %w", err)
+	}
+	requests := make([]exportContactRequest, 0, len(requestRows))
+	for _, r := range requestRows {
+		req := exportContactRequest{
+			ID:        r.ID.String(),
+			Status:    r.Status,
+			CreatedAt: exportTime(r.CreatedAt),
+			UpdatedAt: exportTime(r.UpdatedAt),
+		}
+		if r.RequesterUserID == userId {
+			req.Direction = "sent"
+			req.OtherUserID = r.ReceiverUserID.String()
+			req.Nickname, err = utils.DecryptOptionalField(r.Nickname, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeNickname, ownerId)
+			if err != nil {
+				return fmt.Errorf("contact requests: decrypt nickname: %w", err)
+			}
+		} else {
+			req.Direction = "received"
+			req.OtherUserID = r.RequesterUserID.String()
+		}
+		requests = append(requests, req)
+	}
+	if err := z.writeJSON("contact_requests.json", "Contact requests you sent or received", len(requests), requests); err != nil {
+		return err
+	}
+
+	// Restrictions
+	var restrictions exportRestrictions
+	if restrictions.Global, err = q.ExportUserGlobalRestrictions(ctx, userId); err != nil {
+		return fmt.Errorf("global restrictions: %w", err)
+	}
+	if restrictions.GlobalExemptions, err = q.ExportUserGlobalRestrictionExemptions(ctx, userId); err != nil {
+		return fmt.Errorf("global restriction exemptions: %w", err)
+	}
+	if restrictions.Users, err = q.ExportUserRestrictions(ctx, userId); err != nil {
+		return fmt.Errorf("restrictions: %w", err)
+	}
+	records := len(restrictions.Global) + len(restrictions.GlobalExemptions) + len(restrictions.Users)
+	if err := z.writeJSON("restrictions.json", "What you hide from everyone, exemptions, and per-user restrictions", records, restrictions); err != nil {
+		return err
+	}
+
+	// Blocks
+	blocks, err := q.ExportUserBlocks(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("blocks: %w", err)
+	}
+	if err := z.writeJSON("blocks.json", "Users you blocked", len(blocks), blocks); err != nil {
+		return err
+	}
+
+	// Push tokens
+	pushTokens, err := q.ExportUserPushTokens(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("push tokens: %w", err)
+	}
+	if err := z.writeJSON("push_tokens.json", "Push notification tokens of your devices", len(pushTokens), pushTokens); err != nil {
+		return err
+	}
+
+	// Avatar files
+	for _, size := range utils.AvatarVariantSizes {
+		reader, err := gs.Storage.Get(ctx, gs.Appwrite.PersonalProfilePicBucketID, utils.AvatarVariantFileID(ownerId, size), "")
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return fmt.Errorf("avatar %d: %w", size, err)
+		}
+		err = z.writeFile("avatar/avatar_"+strconv.Itoa(size)+".jpg", "Your profile picture, "+strconv.Itoa(size)+"px", reader.Body)
+		reader.Body.Close()
+		if err != nil {
+			return fmt.Errorf("avatar %d: %w", size, err)
+		}
+	}
+
+	manifest := exportManifest{
+		FormatVersion: 1,
+		UserID:        ownerId,
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		Files:         z.files,
+	}
+	if err := z.writeJSON("manifest.json", "This file", 0, manifest); err != nil {
+		return err
+	}
+	return z.zw.Close()
+}
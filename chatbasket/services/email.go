@@ -0,0 +1,66 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/mail"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SendTemplatedEmail renders mail template name in the user's language and sends it to address.
+func (gs *GlobalService) SendTemplatedEmail(ctx context.Context, userId, address, name string, data any) *model.ApiError {
+	msg, err := mail.Render(name, gs.emailLocale(ctx, userId), data)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to render email: " + err.Error(), Type: "internal_server_error"}
+	}
+	msg.UserId = userId
+	msg.To = address
+
+	if err := gs.Mailer.Send(ctx, msg); err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to send email: " + err.Error(), Type: "internal_server_error"}
+	}
+	return nil
+}
+
+// emailLocale picks the language of an email: the user's stored preference, then the
+// Accept-Language of the request being handled, then mail.DefaultLocale.
+func (gs *GlobalService) emailLocale(ctx context.Context, userId string) string {
+	if uuidUserId, err := utils.StringToUUID(userId); err == nil {
+		locale, err := gs.Queries.GetUserLocale(ctx, uuidUserId)
+		if err == nil && mail.SupportedLocale(locale) {
+			return locale
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("email locale: failed to load preference of %s: %v", userId, err)
+		}
+	}
+	if locale := mail.MatchLocale(mail.AcceptLanguage(ctx)); locale != "" {
+		return locale
+	}
+	return mail.DefaultLocale
+}
+
+// SetEmailLocale stores the language the user gets emails in. An empty locale clears the
+// preference, so the request's Accept-Language decides again.
+func (gs *GlobalService) SetEmailLocale(ctx context.Context, payload *model.UpdateLocalePayload, userId model.UserId) (*model.StatusOkay, *model.ApiError) {
+	if payload.Locale == "" {
+		if err := gs.Queries.DeleteUserPreferences(ctx, userId.UuidUserId); err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Failed to clear locale: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		return &model.StatusOkay{Status: true, Message: "Locale cleared"}, nil
+	}
+	if !mail.SupportedLocale(payload.Locale) {
+		return nil, &model.ApiError{Code: 400, Message: "Unsupported locale", Type: "bad_request"}
+	}
+
+	err := gs.Queries.SetUserLocale(ctx, postgresCode.SetUserLocaleParams{UserID: userId.UuidUserId, Locale: payload.Locale})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to save locale: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &model.StatusOkay{Status: true, Message: payload.Locale}, nil
+}
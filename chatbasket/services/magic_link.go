@@ -0,0 +1,111 @@
+package services
+
+import (
+	"chatbasket/mail"
+	"chatbasket/model"
+	"chatbasket/otp"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// AuthConfig holds the settings of the passwordless login flows.
+type AuthConfig struct {
+	// WebAuthn runs the passkey ceremonies for the configured relying party.
+	WebAuthn *webauthn.WebAuthn
+	// MagicLinkSigningKey signs magic login link tokens.
+	MagicLinkSigningKey []byte
+	// MagicLinkRedirectURL is where a browser is sent after opening a link, or "" to answer
+	// with JSON.
+	MagicLinkRedirectURL string
+	// AccessTokenSigningKey signs the access tokens of native clients.
+	AccessTokenSigningKey []byte
+}
+
+// sendMagicLink emails a login link that only works together with nonce.
+func (gs *GlobalService) sendMagicLink(ctx context.Context, userId, email, nonce string) *model.ApiError {
+	uuidUserId, err := utils.StringToUUID(userId)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	err = gs.Otp.IssueLink(ctx, uuidUserId, email, nonce, func(token string, expiresAt time.Time) error {
+		data := mail.MagicLinkData{Link: gs.magicLinkURL(token), ValidMinutes: int(otp.LinkTTL.Minutes())}
+		if apiErr := gs.SendTemplatedEmail(ctx, userId, email, mail.TemplateMagicLink, data); apiErr != nil {
+			return errors.New(apiErr.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return otp.LinkApiError(err)
+	}
+	return nil
+}
+
+// magicLinkURL returns the link emailed for token. Native apps open it as a universal link and
+// post the token to /auth/magic-link themselves.
+func (gs *GlobalService) magicLinkURL(token string) string {
+	return strings.TrimRight(gs.Appwrite.PublicApiBaseURL, "/") + "/auth/magic-link?t=" + url.QueryEscape(token)
+}
+
+// MagicLinkLogin creates a session from a link sent by Login, presented with the nonce of the
+// device that asked for it. Users with TOTP enabled also need their second factor.
+func (gs *GlobalService) MagicLinkLogin(ctx context.Context, payload *model.MagicLinkVerificationPayload) (*model.SessionResponse, *model.ApiError) {
+	// 🔑 Step 1: Verify the link and the device
+	link, err := gs.Otp.CheckLink(ctx, payload.Token, payload.Nonce)
+	if err != nil {
+		return nil, otp.LinkApiError(err)
+	}
+
+	userId := link.UserID.String()
+	user, err := gs.Appwrite.Users.Get(userId)
+	if err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return nil, otp.LinkApiError(otp.ErrInvalid)
+		}
+		return nil, &model.ApiError{Code: 500, Message: "Failed to query user: " + err.Error(), Type: "internal_server_error"}
+	}
+	// The link was sent to an address the account no longer has
+	if !strings.EqualFold(user.Email, link.Email) {
+		return nil, otp.LinkApiError(otp.ErrInvalid)
+	}
+
+	// 🔑 Step 2: Second factor, when TOTP is enabled
+	if _, apiErr := gs.CheckSecondFactor(ctx, link.UserID, payload.TotpCode, payload.RecoveryCode); apiErr != nil {
+		if apiErr.Code == 401 && apiErr.Message != "totp_required" {
+			if err := gs.Otp.FailLink(ctx, link); err != nil {
+				return nil, otp.LinkApiError(err)
+			}
+		}
+		return nil, apiErr
+	}
+	if err := gs.Otp.ConsumeLink(ctx, link); err != nil {
+		return nil, otp.LinkApiError(err)
+	}
+
+	// 🔑 Step 3: Create session
+	session, err := gs.Appwrite.Users.CreateSession(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to create session: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	// Opening the link proves the address
+	if !user.EmailVerification {
+		if _, err := gs.Appwrite.Users.UpdateEmailVerification(userId, true); err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Failed to update email verification status: " + err.Error(), Type: "internal_server_error"}
+		}
+	}
+
+	return &model.SessionResponse{
+		UserId:        userId,
+		Name:          user.Name,
+		Email:         user.Email,
+		SessionID:     session.Id,
+		SessionExpiry: session.Expire,
+	}, nil
+}
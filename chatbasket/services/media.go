@@ -0,0 +1,72 @@
+package services
+
+import (
+	"chatbasket/model"
+	"chatbasket/storage"
+	"chatbasket/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// AvatarStream is an avatar opened from storage, ready to be copied to the client.
+// When NotModified is true Body is nil and only ETag is set.
+type AvatarStream struct {
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64
+	ETag          string
+	NotModified   bool
+}
+
+// AvatarETag derives a strong ETag from the file id and a value that changes whenever
+// the file is replaced (e.g. the avatar row's updated_at).
+func AvatarETag(fileID, version string) string {
+	sum := sha256.Sum256([]byte(fileID + "|" + version))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// OpenAvatarStream streams a file from storage unless the client already holds etag.
+// tokenSecret may be empty, in which case server credentials are used.
+func (gs *GlobalService) OpenAvatarStream(ctx context.Context, bucketID, fileID, tokenSecret, etag, ifNoneMatch string) (*AvatarStream, *model.ApiError) {
+	if ifNoneMatch != "" && ifNoneMatch == etag {
+		return &AvatarStream{ETag: etag, NotModified: true}, nil
+	}
+
+	obj, err := gs.Storage.Get(ctx, bucketID, fileID, tokenSecret)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, &model.ApiError{Code: http.StatusNotFound, Message: "avatar_not_found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: http.StatusBadGateway, Message: "Failed to fetch avatar: " + err.Error(), Type: "bad_gateway"}
+	}
+
+	return &AvatarStream{
+		Body:          obj.Body,
+		ContentType:   obj.ContentType,
+		ContentLength: obj.ContentLength,
+		ETag:          etag,
+	}, nil
+}
+
+// OpenAvatarVariant streams the requested size variant of an avatar. File tokens are only issued
+// for the main file, so variants are always read with the API key. Avatars uploaded before
+// variants existed only have the main file, which is served instead when the variant is missing.
+func (gs *GlobalService) OpenAvatarVariant(ctx context.Context, bucketID, fileID string, size int, tokenSecret, version, ifNoneMatch string) (*AvatarStream, *model.ApiError) {
+	etag := AvatarETag(fileID, version+"|"+strconv.Itoa(size))
+
+	variantID := utils.AvatarVariantFileID(fileID, size)
+	if variantID == fileID {
+		return gs.OpenAvatarStream(ctx, bucketID, fileID, tokenSecret, etag, ifNoneMatch)
+	}
+
+	stream, apiErr := gs.OpenAvatarStream(ctx, bucketID, variantID, "", etag, ifNoneMatch)
+	if apiErr != nil && apiErr.Code == http.StatusNotFound {
+		return gs.OpenAvatarStream(ctx, bucketID, fileID, tokenSecret, etag, ifNoneMatch)
+	}
+	return stream, apiErr
+}
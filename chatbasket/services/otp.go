@@ -0,0 +1,43 @@
+package services
+
+import (
+	"chatbasket/mail"
+	"chatbasket/model"
+	"chatbasket/otp"
+	"chatbasket/utils"
+	"context"
+	"errors"
+)
+
+// SendOtpEmail issues a code for purpose and emails it to the user, replacing the user's pending
+// code for that purpose. detail names what is verified in identity emails and is "" otherwise.
+func (gs *GlobalService) SendOtpEmail(ctx context.Context, userId, email, purpose, detail string) *model.ApiError {
+	uuidUserId, err := utils.StringToUUID(userId)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	err = gs.Otp.Issue(ctx, uuidUserId, purpose, email, func(code string) error {
+		data := mail.OtpData{Purpose: purpose, Code: code, ValidMinutes: int(otp.TTL.Minutes()), Detail: detail}
+		if apiErr := gs.SendTemplatedEmail(ctx, userId, email, mail.TemplateOtp, data); apiErr != nil {
+			return errors.New(apiErr.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return otp.ApiError(err)
+	}
+	return nil
+}
+
+// verifyOtp checks secret against the user's pending code for purpose and uses it up.
+func (gs *GlobalService) verifyOtp(ctx context.Context, userId, purpose, secret string) *model.ApiError {
+	uuidUserId, err := utils.StringToUUID(userId)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
+	}
+	if _, err := gs.Otp.Verify(ctx, uuidUserId, purpose, "", secret); err != nil {
+		return otp.ApiError(err)
+	}
+	return nil
+}
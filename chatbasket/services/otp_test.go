@@ -0,0 +1,71 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/mail"
+	"chatbasket/otp"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// otpStore lets every send through and keeps the issued challenges.
+type otpStore struct {
+	otp.Store
+	challenges []postgresCode.UpsertOtpChallengeParams
+}
+
+func (s *otpStore) ReserveOtpSend(_ context.Context, arg postgresCode.ReserveOtpSendParams) (uuid.UUID, error) {
+	return arg.UserID, nil
+}
+
+func (s *otpStore) UpsertOtpChallenge(_ context.Context, arg postgresCode.UpsertOtpChallengeParams) error {
+	s.challenges = append(s.challenges, arg)
+	return nil
+}
+
+var otpCodePattern = regexp.MustCompile(`\b\d{6}\b`)
+
+func TestSendOtpEmail(t *testing.T) {
+	store := &otpStore{}
+	mailer := mail.NewMemory()
+	gs := &GlobalService{Queries: postgresCode.New(nopDB{}), Otp: otp.New(store, nil), Mailer: mailer}
+	userId := uuid.NewString()
+
+	ctx := mail.WithAcceptLanguage(context.Background(), "es-MX,en;q=0.5")
+	if apiErr := gs.SendOtpEmail(ctx, userId, "user@example.com", otp.PurposeLogin, ""); apiErr != nil {
+		t.Fatalf("SendOtpEmail: %+v", apiErr)
+	}
+
+	sent := mailer.Messages()
+	if len(sent) != 1 || len(store.challenges) != 1 {
+		t.Fatalf("sent %d messages and stored %d challenges, want 1 of each", len(sent), len(store.challenges))
+	}
+	msg := sent[0]
+	if msg.To != "user@example.com" || msg.UserId != userId {
+		t.Fatalf("message to %q (%s)", msg.To, msg.UserId)
+	}
+
+	code := otpCodePattern.FindString(msg.Text)
+	if code == "" {
+		t.Fatalf("no code in the text body: %q", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, code) {
+		t.Fatalf("the HTML body lacks the code %s", code)
+	}
+	if strings.Contains(store.challenges[0].CodeHash, code) {
+		t.Fatal("the code was stored in the clear")
+	}
+
+	// The request's Accept-Language picked the Spanish template
+	want, err := mail.Render(mail.TemplateOtp, "es", mail.OtpData{Purpose: otp.PurposeLogin, Code: code, ValidMinutes: int(otp.TTL.Minutes())})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if msg.Subject != want.Subject || msg.Text != want.Text {
+		t.Fatalf("sent %q, want the Spanish %q", msg.Subject, want.Subject)
+	}
+}
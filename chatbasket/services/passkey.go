@@ -0,0 +1,344 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// WebAuthn ceremonies, stored with their challenge so one cannot finish the other.
+const (
+	webauthnCeremonyRegistration = "registration"
+	webauthnCeremonyLogin        = "login"
+)
+
+// webauthnChallengeTTL bounds a ceremony; it matches the library's default client timeout.
+const webauthnChallengeTTL = 5 * time.Minute
+
+// maxPasskeyNameLength caps the label of a passkey.
+const maxPasskeyNameLength = 64
+
+// passkeyUser adapts an account to webauthn.User. The user handle is the 16-byte user id.
+type passkeyUser struct {
+	id          uuid.UUID
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte                         { return u.id[:] }
+func (u *passkeyUser) WebAuthnName() string                       { return u.name }
+func (u *passkeyUser) WebAuthnDisplayName() string                { return u.name }
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+func toWebauthnCredential(c postgresCode.PasskeyCredential) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, len(c.Transports))
+	for i, t := range c.Transports {
+		transports[i] = protocol.AuthenticatorTransport(t)
+	}
+	return webauthn.Credential{
+		ID:              c.CredentialID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(c.Flags)),
+		Authenticator:   webauthn.Authenticator{AAGUID: c.Aaguid, SignCount: uint32(c.SignCount)},
+	}
+}
+
+func toPasskey(c postgresCode.PasskeyCredential) model.Passkey {
+	p := model.Passkey{
+		Id:        c.ID.String(),
+		Name:      c.Name,
+		Synced:    protocol.AuthenticatorFlags(c.Flags).HasBackupState(),
+		CreatedAt: c.CreatedAt.Time.UTC().Format(time.RFC3339),
+	}
+	if c.LastUsedAt.Valid {
+		p.LastUsedAt = c.LastUsedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return p
+}
+
+// loadPasskeyUser returns the account with its registered credentials.
+func (gs *GlobalService) loadPasskeyUser(ctx context.Context, userId uuid.UUID, name string) (*passkeyUser, []postgresCode.PasskeyCredential, error) {
+	rows, err := gs.Queries.ListPasskeyCredentials(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := &passkeyUser{id: userId, name: name, credentials: make([]webauthn.Credential, len(rows))}
+	for i, row := range rows {
+		user.credentials[i] = toWebauthnCredential(row)
+	}
+	return user, rows, nil
+}
+
+// storeWebauthnChallenge keeps the session data of a ceremony until it is finished.
+func (gs *GlobalService) storeWebauthnChallenge(ctx context.Context, userId pgtype.UUID, ceremony string, session *webauthn.SessionData) (uuid.UUID, *model.ApiError) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return uuid.Nil, &model.ApiError{Code: 500, Message: "Failed to encode challenge: " + err.Error(), Type: "internal_server_error"}
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.Nil, &model.ApiError{Code: 500, Message: "Failed to generate UUID: " + err.Error(), Type: "internal_server_error"}
+	}
+	err = gs.Queries.CreateWebauthnChallenge(ctx, postgresCode.CreateWebauthnChallengeParams{
+		ID:          id,
+		UserID:      userId,
+		Ceremony:    ceremony,
+		SessionData: data,
+		Ttl:         pgtype.Interval{Microseconds: webauthnChallengeTTL.Microseconds(), Valid: true},
+	})
+	if err != nil {
+		return uuid.Nil, &model.ApiError{Code: 500, Message: "Failed to store challenge: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return id, nil
+}
+
+// takeWebauthnChallenge uses up the challenge of a ceremony and returns its session data.
+func (gs *GlobalService) takeWebauthnChallenge(ctx context.Context, challengeId, ceremony string) (*postgresCode.WebauthnChallenge, *webauthn.SessionData, *model.ApiError) {
+	id, err := uuid.Parse(challengeId)
+	if err != nil {
+		return nil, nil, &model.ApiError{Code: 400, Message: "Invalid challenge id", Type: "bad_request"}
+	}
+	challenge, err := gs.Queries.TakeWebauthnChallenge(ctx, postgresCode.TakeWebauthnChallengeParams{ID: id, Ceremony: ceremony})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, &model.ApiError{Code: 401, Message: "challenge_expired", Type: "unauthorized"}
+		}
+		return nil, nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal(challenge.SessionData, &session); err != nil {
+		return nil, nil, &model.ApiError{Code: 500, Message: "Failed to decode challenge: " + err.Error(), Type: "internal_server_error"}
+	}
+	return &challenge, &session, nil
+}
+
+// BeginPasskeyRegistration starts registering a passkey for the signed-in user. A passkey signs
+// in without TOTP, so the user re-authenticates like DisableTotp first. Passkeys the user already
+// has are excluded, so an authenticator is not registered twice.
+func (gs *GlobalService) BeginPasskeyRegistration(ctx context.Context, userId model.UserId, email string, payload *model.BeginPasskeyRegistrationPayload) (*model.PasskeyCreationOptions, *model.ApiError) {
+	if _, apiErr := gs.reauthenticate(ctx, userId, payload.Password, payload.Code, payload.RecoveryCode); apiErr != nil {
+		return nil, apiErr
+	}
+
+	user, _, err := gs.loadPasskeyUser(ctx, userId.UuidUserId, email)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	creation, session, err := gs.Auth.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to start passkey registration: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	challengeId, apiErr := gs.storeWebauthnChallenge(ctx, pgtype.UUID{Bytes: userId.UuidUserId, Valid: true}, webauthnCeremonyRegistration, session)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &model.PasskeyCreationOptions{ChallengeId: challengeId.String(), Options: creation}, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation and stores the passkey.
+func (gs *GlobalService) FinishPasskeyRegistration(ctx context.Context, userId model.UserId, email string, payload *model.PasskeyRegistrationPayload) (*model.Passkey, *model.ApiError) {
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		return nil, &model.ApiError{Code: 400, Message: "Passkey name is too long", Type: "bad_request"}
+	}
+
+	challenge, session, apiErr := gs.takeWebauthnChallenge(ctx, payload.ChallengeId, webauthnCeremonyRegistration)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !challenge.UserID.Valid || uuid.UUID(challenge.UserID.Bytes) != userId.UuidUserId {
+		return nil, &model.ApiError{Code: 401, Message: "challenge_expired", Type: "unauthorized"}
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(payload.Credential)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid passkey response: " + err.Error(), Type: "bad_request"}
+	}
+	user, _, err := gs.loadPasskeyUser(ctx, userId.UuidUserId, email)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	credential, err := gs.Auth.WebAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, &model.ApiError{Code: 401, Message: "Passkey verification failed: " + err.Error(), Type: "unauthorized"}
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to generate UUID: " + err.Error(), Type: "internal_server_error"}
+	}
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+	row, err := gs.Queries.CreatePasskeyCredential(ctx, postgresCode.CreatePasskeyCredentialParams{
+		ID:              id,
+		UserID:          userId.UuidUserId,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		Aaguid:          credential.Authenticator.AAGUID,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		Flags:           int16(credential.Flags.ProtocolValue()),
+		Name:            name,
+	})
+	if err != nil {
+		pgErr := utils.GetPostgresError(err)
+		if pgErr.PgError != nil && pgErr.PgError.Code == "23505" { // unique_violation on credential_id
+			return nil, &model.ApiError{Code: 409, Message: "passkey_already_registered", Type: "conflict"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: pgErr.Message, Type: "internal_server_error"}
+	}
+
+	passkey := toPasskey(row)
+	return &passkey, nil
+}
+
+// ListPasskeys returns the user's passkeys, oldest first.
+func (gs *GlobalService) ListPasskeys(ctx context.Context, userId model.UserId) (*model.PasskeyList, *model.ApiError) {
+	rows, err := gs.Queries.ListPasskeyCredentials(ctx, userId.UuidUserId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	list := &model.PasskeyList{Passkeys: make([]model.Passkey, len(rows))}
+	for i, row := range rows {
+		list.Passkeys[i] = toPasskey(row)
+	}
+	return list, nil
+}
+
+// DeletePasskey removes one of the user's passkeys.
+func (gs *GlobalService) DeletePasskey(ctx context.Context, userId model.UserId, passkeyId string) (*model.StatusOkay, *model.ApiError) {
+	id, err := uuid.Parse(passkeyId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid passkey id", Type: "bad_request"}
+	}
+	n, err := gs.Queries.DeletePasskeyCredential(ctx, postgresCode.DeletePasskeyCredentialParams{ID: id, UserID: userId.UuidUserId})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if n == 0 {
+		return nil, &model.ApiError{Code: 404, Message: "Passkey not found", Type: "not_found"}
+	}
+	return &model.StatusOkay{Status: true, Message: "Passkey deleted"}, nil
+}
+
+// BeginPasskeyLogin starts a discoverable login: the authenticator offers the user's passkeys
+// for this site, so no email is asked for first.
+func (gs *GlobalService) BeginPasskeyLogin(ctx context.Context) (*model.PasskeyRequestOptions, *model.ApiError) {
+	assertion, session, err := gs.Auth.WebAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to start passkey login: " + err.Error(), Type: "internal_server_error"}
+	}
+	challengeId, apiErr := gs.storeWebauthnChallenge(ctx, pgtype.UUID{}, webauthnCeremonyLogin, session)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &model.PasskeyRequestOptions{ChallengeId: challengeId.String(), Options: assertion}, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and creates a session like LoginVerification. The
+// signature counter has to move forward, which rejects replays and flags cloned authenticators.
+// A passkey with user verification already is a second factor, so TOTP is not asked for.
+func (gs *GlobalService) FinishPasskeyLogin(ctx context.Context, payload *model.PasskeyLoginPayload) (*model.SessionResponse, *model.ApiError) {
+	// 🔑 Step 1: Verify the assertion
+	_, session, apiErr := gs.takeWebauthnChallenge(ctx, payload.ChallengeId, webauthnCeremonyLogin)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(payload.Credential)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid passkey response: " + err.Error(), Type: "bad_request"}
+	}
+
+	var rows []postgresCode.PasskeyCredential
+	lookup := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userId, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		user, userRows, err := gs.loadPasskeyUser(ctx, userId, "")
+		if err != nil {
+			return nil, err
+		}
+		rows = userRows
+		return user, nil
+	}
+	user, credential, err := gs.Auth.WebAuthn.ValidatePasskeyLogin(lookup, *session, parsed)
+	if err != nil {
+		return nil, &model.ApiError{Code: 401, Message: "Passkey verification failed", Type: "unauthorized"}
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, &model.ApiError{Code: 401, Message: "passkey_clone_detected", Type: "unauthorized"}
+	}
+
+	// 🔑 Step 2: Move the signature counter forward
+	var stored *postgresCode.PasskeyCredential
+	for i := range rows {
+		if string(rows[i].CredentialID) == string(credential.ID) {
+			stored = &rows[i]
+			break
+		}
+	}
+	if stored == nil {
+		return nil, &model.ApiError{Code: 401, Message: "Passkey verification failed", Type: "unauthorized"}
+	}
+	n, err := gs.Queries.UpdatePasskeySignCount(ctx, postgresCode.UpdatePasskeySignCountParams{
+		ID:        stored.ID,
+		SignCount: int64(credential.Authenticator.SignCount),
+	})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if n == 0 {
+		return nil, &model.ApiError{Code: 401, Message: "passkey_clone_detected", Type: "unauthorized"}
+	}
+
+	// 🔑 Step 3: Create session
+	userId := uuid.UUID(user.WebAuthnID()).String()
+	account, err := gs.Appwrite.Users.Get(userId)
+	if err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return nil, &model.ApiError{Code: 401, Message: "Passkey verification failed", Type: "unauthorized"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: "Failed to query user: " + err.Error(), Type: "internal_server_error"}
+	}
+	appwriteSession, err := gs.Appwrite.Users.CreateSession(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to create session: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	return &model.SessionResponse{
+		UserId:        userId,
+		Name:          account.Name,
+		Email:         account.Email,
+		SessionID:     appwriteSession.Id,
+		SessionExpiry: appwriteSession.Expire,
+	}, nil
+}
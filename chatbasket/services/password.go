@@ -0,0 +1,21 @@
+package services
+
+import (
+	"chatbasket/utils"
+
+	"github.com/appwrite/sdk-for-go/models"
+)
+
+// accountPasswordPrefix is prepended to every account password before it is hashed or handed to
+// Appwrite. Existing hashes include it, so it cannot change without resetting every password.
+const accountPasswordPrefix = "00"
+
+// AccountPassword returns the form of password that is stored in Appwrite.
+func AccountPassword(password string) string {
+	return accountPasswordPrefix + password
+}
+
+// verifyAccountPassword reports whether password matches the user's stored Argon2 hash.
+func verifyAccountPassword(user *models.User, password string) (bool, error) {
+	return utils.VerifyOTP(AccountPassword(password), user.Password)
+}
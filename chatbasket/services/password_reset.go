@@ -0,0 +1,98 @@
+package services
+
+import (
+	"chatbasket/audit"
+	"chatbasket/model"
+	"chatbasket/otp"
+	"chatbasket/utils"
+	"context"
+	"log"
+
+	"github.com/appwrite/sdk-for-go/models"
+	"github.com/appwrite/sdk-for-go/query"
+)
+
+// passwordResetSent is the answer to every forgot-password request, so the endpoint cannot be
+// used to find out whether an email is registered.
+var passwordResetSent = &model.StatusOkay{Status: true, Message: "If the email is registered, a reset code has been sent"}
+
+// findUserByEmail returns the Appwrite user with the email, or nil when there is none.
+func (gs *GlobalService) findUserByEmail(email string) (*models.User, error) {
+	res, err := gs.Appwrite.Users.List(
+		gs.Appwrite.Users.WithListQueries([]string{
+			query.Equal("email", email),
+			query.Limit(1),
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if res.Total == 0 || len(res.Users) == 0 || res.Users[0].Email != email {
+		return nil, nil
+	}
+	return &res.Users[0], nil
+}
+
+// ForgotPassword emails a single-use reset code when the email is registered. Failures are only
+// logged: the response is the same whether or not a code was sent.
+func (gs *GlobalService) ForgotPassword(ctx context.Context, payload *model.ForgotPasswordPayload) (*model.StatusOkay, *model.ApiError) {
+	user, err := gs.findUserByEmail(payload.Email)
+	if err != nil {
+		log.Printf("forgot password: failed to query email: %v", err)
+		return passwordResetSent, nil
+	}
+	if user == nil {
+		return passwordResetSent, nil
+	}
+
+	if apiErr := gs.SendOtpEmail(ctx, user.Id, user.Email, otp.PurposePasswordReset, ""); apiErr != nil {
+		log.Printf("forgot password: failed to send reset code to %s: %s", user.Id, apiErr.Message)
+	}
+	return passwordResetSent, nil
+}
+
+// ResetPassword sets a new password with a code from ForgotPassword, signs the user out
+// everywhere and removes their passkeys. An unknown email fails exactly like a wrong code.
+func (gs *GlobalService) ResetPassword(ctx context.Context, payload *model.ResetPasswordPayload) (*model.StatusOkay, *model.ApiError) {
+	user, err := gs.findUserByEmail(payload.Email)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to query email: " + err.Error(), Type: "internal_server_error"}
+	}
+	if user == nil {
+		return nil, &model.ApiError{Code: 401, Message: "Invalid OTP", Type: "unauthorized"}
+	}
+
+	if apiErr := gs.verifyOtp(ctx, user.Id, otp.PurposePasswordReset, payload.Secret); apiErr != nil {
+		return nil, apiErr
+	}
+
+	_, err = gs.Appwrite.Users.UpdatePassword(user.Id, AccountPassword(payload.NewPassword))
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to update password: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	// Whoever had the old password must not stay signed in
+	if uuidUserId, err := utils.StringToUUID(user.Id); err == nil {
+		if _, err := gs.Queries.DeactivateUserTokens(ctx, uuidUserId); err != nil {
+			log.Printf("reset password: failed to revoke push tokens of %s: %v", user.Id, err)
+		}
+		if apiErr := gs.RevokeRefreshTokens(ctx, uuidUserId, ""); apiErr != nil {
+			log.Printf("reset password: failed to revoke refresh tokens of %s: %s", user.Id, apiErr.Message)
+		}
+		if apiErr := gs.RevokeApiKeys(ctx, uuidUserId); apiErr != nil {
+			log.Printf("reset password: failed to revoke API keys of %s: %s", user.Id, apiErr.Message)
+		}
+		// A passkey signs in without the password, so one planted by whoever had it goes too
+		if err := gs.Queries.DeleteUserPasskeyCredentials(ctx, uuidUserId); err != nil {
+			log.Printf("reset password: failed to delete passkeys of %s: %v", user.Id, err)
+		}
+	}
+	if _, err := gs.Appwrite.Users.DeleteSessions(user.Id); err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Password updated but failed to sign out sessions: " + err.Error(), Type: "internal_server_error"}
+	}
+	if uuidUserId, err := utils.StringToUUID(user.Id); err == nil {
+		gs.RecordUserAudit(ctx, uuidUserId, audit.ActionPasswordReset, audit.TargetUser, user.Id, nil)
+	}
+
+	return &model.StatusOkay{Status: true, Message: "Password reset successfully"}, nil
+}
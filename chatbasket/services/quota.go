@@ -0,0 +1,157 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Storage plans. A user without a storage_usage row is on StoragePlanFree.
+const (
+	StoragePlanFree = "free"
+	StoragePlanPlus = "plus"
+)
+
+// Object kinds recorded in storage_objects.
+const (
+	StorageKindAvatar     = "avatar"
+	StorageKindAttachment = "attachment"
+)
+
+// StoragePlanLimits is the number of bytes each plan may keep in storage.
+var StoragePlanLimits = map[string]int64{
+	StoragePlanFree: 50 << 20,
+	StoragePlanPlus: 2 << 30,
+}
+
+func storagePlanLimit(plan string) int64 {
+	if limit, ok := StoragePlanLimits[plan]; ok {
+		return limit
+	}
+	return StoragePlanLimits[StoragePlanFree]
+}
+
+func quotaExceededError() *model.ApiError {
+	return &model.ApiError{Code: 403, Message: "quota_exceeded", Type: "forbidden"}
+}
+
+// StorageHeadroom returns how many bytes ownerId may still store once the files in replacedIds
+// (which an upload is about to overwrite) are released. It only sizes a reservation; uploads
+// are admitted by ReserveStorage.
+func (gs *GlobalService) StorageHeadroom(ctx context.Context, ownerId uuid.UUID, bucketId string, replacedIds []string) (int64, *model.ApiError) {
+	row, err := gs.Queries.GetStorageQuotaHeadroom(ctx, postgresCode.GetStorageQuotaHeadroomParams{
+		OwnerID:  ownerId,
+		BucketID: bucketId,
+		FileIds:  replacedIds,
+	})
+	if err != nil {
+		return 0, &model.ApiError{Code: 500, Message: "Failed to check storage quota: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return max(storagePlanLimit(row.Plan)-row.UsedBytes+row.ReplacedBytes, 0), nil
+}
+
+// ReserveStorage adds incoming bytes to ownerId's usage ahead of an upload, or rejects it with
+// quota_exceeded when they would take ownerId over their plan limit once the files in
+// replacedIds are released. The check and the increment are one statement, so concurrent
+// uploads cannot overshoot the limit together. The reservation is settled by
+// RecordStoredObject or given back with ReleaseStorage.
+func (gs *GlobalService) ReserveStorage(ctx context.Context, ownerId uuid.UUID, bucketId string, incoming int64, replacedIds []string) *model.ApiError {
+	if err := gs.Queries.EnsureStorageUsage(ctx, ownerId); err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to check storage quota: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	plans := make([]string, 0, len(StoragePlanLimits))
+	limits := make([]int64, 0, len(StoragePlanLimits))
+	for plan, limit := range StoragePlanLimits {
+		plans = append(plans, plan)
+		limits = append(limits, limit)
+	}
+	_, err := gs.Queries.ReserveStorageBytes(ctx, postgresCode.ReserveStorageBytesParams{
+		Bytes:    incoming,
+		OwnerID:  ownerId,
+		BucketID: bucketId,
+		FileIds:  replacedIds,
+		Limits:   limits,
+		Plans:    plans,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return quotaExceededError()
+		}
+		return &model.ApiError{Code: 500, Message: "Failed to check storage quota: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return nil
+}
+
+// ReleaseStorage gives back bytes reserved by ReserveStorage for an upload that failed. It runs
+// even when ctx was cancelled by the client going away, and a failure is only logged: the
+// caller is already answering with the upload's error.
+func (gs *GlobalService) ReleaseStorage(ctx context.Context, ownerId uuid.UUID, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	err := gs.Queries.ReleaseStorageBytes(context.WithoutCancel(ctx), postgresCode.ReleaseStorageBytesParams{Bytes: bytes, OwnerID: ownerId})
+	if err != nil {
+		log.Printf("storage quota: failed to release %d bytes of %s: %v", bytes, ownerId, err)
+	}
+}
+
+// RecordStoredObject records the size of a stored file against ownerId, replacing any earlier
+// record for the same file, and settles the reserved bytes ReserveStorage added for it.
+func (gs *GlobalService) RecordStoredObject(ctx context.Context, ownerId uuid.UUID, bucketId string, fileId string, size, reserved int64, kind string) *model.ApiError {
+	_, err := gs.Queries.RecordStorageObject(ctx, postgresCode.RecordStorageObjectParams{
+		BucketID:      bucketId,
+		FileID:        fileId,
+		OwnerID:       ownerId,
+		SizeBytes:     size,
+		Kind:          kind,
+		ReservedBytes: reserved,
+	})
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to record storage usage: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return nil
+}
+
+// ForgetStoredObject releases a deleted file's bytes from its owner's usage. Files that were
+// never recorded are ignored.
+func (gs *GlobalService) ForgetStoredObject(ctx context.Context, bucketId string, fileId string) *model.ApiError {
+	_, err := gs.Queries.DeleteStorageObject(ctx, postgresCode.DeleteStorageObjectParams{
+		BucketID: bucketId,
+		FileID:   fileId,
+	})
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to release storage usage: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return nil
+}
+
+// GetStorageUsage returns ownerId's plan, limit and usage broken down by kind.
+func (gs *GlobalService) GetStorageUsage(ctx context.Context, ownerId uuid.UUID) (*model.StorageUsage, *model.ApiError) {
+	row, err := gs.Queries.GetStorageUsage(ctx, ownerId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to get storage usage: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	kinds, err := gs.Queries.GetStorageUsageByKind(ctx, ownerId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to get storage usage: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	usage := &model.StorageUsage{
+		Plan:        row.Plan,
+		UsedBytes:   row.UsedBytes,
+		LimitBytes:  storagePlanLimit(row.Plan),
+		ObjectCount: row.ObjectCount,
+		ByKind:      make([]model.StorageKindUsage, 0, len(kinds)),
+	}
+	for _, k := range kinds {
+		usage.ByKind = append(usage.ByKind, model.StorageKindUsage{Kind: k.Kind, Objects: k.Objects, Bytes: k.Bytes})
+	}
+	return usage, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"chatbasket/audit"
+	"chatbasket/db/postgresCode"
+	"chatbasket/mail"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxResolutionNoteLength = 1000
+
+// toAdminReport opens the sealed evidence; only admins ever see it in the clear.
+func (gs *GlobalService) toAdminReport(r postgresCode.Report) (model.AdminReport, *model.ApiError) {
+	evidence, err := utils.DecryptField(r.B64CipherEvidence, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeReportEvidence, r.ID.String())
+	if err != nil {
+		return model.AdminReport{}, &model.ApiError{Code: 500, Message: "Failed to decrypt report evidence: " + err.Error(), Type: "internal_server_error"}
+	}
+	report := model.AdminReport{
+		Id:             r.ID.String(),
+		ReporterUserId: r.ReporterUserID.String(),
+		TargetType:     r.TargetType,
+		TargetId:       r.TargetID,
+		Category:       r.Category,
+		Details:        r.Details,
+		Evidence:       json.RawMessage(evidence),
+		Status:         r.Status,
+		ResolutionNote: r.ResolutionNote,
+		CreatedAt:      r.CreatedAt.Time.UTC().Format(time.RFC3339),
+		// Messages are not stored on the server; their content comes from the reporter's client
+		ReporterSuppliedEvidence: r.TargetType == model.ReportTargetMessage,
+	}
+	if r.TargetUserID.Valid {
+		report.TargetUserId = uuid.UUID(r.TargetUserID.Bytes).String()
+	}
+	if r.HandledBy.Valid {
+		report.HandledBy = uuid.UUID(r.HandledBy.Bytes).String()
+	}
+	if r.ResolvedAt.Valid {
+		report.ResolvedAt = r.ResolvedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return report, nil
+}
+
+func reportNote(note string) (*string, *model.ApiError) {
+	note = strings.TrimSpace(note)
+	if len(note) > maxResolutionNoteLength {
+		return nil, &model.ApiError{Code: 400, Message: "The note is limited to 1000 characters", Type: "bad_request"}
+	}
+	return optionalString(note), nil
+}
+
+// AdminListReports returns the moderation queue, oldest first, with the number of open reports
+// against each reported user.
+func (gs *GlobalService) AdminListReports(ctx context.Context, f *model.AdminReportFilter) (*model.AdminReportList, *model.ApiError) {
+	params := postgresCode.ListReportQueueParams{Category: optionalString(f.Category)}
+	switch f.Status {
+	case "":
+		params.Status = optionalString(model.ReportStatusOpen)
+	case "all":
+	case model.ReportStatusOpen, model.ReportStatusInReview, model.ReportStatusActioned, model.ReportStatusDismissed:
+		params.Status = optionalString(f.Status)
+	default:
+		return nil, &model.ApiError{Code: 400, Message: "Invalid status", Type: "bad_request"}
+	}
+	var apiErr *model.ApiError
+	if params.TargetUserID, apiErr = optionalUUID(f.TargetUserId, "target_user_id"); apiErr != nil {
+		return nil, apiErr
+	}
+	if params.AfterID, apiErr = optionalUUID(f.After, "after"); apiErr != nil {
+		return nil, apiErr
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAdminPageLimit
+	}
+	limit = min(limit, maxAdminPageLimit)
+	params.MaxResults = int32(limit)
+
+	rows, err := gs.Queries.ListReportQueue(ctx, params)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	res := &model.AdminReportList{Reports: make([]model.AdminReport, 0, len(rows))}
+	for _, row := range rows {
+		r, apiErr := gs.toAdminReport(postgresCode.Report{
+			ID:                row.ID,
+			ReporterUserID:    row.ReporterUserID,
+			TargetType:        row.TargetType,
+			TargetUserID:      row.TargetUserID,
+			TargetID:          row.TargetID,
+			Category:          row.Category,
+			Details:           row.Details,
+			B64CipherEvidence: row.B64CipherEvidence,
+			Status:            row.Status,
+			ResolutionNote:    row.ResolutionNote,
+			HandledBy:         row.HandledBy,
+			ResolvedAt:        row.ResolvedAt,
+			CreatedAt:         row.CreatedAt,
+			UpdatedAt:         row.UpdatedAt,
+		})
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		r.OpenReportsOnTarget = row.OpenReportsOnTarget
+		res.Reports = append(res.Reports, r)
+	}
+	if len(rows) == limit {
+		res.NextAfter = rows[len(rows)-1].ID.String()
+	}
+	return res, nil
+}
+
+func (gs *GlobalService) AdminGetReport(ctx context.Context, reportId string) (*model.AdminReport, *model.ApiError) {
+	id, err := utils.StringToUUID(reportId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid report id", Type: "bad_request"}
+	}
+	r, err := gs.Queries.GetReport(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 404, Message: "Report not found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	report, apiErr := gs.toAdminReport(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &report, nil
+}
+
+// AdminStartReportReview claims an open report for adminId, so other admins can see it is being
+// handled.
+func (gs *GlobalService) AdminStartReportReview(ctx context.Context, adminId uuid.UUID, reportId string) (*model.AdminReport, *model.ApiError) {
+	id, err := utils.StringToUUID(reportId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid report id", Type: "bad_request"}
+	}
+	r, err := gs.Queries.StartReportReview(ctx, postgresCode.StartReportReviewParams{
+		HandledBy: pgtype.UUID{Bytes: adminId, Valid: true},
+		ID:        id,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, gs.reportNotInState(ctx, id, "open")
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	gs.RecordAudit(ctx, audit.Event{ActorId: adminId, ActorType: audit.ActorAdmin, Action: audit.ActionReportReviewStarted, TargetType: audit.TargetReport, TargetId: id.String()})
+
+	report, apiErr := gs.toAdminReport(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &report, nil
+}
+
+// AdminResolveReport closes an open or in-review report as actioned or dismissed and lets the
+// reporter know the outcome.
+func (gs *GlobalService) AdminResolveReport(ctx context.Context, adminId uuid.UUID, reportId string, payload *model.ResolveReportPayload) (*model.AdminReport, *model.ApiError) {
+	id, err := utils.StringToUUID(reportId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid report id", Type: "bad_request"}
+	}
+	if payload.Status != model.ReportStatusActioned && payload.Status != model.ReportStatusDismissed {
+		return nil, &model.ApiError{Code: 400, Message: "status must be actioned or dismissed", Type: "bad_request"}
+	}
+	note, apiErr := reportNote(payload.Note)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	r, err := gs.Queries.ResolveReport(ctx, postgresCode.ResolveReportParams{
+		Status:         payload.Status,
+		ResolutionNote: note,
+		HandledBy:      pgtype.UUID{Bytes: adminId, Valid: true},
+		ID:             id,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, gs.reportNotInState(ctx, id, "open or in review")
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	gs.RecordAudit(ctx, audit.Event{
+		ActorId:    adminId,
+		ActorType:  audit.ActorAdmin,
+		Action:     audit.ActionReportResolved,
+		TargetType: audit.TargetReport,
+		TargetId:   id.String(),
+		Metadata:   map[string]any{"status": r.Status},
+	})
+	gs.notifyReporter(ctx, r)
+
+	report, apiErr := gs.toAdminReport(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &report, nil
+}
+
+// AdminEscalateReport admin-blocks the reported user and closes every open report against them
+// as actioned, notifying each reporter.
+func (gs *GlobalService) AdminEscalateReport(ctx context.Context, adminId uuid.UUID, reportId string, payload *model.EscalateReportPayload) (*model.AdminReportEscalation, *model.ApiError) {
+	id, err := utils.StringToUUID(reportId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 400, Message: "Invalid report id", Type: "bad_request"}
+	}
+	note, apiErr := reportNote(payload.Note)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	r, err := gs.Queries.GetReport(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 404, Message: "Report not found", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if r.Status != model.ReportStatusOpen && r.Status != model.ReportStatusInReview {
+		return nil, &model.ApiError{Code: 409, Message: "Report is already " + r.Status, Type: "conflict"}
+	}
+	if !r.TargetUserID.Valid {
+		return nil, &model.ApiError{Code: 409, Message: "The reported user deleted their account; resolve the report instead", Type: "conflict"}
+	}
+
+	user, apiErr := gs.AdminSetUserBlock(ctx, adminId, uuid.UUID(r.TargetUserID.Bytes).String(), true, payload.Reason)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	closed, err := gs.Queries.ResolveOpenReportsOnUser(ctx, postgresCode.ResolveOpenReportsOnUserParams{
+		Status:         model.ReportStatusActioned,
+		ResolutionNote: note,
+		HandledBy:      pgtype.UUID{Bytes: adminId, Valid: true},
+		TargetUserID:   r.TargetUserID,
+	})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	res := &model.AdminReportEscalation{User: *user, Reports: make([]model.AdminReport, 0, len(closed))}
+	for _, c := range closed {
+		gs.RecordAudit(ctx, audit.Event{
+			ActorId:    adminId,
+			ActorType:  audit.ActorAdmin,
+			Action:     audit.ActionReportResolved,
+			TargetType: audit.TargetReport,
+			TargetId:   c.ID.String(),
+			Metadata:   map[string]any{"status": c.Status, "escalated_from": id.String()},
+		})
+		gs.notifyReporter(ctx, c)
+		report, apiErr := gs.toAdminReport(c)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		res.Reports = append(res.Reports, report)
+	}
+	return res, nil
+}
+
+// reportNotInState explains why a report could not move on: it is missing or already past state.
+func (gs *GlobalService) reportNotInState(ctx context.Context, id uuid.UUID, state string) *model.ApiError {
+	r, err := gs.Queries.GetReport(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &model.ApiError{Code: 404, Message: "Report not found", Type: "not_found"}
+		}
+		return &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &model.ApiError{Code: 409, Message: "Report is " + r.Status + ", not " + state, Type: "conflict"}
+}
+
+// notifyReporter emails the reporter the outcome of their report. The report is already closed,
+// so a failure is logged rather than returned.
+func (gs *GlobalService) notifyReporter(ctx context.Context, r postgresCode.Report) {
+	reporterId := r.ReporterUserID.String()
+	reporter, err := gs.Appwrite.Users.Get(reporterId)
+	if err != nil {
+		log.Printf("reports: failed to look up reporter of %s: %v", r.ID, err)
+		return
+	}
+	if reporter.Email == "" {
+		return
+	}
+	data := mail.ReportOutcomeData{Actioned: r.Status == model.ReportStatusActioned, FiledAt: r.CreatedAt.Time}
+	if apiErr := gs.SendTemplatedEmail(ctx, reporterId, reporter.Email, mail.TemplateReportOutcome, data); apiErr != nil {
+		log.Printf("reports: failed to notify reporter of %s: %s", r.ID, apiErr.Message)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// AccessTokenTTL is how long an access token is accepted by the session middleware.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a refresh token can be used; every refresh starts it again,
+	// but never past the expiry of the Appwrite session.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// RefreshTokenRetryGrace is how long a rotated refresh token can be presented again while
+	// the pair issued for it is unused, e.g. when the client never received it.
+	RefreshTokenRetryGrace = time.Minute
+)
+
+// IssueSessionTokens starts a refresh token family for a new session and attaches the first
+// access and refresh token pair to the response.
+func (gs *GlobalService) IssueSessionTokens(ctx context.Context, user *model.SessionResponse) *model.ApiError {
+	uuidUserId, err := utils.StringToUUID(user.UserId)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
+	}
+	sessionExpiry, err := time.Parse(time.RFC3339, user.SessionExpiry)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Invalid session expiry: " + err.Error(), Type: "internal_server_error"}
+	}
+	familyId, err := uuid.NewV7()
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to generate UUID: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	_, tokens, apiErr := gs.issueTokenPair(ctx, gs.Queries, familyId, uuidUserId, user.SessionID, user.Email, sessionExpiry)
+	if apiErr != nil {
+		return apiErr
+	}
+	user.SessionTokens = tokens
+	return nil
+}
+
+// RefreshSessionTokens exchanges a refresh token for a new pair. A token that was already
+// rotated means it leaked: the whole family and its session are revoked. The exception is a
+// retry within RefreshTokenRetryGrace whose new pair was never used, e.g. after the response
+// was lost; it gets another pair in place of the unused one.
+func (gs *GlobalService) RefreshSessionTokens(ctx context.Context, refreshToken string) (*model.SessionTokens, *model.ApiError) {
+	invalid := &model.ApiError{Code: 401, Message: "Invalid refresh token", Type: "refresh_token_invalid"}
+
+	token, err := gs.Queries.GetRefreshTokenByHash(ctx, utils.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalid
+		}
+		return nil, &model.ApiError{Code: 500, Message: "Failed to load refresh token: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if token.RevokedAt.Valid || !token.ExpiresAt.Time.After(time.Now()) {
+		return nil, invalid
+	}
+	if token.RotatedAt.Valid && (!token.ReplacedBy.Valid || time.Since(token.RotatedAt.Time) > RefreshTokenRetryGrace) {
+		return nil, gs.rejectReusedRefreshToken(ctx, token)
+	}
+
+	userId := token.UserID.String()
+	sessionId, err := utils.DecryptField(token.B64CipherSessionID, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeRefreshSession, userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to decrypt session: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	// The Appwrite session is the source of truth: a family ends with its session. It is checked
+	// before the token is rotated, so an Appwrite failure leaves the token usable for a retry.
+	sessions, err := gs.Appwrite.Users.ListSessions(userId)
+	if err != nil && !utils.IsAppwriteNotFound(err) {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to list sessions: " + err.Error(), Type: "internal_server_error"}
+	}
+	var sessionExpiry string
+	if err == nil {
+		for _, s := range sessions.Sessions {
+			if s.Id == sessionId {
+				sessionExpiry = s.Expire
+				break
+			}
+		}
+	}
+	expiry, err := time.Parse(time.RFC3339, sessionExpiry)
+	if err != nil || !expiry.After(time.Now()) {
+		if _, err := gs.Queries.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+			log.Printf("refresh token: failed to revoke family %s: %v", token.FamilyID, err)
+		}
+		return nil, &model.ApiError{Code: 401, Message: "Session has ended", Type: "session_invalid"}
+	}
+
+	user, err := gs.Appwrite.Users.Get(userId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to load user: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	// Store the new pair and rotate the token together, so a failure leaves the old token as it was
+	tx, err := gs.DB.Begin(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	defer tx.Rollback(ctx)
+	q := gs.Queries.WithTx(tx)
+
+	successorId, tokens, apiErr := gs.issueTokenPair(ctx, q, token.FamilyID, token.UserID, sessionId, user.Email, expiry)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if token.ReplacedBy.Valid {
+		n, err := q.RevokeUnusedRefreshToken(ctx, uuid.UUID(token.ReplacedBy.Bytes))
+		if err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Failed to revoke refresh token: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		if n == 0 {
+			// The pair handed out on the first rotation has been used since
+			tx.Rollback(ctx)
+			return nil, gs.rejectReusedRefreshToken(ctx, token)
+		}
+	}
+	n, err := q.RotateRefreshToken(ctx, postgresCode.RotateRefreshTokenParams{
+		ReplacedBy:     pgtype.UUID{Bytes: successorId, Valid: true},
+		ID:             token.ID,
+		PriorSuccessor: token.ReplacedBy,
+	})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to rotate refresh token: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if n == 0 {
+		return nil, invalid // rotated, revoked or expired by a concurrent request
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return tokens, nil
+}
+
+// rejectReusedRefreshToken answers the reuse of a rotated token: its family is revoked and the
+// session signed out.
+func (gs *GlobalService) rejectReusedRefreshToken(ctx context.Context, token postgresCode.RefreshToken) *model.ApiError {
+	log.Printf("refresh token: reuse detected for user %s, revoking family %s", token.UserID, token.FamilyID)
+	if _, err := gs.Queries.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to revoke refresh tokens: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	userId := token.UserID.String()
+	sessionId, err := utils.DecryptField(token.B64CipherSessionID, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeRefreshSession, userId)
+	if err == nil {
+		_, err = gs.Appwrite.Users.DeleteSession(userId, sessionId)
+	}
+	if err != nil && !utils.IsAppwriteNotFound(err) {
+		log.Printf("refresh token: failed to end session of reused family %s: %v", token.FamilyID, err)
+	}
+	return &model.ApiError{Code: 401, Message: "Refresh token was already used", Type: "refresh_token_reused"}
+}
+
+// issueTokenPair stores a new refresh token in the family with queries and signs a matching
+// access token. Neither outlives the session. It returns the id of the new refresh token.
+func (gs *GlobalService) issueTokenPair(ctx context.Context, queries *postgresCode.Queries, familyId, userId uuid.UUID, sessionId, email string, sessionExpiry time.Time) (uuid.UUID, *model.SessionTokens, *model.ApiError) {
+	now := time.Now()
+	accessExpiry := now.Add(AccessTokenTTL)
+	if accessExpiry.After(sessionExpiry) {
+		accessExpiry = sessionExpiry
+	}
+	refreshExpiry := now.Add(RefreshTokenTTL)
+	if refreshExpiry.After(sessionExpiry) {
+		refreshExpiry = sessionExpiry
+	}
+
+	accessToken, err := utils.SignAccessToken(userId.String(), sessionId, email, accessExpiry, gs.Auth.AccessTokenSigningKey)
+	if err != nil {
+		return uuid.Nil, nil, &model.ApiError{Code: 500, Message: "Failed to sign access token: " + err.Error(), Type: "internal_server_error"}
+	}
+	sealedSessionId, err := utils.EncryptField(sessionId, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeRefreshSession, userId.String())
+	if err != nil {
+		return uuid.Nil, nil, &model.ApiError{Code: 500, Message: "Failed to encrypt session: " + err.Error(), Type: "internal_server_error"}
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.Nil, nil, &model.ApiError{Code: 500, Message: "Failed to generate UUID: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	refreshToken, hash := utils.GenerateRefreshToken()
+	err = queries.CreateRefreshToken(ctx, postgresCode.CreateRefreshTokenParams{
+		ID:                 id,
+		FamilyID:           familyId,
+		UserID:             userId,
+		Sha256HexSessionID: utils.HashSessionID(sessionId),
+		B64CipherSessionID: sealedSessionId,
+		TokenHash:          hash,
+		ExpiresAt:          pgtype.Timestamptz{Time: refreshExpiry, Valid: true},
+	})
+	if err != nil {
+		return uuid.Nil, nil, &model.ApiError{Code: 500, Message: "Failed to store refresh token: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	return id, &model.SessionTokens{
+		AccessToken:        accessToken,
+		AccessTokenExpiry:  accessExpiry.UTC().Format(time.RFC3339),
+		RefreshToken:       refreshToken,
+		RefreshTokenExpiry: refreshExpiry.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// AccessTokenSessionActive reports whether the session an access token was issued for is still
+// signed in. Every sign-out revokes the session's refresh tokens, so a session without a live one
+// has ended even though its access tokens have not expired yet.
+func (gs *GlobalService) AccessTokenSessionActive(ctx context.Context, userId uuid.UUID, sessionId string) (bool, error) {
+	return gs.Queries.IsSessionRefreshTokenLive(ctx, postgresCode.IsSessionRefreshTokenLiveParams{
+		UserID:             userId,
+		Sha256HexSessionID: utils.HashSessionID(sessionId),
+	})
+}
+
+// RevokeRefreshTokens revokes the refresh tokens of one of the user's sessions, or of all of
+// them when sessionId is empty. It is called wherever a session is signed out.
+func (gs *GlobalService) RevokeRefreshTokens(ctx context.Context, userId uuid.UUID, sessionId string) *model.ApiError {
+	var err error
+	if sessionId == "" {
+		_, err = gs.Queries.RevokeUserRefreshTokens(ctx, userId)
+	} else {
+		_, err = gs.Queries.RevokeSessionRefreshTokens(ctx, postgresCode.RevokeSessionRefreshTokensParams{
+			UserID:             userId,
+			Sha256HexSessionID: utils.HashSessionID(sessionId),
+		})
+	}
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to revoke refresh tokens: " + utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return nil
+}
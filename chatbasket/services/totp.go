@@ -0,0 +1,234 @@
+package services
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/model"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// totpIssuer is the account label shown in authenticator apps.
+const totpIssuer = "ChatBasket"
+
+// totpRecoveryCodeCount is how many recovery codes are issued when TOTP is activated.
+const totpRecoveryCodeCount = 10
+
+// EnrollTotp starts a TOTP enrolment, replacing any unfinished one. TOTP is not enforced until
+// ActivateTotp confirms that the user's app produces valid codes.
+func (gs *GlobalService) EnrollTotp(ctx context.Context, userId model.UserId, email string) (*model.TotpEnrollment, *model.ApiError) {
+	secret, uri, err := utils.GenerateTOTPKey(totpIssuer, email)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to generate TOTP secret: " + err.Error(), Type: "internal_server_error"}
+	}
+	sealed, err := utils.EncryptField(secret, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeTotpSecret, userId.StringUserId)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to encrypt TOTP secret", Type: "internal_server_error"}
+	}
+
+	_, err = gs.Queries.UpsertPendingTotpFactor(ctx, postgresCode.UpsertPendingTotpFactorParams{
+		UserID: userId.UuidUserId,
+		Secret: sealed,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 409, Message: "totp_already_enabled", Type: "conflict"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+
+	return &model.TotpEnrollment{Secret: secret, OtpauthUri: uri}, nil
+}
+
+// ActivateTotp enables a pending enrolment with a code from the app and issues recovery codes.
+func (gs *GlobalService) ActivateTotp(ctx context.Context, userId model.UserId, code string) (*model.TotpRecoveryCodes, *model.ApiError) {
+	factor, apiErr := gs.getTotpFactor(ctx, userId.UuidUserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if factor.EnabledAt.Valid {
+		return nil, &model.ApiError{Code: 409, Message: "totp_already_enabled", Type: "conflict"}
+	}
+
+	step, apiErr := gs.matchTotpCode(factor, code)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	// Enable and issue the recovery codes together so TOTP is never on without them
+	tx, err := gs.DB.Begin(ctx)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	defer tx.Rollback(ctx)
+	q := gs.Queries.WithTx(tx)
+
+	n, err := q.EnableTotpFactor(ctx, postgresCode.EnableTotpFactorParams{UserID: userId.UuidUserId, Step: step})
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if n == 0 {
+		return nil, &model.ApiError{Code: 409, Message: "totp_already_enabled", Type: "conflict"}
+	}
+	codes, apiErr := createTotpRecoveryCodes(ctx, q, userId.UuidUserId)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &model.TotpRecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// DisableTotp turns TOTP off after re-authenticating with the password and a second factor.
+func (gs *GlobalService) DisableTotp(ctx context.Context, userId model.UserId, payload *model.DisableTotpPayload) (*model.StatusOkay, *model.ApiError) {
+	enabled, apiErr := gs.reauthenticate(ctx, userId, payload.Password, payload.Code, payload.RecoveryCode)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !enabled {
+		return nil, &model.ApiError{Code: 404, Message: "totp_not_enabled", Type: "not_found"}
+	}
+
+	if err := gs.Queries.DeleteTotpFactor(ctx, userId.UuidUserId); err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &model.StatusOkay{Status: true, Message: "Two-factor authentication disabled"}, nil
+}
+
+// reauthenticate asks a signed-in user for the password and, when TOTP is enabled, a second
+// factor before a sensitive change, so a stolen session alone is not enough. It reports whether
+// TOTP is enabled.
+func (gs *GlobalService) reauthenticate(ctx context.Context, userId model.UserId, password, code, recoveryCode string) (bool, *model.ApiError) {
+	user, err := gs.Appwrite.Users.Get(userId.StringUserId)
+	if err != nil {
+		return false, &model.ApiError{Code: 500, Message: "Failed to get user: " + err.Error(), Type: "internal_server_error"}
+	}
+	match, err := verifyAccountPassword(user, password)
+	if err != nil {
+		return false, &model.ApiError{Code: 500, Message: "Failed to verify password: " + err.Error(), Type: "internal_server_error"}
+	}
+	if !match {
+		return false, &model.ApiError{Code: 401, Message: "Invalid credentials", Type: "unauthorized"}
+	}
+	return gs.CheckSecondFactor(ctx, userId.UuidUserId, code, recoveryCode)
+}
+
+// CheckSecondFactor verifies a TOTP code or, failing that, a recovery code when the user has
+// TOTP enabled. It reports whether TOTP is enabled; a user without it passes with no code.
+func (gs *GlobalService) CheckSecondFactor(ctx context.Context, userId uuid.UUID, code, recoveryCode string) (bool, *model.ApiError) {
+	factor, err := gs.Queries.GetTotpFactor(ctx, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	if !factor.EnabledAt.Valid {
+		return false, nil
+	}
+
+	switch {
+	case code != "":
+		step, apiErr := gs.matchTotpCode(&factor, code)
+		if apiErr != nil {
+			return true, apiErr
+		}
+		n, err := gs.Queries.UseTotpStep(ctx, postgresCode.UseTotpStepParams{UserID: userId, Step: step})
+		if err != nil {
+			return true, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		if n == 0 {
+			// The code was already used
+			return true, &model.ApiError{Code: 401, Message: "invalid_totp", Type: "unauthorized"}
+		}
+		return true, nil
+	case recoveryCode != "":
+		return true, gs.useTotpRecoveryCode(ctx, userId, recoveryCode)
+	default:
+		return true, &model.ApiError{Code: 401, Message: "totp_required", Type: "unauthorized"}
+	}
+}
+
+func (gs *GlobalService) getTotpFactor(ctx context.Context, userId uuid.UUID) (*postgresCode.TotpFactor, *model.ApiError) {
+	factor, err := gs.Queries.GetTotpFactor(ctx, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &model.ApiError{Code: 404, Message: "totp_not_enrolled", Type: "not_found"}
+		}
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	return &factor, nil
+}
+
+// matchTotpCode checks code against the factor's secret and returns its time step.
+func (gs *GlobalService) matchTotpCode(factor *postgresCode.TotpFactor, code string) (int64, *model.ApiError) {
+	secret, err := utils.DecryptField(factor.Secret, gs.Appwrite.PersonalFieldKey, utils.FieldPurposeTotpSecret, factor.UserID.String())
+	if err != nil {
+		return 0, &model.ApiError{Code: 500, Message: "Failed to decrypt TOTP secret", Type: "internal_server_error"}
+	}
+	step, ok, err := utils.ValidateTOTP(code, secret, time.Now())
+	if err != nil {
+		return 0, &model.ApiError{Code: 500, Message: "Failed to verify TOTP: " + err.Error(), Type: "internal_server_error"}
+	}
+	if !ok {
+		return 0, &model.ApiError{Code: 401, Message: "invalid_totp", Type: "unauthorized"}
+	}
+	return step, nil
+}
+
+// useTotpRecoveryCode burns the unused recovery code that matches code.
+func (gs *GlobalService) useTotpRecoveryCode(ctx context.Context, userId uuid.UUID, code string) *model.ApiError {
+	rows, err := gs.Queries.ListUnusedTotpRecoveryCodes(ctx, userId)
+	if err != nil {
+		return &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	normalized := utils.NormalizeRecoveryCode(code)
+	for _, row := range rows {
+		match, err := utils.VerifyOTP(normalized, row.CodeHash)
+		if err != nil {
+			return &model.ApiError{Code: 500, Message: "Failed to verify recovery code: " + err.Error(), Type: "internal_server_error"}
+		}
+		if !match {
+			continue
+		}
+		n, err := gs.Queries.UseTotpRecoveryCode(ctx, row.ID)
+		if err != nil {
+			return &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+		if n == 0 {
+			break // used concurrently
+		}
+		return nil
+	}
+	return &model.ApiError{Code: 401, Message: "invalid_recovery_code", Type: "unauthorized"}
+}
+
+// createTotpRecoveryCodes replaces the user's recovery codes and returns the new plain codes.
+func createTotpRecoveryCodes(ctx context.Context, q *postgresCode.Queries, userId uuid.UUID) ([]string, *model.ApiError) {
+	codes, err := utils.GenerateRecoveryCodes(totpRecoveryCodeCount)
+	if err != nil {
+		return nil, &model.ApiError{Code: 500, Message: "Failed to generate recovery codes: " + err.Error(), Type: "internal_server_error"}
+	}
+
+	if err := q.DeleteTotpRecoveryCodes(ctx, userId); err != nil {
+		return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+	}
+	for _, code := range codes {
+		hash, err := utils.HashOTP(utils.NormalizeRecoveryCode(code))
+		if err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Failed to hash recovery code: " + err.Error(), Type: "internal_server_error"}
+		}
+		codeId, err := uuid.NewV7()
+		if err != nil {
+			return nil, &model.ApiError{Code: 500, Message: "Failed to generate uuid", Type: "internal_server_error"}
+		}
+		if err := q.CreateTotpRecoveryCode(ctx, postgresCode.CreateTotpRecoveryCodeParams{ID: codeId, UserID: userId, CodeHash: hash}); err != nil {
+			return nil, &model.ApiError{Code: 500, Message: utils.GetPostgresError(err).Message, Type: "internal_server_error"}
+		}
+	}
+	return codes, nil
+}
@@ -1,21 +1,40 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"chatbasket/model"
+	"chatbasket/storage"
 	"chatbasket/utils"
-	"mime/multipart"
-	"os"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
 	"time"
 
-	"github.com/appwrite/sdk-for-go/query"
+	"github.com/google/uuid"
 )
 
+// FileTokenTTL is the lifetime of storage grants (Appwrite file tokens) minted for stored files.
+// Tokens are only used server-side by the media proxy and are renewed before they expire.
+const FileTokenTTL = 7 * 24 * time.Hour
+
 // UploadOptions controls optional behaviors for file upload.
 type UploadOptions struct {
-	// DeleteExisting will check if a file with the same ID exists and delete it before upload.
+	// DeleteExisting will revoke the grants of and delete an existing file with the same ID before upload.
 	DeleteExisting bool
-	// GenerateTokens will create a personal file token after upload.
+	// GenerateTokens will create a storage grant after upload.
 	GenerateTokens bool
+	// MaxBytes rejects bodies larger than this many bytes; 0 means no limit.
+	MaxBytes int64
+	// AllowedTypes restricts the content type sniffed from the first bytes; empty allows any.
+	AllowedTypes []string
+	// OwnerID, when set, enforces the owner's storage quota and records the stored size
+	// against them under Kind.
+	OwnerID uuid.UUID
+	Kind    string
 }
 
 // UploadResult contains the outcome of an upload.
@@ -27,81 +46,236 @@ type UploadResult struct {
 	TokenSecrets []string // [personalTokenSecret]
 }
 
-// UploadFileFromMultipart uploads a file to Appwrite Storage from a multipart.FileHeader.
-// It will handle temporary file creation/cleanup and (optionally) delete existing files
-// and create file access tokens.
-func (gs *GlobalService) UploadFileFromMultipart(
+// UploadFile streams body to storage, validating its sniffed content type and size on the way,
+// and (optionally) deletes an existing file and creates a file access grant.
+func (gs *GlobalService) UploadFile(
+	ctx context.Context,
 	bucketId string,
 	fileId string,
-	fh *multipart.FileHeader,
+	name string,
+	body io.Reader,
 	opts UploadOptions,
 ) (*UploadResult, *model.ApiError) {
-	inputFile, apiErr := utils.ConvertToInputFile(fh)
-	if apiErr != nil {
-		return nil, apiErr
+	br := bufio.NewReaderSize(body, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, utils.MultipartReadError(err)
+	}
+	if len(opts.AllowedTypes) > 0 && !slices.Contains(opts.AllowedTypes, http.DetectContentType(head)) {
+		return nil, &model.ApiError{Code: 415, Message: "unsupported_media_type", Type: "unsupported_media_type"}
 	}
 
-	// Clean up temp file after upload
-	defer func() {
-		if inputFile.Path != "" {
-			os.Remove(inputFile.Path)
+	// The size is unknown up front, so the quota caps the body like MaxBytes does. The whole cap
+	// is reserved and the unused part given back once the file is recorded.
+	limit := opts.MaxBytes
+	quotaCapped := false
+	var reserved int64
+	if opts.OwnerID != uuid.Nil {
+		headroom, apiErr := gs.StorageHeadroom(ctx, opts.OwnerID, bucketId, []string{fileId})
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		if headroom == 0 {
+			return nil, quotaExceededError()
+		}
+		if limit <= 0 || headroom < limit {
+			limit = headroom
+			quotaCapped = true
 		}
-	}()
+		if apiErr := gs.ReserveStorage(ctx, opts.OwnerID, bucketId, limit, []string{fileId}); apiErr != nil {
+			return nil, apiErr
+		}
+		reserved = limit
+	}
 
-	// Optionally delete existing file with same ID
-	if opts.DeleteExisting {
+	var reader io.Reader = br
+	if limit > 0 {
+		reader = utils.LimitUpload(br, limit)
+	}
+	res, apiErr := gs.putFile(ctx, bucketId, fileId, name, reader, -1, reserved, opts)
+	if apiErr != nil && apiErr.Code == 413 && quotaCapped {
+		return nil, quotaExceededError()
+	}
+	return res, apiErr
+}
 
-		// delete file tokens
-		tok, err := gs.Appwrite.Tokens.List(bucketId, fileId)
-		if err != nil {
-			return nil, &model.ApiError{Code: 500, Message: "Failed to list tokens: " + err.Error(), Type: "internal_server_error"}
-		}
-		if tok.Total > 0 {
-			for _, tokens := range tok.Tokens {
-				_, err := gs.Appwrite.Tokens.Delete(tokens.Id)
-				if err != nil {
-					return nil, &model.ApiError{Code: 500, Message: "Failed to delete token: " + err.Error(), Type: "internal_server_error"}
-				}
-			}
+// putFile performs the storage side of an upload: optional replacement of an existing
+// file (and its grants), the upload itself and optional grant creation. reserved is the
+// number of bytes ReserveStorage set aside for the file when opts has an owner; they are
+// settled when the file is recorded and given back if it is not.
+func (gs *GlobalService) putFile(
+	ctx context.Context,
+	bucketId string,
+	fileId string,
+	name string,
+	body io.Reader,
+	size int64,
+	reserved int64,
+	opts UploadOptions,
+) (*UploadResult, *model.ApiError) {
+	fail := func(apiErr *model.ApiError) (*UploadResult, *model.ApiError) {
+		if opts.OwnerID != uuid.Nil {
+			gs.ReleaseStorage(ctx, opts.OwnerID, reserved)
 		}
+		return nil, apiErr
+	}
 
-		// delete file
-		listFilesRes, err := gs.Appwrite.Storage.ListFiles(
-			bucketId,
-			gs.Appwrite.Storage.WithListFilesQueries([]string{
-				query.Equal("$id", fileId),
-			}),
-		)
-		if err != nil {
-			return nil, &model.ApiError{Code: 500, Message: "Failed to list existing file: " + err.Error(), Type: "internal_server_error"}
+	// Optionally delete existing file with same ID
+	if opts.DeleteExisting {
+		if err := gs.Storage.RevokeSignedURLs(ctx, bucketId, fileId); err != nil {
+			return fail(&model.ApiError{Code: 500, Message: "Failed to delete token: " + err.Error(), Type: "internal_server_error"})
 		}
-		if listFilesRes.Total == 1 {
-			if _, err := gs.Appwrite.Storage.DeleteFile(bucketId, fileId); err != nil {
-				return nil, &model.ApiError{Code: 500, Message: "Failed to delete existing file: " + err.Error(), Type: "internal_server_error"}
-			}
+		if err := gs.Storage.Delete(ctx, bucketId, fileId); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fail(&model.ApiError{Code: 500, Message: "Failed to delete existing file: " + err.Error(), Type: "internal_server_error"})
+		}
+		if apiErr := gs.ForgetStoredObject(ctx, bucketId, fileId); apiErr != nil {
+			return fail(apiErr)
 		}
 	}
 
-	uploadRes, err := gs.Appwrite.Storage.CreateFile(bucketId, fileId, inputFile)
+	obj, err := gs.Storage.Put(ctx, bucketId, fileId, name, body, size)
 	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to upload file: " + err.Error(), Type: "internal_server_error"}
+		if errors.Is(err, utils.ErrUploadTooLarge) {
+			return fail(utils.MultipartReadError(err))
+		}
+		return fail(&model.ApiError{Code: 500, Message: "Failed to upload file: " + err.Error(), Type: "internal_server_error"})
+	}
+
+	if opts.OwnerID != uuid.Nil {
+		if apiErr := gs.RecordStoredObject(ctx, opts.OwnerID, bucketId, obj.Key, obj.Size, reserved, opts.Kind); apiErr != nil {
+			return fail(apiErr)
+		}
 	}
 
 	result := &UploadResult{
-		FileId: uploadRes.Id,
-		Name:   uploadRes.Name,
+		FileId: obj.Key,
+		Name:   obj.Name,
 	}
 
 	if opts.GenerateTokens {
-		exp := time.Now().AddDate(1, 0, 0).Format("2006-01-02T15:04:05.000Z")
-		personalToken, err := gs.Appwrite.Tokens.CreateFileToken(bucketId, fileId, gs.Appwrite.Tokens.WithCreateFileTokenExpire(exp))
+		grant, err := gs.Storage.SignedURL(ctx, bucketId, fileId, FileTokenTTL)
 		if err != nil {
 			return nil, &model.ApiError{Code: 500, Message: "Failed to create personal token: " + err.Error(), Type: "internal_server_error"}
 		}
-		result.TokenIDs = []string{personalToken.Id}
-		result.TokenSecrets = []string{personalToken.Secret}
-		result.Expire = personalToken.Expire
+		result.TokenIDs = []string{grant.ID}
+		result.TokenSecrets = []string{grant.Token}
+		result.Expire = grant.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return result, nil
+}
+
+// UploadAvatarImage runs an uploaded image through utils.ProcessAvatarImage and stores every
+// variant. The type is sniffed before the rest of the body is read and the body is capped at
+// utils.AvatarMaxUploadBytes. The largest variant is stored under fileId and receives the tokens
+// requested in opts; smaller variants are stored under utils.AvatarVariantFileID. The result
+// describes the main file.
+func (gs *GlobalService) UploadAvatarImage(
+	ctx context.Context,
+	bucketId string,
+	fileId string,
+	body io.Reader,
+	opts UploadOptions,
+) (*UploadResult, *model.ApiError) {
+	br := bufio.NewReaderSize(body, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, utils.MultipartReadError(err)
+	}
+	if _, err := utils.SniffImageType(head); err != nil {
+		return nil, &model.ApiError{Code: 415, Message: "unsupported_image_type", Type: "unsupported_media_type"}
+	}
+
+	data, err := io.ReadAll(utils.LimitUpload(br, utils.AvatarMaxUploadBytes))
+	if err != nil {
+		return nil, utils.MultipartReadError(err)
+	}
+
+	variants, err := utils.ProcessAvatarImage(data)
+	if err != nil {
+		switch err {
+		case utils.ErrUnsupportedImageType:
+			return nil, &model.ApiError{Code: 415, Message: "unsupported_image_type", Type: "unsupported_media_type"}
+		case utils.ErrImageTooLarge:
+			return nil, &model.ApiError{Code: 400, Message: "image_too_large", Type: "bad_request"}
+		case utils.ErrInvalidImage:
+			return nil, &model.ApiError{Code: 400, Message: "invalid_image", Type: "bad_request"}
+		default:
+			return nil, &model.ApiError{Code: 500, Message: "Failed to process image: " + err.Error(), Type: "internal_server_error"}
+		}
+	}
+
+	// Every variant replaces the one stored under the same ID
+	var incoming int64
+	for _, size := range utils.AvatarVariantSizes {
+		incoming += int64(len(variants[size]))
+	}
+	if opts.OwnerID != uuid.Nil {
+		replaced := make([]string, 0, len(utils.AvatarVariantSizes))
+		for _, size := range utils.AvatarVariantSizes {
+			replaced = append(replaced, utils.AvatarVariantFileID(fileId, size))
+		}
+		if apiErr := gs.ReserveStorage(ctx, opts.OwnerID, bucketId, incoming, replaced); apiErr != nil {
+			return nil, apiErr
+		}
 	}
 
+	var result *UploadResult
+	for _, size := range utils.AvatarVariantSizes {
+		// Small variants are always replaced; a leftover from an earlier avatar would block the upload
+		variantOpts := UploadOptions{DeleteExisting: true, OwnerID: opts.OwnerID, Kind: opts.Kind}
+		if size == utils.AvatarVariantMaxSize() {
+			variantOpts = opts
+		}
+
+		// putFile settles or releases this variant's share of the reservation
+		name := "avatar_" + strconv.Itoa(size) + ".jpg"
+		variantSize := int64(len(variants[size]))
+		incoming -= variantSize
+		res, apiErr := gs.putFile(ctx, bucketId, utils.AvatarVariantFileID(fileId, size), name, bytes.NewReader(variants[size]), variantSize, variantSize, variantOpts)
+		if apiErr != nil {
+			if opts.OwnerID != uuid.Nil {
+				gs.ReleaseStorage(ctx, opts.OwnerID, incoming)
+			}
+			return nil, apiErr
+		}
+		if size == utils.AvatarVariantMaxSize() {
+			result = res
+		}
+	}
 	return result, nil
 }
+
+// DeleteAvatarFiles revokes the grants of an avatar and removes its main file and variants.
+// Files that are already gone are ignored.
+func (gs *GlobalService) DeleteAvatarFiles(ctx context.Context, bucketId string, fileId string) *model.ApiError {
+	if err := gs.Storage.RevokeSignedURLs(ctx, bucketId, fileId); err != nil {
+		return &model.ApiError{Code: 500, Message: "Failed to delete token data: " + err.Error(), Type: "internal_server_error"}
+	}
+	if err := gs.Storage.Delete(ctx, bucketId, fileId); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return &model.ApiError{Code: 500, Message: "Failed to delete profile picture from storage: " + err.Error(), Type: "internal_server_error"}
+	}
+	if apiErr := gs.ForgetStoredObject(ctx, bucketId, fileId); apiErr != nil {
+		return apiErr
+	}
+	return gs.DeleteAvatarVariants(ctx, bucketId, fileId)
+}
+
+// DeleteAvatarVariants removes the smaller variants stored next to an avatar's main file.
+// Missing variants (avatars uploaded before the image pipeline) are ignored.
+func (gs *GlobalService) DeleteAvatarVariants(ctx context.Context, bucketId string, fileId string) *model.ApiError {
+	for _, size := range utils.AvatarVariantSizes {
+		if size == utils.AvatarVariantMaxSize() {
+			continue
+		}
+		variantId := utils.AvatarVariantFileID(fileId, size)
+		err := gs.Storage.Delete(ctx, bucketId, variantId)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return &model.ApiError{Code: 500, Message: "Failed to delete avatar variant: " + err.Error(), Type: "internal_server_error"}
+		}
+		if apiErr := gs.ForgetStoredObject(ctx, bucketId, variantId); apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bytes"
+	"chatbasket/db/postgresCode"
+	"chatbasket/storage"
+	"chatbasket/utils"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// nopDB accepts every statement and affects no rows. The uploads below have no owner, so the
+// only queries they make release storage records that do not exist.
+type nopDB struct{}
+
+func (nopDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+func (nopDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, pgx.ErrNoRows
+}
+
+func (nopDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return noRow{}
+}
+
+type noRow struct{}
+
+func (noRow) Scan(...any) error { return pgx.ErrNoRows }
+
+func newLocalStorageService(t *testing.T) (*GlobalService, *storage.Local) {
+	t.Helper()
+	local, err := storage.NewLocal(t.TempDir(), bytes.Repeat([]byte{0x33}, 32))
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return &GlobalService{Storage: local, Queries: postgresCode.New(nopDB{})}, local
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadFileToLocalStorage(t *testing.T) {
+	gs, local := newLocalStorageService(t)
+	ctx := context.Background()
+	data := testPNG(t, 8, 8)
+
+	res, apiErr := gs.UploadFile(ctx, "bucket", "file1", "pic.png", bytes.NewReader(data), UploadOptions{
+		GenerateTokens: true,
+		MaxBytes:       1 << 20,
+		AllowedTypes:   []string{"image/png"},
+	})
+	if apiErr != nil {
+		t.Fatalf("UploadFile: %+v", apiErr)
+	}
+	if res.FileId != "file1" || res.Name != "pic.png" || len(res.TokenSecrets) != 1 || res.Expire == "" {
+		t.Fatalf("UploadFile = %+v", res)
+	}
+
+	// The token of the result reads the stored file back
+	r, err := local.Get(ctx, "bucket", "file1", res.TokenSecrets[0])
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Body.Close()
+	stored, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(stored, data) || r.ContentType != "image/png" {
+		t.Fatalf("stored %d bytes of %q, want the %d uploaded bytes of image/png", len(stored), r.ContentType, len(data))
+	}
+}
+
+func TestUploadFileRejectedBodiesAreNotStored(t *testing.T) {
+	gs, local := newLocalStorageService(t)
+	ctx := context.Background()
+	data := testPNG(t, 8, 8)
+
+	_, apiErr := gs.UploadFile(ctx, "bucket", "text", "notes.txt", strings.NewReader("plain text"), UploadOptions{
+		AllowedTypes: []string{"image/png"},
+	})
+	if apiErr == nil || apiErr.Code != 415 {
+		t.Fatalf("UploadFile of text = %+v, want 415", apiErr)
+	}
+
+	_, apiErr = gs.UploadFile(ctx, "bucket", "big", "pic.png", bytes.NewReader(data), UploadOptions{
+		MaxBytes: int64(len(data) - 1),
+	})
+	if apiErr == nil || apiErr.Code != 413 {
+		t.Fatalf("UploadFile over MaxBytes = %+v, want 413", apiErr)
+	}
+
+	for _, key := range []string{"text", "big"} {
+		if exists, err := local.Exists(ctx, "bucket", key); err != nil || exists {
+			t.Fatalf("%s: Exists = %v, %v; want false", key, exists, err)
+		}
+	}
+}
+
+func TestUploadAvatarImageStoresEveryVariant(t *testing.T) {
+	gs, local := newLocalStorageService(t)
+	ctx := context.Background()
+
+	res, apiErr := gs.UploadAvatarImage(ctx, "avatars", "user1", bytes.NewReader(testPNG(t, 300, 200)), UploadOptions{
+		DeleteExisting: true,
+		GenerateTokens: true,
+	})
+	if apiErr != nil {
+		t.Fatalf("UploadAvatarImage: %+v", apiErr)
+	}
+	if res.FileId != "user1" || len(res.TokenSecrets) != 1 {
+		t.Fatalf("UploadAvatarImage = %+v", res)
+	}
+
+	for _, size := range utils.AvatarVariantSizes {
+		r, err := local.Get(ctx, "avatars", utils.AvatarVariantFileID("user1", size), "")
+		if err != nil {
+			t.Fatalf("variant %d: Get: %v", size, err)
+		}
+		img, err := jpeg.Decode(r.Body)
+		r.Body.Close()
+		if err != nil {
+			t.Fatalf("variant %d: decode: %v", size, err)
+		}
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Fatalf("variant %d is %v", size, b.Size())
+		}
+	}
+
+	if apiErr := gs.DeleteAvatarFiles(ctx, "avatars", "user1"); apiErr != nil {
+		t.Fatalf("DeleteAvatarFiles: %+v", apiErr)
+	}
+	for _, size := range utils.AvatarVariantSizes {
+		if exists, _ := local.Exists(ctx, "avatars", utils.AvatarVariantFileID("user1", size)); exists {
+			t.Fatalf("variant %d survived DeleteAvatarFiles", size)
+		}
+	}
+}
+
+// quotaDB answers the quota queries of an owner with headroom bytes of free space left and records
+// the statements it runs by sqlc name.
+type quotaDB struct {
+	nopDB
+	headroom int64
+	reserved bool
+	ran      []string
+	args     map[string][]any
+}
+
+func queryName(sql string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(sql, "-- name: "), " ")
+	return name
+}
+
+func (db *quotaDB) record(sql string, args []any) string {
+	name := queryName(sql)
+	db.ran = append(db.ran, name)
+	if db.args == nil {
+		db.args = map[string][]any{}
+	}
+	db.args[name] = args
+	return name
+}
+
+func (db *quotaDB) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	db.record(sql, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *quotaDB) QueryRow(_ context.Context, sql string, args ...interface{}) pgx.Row {
+	switch db.record(sql, args) {
+	case "GetStorageQuotaHeadroom":
+		return valuesRow{StoragePlanFree, StoragePlanLimits[StoragePlanFree] - db.headroom, int64(0)}
+	case "ReserveStorageBytes":
+		if args[0].(int64) > db.headroom {
+			return noRow{}
+		}
+		db.reserved = true
+		return valuesRow{args[0].(int64)}
+	case "RecordStorageObject":
+		return valuesRow{args[3].(int64)}
+	}
+	return noRow{}
+}
+
+type valuesRow []any
+
+func (r valuesRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r[i].(string)
+		case *int64:
+			*d = r[i].(int64)
+		}
+	}
+	return nil
+}
+
+func TestUploadFileReleasesReservationOnFailure(t *testing.T) {
+	gs, _ := newLocalStorageService(t)
+	db := &quotaDB{headroom: 1000}
+	gs.Queries = postgresCode.New(db)
+	ctx := context.Background()
+	owner := uuid.New()
+
+	// An invalid key fails in storage after the bytes were reserved
+	_, apiErr := gs.UploadFile(ctx, "bucket", "../escape", "f.txt", strings.NewReader("hello"), UploadOptions{OwnerID: owner, Kind: StorageKindAttachment})
+	if apiErr == nil || apiErr.Code != 500 {
+		t.Fatalf("UploadFile = %+v, want 500", apiErr)
+	}
+	if !db.reserved {
+		t.Fatalf("no reservation was made: %v", db.ran)
+	}
+	release, ok := db.args["ReleaseStorageBytes"]
+	if !ok || release[0].(int64) != 1000 || release[1].(uuid.UUID) != owner {
+		t.Fatalf("ReleaseStorageBytes args = %v, want the 1000 reserved bytes of %s (ran %v)", release, owner, db.ran)
+	}
+	if _, ok := db.args["RecordStorageObject"]; ok {
+		t.Fatal("a failed upload was recorded")
+	}
+}
+
+func TestUploadFileSettlesReservation(t *testing.T) {
+	gs, _ := newLocalStorageService(t)
+	db := &quotaDB{headroom: 1000}
+	gs.Queries = postgresCode.New(db)
+	owner := uuid.New()
+
+	if _, apiErr := gs.UploadFile(context.Background(), "bucket", "file1", "f.txt", strings.NewReader("hello"), UploadOptions{OwnerID: owner, Kind: StorageKindAttachment}); apiErr != nil {
+		t.Fatalf("UploadFile: %+v", apiErr)
+	}
+	record, ok := db.args["RecordStorageObject"]
+	if !ok {
+		t.Fatalf("the upload was not recorded: %v", db.ran)
+	}
+	// OwnerID, BucketID, FileID, SizeBytes, Kind, ReservedBytes
+	if record[3].(int64) != 5 || record[5].(int64) != 1000 {
+		t.Fatalf("RecordStorageObject args = %v, want size 5 settling 1000 reserved bytes", record)
+	}
+	if _, ok := db.args["ReleaseStorageBytes"]; ok {
+		t.Fatal("a stored upload released its reservation")
+	}
+}
+
+func TestUploadFileOverQuota(t *testing.T) {
+	gs, local := newLocalStorageService(t)
+	gs.Queries = postgresCode.New(&quotaDB{headroom: 3})
+
+	_, apiErr := gs.UploadFile(context.Background(), "bucket", "file1", "f.txt", strings.NewReader("hello"), UploadOptions{OwnerID: uuid.New(), Kind: StorageKindAttachment})
+	if apiErr == nil || apiErr.Message != "quota_exceeded" {
+		t.Fatalf("UploadFile = %+v, want quota_exceeded", apiErr)
+	}
+	if exists, _ := local.Exists(context.Background(), "bucket", "file1"); exists {
+		t.Fatal("an upload over quota was stored")
+	}
+}
@@ -3,10 +3,9 @@ package services
 import (
 	// "chatbasket/appwriteinternal"
 	"chatbasket/model"
+	"chatbasket/otp"
 	"chatbasket/utils"
 	"context"
-	"log"
-	"time"
 
 	"github.com/appwrite/sdk-for-go/id"
 	"github.com/appwrite/sdk-for-go/query"
@@ -45,9 +44,7 @@ func (us *GlobalService) Signup(ctx context.Context, payload *model.SignupPayloa
 			Type:    "conflict",
 		}
 	}
-	pass := "00" + payload.Password
-
-	hashedPassword, err := utils.HashOTP(pass)
+	hashedPassword, err := utils.HashOTP(AccountPassword(payload.Password))
 	if err != nil {
 		return nil, &model.ApiError{
 			Code:    500,
@@ -81,127 +78,9 @@ func (us *GlobalService) Signup(ctx context.Context, payload *model.SignupPayloa
 		}
 	}
 
-	// Step 3: Send OTP (CreateEmailToken)
-	messageId := id.Custom(uuid.NewString())
-	subject := "Otp for email verification"
-	otp, err := utils.GenerateOTP()
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to generate OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	content := "<p>Hello,<br>Please enter this code in the app to verify your email address. This code is valid for 3 minutes.Your One-Time Password (OTP) for verifying your email address is:<br><h1>" + otp + "</h1></p><p>Thank you,<br>ChatBasket</p>"
-
-	targetRes, err := us.Appwrite.Users.ListTargets(userID)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to list targets: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	var target string
-	if targetRes.Total == 0 {
-		target = uuid.New().String()
-		createTargetRes, err := us.Appwrite.Users.CreateTarget(
-			userID,
-			target,
-			"email",
-			payload.Email,
-		)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to create target: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-		target = createTargetRes.Id
-	}
-	if targetRes.Total > 0 {
-		target = targetRes.Targets[0].Id
-	}
-
-	_, err = us.Appwrite.Message.CreateEmail(
-		messageId,
-		subject,
-		content,
-		us.Appwrite.Message.WithCreateEmailUsers([]string{userID}),
-		us.Appwrite.Message.WithCreateEmailCc([]string{target}),
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to send email: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	doc, err := us.Appwrite.Database.ListDocuments(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		us.Appwrite.Database.WithListDocumentsQueries(
-			[]string{
-				query.Equal("userId", userID),
-				query.Limit(1),
-			},
-		),
-	)
-
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	if doc.Total == 1 {
-		_, err = us.Appwrite.Database.DeleteDocument(
-			us.Appwrite.DatabaseID,
-			us.Appwrite.TempOtpCollectionID,
-			userID,
-		)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to delete existing otp: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-	}
-
-	hashedOtp, err := utils.HashOTP(otp)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to hash OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	tempOtpPayload := model.TempOtpPayload{
-		Email:     payload.Email,
-		Otp:       hashedOtp,
-		UserId:    userID,
-		MessageId: messageId,
-	}
-
-	_, err = us.Appwrite.Database.CreateDocument(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		userID,
-		tempOtpPayload,
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to save otp in database: " + err.Error(),
-			Type:    "internal_server_error",
-		}
+	// Step 3: Send OTP
+	if apiErr := us.SendOtpEmail(ctx, userID, payload.Email, otp.PurposeSignup, ""); apiErr != nil {
+		return nil, apiErr
 	}
 
 	// 👤 Step 4: Return success response
@@ -229,42 +108,12 @@ func (us *GlobalService) AccountVerification(ctx context.Context, payload *model
 
 	// Step2: verify otp
 
-	// Retrieve the temporary OTP document from the database
-	doc, err := us.Appwrite.Database.GetDocument(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		userId,
-	)
+	uuidUserId, err := utils.StringToUUID(userId)
 	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to query otp data: " + err.Error(), Type: "internal_server_error"}
+		return nil, &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
 	}
-	var tempOtp model.TempOtp
-	if err := doc.Decode(&tempOtp); err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to parse otp data: " + err.Error(), Type: "internal_server_error"}
-	}
-
-	if tempOtp.Email != payload.Email {
-		return nil, &model.ApiError{Code: 401, Message: "Email does not match with the sent OTP email", Type: "unauthorized"}
-	}
-
-	match, err := utils.VerifyOTP(payload.Secret, tempOtp.Otp)
-	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to verify OTP: " + err.Error(), Type: "internal_server_error"}
-	}
-	if !match {
-		return nil, &model.ApiError{Code: 401, Message: "Invalid OTP", Type: "unauthorized"}
-	}
-
-	// check if tempOtp has expired or not time limit is till 3 minutes after created at
-
-	createdAtTime, err := time.Parse(time.RFC3339, tempOtp.CreatedAt)
-	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to parse OTP creation time: " + err.Error(), Type: "internal_server_error"}
-	}
-
-	expired := utils.IsExpiredOTP(createdAtTime, 3)
-	if expired {
-		return nil, &model.ApiError{Code: 401, Message: "OTP has expired", Type: "unauthorized"}
+	if _, err := us.Otp.Verify(ctx, uuidUserId, otp.PurposeSignup, payload.Email, payload.Secret); err != nil {
+		return nil, otp.ApiError(err)
 	}
 
 	// Step3: Verify account using OTP and create session
@@ -277,20 +126,6 @@ func (us *GlobalService) AccountVerification(ctx context.Context, payload *model
 		return nil, &model.ApiError{Code: 500, Message: "Failed to update email verification status: " + err.Error(), Type: "internal_server_error"}
 	}
 
-	_, err = us.Appwrite.Message.Delete(tempOtp.MessageId)
-	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to delete message: " + err.Error(), Type: "internal_server_error"}
-	}
-
-	_, err = us.Appwrite.Database.DeleteDocument(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		userId,
-	)
-	if err != nil {
-		log.Printf("Failed to delete otp: %v", err.Error())
-	}
-
 	sessionId := session.Id
 	resUserid := userId
 	sessionExpiry := session.Expire
@@ -337,9 +172,15 @@ func (us *GlobalService) Login(ctx context.Context, payload *model.LoginPayload)
 
 	}
 
-	passWord := userRes.Users[0].Password
-	payloadPass := "00" + payload.Password
-	match, err := utils.VerifyOTP(payloadPass, passWord)
+	// Passwordless: email a login link bound to the requesting device instead
+	if payload.Method == model.LoginMethodMagicLink {
+		if apiErr := us.sendMagicLink(ctx, userRes.Users[0].Id, payload.Email, payload.Nonce); apiErr != nil {
+			return nil, apiErr
+		}
+		return &model.StatusOkay{Status: true, Message: "Login link sent to email"}, nil
+	}
+
+	match, err := verifyAccountPassword(&userRes.Users[0], payload.Password)
 	if err != nil {
 		return nil, &model.ApiError{
 			Code:    500,
@@ -357,128 +198,10 @@ func (us *GlobalService) Login(ctx context.Context, payload *model.LoginPayload)
 
 	}
 
-	// Step2: Generate otp to create session
-	messageId := id.Custom(uuid.NewString())
-	subject := "Otp for login verification"
-	otp, err := utils.GenerateOTP()
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to generate OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	content := "<p>Hello,<br>Please enter this code in the app to verify your login. This code is valid for 3 minutes.Your One-Time Password (OTP) for login verification is:<br><h1>" + otp + "</h1></p><p>Thank you,<br>ChatBasket</p>"
+	// Step2: Send otp to create session
 	userId := userRes.Users[0].Id
-
-
-	emailTarget,err := us.Appwrite.Users.ListTargets(userId)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to list targets: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	var emailT string
-
-	if emailTarget.Total==0{
-		targetId := uuid.New().String()
-		createTargetRes, err := us.Appwrite.Users.CreateTarget(
-			userId,
-			targetId,
-			"email",
-			payload.Email,
-		)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to create target: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-		emailT = createTargetRes.Id
-
-	}
-
-	if emailTarget.Total>0{
-		emailT = emailTarget.Targets[0].Id
-	}
-
-	_, err = us.Appwrite.Message.CreateEmail(
-		messageId,
-		subject,
-		content,
-		us.Appwrite.Message.WithCreateEmailUsers([]string{userId}),
-		us.Appwrite.Message.WithCreateEmailCc([]string{emailT}),
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to send email: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-
-	doc, err := us.Appwrite.Database.ListDocuments(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		us.Appwrite.Database.WithListDocumentsQueries(
-			[]string{
-				query.Equal("userId", userId),
-				query.Limit(1),
-			},
-		),
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to query otp data: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-	}
-	if doc.Total == 1 {
-		_, err = us.Appwrite.Database.DeleteDocument(
-			us.Appwrite.DatabaseID,
-			us.Appwrite.TempOtpCollectionID,
-			userId,
-		)
-		if err != nil {
-			return nil, &model.ApiError{
-				Code:    500,
-				Message: "Failed to delete existing otp: " + err.Error(),
-				Type:    "internal_server_error",
-			}
-		}
-	}
-	hashedOtp, err := utils.HashOTP(otp)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to hash OTP: " + err.Error(),
-			Type:    "internal_server_error",
-		}
-
-	}
-	tempOtpPayload := model.TempOtpPayload{
-		Email:     payload.Email,
-		Otp:       hashedOtp,
-		UserId:    userId,
-		MessageId: messageId,
-	}
-
-	_, err = us.Appwrite.Database.CreateDocument(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		userId,
-		tempOtpPayload,
-	)
-	if err != nil {
-		return nil, &model.ApiError{
-			Code:    500,
-			Message: "Failed to save otp in database: " + err.Error(),
-			Type:    "internal_server_error",
-		}
+	if apiErr := us.SendOtpEmail(ctx, userId, payload.Email, otp.PurposeLogin, ""); apiErr != nil {
+		return nil, apiErr
 	}
 
 	return &model.StatusOkay{Status: true, Message: "OTP sent to email"}, nil
@@ -502,46 +225,32 @@ func (us *GlobalService) LoginVerification(ctx context.Context, payload *model.A
 	userName := userRes.Users[0].Name
 	userEmail := userRes.Users[0].Email
 
-	// 🔑 Step 2: Verify OTP
-	// Retrieve the temporary OTP document from the database
-	doc, err := us.Appwrite.Database.GetDocument(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		userId,
-	)
+	// 🔑 Step 2: Verify OTP. The code is used up only after the second factor passed, so the
+	// client can retry with a TOTP or recovery code.
+	uuidUserId, err := utils.StringToUUID(userId)
 	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to query otp data: " + err.Error(), Type: "internal_server_error"}
-	}
-	var tempOtp model.TempOtp
-	if err := doc.Decode(&tempOtp); err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to parse otp data: " + err.Error(), Type: "internal_server_error"}
+		return nil, &model.ApiError{Code: 500, Message: "Invalid user id: " + err.Error(), Type: "internal_server_error"}
 	}
-
-	if tempOtp.Email != payload.Email {
-		return nil, &model.ApiError{Code: 401, Message: "Email does not match with the sent OTP email", Type: "unauthorized"}
-	}
-
-	match, err := utils.VerifyOTP(payload.Secret, tempOtp.Otp)
+	challenge, err := us.Otp.Check(ctx, uuidUserId, otp.PurposeLogin, payload.Email, payload.Secret)
 	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to verify OTP: " + err.Error(), Type: "internal_server_error"}
+		return nil, otp.ApiError(err)
 	}
-	if !match {
-		return nil, &model.ApiError{Code: 401, Message: "Invalid OTP", Type: "unauthorized"}
-	}
-
-	// check if tempOtp has expired or not time limit is till 3 minutes after created at
 
-	createdAtTime, err := time.Parse(time.RFC3339, tempOtp.CreatedAt)
-	if err != nil {
-		return nil, &model.ApiError{Code: 500, Message: "Failed to parse OTP creation time: " + err.Error(), Type: "internal_server_error"}
+	// 🔑 Step 3: Second factor, when TOTP is enabled
+	if _, apiErr := us.CheckSecondFactor(ctx, uuidUserId, payload.TotpCode, payload.RecoveryCode); apiErr != nil {
+		// A wrong second factor counts against the same attempt limit as the email OTP
+		if apiErr.Code == 401 && apiErr.Message != "totp_required" {
+			if err := us.Otp.Fail(ctx, challenge); err != nil {
+				return nil, otp.ApiError(err)
+			}
+		}
+		return nil, apiErr
 	}
-
-	expired := utils.IsExpiredOTP(createdAtTime, 3)
-	if expired {
-		return nil, &model.ApiError{Code: 401, Message: "OTP has expired", Type: "unauthorized"}
+	if err := us.Otp.Consume(ctx, challenge); err != nil {
+		return nil, otp.ApiError(err)
 	}
 
-	// 🔑 Step 3:  create session
+	// 🔑 Step 4:  create session
 	session, err := us.Appwrite.Users.CreateSession(userId)
 	if err != nil {
 		return nil, &model.ApiError{Code: 500, Message: "OTP verification failed: " + err.Error(), Type: "internal_server_error"}
@@ -556,21 +265,6 @@ func (us *GlobalService) LoginVerification(ctx context.Context, payload *model.A
 		}
 	}
 
-	// delete message but even it fails continue dont return nil
-	_, err = us.Appwrite.Message.Delete(tempOtp.MessageId)
-	if err != nil {
-		log.Printf("could not delete message: %v", err.Error())
-	}
-
-	_, err = us.Appwrite.Database.DeleteDocument(
-		us.Appwrite.DatabaseID,
-		us.Appwrite.TempOtpCollectionID,
-		userId,
-	)
-	if err != nil {
-		log.Printf("Failed to delete otp: %v", err.Error())
-	}
-
 	sessionId := session.Id
 	resUserid := userId
 	sessionExpiry := session.Expire
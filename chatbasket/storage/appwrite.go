@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"chatbasket/appwriteinternal"
+	"chatbasket/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Appwrite stores objects in Appwrite Storage buckets and uses Appwrite file tokens as grants.
+type Appwrite struct {
+	Service *appwriteinternal.AppwriteService
+}
+
+func NewAppwrite(as *appwriteinternal.AppwriteService) *Appwrite {
+	return &Appwrite{Service: as}
+}
+
+func (s *Appwrite) Put(ctx context.Context, bucket, key, name string, body io.Reader, size int64) (*Object, error) {
+	// Appwrite rejects ids that are already taken
+	if err := s.Delete(ctx, bucket, key); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	res, err := s.Service.CreateFileStream(ctx, bucket, key, name, body, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Key: res.Id, Name: res.Name, Size: int64(res.SizeOriginal)}, nil
+}
+
+func (s *Appwrite) Get(ctx context.Context, bucket, key, token string) (*Reader, error) {
+	resp, err := s.Service.OpenFileView(ctx, bucket, key, token)
+	if err != nil {
+		var viewErr *appwriteinternal.FileViewError
+		if errors.As(err, &viewErr) {
+			switch viewErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, ErrNotFound
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return nil, ErrInvalidToken
+			}
+		}
+		return nil, err
+	}
+	return &Reader{
+		Body:          resp.Body,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+	}, nil
+}
+
+func (s *Appwrite) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	if _, err := s.Service.Storage.GetFile(bucket, key); err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Appwrite) Delete(ctx context.Context, bucket, key string) error {
+	if _, err := s.Service.Storage.DeleteFile(bucket, key); err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Appwrite) SignedURL(ctx context.Context, bucket, key string, ttl time.Duration) (*SignedURL, error) {
+	exp := time.Now().UTC().Add(ttl).Format("2006-01-02T15:04:05.000Z")
+	tok, err := s.Service.Tokens.CreateFileToken(bucket, key, s.Service.Tokens.WithCreateFileTokenExpire(exp))
+	if err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	expiresAt, err := time.Parse(time.RFC3339, tok.Expire)
+	if err != nil {
+		_, _ = s.Service.Tokens.Delete(tok.Id)
+		return nil, fmt.Errorf("parse token expiry: %w", err)
+	}
+
+	q := url.Values{}
+	q.Set("project", s.Service.ProjectID)
+	q.Set("token", tok.Secret)
+	return &SignedURL{
+		ID:    tok.Id,
+		Token: tok.Secret,
+		URL: fmt.Sprintf("%s/storage/buckets/%s/files/%s/view?%s",
+			strings.TrimRight(s.Service.Endpoint, "/"), url.PathEscape(bucket), url.PathEscape(key), q.Encode()),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *Appwrite) RevokeSignedURL(ctx context.Context, id string) error {
+	if _, err := s.Service.Tokens.Delete(id); err != nil && !utils.IsAppwriteNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Appwrite) RevokeSignedURLs(ctx context.Context, bucket, key string) error {
+	list, err := s.Service.Tokens.List(bucket, key)
+	if err != nil {
+		if utils.IsAppwriteNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, tok := range list.Tokens {
+		if err := s.RevokeSignedURL(ctx, tok.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ Storage = (*Appwrite)(nil)
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local stores objects as files under Root/<bucket>/<key>. It is meant for development and
+// single-node deployments. Grants are stateless HMAC tokens, so they cannot be revoked early
+// and only stop working when they expire.
+type Local struct {
+	Root       string
+	SigningKey []byte
+}
+
+func NewLocal(root string, signingKey []byte) (*Local, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("storage: local backend needs a signing key")
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &Local{Root: root, SigningKey: signingKey}, nil
+}
+
+// path maps bucket and key to a file path, rejecting ids that could escape Root.
+func (s *Local) path(bucket, key string) (string, error) {
+	for _, part := range []string{bucket, key} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", ErrInvalidKey
+		}
+	}
+	return filepath.Join(s.Root, bucket, key), nil
+}
+
+func (s *Local) Put(ctx context.Context, bucket, key, name string, body io.Reader, size int64) (*Object, error) {
+	dst, err := s.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return nil, err
+	}
+
+	// Write next to the destination and rename so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload_*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return nil, err
+	}
+	return &Object{Key: key, Name: name, Size: written}, nil
+}
+
+func (s *Local) Get(ctx context.Context, bucket, key, token string) (*Reader, error) {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		if err := s.verifyToken(token, bucket, key, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Content type is sniffed, the same way it is checked on upload
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Reader{
+		Body:          f,
+		ContentType:   http.DetectContentType(head[:n]),
+		ContentLength: info.Size(),
+	}, nil
+}
+
+func (s *Local) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Local) Delete(ctx context.Context, bucket, key string) error {
+	p, err := s.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Local) SignedURL(ctx context.Context, bucket, key string, ttl time.Duration) (*SignedURL, error) {
+	exists, err := s.Exists(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	payload := localTokenPayload(bucket, key, expiresAt)
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+
+	// The token doubles as its id since nothing is stored server-side
+	return &SignedURL{ID: token, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// RevokeSignedURL is a no-op: local grants are stateless.
+func (s *Local) RevokeSignedURL(ctx context.Context, id string) error {
+	return nil
+}
+
+// RevokeSignedURLs is a no-op: local grants are stateless.
+func (s *Local) RevokeSignedURLs(ctx context.Context, bucket, key string) error {
+	return nil
+}
+
+func localTokenPayload(bucket, key string, expiresAt time.Time) string {
+	return "storage|" + bucket + "|" + key + "|" + strconv.FormatInt(expiresAt.Unix(), 10)
+}
+
+func (s *Local) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.SigningKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (s *Local) verifyToken(token, bucket, key string, now time.Time) error {
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !hmac.Equal(sig, s.sign(string(payload))) {
+		return ErrInvalidToken
+	}
+
+	prefix := "storage|" + bucket + "|" + key + "|"
+	expStr, ok := strings.CutPrefix(string(payload), prefix)
+	if !ok {
+		return ErrInvalidToken
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || !now.Before(time.Unix(exp, 0)) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+var _ Storage = (*Local)(nil)
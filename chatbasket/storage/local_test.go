@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	s, err := NewLocal(t.TempDir(), bytes.Repeat([]byte{0x5A}, 32))
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return s
+}
+
+func put(t *testing.T, s *Local, bucket, key, body string) {
+	t.Helper()
+	if _, err := s.Put(context.Background(), bucket, key, key+".txt", strings.NewReader(body), int64(len(body))); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+}
+
+func read(t *testing.T, r *Reader) string {
+	t.Helper()
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return string(data)
+}
+
+func TestNewLocalNeedsSigningKey(t *testing.T) {
+	if _, err := NewLocal(t.TempDir(), nil); err == nil {
+		t.Fatal("NewLocal without a key succeeded")
+	}
+}
+
+func TestLocalPutGetExistsDelete(t *testing.T) {
+	s := newTestLocal(t)
+	ctx := context.Background()
+
+	obj, err := s.Put(ctx, "bucket", "file1", "notes.txt", strings.NewReader("hello world"), -1)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if obj.Key != "file1" || obj.Name != "notes.txt" || obj.Size != 11 {
+		t.Fatalf("Put = %+v", obj)
+	}
+	if exists, err := s.Exists(ctx, "bucket", "file1"); err != nil || !exists {
+		t.Fatalf("Exists = %v, %v; want true", exists, err)
+	}
+
+	r, err := s.Get(ctx, "bucket", "file1", "")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if r.ContentLength != 11 || !strings.HasPrefix(r.ContentType, "text/plain") {
+		t.Fatalf("Get = length %d, type %q", r.ContentLength, r.ContentType)
+	}
+	if body := read(t, r); body != "hello world" {
+		t.Fatalf("body = %q", body)
+	}
+
+	// Put replaces the object
+	put(t, s, "bucket", "file1", "replaced")
+	r, err = s.Get(ctx, "bucket", "file1", "")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if body := read(t, r); body != "replaced" {
+		t.Fatalf("body after replace = %q", body)
+	}
+
+	if err := s.Delete(ctx, "bucket", "file1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists, err := s.Exists(ctx, "bucket", "file1"); err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v; want false", exists, err)
+	}
+	if _, err := s.Get(ctx, "bucket", "file1", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "bucket", "file1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete = %v, want ErrNotFound", err)
+	}
+}
+
+// A body that fails leaves neither the object nor a temporary file behind.
+func TestLocalPutFailureLeavesNothing(t *testing.T) {
+	s := newTestLocal(t)
+	bodyErr := errors.New("client went away")
+	body := io.MultiReader(strings.NewReader("partial"), &failingReader{bodyErr})
+
+	if _, err := s.Put(context.Background(), "bucket", "file1", "f", body, -1); !errors.Is(err, bodyErr) {
+		t.Fatalf("Put = %v, want the body's error", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.Root, "bucket"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("bucket holds %d entries after a failed Put", len(entries))
+	}
+}
+
+// flipFirst changes the first character of a base64 string, which always changes the decoded bytes.
+func flipFirst(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestLocalPathRejectsTraversal(t *testing.T) {
+	s := newTestLocal(t)
+	for _, tc := range []struct{ bucket, key string }{
+		{"bucket", ""},
+		{"", "file"},
+		{"bucket", "."},
+		{"bucket", ".."},
+		{"..", "file"},
+		{"bucket", "../other/file"},
+		{"bucket", "a/b"},
+		{"bucket", `..\file`},
+		{"../bucket", "file"},
+	} {
+		if _, err := s.path(tc.bucket, tc.key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("path(%q, %q) = %v, want ErrInvalidKey", tc.bucket, tc.key, err)
+		}
+	}
+
+	// Every method goes through path
+	ctx := context.Background()
+	if _, err := s.Put(ctx, "bucket", "../escape", "f", strings.NewReader("x"), 1); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put = %v, want ErrInvalidKey", err)
+	}
+	if _, err := s.Get(ctx, "bucket", "../escape", ""); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Get = %v, want ErrInvalidKey", err)
+	}
+	if _, err := s.Exists(ctx, "..", "file"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Exists = %v, want ErrInvalidKey", err)
+	}
+	if err := s.Delete(ctx, "bucket", ".."); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Delete = %v, want ErrInvalidKey", err)
+	}
+
+	if p, err := s.path("bucket", "file"); err != nil || p != filepath.Join(s.Root, "bucket", "file") {
+		t.Fatalf("path = %q, %v", p, err)
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	s := newTestLocal(t)
+	ctx := context.Background()
+	put(t, s, "bucket", "file1", "secret")
+	put(t, s, "bucket", "file2", "other")
+
+	grant, err := s.SignedURL(ctx, "bucket", "file1", time.Hour)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	if grant.ID != grant.Token || grant.URL != "" {
+		t.Fatalf("SignedURL = %+v", grant)
+	}
+	if wait := time.Until(grant.ExpiresAt); wait <= 59*time.Minute || wait > time.Hour {
+		t.Fatalf("expires in %v, want an hour", wait)
+	}
+
+	r, err := s.Get(ctx, "bucket", "file1", grant.Token)
+	if err != nil {
+		t.Fatalf("Get with a valid token: %v", err)
+	}
+	if body := read(t, r); body != "secret" {
+		t.Fatalf("body = %q", body)
+	}
+
+	expired, err := s.SignedURL(ctx, "bucket", "file1", -time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	payload, sig, _ := strings.Cut(grant.Token, ".")
+	otherKey := *s
+	otherKey.SigningKey = bytes.Repeat([]byte{0x11}, 32)
+	foreign, err := otherKey.SignedURL(ctx, "bucket", "file1", time.Hour)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+
+	for name, tc := range map[string]struct{ key, token string }{
+		"expired":          {"file1", expired.Token},
+		"another object":   {"file2", grant.Token},
+		"tampered payload": {"file1", flipFirst(payload) + "." + sig},
+		"tampered sig":     {"file1", payload + "." + flipFirst(sig)},
+		"no signature":     {"file1", payload},
+		"not base64":       {"file1", "!!!." + sig},
+		"other key":        {"file1", foreign.Token},
+	} {
+		if _, err := s.Get(ctx, "bucket", tc.key, tc.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Get = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	// The token stops working at its expiry
+	if err := s.verifyToken(grant.Token, "bucket", "file1", grant.ExpiresAt); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("verifyToken at expiry = %v, want ErrInvalidToken", err)
+	}
+	if err := s.verifyToken(grant.Token, "bucket", "file1", grant.ExpiresAt.Add(-time.Second)); err != nil {
+		t.Fatalf("verifyToken before expiry = %v", err)
+	}
+
+	if _, err := s.SignedURL(ctx, "bucket", "missing", time.Hour); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SignedURL of a missing object = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// Backends selectable with STORAGE_BACKEND.
+const (
+	BackendAppwrite = "appwrite"
+	BackendLocal    = "local"
+)
+
+var (
+	ErrNotFound     = errors.New("storage: object not found")
+	ErrInvalidKey   = errors.New("storage: invalid bucket or key")
+	ErrInvalidToken = errors.New("storage: invalid or expired token")
+)
+
+// Object describes a stored file.
+type Object struct {
+	Key  string
+	Name string
+	Size int64
+}
+
+// Reader is an opened object. The caller must close Body.
+type Reader struct {
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64
+}
+
+// SignedURL grants time-limited read access to one object without server credentials.
+// Token is passed back to Get; URL is a direct download link, or "" when the backend
+// is only reachable through this API.
+type SignedURL struct {
+	ID        string
+	Token     string
+	URL       string
+	ExpiresAt time.Time
+}
+
+// Storage is the file store used for uploads. Buckets and keys are opaque ids; a key is
+// unique within its bucket.
+type Storage interface {
+	// Put streams body under key, replacing any existing object. name is the original file name
+	// and size the body length, or -1 when unknown. Errors returned by body are passed through.
+	Put(ctx context.Context, bucket, key, name string, body io.Reader, size int64) (*Object, error)
+	// Get opens an object. When token is set it must be a valid token from SignedURL for the
+	// same object; otherwise the read is made with server credentials.
+	Get(ctx context.Context, bucket, key, token string) (*Reader, error)
+	// Exists reports whether an object is stored under key.
+	Exists(ctx context.Context, bucket, key string) (bool, error)
+	// Delete removes an object. It returns ErrNotFound when there is nothing to delete.
+	Delete(ctx context.Context, bucket, key string) error
+	// SignedURL issues a read grant for an object that expires after ttl.
+	SignedURL(ctx context.Context, bucket, key string, ttl time.Duration) (*SignedURL, error)
+	// RevokeSignedURL revokes one grant by its ID.
+	RevokeSignedURL(ctx context.Context, id string) error
+	// RevokeSignedURLs revokes every grant issued for an object.
+	RevokeSignedURLs(ctx context.Context, bucket, key string) error
+}
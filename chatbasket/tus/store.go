@@ -0,0 +1,278 @@
+package tus
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version is the tus protocol version implemented here.
+const Version = "1.0.0"
+
+// Extensions lists the supported tus extensions.
+const Extensions = "creation,termination,expiration"
+
+var (
+	ErrNotFound        = errors.New("tus: upload not found")
+	ErrExpired         = errors.New("tus: upload expired")
+	ErrOffsetMismatch  = errors.New("tus: offset mismatch")
+	ErrExceedsLength   = errors.New("tus: body exceeds upload length")
+	ErrLocked          = errors.New("tus: upload is locked by another request")
+	ErrInvalidMetadata = errors.New("tus: invalid Upload-Metadata")
+)
+
+// Upload is the state of one resumable upload.
+type Upload struct {
+	ID        string            `json:"id"`
+	OwnerID   string            `json:"owner_id"`
+	Length    int64             `json:"length"`
+	Metadata  map[string]string `json:"metadata"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	// Offset is the number of bytes received so far. It is the size of the data file and
+	// is not persisted in the info file.
+	Offset int64 `json:"-"`
+}
+
+// Complete reports whether every byte has been received.
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// Store keeps partial uploads on local disk as <id>.bin with an <id>.info JSON sidecar.
+// Uploads are bound to the instance that received them.
+type Store struct {
+	Dir string
+	// TTL is how long an upload may sit idle; every PATCH extends it.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir, TTL: ttl, locks: map[string]*sync.Mutex{}}, nil
+}
+
+func (s *Store) infoPath(id string) string { return filepath.Join(s.Dir, id+".info") }
+func (s *Store) dataPath(id string) string { return filepath.Join(s.Dir, id+".bin") }
+
+// Create registers a new empty upload.
+func (s *Store) Create(ownerID string, length int64, metadata map[string]string) (*Upload, error) {
+	u := &Upload{
+		ID:        uuid.NewString(),
+		OwnerID:   ownerID,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().UTC().Add(s.TTL).Truncate(time.Second),
+	}
+	f, err := os.OpenFile(s.dataPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err := s.writeInfo(u); err != nil {
+		os.Remove(s.dataPath(u.ID))
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get loads an upload. Expired uploads are reported as ErrExpired until they are swept.
+func (s *Store) Get(id string) (*Upload, error) {
+	u, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(u.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return u, nil
+}
+
+func (s *Store) load(id string) (*Upload, error) {
+	// Ids are generated by Create; anything else could point outside Dir
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+	raw, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var u Upload
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(s.dataPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	u.Offset = info.Size()
+	return &u, nil
+}
+
+func (s *Store) writeInfo(u *Upload) error {
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(u.ID) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(u.ID))
+}
+
+// Lock takes the per-upload lock without blocking; concurrent PATCHes to one upload are
+// rejected with ErrLocked. The returned func releases it.
+func (s *Store) Lock(id string) (func(), error) {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[id] = l
+	}
+	s.mu.Unlock()
+
+	if !l.TryLock() {
+		return nil, ErrLocked
+	}
+	return l.Unlock, nil
+}
+
+// Append writes body at offset, which must equal the current offset. Bytes received before
+// body fails are kept so the client can resume from them: on a body error the returned upload
+// reflects them alongside err. Other failures return a nil upload. The caller must hold the
+// upload's lock.
+func (s *Store) Append(id string, offset int64, body io.Reader) (*Upload, error) {
+	u, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != u.Offset {
+		return nil, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.dataPath(id), os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	n, bodyErr := io.Copy(f, io.LimitReader(body, u.Length-u.Offset))
+	if bodyErr == nil && n == u.Length-u.Offset {
+		// Anything past Upload-Length is a client error; drop the whole request's bytes
+		var extra [1]byte
+		if m, _ := body.Read(extra[:]); m > 0 {
+			err := f.Truncate(offset)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrExceedsLength
+		}
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	u.Offset += n
+
+	u.ExpiresAt = time.Now().UTC().Add(s.TTL).Truncate(time.Second)
+	if err := s.writeInfo(u); err != nil {
+		return nil, err
+	}
+	if bodyErr != nil {
+		return u, bodyErr
+	}
+	return u, nil
+}
+
+// Open returns the received bytes of an upload.
+func (s *Store) Open(id string) (*os.File, error) {
+	return os.Open(s.dataPath(id))
+}
+
+// Remove deletes an upload and its data.
+func (s *Store) Remove(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+	err := os.Remove(s.dataPath(id))
+	if infoErr := os.Remove(s.infoPath(id)); err == nil {
+		err = infoErr
+	}
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Sweep removes uploads that expired before now and returns how many were removed.
+func (s *Store) Sweep(now time.Time) (int, error) {
+	matches, err := filepath.Glob(filepath.Join(s.Dir, "*.info"))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, path := range matches {
+		id := strings.TrimSuffix(filepath.Base(path), ".info")
+		u, err := s.load(id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if u != nil && now.Before(u.ExpiresAt) {
+			continue
+		}
+		unlock, err := s.Lock(id)
+		if err != nil {
+			continue // a PATCH is in flight and will extend the expiry
+		}
+		if err := s.Remove(id); err == nil || errors.Is(err, ErrNotFound) {
+			removed++
+		}
+		unlock()
+	}
+	return removed, nil
+}
+
+// ParseMetadata decodes an Upload-Metadata header: comma-separated "key base64value" pairs,
+// where the value may be omitted.
+func ParseMetadata(header string) (map[string]string, error) {
+	meta := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, ErrInvalidMetadata
+		}
+		if _, dup := meta[key]; dup {
+			return nil, ErrInvalidMetadata
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, ErrInvalidMetadata
+		}
+		meta[key] = string(value)
+	}
+	return meta, nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+//
+// ---------- Access and refresh tokens ----------
+//
+
+const accessTokenIssuer = "chatbasket"
+
+// MinAccessTokenKeyLength is the shortest HS256 signing key accepted, in bytes.
+const MinAccessTokenKeyLength = 32
+
+var (
+	ErrAccessTokenInvalid = errors.New("invalid access token")
+	ErrAccessTokenExpired = errors.New("access token expired")
+)
+
+// AccessTokenClaims are carried by the short-lived access tokens of native clients. The subject
+// is the user id.
+type AccessTokenClaims struct {
+	SessionId string `json:"sid"`
+	Email     string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// CheckAccessTokenKey rejects a signing key too short to be used for access tokens.
+func CheckAccessTokenKey(key []byte) error {
+	if len(key) < MinAccessTokenKeyLength {
+		return fmt.Errorf("access token signing key must be at least %d bytes, got %d", MinAccessTokenKeyLength, len(key))
+	}
+	return nil
+}
+
+// SignAccessToken returns an HS256 JWT for the user's session, valid until expiry.
+func SignAccessToken(userId, sessionId, email string, expiry time.Time, key []byte) (string, error) {
+	now := time.Now()
+	claims := &AccessTokenClaims{
+		SessionId: sessionId,
+		Email:     email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    accessTokenIssuer,
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiry),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// VerifyAccessToken checks a token produced by SignAccessToken and returns its claims.
+func VerifyAccessToken(token string, key []byte, now time.Time) (*AccessTokenClaims, error) {
+	claims := &AccessTokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return key, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(accessTokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrAccessTokenExpired
+		}
+		return nil, ErrAccessTokenInvalid
+	}
+	if claims.Subject == "" || claims.SessionId == "" {
+		return nil, ErrAccessTokenInvalid
+	}
+	return claims, nil
+}
+
+// GenerateRefreshToken returns a random refresh token and the SHA-256 hex stored for it. The
+// token has 130 bits of entropy, so a plain hash is enough to keep it out of the database.
+func GenerateRefreshToken() (token, hash string) {
+	token = rand.Text()
+	return token, HashRefreshToken(token)
+}
+
+// HashRefreshToken returns the SHA-256 hex of a refresh token.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
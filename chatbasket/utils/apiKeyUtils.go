@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+//
+// ---------- API keys ----------
+//
+
+// apiKeyTag starts every API key so leaked keys are easy to recognise (e.g. by secret scanners).
+const apiKeyTag = "cbk_"
+
+// GenerateApiKey returns a new key "cbk_<8 chars>_<secret>" and its public prefix "cbk_<8 chars>".
+func GenerateApiKey() (key, prefix string) {
+	prefix = apiKeyTag + strings.ToLower(rand.Text()[:8])
+	return prefix + "_" + rand.Text(), prefix
+}
+
+// ApiKeyPrefix returns the public prefix of a key, or false when it is not shaped like one.
+func ApiKeyPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyTag) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(key[len(apiKeyTag):], "_")
+	if !ok || len(prefix) != 8 || secret == "" {
+		return "", false
+	}
+	return apiKeyTag + prefix, true
+}
+
+// HashApiKey returns the SHA-256 hex stored for a key. Keys are random, so no slow hash is needed.
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
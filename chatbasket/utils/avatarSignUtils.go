@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// ---------- Signed avatar URLs ----------
+//
+
+// AvatarURLTTL is the window a signed avatar URL stays stable for. Expiry is rounded up to
+// the next window boundary (plus one window) so repeated list calls return identical URLs and
+// clients can cache the image, while a leaked URL stops working within two windows.
+const AvatarURLTTL = 15 * time.Minute
+
+// Avatar scopes select which storage and visibility rules the media proxy applies.
+const (
+	AvatarScopePersonal = "personal"
+	AvatarScopePublic   = "public"
+)
+
+var (
+	ErrAvatarTokenMalformed = errors.New("malformed avatar token")
+	ErrAvatarTokenSignature = errors.New("invalid avatar token signature")
+	ErrAvatarTokenExpired   = errors.New("avatar token expired")
+)
+
+// AvatarURLClaims is the data bound into a signed avatar URL.
+type AvatarURLClaims struct {
+	Scope    string
+	OwnerID  string
+	ViewerID string
+	Size     int // one of AvatarVariantSizes
+	Expiry   time.Time
+}
+
+func (c *AvatarURLClaims) payload() string {
+	return strings.Join([]string{c.Scope, c.OwnerID, c.ViewerID, strconv.Itoa(c.Size), strconv.FormatInt(c.Expiry.Unix(), 10)}, "|")
+}
+
+func signAvatarPayload(payload string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// SignAvatarToken returns base64url(payload) + "." + base64url(HMAC-SHA256(payload)).
+func SignAvatarToken(claims *AvatarURLClaims, key []byte) string {
+	payload := claims.payload()
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(signAvatarPayload(payload, key))
+}
+
+// VerifyAvatarToken checks the signature and expiry of a token produced by SignAvatarToken
+// and that it was issued for ownerID. It returns the embedded claims.
+func VerifyAvatarToken(token, ownerID string, key []byte, now time.Time) (*AvatarURLClaims, error) {
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrAvatarTokenMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, ErrAvatarTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return nil, ErrAvatarTokenMalformed
+	}
+
+	// Constant-time compare
+	if !hmac.Equal(sig, signAvatarPayload(string(payload), key)) {
+		return nil, ErrAvatarTokenSignature
+	}
+
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 5 {
+		return nil, ErrAvatarTokenMalformed
+	}
+	size, err := strconv.Atoi(parts[3])
+	if err != nil || !IsAvatarVariantSize(size) {
+		return nil, ErrAvatarTokenMalformed
+	}
+	exp, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return nil, ErrAvatarTokenMalformed
+	}
+	claims := &AvatarURLClaims{Scope: parts[0], OwnerID: parts[1], ViewerID: parts[2], Size: size, Expiry: time.Unix(exp, 0)}
+
+	if claims.OwnerID != ownerID {
+		return nil, ErrAvatarTokenSignature
+	}
+	if !now.Before(claims.Expiry) {
+		return nil, ErrAvatarTokenExpired
+	}
+	return claims, nil
+}
+
+// BuildSignedAvatarURL returns the media proxy URL for the given size variant of ownerID's
+// avatar as seen by viewerID.
+func BuildSignedAvatarURL(baseURL string, key []byte, scope, ownerID, viewerID string, size int, now time.Time) *string {
+	claims := &AvatarURLClaims{
+		Scope:    scope,
+		OwnerID:  ownerID,
+		ViewerID: viewerID,
+		Size:     size,
+		Expiry:   now.UTC().Truncate(AvatarURLTTL).Add(2 * AvatarURLTTL),
+	}
+	uri := fmt.Sprintf("%s/media/avatars/%s?t=%s",
+		strings.TrimRight(baseURL, "/"), url.PathEscape(ownerID), url.QueryEscape(SignAvatarToken(claims, key)))
+	return &uri
+}
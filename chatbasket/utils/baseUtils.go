@@ -35,22 +35,3 @@ func StringToUUID(id string) (uuid.UUID, error) {
 	}
 	return parsed, nil
 }
-
-// AppwriteFileData represents the data needed to construct an appwrite file URI
-type AppwriteFileData struct {
-	FileId     *string   `json:"fileId"`
-	FileToken  *string   `json:"fileToken"`
-	FileSecret *string   `json:"fileSecret"`
-}
-
-// BuildAvatarURI constructs the avatar URL from AppwriteFileData
-// Returns empty string if data is invalid or insufficient tokens
-func BuildAvatarURI(ad *AppwriteFileData) *string {
-	if ad == nil || ad.FileId == nil || *ad.FileId == "" || ad.FileToken == nil || *ad.FileToken == "" || ad.FileSecret == nil || *ad.FileSecret == "" {
-		return nil
-	}
-
-	uri := fmt.Sprintf("https://fra.cloud.appwrite.io/v1/storage/buckets/68f1170100025d36bf45/files/%s/view?project=6858ed4d0005c859ea03&token=%s",
-		*ad.FileId, *ad.FileSecret)
-	return &uri
-}
\ No newline at end of file
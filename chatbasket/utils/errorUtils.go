@@ -2,7 +2,9 @@ package utils
 
 import (
 	"errors"
+	"net/http"
 
+	"github.com/appwrite/sdk-for-go/client"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/labstack/echo/v4"
 )
@@ -29,3 +31,10 @@ func GetPostgresError(err error) (*PostgresError) {
 	}
 	return &PostgresError{Message: err.Error(), PgError: nil}
 }
+
+// IsAppwriteNotFound reports whether err is an Appwrite 404, e.g. a document or file that is
+// already gone.
+func IsAppwriteNotFound(err error) bool {
+	var awErr *client.AppwriteError
+	return errors.As(err, &awErr) && awErr.GetStatusCode() == http.StatusNotFound
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testFieldKey = bytes.Repeat([]byte{0x42}, 32)
+
+const testOwnerID = "0196f1d2-7c3a-7d4e-8f00-123456789abc"
+
+func TestFieldRoundTrip(t *testing.T) {
+	sealed, err := EncryptField("hello there", testFieldKey, FieldPurposeBio, testOwnerID)
+	if err != nil {
+		t.Fatalf("EncryptField: %v", err)
+	}
+	if !strings.HasPrefix(sealed, fieldCipherPrefix) {
+		t.Fatalf("sealed value %q lacks the %q prefix", sealed, fieldCipherPrefix)
+	}
+	if strings.Contains(sealed, "hello there") {
+		t.Fatalf("sealed value contains the plaintext")
+	}
+
+	opened, err := DecryptField(sealed, testFieldKey, FieldPurposeBio, testOwnerID)
+	if err != nil {
+		t.Fatalf("DecryptField: %v", err)
+	}
+	if opened != "hello there" {
+		t.Fatalf("DecryptField = %q, want %q", opened, "hello there")
+	}
+}
+
+func TestFieldAssociatedDataBinding(t *testing.T) {
+	sealed, err := EncryptField("secret", testFieldKey, FieldPurposeNickname, testOwnerID)
+	if err != nil {
+		t.Fatalf("EncryptField: %v", err)
+	}
+
+	if _, err := DecryptField(sealed, testFieldKey, FieldPurposeBio, testOwnerID); err == nil {
+		t.Error("DecryptField with the wrong purpose succeeded")
+	}
+	if _, err := DecryptField(sealed, testFieldKey, FieldPurposeNickname, "0196f1d2-7c3a-7d4e-8f00-000000000000"); err == nil {
+		t.Error("DecryptField with the wrong owner succeeded")
+	}
+	if _, err := DecryptField(sealed, bytes.Repeat([]byte{0x24}, 32), FieldPurposeNickname, testOwnerID); err == nil {
+		t.Error("DecryptField with the wrong key succeeded")
+	}
+}
+
+func TestFieldLegacyPassthrough(t *testing.T) {
+	opened, err := DecryptField("written before encryption", testFieldKey, FieldPurposeBio, testOwnerID)
+	if err != nil {
+		t.Fatalf("DecryptField: %v", err)
+	}
+	if opened != "written before encryption" {
+		t.Fatalf("DecryptField = %q, want the legacy value unchanged", opened)
+	}
+}
+
+func TestOptionalFieldNil(t *testing.T) {
+	sealed, err := EncryptOptionalField(nil, testFieldKey, FieldPurposeBio, testOwnerID)
+	if err != nil || sealed != nil {
+		t.Fatalf("EncryptOptionalField(nil) = %v, %v; want nil, nil", sealed, err)
+	}
+	opened, err := DecryptOptionalField(nil, testFieldKey, FieldPurposeBio, testOwnerID)
+	if err != nil || opened != nil {
+		t.Fatalf("DecryptOptionalField(nil) = %v, %v; want nil, nil", opened, err)
+	}
+}
+
+func TestUsernameRoundTripSharesSealHelper(t *testing.T) {
+	sealed, err := EncryptUsername("alice", testFieldKey, testOwnerID)
+	if err != nil {
+		t.Fatalf("EncryptUsername: %v", err)
+	}
+	opened, err := DecryptUsername(sealed, testFieldKey, testOwnerID)
+	if err != nil {
+		t.Fatalf("DecryptUsername: %v", err)
+	}
+	if opened != "alice" {
+		t.Fatalf("DecryptUsername = %q, want %q", opened, "alice")
+	}
+	if _, err := DecryptUsername(sealed, testFieldKey, "0196f1d2-7c3a-7d4e-8f00-000000000000"); err == nil {
+		t.Error("DecryptUsername with the wrong user succeeded")
+	}
+}
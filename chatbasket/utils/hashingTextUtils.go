@@ -2,10 +2,12 @@ package utils
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/chacha20poly1305"
@@ -45,11 +47,73 @@ func VerifyUsernameHash(username string, storedHex string, secretKey []byte) (bo
 	return hmac.Equal(storedBytes, computedBytes), nil
 }
 
+// HashSessionID returns the SHA-256 hex of an Appwrite session id, the form in which sessions are
+// referenced in Postgres (e.g. tokens.sha256_hex_session_id).
+func HashSessionID(sessionId string) string {
+	sum := sha256.Sum256([]byte(sessionId))
+	return hex.EncodeToString(sum[:])
+}
+
 //
 // ---------- ChaCha20-Poly1305 ----------
 //
 
-// EncryptUsername encrypts username using ChaCha20-Poly1305 with a UUID string as nonce source. It can be reversed to retrieve the original username.
+// usernameCipherV2Prefix marks usernames sealed with XChaCha20-Poly1305 under a random nonce.
+// Legacy (v1) ciphertexts carry no prefix and used the first 12 bytes of the user's UUID as nonce.
+// The "." separator never appears in standard base64, so the two formats cannot be confused.
+const usernameCipherV2Prefix = "v2."
+
+// sealXChaCha encrypts plaintext using XChaCha20-Poly1305 with a random 24-byte nonce and returns
+// the base64 of nonce||ciphertext. associatedData is authenticated but not stored.
+func sealXChaCha(plaintext string, encryptionKey, associatedData []byte) (string, error) {
+	if len(encryptionKey) != chacha20poly1305.KeySize {
+		return "", fmt.Errorf("encryptionKey must be %d bytes, got %d", chacha20poly1305.KeySize, len(encryptionKey))
+	}
+
+	aead, err := chacha20poly1305.NewX(encryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSizeX, chacha20poly1305.NonceSizeX+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("nonce generation failed: %w", err)
+	}
+
+	ciphertext := aead.Seal(nonce, nonce, []byte(plaintext), associatedData)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// openXChaCha reverses sealXChaCha; associatedData must match the value it was sealed with.
+func openXChaCha(encodedB64 string, encryptionKey, associatedData []byte) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(encodedB64)
+	if err != nil {
+		return "", fmt.Errorf("base64 decode failed: %w", err)
+	}
+
+	if len(encryptionKey) != chacha20poly1305.KeySize {
+		return "", fmt.Errorf("encryptionKey must be %d bytes, got %d", chacha20poly1305.KeySize, len(encryptionKey))
+	}
+
+	aead, err := chacha20poly1305.NewX(encryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := chacha20poly1305.NonceSizeX
+	if len(raw) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short: %d bytes, need at least %d", len(raw), nonceSize)
+	}
+
+	plaintext, err := aead.Open(nil, raw[:nonceSize], raw[nonceSize:], associatedData)
+	if err != nil {
+		return "", fmt.Errorf("decryption failed: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptUsername encrypts username using XChaCha20-Poly1305 with a random 24-byte nonce. It can be reversed to retrieve the original username.
+// The user's UUID is bound as associated data, so a ciphertext cannot be moved to another user's row.
 // It is used for storing the username in encrypted form in the database.
 func EncryptUsername(username string, encryptionKey []byte, userIDStr string) (string, error) {
 	// Parse UUID string from Appwrite
@@ -58,25 +122,36 @@ func EncryptUsername(username string, encryptionKey []byte, userIDStr string) (s
 		return "", fmt.Errorf("invalid UUID string: %w", err)
 	}
 
-	if len(encryptionKey) != chacha20poly1305.KeySize {
-		return "", fmt.Errorf("encryptionKey must be %d bytes, got %d", chacha20poly1305.KeySize, len(encryptionKey))
+	sealed, err := sealXChaCha(username, encryptionKey, u[:])
+	if err != nil {
+		return "", err
 	}
+	return usernameCipherV2Prefix + sealed, nil
+}
 
-	aead, err := chacha20poly1305.New(encryptionKey)
+// DecryptUsername decrypts a username ciphertext produced by EncryptUsername.
+// The nonce is embedded in the ciphertext itself. Legacy v1 ciphertexts (no version prefix)
+// are still accepted so rows written before the XChaCha20 switch keep working until migrated.
+func DecryptUsername(encrypted string, encryptionKey []byte, userIDStr string) (string, error) {
+	if !strings.HasPrefix(encrypted, usernameCipherV2Prefix) {
+		return decryptUsernameV1(encrypted, encryptionKey)
+	}
+
+	u, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("invalid UUID string: %w", err)
 	}
 
-	// Use first 12 bytes of UUID as nonce
-	nonce := u[:chacha20poly1305.NonceSize]
+	return openXChaCha(strings.TrimPrefix(encrypted, usernameCipherV2Prefix), encryptionKey, u[:])
+}
 
-	ciphertext := aead.Seal(nonce, nonce, []byte(username), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+// IsLegacyUsernameCipher reports whether an encrypted username still uses the v1 UUID-derived nonce format.
+func IsLegacyUsernameCipher(encrypted string) bool {
+	return !strings.HasPrefix(encrypted, usernameCipherV2Prefix)
 }
 
-// DecryptUsername decrypts a base64 ciphertext using ChaCha20-Poly1305.
-// The nonce is embedded in the ciphertext itself.
-func DecryptUsername(encryptedB64 string, encryptionKey []byte) (string, error) {
+// decryptUsernameV1 decrypts a legacy base64 ChaCha20-Poly1305 ciphertext whose 12-byte nonce is prepended.
+func decryptUsernameV1(encryptedB64 string, encryptionKey []byte) (string, error) {
 	raw, err := base64.StdEncoding.DecodeString(encryptedB64)
 	if err != nil {
 		return "", fmt.Errorf("base64 decode failed: %w", err)
@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+	"net/http"
+	"strconv"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+//
+// ---------- Avatar image pipeline ----------
+//
+
+// AvatarVariantSizes are the square edge lengths (px) every avatar is stored at, smallest first.
+// The largest variant is stored under the avatar's own file id; smaller ones use AvatarVariantFileID.
+var AvatarVariantSizes = []int{64, 256, 1024}
+
+// Variant sizes used by API responses.
+const (
+	AvatarSizeList    = 64   // contact and request lists
+	AvatarSizeCard    = 256  // another user's profile card
+	AvatarSizeProfile = 1024 // own profile
+)
+
+// AvatarMaxUploadBytes is the largest avatar file accepted before processing.
+const AvatarMaxUploadBytes = 5 << 20
+
+// Source limits: a decoded image takes 4 bytes per pixel, so 24 MP is already ~96 MB.
+const (
+	avatarMaxSourceEdge   = 8000
+	avatarMaxSourcePixels = 24_000_000
+	avatarJPEGQuality     = 85
+)
+
+var (
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+	ErrImageTooLarge        = errors.New("image dimensions too large")
+	ErrInvalidImage         = errors.New("invalid image")
+)
+
+// AvatarVariantMaxSize returns the largest configured avatar size.
+func AvatarVariantMaxSize() int {
+	return AvatarVariantSizes[len(AvatarVariantSizes)-1]
+}
+
+// IsAvatarVariantSize reports whether size is one of AvatarVariantSizes.
+func IsAvatarVariantSize(size int) bool {
+	for _, s := range AvatarVariantSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// AvatarVariantFileID returns the storage file id of an avatar variant. The largest variant keeps
+// fileID itself; smaller ones get a deterministic id that fits Appwrite's 36-char id limit.
+func AvatarVariantFileID(fileID string, size int) string {
+	if size == AvatarVariantMaxSize() {
+		return fileID
+	}
+	suffix := "_" + strconv.Itoa(size)
+	if len(fileID)+len(suffix) <= 36 {
+		return fileID + suffix
+	}
+	sum := sha256.Sum256([]byte(fileID))
+	prefix := "v" + strconv.Itoa(size) + "_"
+	return prefix + hex.EncodeToString(sum[:])[:36-len(prefix)]
+}
+
+// SniffImageType returns the MIME type detected from the file content (not its name or headers).
+// Only JPEG, PNG and WebP are accepted.
+func SniffImageType(data []byte) (string, error) {
+	switch ct := http.DetectContentType(data); ct {
+	case "image/jpeg", "image/png", "image/webp":
+		return ct, nil
+	default:
+		return "", ErrUnsupportedImageType
+	}
+}
+
+// ProcessAvatarImage validates and normalises an uploaded avatar. The image is decoded (which
+// drops EXIF/GPS and any other metadata), rotated per its EXIF orientation, centre-cropped to a
+// square, flattened onto white and re-encoded as JPEG at every size in AvatarVariantSizes.
+func ProcessAvatarImage(data []byte) (map[int][]byte, error) {
+	ct, err := SniffImageType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width > avatarMaxSourceEdge || cfg.Height > avatarMaxSourceEdge || cfg.Width*cfg.Height > avatarMaxSourcePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	orientation := 1
+	if ct == "image/jpeg" {
+		orientation = jpegEXIFOrientation(data)
+	}
+
+	// Centre-crop to a square. Every EXIF orientation maps the centre square onto itself, so the
+	// variants are oriented after scaling, on a few small images instead of the full-size one.
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+
+	variants := make(map[int][]byte, len(AvatarVariantSizes))
+	for _, size := range AvatarVariantSizes {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		// JPEG has no alpha; flatten transparent PNG/WebP onto white
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Over, nil)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, applyEXIFOrientation(dst, orientation), &jpeg.Options{Quality: avatarJPEGQuality}); err != nil {
+			return nil, err
+		}
+		variants[size] = buf.Bytes()
+	}
+	return variants, nil
+}
+
+// jpegEXIFOrientation returns the EXIF orientation tag (1-8) of a JPEG, or 1 when absent.
+func jpegEXIFOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return 1
+		}
+		seg := data[pos+4 : pos+2+segLen]
+		if marker == 0xE1 && len(seg) >= 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		pos += 2 + segLen
+	}
+	return 1
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF-structured EXIF block.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		off := ifd + 2 + i*12
+		if off+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[off:]) == 0x0112 {
+			v := int(order.Uint16(tiff[off+8:]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyEXIFOrientation returns src transformed so it displays upright for the given orientation.
+// Pixels are copied straight between the Pix buffers.
+func applyEXIFOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 CW
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 CCW
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], row[x*4:x*4+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// exifTIFF returns a TIFF block whose IFD0 holds a single orientation entry.
+func exifTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 0x002A)
+	order.PutUint32(tiff[4:], 8) // IFD0 right after the header
+	order.PutUint16(tiff[8:], 1) // one entry
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return tiff
+}
+
+// exifJPEG returns the start of a JPEG with an APP1 Exif segment carrying tiff.
+func exifJPEG(tiff []byte) []byte {
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], uint16(2+len(seg)))
+	data = append(data, seg...)
+	return append(data, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestJpegEXIFOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for orientation := 1; orientation <= 8; orientation++ {
+			got := jpegEXIFOrientation(exifJPEG(exifTIFF(order, uint16(orientation))))
+			if got != orientation {
+				t.Errorf("%v orientation %d: got %d", order, orientation, got)
+			}
+		}
+	}
+
+	valid := exifJPEG(exifTIFF(binary.BigEndian, 6))
+	// A segment length past the end of the data
+	truncatedAPP1 := append([]byte{}, valid[:20]...)
+	// An IFD entry cut off after its tag
+	truncatedIFD := exifJPEG(exifTIFF(binary.LittleEndian, 6)[:12])
+	// Another APP segment before the Exif one
+	withAPP0 := append([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00}, valid[2:]...)
+
+	for name, tc := range map[string]struct {
+		data []byte
+		want int
+	}{
+		"not a jpeg":              {[]byte("\x89PNG\r\n\x1a\n"), 1},
+		"empty":                   {nil, 1},
+		"no exif":                 {[]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, 1},
+		"truncated app1":          {truncatedAPP1, 1},
+		"truncated ifd":           {truncatedIFD, 1},
+		"app0 before exif":        {withAPP0, 6},
+		"out of range value":      {exifJPEG(exifTIFF(binary.BigEndian, 9)), 1},
+		"zero value":              {exifJPEG(exifTIFF(binary.LittleEndian, 0)), 1},
+		"unknown byte order":      {exifJPEG(append([]byte("XX"), exifTIFF(binary.BigEndian, 6)[2:]...)), 1},
+		"ifd offset out of range": {exifJPEG(append(exifTIFF(binary.BigEndian, 6)[:4], 0xFF, 0xFF, 0xFF, 0x00)), 1},
+	} {
+		if got := jpegEXIFOrientation(tc.data); got != tc.want {
+			t.Errorf("%s: got %d, want %d", name, got, tc.want)
+		}
+	}
+}
+
+func TestTiffOrientationTooShort(t *testing.T) {
+	if got := tiffOrientation([]byte("MM\x00\x2A")); got != 1 {
+		t.Fatalf("got %d, want 1", got)
+	}
+}
+
+func TestApplyEXIFOrientation(t *testing.T) {
+	// A 3x2 image with a marked top-left pixel
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	marked := color.RGBA{R: 255, A: 255}
+	src.SetRGBA(0, 0, marked)
+
+	for orientation, want := range map[int]struct {
+		w, h   int
+		marked image.Point
+	}{
+		1: {3, 2, image.Pt(0, 0)},
+		2: {3, 2, image.Pt(2, 0)},
+		3: {3, 2, image.Pt(2, 1)},
+		4: {3, 2, image.Pt(0, 1)},
+		5: {2, 3, image.Pt(0, 0)},
+		6: {2, 3, image.Pt(1, 0)},
+		7: {2, 3, image.Pt(1, 2)},
+		8: {2, 3, image.Pt(0, 2)},
+	} {
+		dst := applyEXIFOrientation(src, orientation)
+		if dst.Bounds().Dx() != want.w || dst.Bounds().Dy() != want.h {
+			t.Errorf("orientation %d: size %v, want %dx%d", orientation, dst.Bounds().Size(), want.w, want.h)
+			continue
+		}
+		if got := dst.RGBAAt(want.marked.X, want.marked.Y); got != marked {
+			t.Errorf("orientation %d: pixel at %v is %v, want the marked one", orientation, want.marked, got)
+		}
+	}
+}
+
+func TestProcessAvatarImageRejectsLargeSources(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, avatarMaxSourceEdge+1, 1))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	if _, err := ProcessAvatarImage(buf.Bytes()); !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("ProcessAvatarImage = %v, want ErrImageTooLarge", err)
+	}
+}
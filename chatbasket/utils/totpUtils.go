@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+//
+// ---------- TOTP (RFC 6238) ----------
+//
+
+// TOTP parameters. They are the defaults every authenticator app understands.
+const (
+	totpPeriod = 30
+	totpDigits = otp.DigitsSix
+	// totpSkew is how many steps before and after the current one are accepted, for clock drift.
+	totpSkew = 1
+)
+
+// GenerateTOTPKey creates a new base32 TOTP secret and its otpauth:// URI for authenticator apps.
+func GenerateTOTPKey(issuer, accountName string) (secret, uri string, err error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Digits:      totpDigits,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return key.Secret(), key.URL(), nil
+}
+
+// ValidateTOTP checks code against secret at now, allowing totpSkew steps of drift. It returns
+// the time step the code belongs to so the caller can refuse to accept the same step twice.
+func ValidateTOTP(code, secret string, now time.Time) (step int64, ok bool, err error) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits.Length() {
+		return 0, false, nil
+	}
+	current := now.Unix() / totpPeriod
+	for delta := int64(-totpSkew); delta <= totpSkew; delta++ {
+		s := current + delta
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(s*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    totpDigits,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		// Constant-time compare
+		if subtle.ConstantTimeCompare([]byte(code), []byte(expected)) == 1 {
+			return s, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+//
+// ---------- Recovery codes ----------
+//
+
+// recoveryCodeAlphabet leaves out characters that are easy to misread (0/o, 1/l/i).
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes returns n random codes formatted as "xxxxx-xxxxx".
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	buf := make([]byte, 10)
+	for range n {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		for i := range buf {
+			buf[i] = recoveryCodeAlphabet[int(buf[i])%len(recoveryCodeAlphabet)]
+		}
+		codes = append(codes, string(buf[:5])+"-"+string(buf[5:]))
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode puts a typed recovery code in the form it was hashed in: lower case,
+// without the dash or spaces.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
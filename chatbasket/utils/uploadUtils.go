@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"chatbasket/model"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrUploadTooLarge is returned by readers from LimitUpload once the limit is exceeded.
+var ErrUploadTooLarge = errors.New("upload exceeds size limit")
+
+// OpenMultipartFile walks a multipart request body and returns the part for field without
+// parsing the form into memory or temp files. Parts before it are discarded, so file fields
+// should be sent last. The part must be read before the handler returns.
+func OpenMultipartFile(r *http.Request, field string) (*multipart.Part, *model.ApiError) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, &model.ApiError{Code: http.StatusBadRequest, Message: "Multipart form is missing", Type: "bad_request"}
+	}
+
+	seen := []string{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, MultipartReadError(err)
+		}
+		if part.FormName() == field && part.FileName() != "" {
+			return part, nil
+		}
+		if part.FileName() != "" {
+			seen = append(seen, part.FormName())
+		}
+		part.Close()
+	}
+
+	message := "File field " + field + " not found in request"
+	if len(seen) > 0 {
+		message += ". Available file fields: " + strings.Join(seen, ", ")
+	}
+	return nil, &model.ApiError{Code: http.StatusBadRequest, Message: message, Type: "bad_request"}
+}
+
+// MultipartReadError maps an error from reading a request body to an ApiError. The route body
+// limit surfaces as echo.ErrStatusRequestEntityTooLarge.
+func MultipartReadError(err error) *model.ApiError {
+	var he *echo.HTTPError
+	if errors.Is(err, ErrUploadTooLarge) || (errors.As(err, &he) && he.Code == http.StatusRequestEntityTooLarge) {
+		return &model.ApiError{Code: http.StatusRequestEntityTooLarge, Message: "file_too_large", Type: "payload_too_large"}
+	}
+	return &model.ApiError{Code: http.StatusBadRequest, Message: "Failed to parse multipart form: " + err.Error(), Type: "bad_request"}
+}
+
+// LimitUpload returns a reader that yields at most max bytes of r and then fails with
+// ErrUploadTooLarge instead of silently truncating.
+func LimitUpload(r io.Reader, max int64) io.Reader {
+	return &uploadLimitReader{r: r, remaining: max}
+}
+
+type uploadLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrUploadTooLarge
+	}
+	// Read one byte past the limit so an exact-size body is not rejected
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrUploadTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,125 @@
+package workers
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/services"
+	"context"
+	"expvar"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// accountDeletionMetrics is published at /admin/debug/vars under "account_deletion_worker".
+var accountDeletionMetrics = expvar.NewMap("account_deletion_worker")
+
+// AccountDeletionWorker runs account deletion jobs step by step. A failed step is retried with
+// exponential backoff, so partial failures never leave the account half deleted for good.
+type AccountDeletionWorker struct {
+	Service *services.GlobalService
+	// Interval between polls for due jobs.
+	Interval time.Duration
+	// Lease is how long a claimed job is hidden from other instances while it runs.
+	Lease time.Duration
+	// MaxBackoff caps the delay between retries of a failing step.
+	MaxBackoff time.Duration
+	// BatchSize is the number of jobs claimed per poll.
+	BatchSize int32
+}
+
+func NewAccountDeletionWorker(gs *services.GlobalService) *AccountDeletionWorker {
+	return &AccountDeletionWorker{
+		Service:    gs,
+		Interval:   30 * time.Second,
+		Lease:      10 * time.Minute,
+		MaxBackoff: 6 * time.Hour,
+		BatchSize:  10,
+	}
+}
+
+// Run polls immediately and then every Interval until ctx is cancelled.
+func (w *AccountDeletionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *AccountDeletionWorker) poll(ctx context.Context) {
+	jobs, err := w.Service.Queries.ClaimAccountDeletionJobs(ctx, postgresCode.ClaimAccountDeletionJobsParams{
+		Lease:     pgtype.Interval{Microseconds: w.Lease.Microseconds(), Valid: true},
+		BatchSize: w.BatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("account deletion worker: claim failed: %v", err)
+		}
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		w.run(ctx, job)
+	}
+}
+
+// run executes the job's remaining steps, recording progress after each one.
+func (w *AccountDeletionWorker) run(ctx context.Context, job postgresCode.AccountDeletionJob) {
+	q := w.Service.Queries
+	i := slices.Index(services.AccountDeletionSteps, job.Step)
+	if i < 0 {
+		log.Printf("account deletion worker: job %s has unknown step %q", job.UserID, job.Step)
+		return
+	}
+
+	for ; i < len(services.AccountDeletionSteps); i++ {
+		step := services.AccountDeletionSteps[i]
+		if err := w.Service.RunAccountDeletionStep(ctx, job.UserID, step); err != nil {
+			accountDeletionMetrics.Add("failures", 1)
+			log.Printf("account deletion worker: job %s step %s failed (attempt %d): %v", job.UserID, step, job.Attempts+1, err)
+			message := err.Error()
+			if err := q.FailAccountDeletionJob(ctx, postgresCode.FailAccountDeletionJobParams{
+				UserID:    job.UserID,
+				LastError: &message,
+				RetryAt:   pgtype.Timestamptz{Valid: true, Time: time.Now().Add(w.backoff(job.Attempts))},
+			}); err != nil {
+				log.Printf("account deletion worker: record failure of job %s failed: %v", job.UserID, err)
+			}
+			return
+		}
+
+		if i+1 < len(services.AccountDeletionSteps) {
+			if err := q.AdvanceAccountDeletionJob(ctx, postgresCode.AdvanceAccountDeletionJobParams{
+				UserID:   job.UserID,
+				NextStep: services.AccountDeletionSteps[i+1],
+			}); err != nil {
+				// The lease expires and the finished step is simply run again
+				log.Printf("account deletion worker: record progress of job %s failed: %v", job.UserID, err)
+				return
+			}
+			job.Attempts = 0
+		}
+	}
+
+	if err := q.CompleteAccountDeletionJob(ctx, job.UserID); err != nil {
+		log.Printf("account deletion worker: complete job %s failed: %v", job.UserID, err)
+		return
+	}
+	accountDeletionMetrics.Add("completed", 1)
+}
+
+// backoff returns the delay before retrying a step that has already failed attempts times.
+func (w *AccountDeletionWorker) backoff(attempts int32) time.Duration {
+	d := time.Minute << min(attempts, 16)
+	return min(d, w.MaxBackoff)
+}
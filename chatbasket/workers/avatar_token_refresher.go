@@ -0,0 +1,140 @@
+package workers
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/services"
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// avatarTokenMetrics is published at /admin/debug/vars under "avatar_token_refresher".
+var avatarTokenMetrics = expvar.NewMap("avatar_token_refresher")
+
+// AvatarTokenRefresher renews storage grants (file tokens) of personal avatars before they expire,
+// so the media proxy never has to mint tokens on the request path.
+type AvatarTokenRefresher struct {
+	Service *services.GlobalService
+	// Interval between sweeps.
+	Interval time.Duration
+	// RenewBefore renews tokens expiring within this window.
+	RenewBefore time.Duration
+	// BatchSize is the number of avatars read per query.
+	BatchSize int32
+}
+
+// NewAvatarTokenRefresher returns a refresher with defaults sized for services.FileTokenTTL.
+func NewAvatarTokenRefresher(gs *services.GlobalService) *AvatarTokenRefresher {
+	return &AvatarTokenRefresher{
+		Service:     gs,
+		Interval:    10 * time.Minute,
+		RenewBefore: 24 * time.Hour,
+		BatchSize:   100,
+	}
+}
+
+// Run sweeps immediately and then every Interval until ctx is cancelled.
+func (r *AvatarTokenRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.sweep(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep walks every avatar whose token expires within RenewBefore using keyset pagination,
+// so rows that keep failing cannot starve the rest of the batch.
+func (r *AvatarTokenRefresher) sweep(ctx context.Context) {
+	avatarTokenMetrics.Add("runs", 1)
+	defer avatarTokenMetrics.Set("last_run_unix", unixVar(time.Now()))
+
+	expiresBefore := pgtype.Timestamptz{Valid: true, Time: time.Now().UTC().Add(r.RenewBefore)}
+	afterExpiry := pgtype.Timestamptz{Valid: true, InfinityModifier: pgtype.NegativeInfinity}
+	afterID := uuid.Nil
+
+	for {
+		rows, err := r.Service.Queries.ListAvatarsWithExpiringTokens(ctx, postgresCode.ListAvatarsWithExpiringTokensParams{
+			ExpiresBefore: expiresBefore,
+			AfterExpiry:   afterExpiry,
+			AfterID:       afterID,
+			BatchSize:     r.BatchSize,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				avatarTokenMetrics.Add("query_failures", 1)
+				log.Printf("avatar token refresher: list failed: %v", err)
+			}
+			return
+		}
+
+		for _, row := range rows {
+			if ctx.Err() != nil {
+				return
+			}
+			afterExpiry = row.TokenExpiry
+			afterID = row.ID
+			r.renew(ctx, row)
+		}
+
+		if int32(len(rows)) < r.BatchSize {
+			return
+		}
+	}
+}
+
+func (r *AvatarTokenRefresher) renew(ctx context.Context, row postgresCode.ListAvatarsWithExpiringTokensRow) {
+	store := r.Service.Storage
+	bucket := r.Service.Appwrite.PersonalProfilePicBucketID
+
+	grant, err := store.SignedURL(ctx, bucket, row.FileID, services.FileTokenTTL)
+	if err != nil {
+		avatarTokenMetrics.Add("failures", 1)
+		log.Printf("avatar token refresher: create token for avatar %s failed: %v", row.ID, err)
+		return
+	}
+
+	updated, err := r.Service.Queries.RotateAvatarToken(ctx, postgresCode.RotateAvatarTokenParams{
+		ID:             row.ID,
+		NewTokenID:     &grant.ID,
+		NewTokenSecret: &grant.Token,
+		NewTokenExpiry: pgtype.Timestamptz{Valid: true, Time: grant.ExpiresAt},
+		OldTokenID:     row.TokenID,
+	})
+	if err != nil {
+		avatarTokenMetrics.Add("failures", 1)
+		log.Printf("avatar token refresher: store token for avatar %s failed: %v", row.ID, err)
+		_ = store.RevokeSignedURL(ctx, grant.ID)
+		return
+	}
+	if updated == 0 {
+		// The avatar was re-uploaded or removed meanwhile and already has a fresh token
+		avatarTokenMetrics.Add("skipped", 1)
+		_ = store.RevokeSignedURL(ctx, grant.ID)
+		return
+	}
+
+	// The old token is no longer referenced; failing to delete it only leaves it to expire
+	if row.TokenID != nil && *row.TokenID != "" {
+		if err := store.RevokeSignedURL(ctx, *row.TokenID); err != nil {
+			log.Printf("avatar token refresher: delete old token for avatar %s failed: %v", row.ID, err)
+		}
+	}
+	avatarTokenMetrics.Add("renewed", 1)
+}
+
+// unixVar adapts a timestamp to an expvar.Var for expvar.Map.Set.
+func unixVar(t time.Time) expvar.Var {
+	v := new(expvar.Int)
+	v.Set(t.Unix())
+	return v
+}
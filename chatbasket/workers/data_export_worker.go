@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"chatbasket/db/postgresCode"
+	"chatbasket/services"
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// dataExportMetrics is published at /admin/debug/vars under "data_export_worker".
+var dataExportMetrics = expvar.NewMap("data_export_worker")
+
+// DataExportWorker builds queued personal data exports, emails their download links and deletes
+// archives once they were downloaded or their link expired.
+type DataExportWorker struct {
+	Service *services.GlobalService
+	// Interval between polls for queued exports and purgeable archives.
+	Interval time.Duration
+	// Lease is how long a claimed export is hidden from other instances while it is built.
+	Lease time.Duration
+	// MaxBackoff caps the delay between retries of a failing export.
+	MaxBackoff time.Duration
+	// BatchSize is the number of exports claimed, and archives purged, per poll.
+	BatchSize int32
+}
+
+func NewDataExportWorker(gs *services.GlobalService) *DataExportWorker {
+	return &DataExportWorker{
+		Service:    gs,
+		Interval:   time.Minute,
+		Lease:      15 * time.Minute,
+		MaxBackoff: time.Hour,
+		BatchSize:  5,
+	}
+}
+
+// Run polls immediately and then every Interval until ctx is cancelled.
+func (w *DataExportWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+		w.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *DataExportWorker) poll(ctx context.Context) {
+	exports, err := w.Service.Queries.ClaimDataExports(ctx, postgresCode.ClaimDataExportsParams{
+		Lease:     pgtype.Interval{Microseconds: w.Lease.Microseconds(), Valid: true},
+		BatchSize: w.BatchSize,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("data export worker: claim failed: %v", err)
+		}
+		return
+	}
+	for _, export := range exports {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := w.run(ctx, &export); err != nil {
+			dataExportMetrics.Add("failures", 1)
+			log.Printf("data export worker: export %s failed (attempt %d): %v", export.ID, export.Attempts+1, err)
+			message := err.Error()
+			if err := w.Service.Queries.FailDataExport(ctx, postgresCode.FailDataExportParams{
+				ID:          export.ID,
+				LastError:   &message,
+				RetryAt:     pgtype.Timestamptz{Valid: true, Time: time.Now().Add(w.backoff(export.Attempts))},
+				MaxAttempts: services.DataExportMaxAttempts,
+			}); err != nil {
+				log.Printf("data export worker: record failure of export %s failed: %v", export.ID, err)
+			}
+			continue
+		}
+		dataExportMetrics.Add("completed", 1)
+	}
+}
+
+// run builds and stores the archive, emails the link and then opens the download window. The
+// email goes out first so that a failure retries both; an earlier link stays valid because it is
+// bound to the export id.
+func (w *DataExportWorker) run(ctx context.Context, export *postgresCode.DataExport) error {
+	gs := w.Service
+	userId := export.UserID.String()
+	user, err := gs.Appwrite.Users.Get(userId)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	obj, err := gs.StoreDataExport(ctx, export, user.Email)
+	if err != nil {
+		return fmt.Errorf("store archive: %w", err)
+	}
+
+	expiresAt := time.Now().Add(services.DataExportLinkTTL)
+	if apiErr := gs.SendDataExportReadyEmail(ctx, userId, user.Email, gs.DataExportLink(export.ID, expiresAt), expiresAt); apiErr != nil {
+		return fmt.Errorf("send email: %s", apiErr.Message)
+	}
+
+	return gs.Queries.CompleteDataExport(ctx, postgresCode.CompleteDataExportParams{
+		ID:        export.ID,
+		FileID:    &obj.Key,
+		SizeBytes: &obj.Size,
+		ExpiresAt: pgtype.Timestamptz{Valid: true, Time: expiresAt},
+	})
+}
+
+// purge deletes archives that were downloaded or whose link expired.
+func (w *DataExportWorker) purge(ctx context.Context) {
+	rows, err := w.Service.Queries.ListPurgeableDataExports(ctx, w.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("data export worker: list purgeable failed: %v", err)
+		}
+		return
+	}
+	for _, row := range rows {
+		if row.FileID == nil {
+			continue
+		}
+		if err := w.Service.PurgeDataExportFile(ctx, row.ID, *row.FileID); err != nil {
+			log.Printf("data export worker: purge export %s failed: %v", row.ID, err)
+			continue
+		}
+		dataExportMetrics.Add("purged", 1)
+	}
+}
+
+// backoff returns the delay before retrying an export that has already failed attempts times.
+func (w *DataExportWorker) backoff(attempts int32) time.Duration {
+	d := time.Minute << min(attempts, 16)
+	return min(d, w.MaxBackoff)
+}
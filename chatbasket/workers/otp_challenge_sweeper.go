@@ -0,0 +1,49 @@
+package workers
+
+import (
+	"chatbasket/otp"
+	"context"
+	"log"
+	"time"
+)
+
+// OtpChallengeSweeper deletes expired and used one-time codes and magic links, and OTP guards that have been
+// idle long enough for their lockout history to be forgotten.
+type OtpChallengeSweeper struct {
+	Otp *otp.Service
+	// Interval between sweeps.
+	Interval time.Duration
+	// Grace keeps expired or used challenges around for a while after the fact.
+	Grace time.Duration
+	// GuardIdle is how long a guard has to be untouched before it is removed.
+	GuardIdle time.Duration
+}
+
+func NewOtpChallengeSweeper(otpService *otp.Service) *OtpChallengeSweeper {
+	return &OtpChallengeSweeper{
+		Otp:       otpService,
+		Interval:  10 * time.Minute,
+		Grace:     time.Hour,
+		GuardIdle: 24 * time.Hour,
+	}
+}
+
+// Run sweeps immediately and then every Interval until ctx is cancelled.
+func (w *OtpChallengeSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		challenges, links, guards, err := w.Otp.Sweep(ctx, w.Grace, w.GuardIdle)
+		if err != nil {
+			log.Printf("otp challenge sweeper: sweep failed: %v", err)
+		} else if challenges > 0 || links > 0 || guards > 0 {
+			log.Printf("otp challenge sweeper: removed %d challenges, %d magic links and %d guards", challenges, links, guards)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"chatbasket/services"
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RefreshTokenSweeper deletes refresh tokens that expired or were revoked.
+type RefreshTokenSweeper struct {
+	Service *services.GlobalService
+	// Interval between sweeps.
+	Interval time.Duration
+	// Grace keeps tokens around for a while after they expired or were revoked.
+	Grace time.Duration
+}
+
+func NewRefreshTokenSweeper(gs *services.GlobalService) *RefreshTokenSweeper {
+	return &RefreshTokenSweeper{Service: gs, Interval: time.Hour, Grace: 24 * time.Hour}
+}
+
+// Run sweeps immediately and then every Interval until ctx is cancelled.
+func (w *RefreshTokenSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.Service.Queries.DeleteStaleRefreshTokens(ctx, pgtype.Interval{Microseconds: w.Grace.Microseconds(), Valid: true})
+		if err != nil {
+			log.Printf("refresh token sweeper: sweep failed: %v", err)
+		} else if n > 0 {
+			log.Printf("refresh token sweeper: removed %d stale refresh tokens", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package workers
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CleanStaleUploadTempFiles removes `appwrite_upload_*` files older than olderThan from dir.
+// Uploads used to be staged there and a crashed process could leave them behind; nothing
+// creates them any more, so this only clears leftovers. It returns the number removed.
+func CleanStaleUploadTempFiles(dir string, olderThan time.Duration) int {
+	matches, err := filepath.Glob(filepath.Join(dir, "appwrite_upload_*"))
+	if err != nil {
+		log.Printf("temp janitor: glob failed: %v", err)
+		return 0
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, path := range matches {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("temp janitor: remove %s failed: %v", path, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,39 @@
+package workers
+
+import (
+	"chatbasket/tus"
+	"context"
+	"log"
+	"time"
+)
+
+// TusUploadSweeper deletes resumable uploads whose Upload-Expires has passed.
+type TusUploadSweeper struct {
+	Store *tus.Store
+	// Interval between sweeps.
+	Interval time.Duration
+}
+
+func NewTusUploadSweeper(store *tus.Store) *TusUploadSweeper {
+	return &TusUploadSweeper{Store: store, Interval: 15 * time.Minute}
+}
+
+// Run sweeps immediately and then every Interval until ctx is cancelled.
+func (w *TusUploadSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.Store.Sweep(time.Now())
+		if err != nil {
+			log.Printf("tus upload sweeper: sweep failed: %v", err)
+		} else if n > 0 {
+			log.Printf("tus upload sweeper: removed %d expired uploads", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"chatbasket/services"
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// WebauthnChallengeSweeper deletes passkey ceremonies that were started but never finished.
+type WebauthnChallengeSweeper struct {
+	Service *services.GlobalService
+	// Interval between sweeps.
+	Interval time.Duration
+	// Grace keeps expired challenges around for a while after they expired.
+	Grace time.Duration
+}
+
+func NewWebauthnChallengeSweeper(gs *services.GlobalService) *WebauthnChallengeSweeper {
+	return &WebauthnChallengeSweeper{Service: gs, Interval: 15 * time.Minute, Grace: time.Hour}
+}
+
+// Run sweeps immediately and then every Interval until ctx is cancelled.
+func (w *WebauthnChallengeSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.Service.Queries.DeleteStaleWebauthnChallenges(ctx, pgtype.Interval{Microseconds: w.Grace.Microseconds(), Valid: true})
+		if err != nil {
+			log.Printf("webauthn challenge sweeper: sweep failed: %v", err)
+		} else if n > 0 {
+			log.Printf("webauthn challenge sweeper: removed %d expired challenges", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}